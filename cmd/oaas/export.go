@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/export"
+)
+
+// runExport renders the causal graph around a substance, a kind or the whole store
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dsn := flags.String("dsn", "", "Postgres DSN, or SQLite file with DB_DRIVER=sqlite (defaults to the server's database)")
+	workspaceRef := flags.String("workspace", entities.DefaultWorkspace, "workspace ID or name")
+	format := flags.String("format", export.FormatDOT, "output format: "+strings.Join(export.Formats, ", "))
+	substanceID := flags.String("substance", "", "export the neighbourhood of this substance ID")
+	kind := flags.String("kind", "", "export the neighbourhood of every substance of this kind (name or ID)")
+	depth := flags.Int("depth", 0, "how many relations away from the substance or kind to reach (default 10)")
+	causeTypes := flags.String("cause-types", "", "comma-separated cause types to include (default all)")
+	output := flags.String("o", "", "write to this file instead of standard output")
+	flags.Parse(args)
+
+	if *substanceID != "" && *kind != "" {
+		return fmt.Errorf("-substance and -kind cannot be combined")
+	}
+
+	db, err := connect(*dsn, *workspaceRef)
+	if err != nil {
+		return err
+	}
+
+	scope := export.Scope{SubstanceID: *substanceID, Kind: *kind, Depth: *depth}
+	for _, causeType := range strings.Split(*causeTypes, ",") {
+		if causeType = strings.TrimSpace(causeType); causeType != "" {
+			scope.CauseTypes = append(scope.CauseTypes, causeType)
+		}
+	}
+
+	graph, err := export.Build(db, scope)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return export.Render(out, graph, *format)
+}
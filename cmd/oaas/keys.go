@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/entities"
+)
+
+// keysUsage describes the keys subcommands
+const keysUsage = `Usage: oaas keys <create|list|revoke> [flags]
+
+  create -name NAME [-admin] [-roles R1,R2] [-expires DURATION]   issue a key and print it once
+  list                                                            list keys without their secrets
+  revoke ID                                                       make a key unusable
+`
+
+// runKeys manages API keys directly in the database, which is how the first
+// administrator key is issued
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, keysUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	dsn := flags.String("dsn", "", "Postgres DSN, or SQLite file with DB_DRIVER=sqlite (defaults to the server's database)")
+	name := flags.String("name", "", "name of the key's holder (create)")
+	admin := flags.Bool("admin", false, "let the key issue and revoke keys and read audit records (create)")
+	roles := flags.String("roles", "", "comma-separated roles giving the key its permissions, e.g. taxonomist (create)")
+	expires := flags.Duration("expires", 0, "how long the key is valid, e.g. 720h (create; default forever)")
+	flags.Parse(args[1:])
+
+	db, err := connect(*dsn, entities.DefaultWorkspace)
+	if err != nil {
+		return err
+	}
+	keys := auth.NewKeys(db)
+
+	switch args[0] {
+	case "create":
+		var expiresAt *time.Time
+		if *expires > 0 {
+			at := time.Now().Add(*expires)
+			expiresAt = &at
+		}
+		key, secret, err := keys.Issue(*name, *admin, auth.ParseRoles(*roles), expiresAt)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Issued key %s (%s); it is shown only once:\n", key.ID, key.Name)
+		fmt.Println(secret)
+	case "list":
+		list, err := keys.List()
+		if err != nil {
+			return err
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "ID\tNAME\tPREFIX\tADMIN\tROLES\tSTATUS\tLAST USED")
+		for _, key := range list {
+			status, lastUsed := "active", "never"
+			switch {
+			case key.RevokedAt != nil:
+				status = "revoked"
+			case key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt):
+				status = "expired"
+			}
+			if key.LastUsedAt != nil {
+				lastUsed = key.LastUsedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%t\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.Admin, key.Roles, status, lastUsed)
+		}
+		return out.Flush()
+	case "revoke":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("revoke takes the ID of one key")
+		}
+		key, err := keys.Revoke(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %s (%s)\n", key.ID, key.Name)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown keys command %q\n\n%s", args[0], keysUsage)
+		os.Exit(2)
+	}
+	return nil
+}
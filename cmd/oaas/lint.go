@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/lint"
+	"gorm.io/gorm"
+)
+
+// errLintFailed makes the command exit non-zero when errors remain after the scan
+var errLintFailed = errors.New("unrepaired errors remain")
+
+// runLint checks the whole store for inconsistencies, optionally repairing them
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	dsn := flags.String("dsn", "", "Postgres DSN, or SQLite file with DB_DRIVER=sqlite (defaults to the server's database)")
+	workspaceRef := flags.String("workspace", entities.DefaultWorkspace, "workspace ID or name")
+	repair := flags.Bool("repair", false, "apply every safe repair in a single transaction")
+	severity := flags.String("severity", "", "report only issues at least this serious: error, warning or info")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	opts := lint.Options{Repair: *repair}
+	if *severity != "" {
+		parsed, err := lint.ParseSeverity(*severity)
+		if err != nil {
+			return err
+		}
+		opts.MinSeverity = parsed
+	}
+
+	db, err := connect(*dsn, *workspaceRef)
+	if err != nil {
+		return err
+	}
+
+	var report *lint.Report
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		report, err = lint.Run(tx, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printLintReport(report)
+	}
+
+	for _, issue := range report.Issues {
+		if issue.Severity == lint.SeverityError && !issue.Repaired {
+			return errLintFailed
+		}
+	}
+	return nil
+}
+
+// printLintReport writes one line per issue followed by a summary
+func printLintReport(report *lint.Report) {
+	for _, issue := range report.Issues {
+		ids := make([]string, 0, len(issue.Entities))
+		for _, entity := range issue.Entities {
+			if entity.ID != "" {
+				ids = append(ids, entity.Type+":"+entity.ID)
+			} else {
+				ids = append(ids, entity.Type+":"+entity.Name)
+			}
+		}
+		fmt.Printf("%-7s %s: %s [%s]\n", issue.Severity, issue.Code, issue.Message, strings.Join(ids, " "))
+		switch {
+		case issue.Repaired:
+			fmt.Printf("        repaired: %s\n", issue.Repair)
+		case issue.Repair != "":
+			fmt.Printf("        repair available: %s\n", issue.Repair)
+		}
+	}
+	fmt.Printf("%d errors, %d warnings, %d info; %d repaired\n",
+		report.Counts[lint.SeverityError], report.Counts[lint.SeverityWarning], report.Counts[lint.SeverityInfo], report.Repaired)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/config"
+	"github.com/apodicticscott/oaas/internal/persistence"
+	"github.com/apodicticscott/oaas/internal/workspace"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+// usage describes the available subcommands
+const usage = `Usage: oaas <command> [flags]
+
+Commands:
+  export    Render the causal graph as DOT, GraphML, Mermaid or Cytoscape JSON
+  lint      Check the store for inconsistencies and optionally repair them
+  keys      Issue, list and revoke API keys
+
+Run "oaas <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "lint":
+		err = runLint(os.Args[2:])
+	case "keys":
+		err = runKeys(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("oaas %s: %v", os.Args[1], err)
+	}
+}
+
+// getEnvOrDefault returns the value of the environment variable or the default value if not set
+func getEnvOrDefault(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// defaultDatabase returns the server's database settings, configured as the
+// server's are by the file OAAS_CONFIG names and DB_* and DATABASE_URL
+// environment variables, loading .env if present
+func defaultDatabase() config.Database {
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(".env"); err != nil {
+			log.Printf("Error loading environment file: %v", err)
+		}
+	}
+
+	cfg, err := config.Load(flag.NewFlagSet("oaas", flag.ContinueOnError), nil, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return cfg.Database
+}
+
+// connect opens the database named by dsn, or the server's if dsn is empty,
+// confined to the workspace with the given ID or name. Changes are audited
+// as made by the local user.
+func connect(dsn, workspaceRef string) (*gorm.DB, error) {
+	database := defaultDatabase()
+	if dsn == "" {
+		dsn = database.ConnectionString()
+	}
+	db, err := persistence.Open(database.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+	selected, err := workspace.NewManager(db).Get(workspaceRef)
+	if err != nil {
+		return nil, err
+	}
+	name := getEnvOrDefault("USER", "unknown")
+	principal := &auth.Principal{ID: "cli:" + name, Name: name, Method: auth.MethodCLI, Admin: true}
+	return workspace.Scope(db.WithContext(auth.WithPrincipal(context.Background(), principal)), selected.ID), nil
+}
@@ -1,33 +1,39 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/apodicticscott/oaas/graph/generated"
 	"github.com/apodicticscott/oaas/graph/resolvers"
 	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/config"
+	"github.com/apodicticscott/oaas/internal/metrics"
 	"github.com/apodicticscott/oaas/internal/persistence"
+	"github.com/apodicticscott/oaas/internal/ratelimit"
+	"github.com/apodicticscott/oaas/internal/telemetry"
+	"github.com/apodicticscott/oaas/internal/version"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
-// getEnvOrDefault returns the value of the environment variable or the default value if not set
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
-
 func main() {
-	log.Println("Starting OaaS server...")
+	slog.Info("Starting OaaS server...")
 
 	// Try to load .env file from different possible locations
 	envPaths := []string{".env", "../.env", "../../.env"}
@@ -35,103 +41,265 @@ func main() {
 
 	for _, path := range envPaths {
 		if _, err := os.Stat(path); err == nil {
-			log.Printf("Loading environment from %s...", path)
+			slog.Info("Loading environment", "path", path)
 			if err := godotenv.Load(path); err != nil {
-				log.Printf("Error loading environment file: %v\n", err)
+				slog.Error("Error loading environment file", "path", path, "error", err)
 			} else {
 				envLoaded = true
-				log.Printf("Successfully loaded environment from %s", path)
+				slog.Info("Successfully loaded environment", "path", path)
 				break
 			}
 		}
 	}
 
 	if !envLoaded {
-		log.Println("No environment file found, using system environment variables")
+		slog.Info("No environment file found, using system environment variables")
 	}
 
-	// Get database connection parameters from environment variables or use defaults
-	// Determine if we're running locally or in Docker
-	isLocal := true
-	_, err := net.LookupHost("db")
-	if err == nil {
-		// If we can resolve 'db', we're probably running in Docker
-		isLocal = false
+	// Settings come from flags, then the environment, then a config file
+	flags := flag.NewFlagSet("server", flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the configuration, secrets redacted, and exit")
+	printVersion := flags.Bool("version", false, "print the build information and exit")
+	cfg, err := config.Load(flags, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
-
-	// Set database connection parameters based on environment
-	var dbHost, dbPort string
-	if isLocal {
-		log.Println("Running in local environment, using localhost:5433")
-		dbHost = "localhost"
-		dbPort = "5433"
-	} else {
-		log.Println("Running in Docker environment, using db:5432")
-		dbHost = "db"
-		dbPort = "5432"
+	build := version.Get()
+	if *printVersion {
+		fmt.Printf("oaas %s (commit %s, %s)\n", build.Version, build.Commit, build.GoVersion)
+		return
+	}
+	if *printConfig {
+		if err := cfg.WriteYAML(os.Stdout); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
 	}
 
-	dbUser := getEnvOrDefault("DB_USER", "postgres")
-	dbPassword := getEnvOrDefault("DB_PASSWORD", "postgres")
-	dbName := getEnvOrDefault("DB_NAME", "ontology")
-
-	// Log the database connection parameters for debugging
-	log.Printf("Database connection parameters: host=%s, port=%s, user=%s, dbname=%s", dbHost, dbPort, dbUser, dbName)
+	// Log structured records, each carrying its request's ID and trace
+	logger, err := telemetry.NewLogger(os.Stderr, cfg.Logging.Format, cfg.Logging.Level)
+	if err != nil {
+		log.Fatalf("failed to set up logging: %v", err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("Configuration loaded", "config", cfg, "version", build)
+	promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oaas_build_info",
+		Help: "Always 1, labelled with the build of the running server.",
+	}, []string{"version", "commit", "go_version"}).WithLabelValues(build.Version, build.Commit, build.GoVersion).Set(1)
 
-	// DSN for Postgres
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		dbHost, dbUser, dbPassword, dbName, dbPort,
-	)
+	// Export spans to stdout or to an OTLP collector
+	stopTracing, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint,
+	})
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
 
-	log.Println("Connecting to PostgreSQL database...")
-	db, err := persistence.NewPostgres(dsn)
+	slog.Info("Connecting to database...", "driver", cfg.Database.Driver)
+	db, err := persistence.Open(cfg.Database.Driver, cfg.Database.ConnectionString())
 	if err != nil {
 		log.Fatalf("failed to connect database: %v", err)
 	}
-	log.Println("Database connected successfully")
+	err = persistence.ConfigurePool(db, persistence.Pool{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime.Duration,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime.Duration,
+	})
+	if err != nil {
+		log.Fatalf("failed to configure connection pool: %v", err)
+	}
+	slog.Info("Database connected successfully")
 
 	// Initialize API handler
 	apiHandler := api.NewHandler(db)
 
+	// Authenticate with API keys and, if key files are given, JWTs
+	if cfg.Auth.Disabled {
+		slog.Warn("Authentication disabled: every request is allowed")
+	} else {
+		var verifier *auth.Verifier
+		if len(cfg.Auth.JWTKeyFiles) > 0 {
+			verifier, err = auth.NewVerifier(cfg.Auth.JWTKeyFiles, cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience)
+			if err != nil {
+				log.Fatalf("failed to load JWT keys: %v", err)
+			}
+		}
+		apiHandler.Auth = auth.NewAuthenticator(db, verifier)
+		apiHandler.Auth.AnonymousReads = cfg.Auth.AnonymousReads
+		apiHandler.Auth.DefaultRoles = auth.ParseRoles(strings.Join(cfg.Auth.DefaultRoles, ","))
+	}
+
+	// Throttle each API key, token or address; a rate of 0 turns a limit off
+	apiHandler.RateLimits = &ratelimit.Policy{
+		Addresses: ratelimit.NewLimiter(ratelimit.Limit{
+			Rate:  cfg.RateLimit.AddressRate,
+			Burst: cfg.RateLimit.AddressBurst,
+		}),
+		Requests: ratelimit.NewLimiter(ratelimit.Limit{
+			Rate:  cfg.RateLimit.Rate,
+			Burst: cfg.RateLimit.Burst,
+		}),
+		Expensive: ratelimit.NewLimiter(ratelimit.Limit{
+			Rate:  cfg.RateLimit.ExpensiveRate,
+			Burst: cfg.RateLimit.ExpensiveBurst,
+		}),
+		Quotas: ratelimit.NewQuotas(db, cfg.RateLimit.DailyQuota),
+	}
+
+	// Where the server can be reached, for the index and the logs
+	scheme := "http"
+	if cfg.Server.TLS.Enabled() {
+		scheme = "https"
+	}
+	host, port, _ := net.SplitHostPort(cfg.Server.Address)
+	if host == "" {
+		host = "localhost"
+	}
+	baseURL := scheme + "://" + net.JoinHostPort(host, port)
+
 	// Initialize GraphQL resolver
-	resolver := &resolvers.Resolver{DB: db}
+	resolver := &resolvers.Resolver{Store: apiHandler.Store, DB: db}
 	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	srv.AroundOperations(resolvers.TraceOperations())
+	srv.AroundOperations(resolvers.InstrumentOperations())
+	srv.AroundFields(resolvers.TraceFields())
+	srv.AroundFields(resolvers.ThrottleFields(apiHandler.RateLimits.Expensive,
+		"substances", "misclassifiedSubstances", "modes", "potentialities",
+		"causalCycles", "causalCentrality", "causalComponents"))
+	srv.AroundOperations(resolvers.RequireAuthentication(apiHandler.Auth))
 
 	// Setup Gin router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), apiHandler.Trace, apiHandler.Instrument)
+	if cors := cfg.Server.CORS; len(cors.AllowedOrigins) > 0 {
+		router.Use(api.CORS(api.CORSPolicy{
+			AllowedOrigins:   cors.AllowedOrigins,
+			AllowedMethods:   cors.AllowedMethods,
+			AllowedHeaders:   cors.AllowedHeaders,
+			AllowCredentials: cors.AllowCredentials,
+			MaxAge:           cors.MaxAge.Duration,
+		}))
+	}
 
-	// Health check
+	// Health check and metrics
 	router.GET("/health", apiHandler.HealthCheck)
+	router.GET("/livez", apiHandler.Livez)
+	router.GET("/readyz", apiHandler.Readyz)
+	router.GET("/version", apiHandler.GetVersion)
+	if cfg.Features.Metrics {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	// Authentication and API keys
+	router.GET("/api/v1/me", apiHandler.ThrottleAddress, apiHandler.Authenticate, apiHandler.Throttle, apiHandler.GetPrincipal)
+	admin := router.Group("/api/v1/admin", apiHandler.ThrottleAddress, apiHandler.Authenticate, apiHandler.Throttle, apiHandler.RequireAdmin)
+	{
+		admin.GET("/keys", apiHandler.GetAPIKeys)
+		admin.POST("/keys", apiHandler.CreateAPIKey)
+		admin.DELETE("/keys/:id", apiHandler.RevokeAPIKey)
+		admin.GET("/roles", apiHandler.GetRoles)
+		admin.POST("/roles", apiHandler.CreateRole)
+		admin.DELETE("/roles/:name", apiHandler.DeleteRole)
+	}
 
-	// REST API routes
-	api := router.Group("/api/v1")
+	// Workspaces
+	workspaces := router.Group("/api/v1/workspaces", apiHandler.ThrottleAddress, apiHandler.Authenticate, apiHandler.Throttle)
 	{
+		workspaces.GET("", apiHandler.GetWorkspaces)
+		workspaces.POST("", apiHandler.CreateWorkspace)
+		workspaces.GET("/:workspace", apiHandler.GetWorkspace)
+		workspaces.DELETE("/:workspace", apiHandler.DeleteWorkspace)
+	}
+
+	// REST API routes, in the workspace named by the X-Workspace header (or the
+	// default one) under /api/v1 and in the one named by the path under
+	// /api/v1/workspaces/:workspace
+	for _, api := range []*gin.RouterGroup{router.Group("/api/v1"), router.Group("/api/v1/workspaces/:workspace")} {
+		api.Use(apiHandler.ThrottleAddress, apiHandler.Authenticate, apiHandler.Throttle, apiHandler.SelectWorkspace)
+		api.POST("/copy", apiHandler.CopyToWorkspace)
+		api.GET("/audit", apiHandler.RequireAdmin, apiHandler.GetAuditRecords)
+
 		// Substances
-		api.GET("/substances", apiHandler.GetSubstances)
+		api.GET("/substances", apiHandler.ThrottleExpensive, apiHandler.GetSubstances)
 		api.GET("/substances/:id", apiHandler.GetSubstance)
 		api.POST("/substances", apiHandler.CreateSubstance)
 		api.PUT("/substances/:id", apiHandler.UpdateSubstance)
 		api.DELETE("/substances/:id", apiHandler.DeleteSubstance)
+		api.GET("/substances/:id/classification", apiHandler.GetSubstanceClassification)
+		api.GET("/substances/:id/parts", apiHandler.GetParts)
+		api.POST("/substances/:id/parts", apiHandler.AddPart)
+		api.GET("/substances/:id/parts/aggregate", apiHandler.AggregateParts)
+		api.DELETE("/substances/:id/parts/:part_id", apiHandler.RemovePart)
+		api.GET("/substances/:id/wholes", apiHandler.GetWholes)
+		api.POST("/substances/:id/merge", apiHandler.MergeSubstance)
+		api.GET("/substances/:id/merges", apiHandler.GetSubstanceMerges)
+		api.POST("/substances/:id/clone", apiHandler.CloneSubstance)
+
+		// Substance templates
+		api.GET("/templates", apiHandler.GetTemplates)
+		api.POST("/templates", apiHandler.CreateTemplate)
+		api.GET("/templates/:id", apiHandler.GetTemplate)
+		api.DELETE("/templates/:id", apiHandler.DeleteTemplate)
 
 		// Kinds
 		api.GET("/kinds", apiHandler.GetKinds)
 		api.POST("/kinds", apiHandler.CreateKind)
+		api.DELETE("/kinds/:id", apiHandler.DeleteKind)
+		api.GET("/kinds/misclassified", apiHandler.ThrottleExpensive, apiHandler.GetMisclassifiedSubstances)
+		api.PUT("/kinds/:id/definition", apiHandler.UpdateKindDefinition)
 
 		// Attributes
 		api.GET("/attributes", apiHandler.GetAttributes)
 		api.POST("/attributes", apiHandler.CreateAttribute)
+		api.PUT("/attributes/:id/cardinality", apiHandler.UpdateAttributeCardinality)
 
 		// Modes
-		api.GET("/modes", apiHandler.GetModes)
+		api.GET("/modes", apiHandler.ThrottleExpensive, apiHandler.GetModes)
 		api.POST("/modes", apiHandler.CreateMode)
+		api.DELETE("/modes/:id", apiHandler.DeleteMode)
+		api.GET("/modes/conflicts", apiHandler.GetModeConflicts)
+		api.GET("/relations", apiHandler.GetRelations)
+		api.POST("/relations", apiHandler.CreateRelation)
 
 		// Causality
 		api.GET("/substances/:id/causes", apiHandler.GetCauses)
+		api.GET("/causes", apiHandler.GetCausalRelations)
 		api.POST("/causes", apiHandler.AddCause)
+		api.GET("/causes/path", apiHandler.GetCausalPath)
+		api.GET("/causes/cycles", apiHandler.ThrottleExpensive, apiHandler.GetCausalCycles)
+		api.GET("/causes/centrality", apiHandler.ThrottleExpensive, apiHandler.GetCausalCentrality)
+		api.GET("/causes/components", apiHandler.ThrottleExpensive, apiHandler.GetCausalComponents)
+		api.GET("/causes/roots", apiHandler.GetRootCauses)
+		api.GET("/causes/terminals", apiHandler.GetTerminalEnds)
+		api.GET("/entities/:id/ancestors", apiHandler.GetAncestors)
+		api.GET("/entities/:id/descendants", apiHandler.GetDescendants)
+
+		// Export
+		api.GET("/export/causal-graph", apiHandler.ThrottleExpensive, apiHandler.ExportCausalGraph)
+
+		// Inference Rules
+		api.GET("/rules", apiHandler.GetRules)
+		api.POST("/rules", apiHandler.CreateRule)
+		api.POST("/rules/run", apiHandler.ThrottleExpensive, apiHandler.RunRules)
+		api.GET("/rules/:id", apiHandler.GetRule)
+		api.DELETE("/rules/:id", apiHandler.DeleteRule)
+
+		// Consistency
+		api.GET("/lint", apiHandler.ThrottleExpensive, apiHandler.GetLintReport)
+		api.POST("/lint/repair", apiHandler.ThrottleExpensive, apiHandler.RepairLintIssues)
+
+		// Dependence
+		api.GET("/dependences", apiHandler.GetDependences)
+		api.POST("/dependences", apiHandler.CreateDependence)
+		api.DELETE("/dependences/:id", apiHandler.DeleteDependence)
+		api.GET("/entities/:id/dependents", apiHandler.GetDependents)
+		api.GET("/entities/:id/dependencies", apiHandler.GetDependencies)
 
 		// Potentialities
-		api.GET("/potentialities", apiHandler.GetPotentialities)
+		api.GET("/potentialities", apiHandler.ThrottleExpensive, apiHandler.GetPotentialities)
 		api.POST("/potentialities", apiHandler.CreatePotentiality)
 		api.POST("/potentialities/:id/actualize", apiHandler.ActualizePotentiality)
 		api.GET("/potentialities/:id/conditions", apiHandler.CheckConditions)
@@ -141,22 +309,65 @@ func main() {
 	}
 
 	// GraphQL routes
-	router.GET("/playground", gin.WrapF(playground.Handler("GraphQL playground", "/query")))
-	router.POST("/query", gin.WrapH(srv))
+	endpoints := gin.H{"rest_api": baseURL + "/api/v1/"}
+	if cfg.Features.GraphQL {
+		router.POST("/query", apiHandler.ThrottleAddress, apiHandler.IdentifyPrincipal, apiHandler.Throttle, apiHandler.SelectWorkspace, gin.WrapH(srv))
+		endpoints["graphql_endpoint"] = baseURL + "/query"
+	}
+	if cfg.Features.Playground {
+		router.GET("/playground", apiHandler.ThrottleAddress, apiHandler.Authenticate, apiHandler.Throttle, gin.WrapF(playground.Handler("GraphQL playground", "/query")))
+		endpoints["graphql_playground"] = baseURL + "/playground"
+	}
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"message": "OaaS API is running",
-			"endpoints": gin.H{
-				"rest_api":           "http://localhost:8080/api/v1/",
-				"graphql_playground": "http://localhost:8080/playground",
-				"graphql_endpoint":   "http://localhost:8080/query",
-			},
+			"message":   "OaaS API is running",
+			"version":   build.Version,
+			"endpoints": endpoints,
 		})
 	})
 
-	log.Println("🚀 Server running on http://localhost:8080/")
-	log.Println("REST API available at http://localhost:8080/api/v1/")
-	log.Println("GraphQL playground available at http://localhost:8080/playground")
-	log.Println("GraphQL endpoint available at http://localhost:8080/query")
-	log.Fatal(router.Run(":8080"))
+	server := &http.Server{
+		Addr:              cfg.Server.Address,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:       cfg.Server.IdleTimeout.Duration,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.Server.TLS.Enabled() {
+			serveErr <- server.ListenAndServeTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+	slog.Info("🚀 Server running on "+baseURL+"/", "endpoints", endpoints, "version", build)
+
+	// Serve until SIGINT or SIGTERM, then drain: fail readiness, give load
+	// balancers the shutdown delay to notice, and let requests in flight finish
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErr:
+		log.Fatalf("server failed: %v", err)
+	case <-signals.Done():
+	}
+	stop()
+	slog.Info("Shutting down", "delay", cfg.Server.ShutdownDelay.Duration, "timeout", cfg.Server.ShutdownTimeout.Duration)
+	apiHandler.Drain()
+	time.Sleep(cfg.Server.ShutdownDelay.Duration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Requests were cut off by the shutdown timeout", "error", err)
+	}
+	if err := stopTracing(ctx); err != nil {
+		slog.Error("Failed to flush spans", "error", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,15 @@
+-- Migration 003: Qualify Causal Relations
+-- Analysts record how strong or certain a causal link is, the period it held,
+-- a free-text justification and a citation supporting it.
+
+ALTER TABLE causal_relations ADD COLUMN strength DOUBLE PRECISION NOT NULL DEFAULT 1
+    CHECK (strength > 0 AND strength <= 1);
+ALTER TABLE causal_relations ADD COLUMN evidence TEXT;
+ALTER TABLE causal_relations ADD COLUMN notes TEXT;
+ALTER TABLE causal_relations ADD COLUMN valid_from TIMESTAMP;
+ALTER TABLE causal_relations ADD COLUMN valid_until TIMESTAMP;
+
+ALTER TABLE causal_relations ADD CONSTRAINT chk_causal_relations_validity
+    CHECK (valid_from IS NULL OR valid_until IS NULL OR valid_from <= valid_until);
+
+CREATE INDEX idx_causal_relations_strength ON causal_relations(strength);
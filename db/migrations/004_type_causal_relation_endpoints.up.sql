@@ -0,0 +1,36 @@
+-- Migration 004: Typed Causal Relation Endpoints
+-- Causal relations may point at any entity (see migration 002), so each side
+-- now records which kind of entity it refers to. References matching no
+-- stored entity are kept as 'external' concepts.
+
+ALTER TABLE causal_relations ADD COLUMN from_type TEXT NOT NULL DEFAULT 'external';
+ALTER TABLE causal_relations ADD COLUMN to_type TEXT NOT NULL DEFAULT 'external';
+
+-- Backfill existing relations from whichever table each ID is found in
+UPDATE causal_relations cr SET from_type = CASE
+    WHEN EXISTS (SELECT 1 FROM substances WHERE id = cr.from_entity) THEN 'substance'
+    WHEN EXISTS (SELECT 1 FROM kinds WHERE id = cr.from_entity) THEN 'kind'
+    WHEN EXISTS (SELECT 1 FROM attributes WHERE id = cr.from_entity) THEN 'attribute'
+    WHEN EXISTS (SELECT 1 FROM modes WHERE id = cr.from_entity) THEN 'mode'
+    WHEN EXISTS (SELECT 1 FROM potentialities WHERE id = cr.from_entity) THEN 'potentiality'
+    WHEN EXISTS (SELECT 1 FROM actualities WHERE id = cr.from_entity) THEN 'actuality'
+    ELSE 'external'
+END;
+
+UPDATE causal_relations cr SET to_type = CASE
+    WHEN EXISTS (SELECT 1 FROM substances WHERE id = cr.to_entity) THEN 'substance'
+    WHEN EXISTS (SELECT 1 FROM kinds WHERE id = cr.to_entity) THEN 'kind'
+    WHEN EXISTS (SELECT 1 FROM attributes WHERE id = cr.to_entity) THEN 'attribute'
+    WHEN EXISTS (SELECT 1 FROM modes WHERE id = cr.to_entity) THEN 'mode'
+    WHEN EXISTS (SELECT 1 FROM potentialities WHERE id = cr.to_entity) THEN 'potentiality'
+    WHEN EXISTS (SELECT 1 FROM actualities WHERE id = cr.to_entity) THEN 'actuality'
+    ELSE 'external'
+END;
+
+ALTER TABLE causal_relations ADD CONSTRAINT chk_causal_relations_from_type
+    CHECK (from_type IN ('substance', 'kind', 'attribute', 'mode', 'potentiality', 'actuality', 'external'));
+ALTER TABLE causal_relations ADD CONSTRAINT chk_causal_relations_to_type
+    CHECK (to_type IN ('substance', 'kind', 'attribute', 'mode', 'potentiality', 'actuality', 'external'));
+
+CREATE INDEX idx_causal_relations_from_type ON causal_relations(from_type);
+CREATE INDEX idx_causal_relations_to_type ON causal_relations(to_type);
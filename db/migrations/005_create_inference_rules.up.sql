@@ -0,0 +1,24 @@
+-- Migration 005: Inference Rules
+-- Rules derive modes from conditions on a substance (its kind and its modes).
+-- Derived modes live alongside asserted ones in the modes table, marked as
+-- inferred and linked to the rule that produced them so they can be retracted
+-- when the rule's conditions stop holding.
+
+CREATE TABLE rules (
+    id TEXT PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    description TEXT,
+    conditions TEXT NOT NULL, -- JSON string of conditions, all of which must hold
+    attribute_id TEXT NOT NULL,
+    value TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (attribute_id) REFERENCES attributes(id) ON DELETE CASCADE
+);
+
+ALTER TABLE modes ADD COLUMN inferred BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE modes ADD COLUMN rule_id TEXT REFERENCES rules(id) ON DELETE CASCADE;
+
+ALTER TABLE modes ADD CONSTRAINT chk_modes_inferred_rule
+    CHECK (inferred = (rule_id IS NOT NULL));
+
+CREATE INDEX idx_modes_rule_id ON modes(rule_id);
@@ -0,0 +1,6 @@
+-- Migration 006: Kind Definitions
+-- A kind may be defined by necessary-and-sufficient conditions on a substance's
+-- modes, stored in the same JSON condition format as potentialities and rules.
+-- Substances can then be classified into the kinds whose definitions they meet.
+
+ALTER TABLE kinds ADD COLUMN definition TEXT;
@@ -0,0 +1,7 @@
+-- Migration 007: Attribute Cardinality
+-- A single-valued attribute admits one value per substance at a time: asserting
+-- a second, different value contradicts the first and is rejected. Existing
+-- attributes stay multi-valued, which is how they have always behaved.
+
+ALTER TABLE attributes ADD COLUMN cardinality VARCHAR(16) NOT NULL DEFAULT 'multiple'
+    CHECK (cardinality IN ('single', 'multiple'));
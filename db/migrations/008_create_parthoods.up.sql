@@ -0,0 +1,17 @@
+-- Migration 008: Parthood
+-- Part-whole relations between substances (a leaf is part of a tree). Only
+-- direct parthood is stored; transitive parts and wholes are derived. The
+-- application keeps the relation acyclic, so no substance is part of itself.
+
+CREATE TABLE parthoods (
+    id TEXT PRIMARY KEY,
+    part_id TEXT NOT NULL,
+    whole_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (part_id) REFERENCES substances(id) ON DELETE CASCADE,
+    FOREIGN KEY (whole_id) REFERENCES substances(id) ON DELETE CASCADE,
+    CHECK (part_id <> whole_id)
+);
+
+CREATE UNIQUE INDEX idx_parthoods_part_whole ON parthoods(part_id, whole_id);
+CREATE INDEX idx_parthoods_whole_id ON parthoods(whole_id);
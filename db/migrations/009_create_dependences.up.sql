@@ -0,0 +1,23 @@
+-- Migration 009: Ontological Dependence
+-- Substances, modes and kinds may depend on one another for their existence or
+-- their identity (Lowe). Rigid dependence is on a particular entity; generic
+-- dependence is on there being some instance of a kind. Deleting an entity that
+-- others rigidly depend on is blocked unless the deletion cascades to them.
+
+CREATE TABLE dependences (
+    id TEXT PRIMARY KEY,
+    dependent_id TEXT NOT NULL,
+    dependent_type VARCHAR(16) NOT NULL CHECK (dependent_type IN ('substance', 'mode', 'kind')),
+    depends_on_id TEXT NOT NULL,
+    depends_on_type VARCHAR(16) NOT NULL CHECK (depends_on_type IN ('substance', 'mode', 'kind')),
+    type VARCHAR(16) NOT NULL CHECK (type IN ('existential', 'identity')),
+    modality VARCHAR(16) NOT NULL DEFAULT 'rigid' CHECK (modality IN ('rigid', 'generic')),
+    notes TEXT,
+    created_at TIMESTAMP NOT NULL,
+    CHECK (dependent_id <> depends_on_id),
+    CHECK (modality = 'rigid' OR (depends_on_type = 'kind' AND type = 'existential'))
+);
+
+CREATE UNIQUE INDEX idx_dependences_unique ON dependences(dependent_id, depends_on_id, type, modality);
+CREATE INDEX idx_dependences_dependent_id ON dependences(dependent_id);
+CREATE INDEX idx_dependences_depends_on_id ON dependences(depends_on_id);
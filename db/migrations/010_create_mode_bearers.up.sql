@@ -0,0 +1,20 @@
+-- Migration 010: Relational Modes
+-- A relational attribute ("taller than", "shades") lists the roles its bearers
+-- play, in order. Each of its modes is borne by one substance per role; the
+-- mode's substance_id is its first bearer. Monadic attributes have no roles.
+
+ALTER TABLE attributes ADD COLUMN roles TEXT;
+
+CREATE TABLE mode_bearers (
+    id TEXT PRIMARY KEY,
+    mode_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    substance_id TEXT NOT NULL,
+    FOREIGN KEY (mode_id) REFERENCES modes(id) ON DELETE CASCADE,
+    FOREIGN KEY (substance_id) REFERENCES substances(id) ON DELETE CASCADE,
+    CHECK (position >= 0)
+);
+
+CREATE UNIQUE INDEX idx_mode_bearers_mode_position ON mode_bearers(mode_id, position);
+CREATE INDEX idx_mode_bearers_substance_id ON mode_bearers(substance_id);
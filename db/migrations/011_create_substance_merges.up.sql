@@ -0,0 +1,29 @@
+-- Migration 011: Substance Merges
+-- Merging a duplicate substance into a survivor moves everything that
+-- referenced the duplicate and deletes it. The merge is recorded, and the
+-- duplicate's ID is left redirecting to the survivor so it still resolves.
+-- Redirects are kept flat: merging a survivor later repoints its redirects.
+
+CREATE TABLE substance_merges (
+    id TEXT PRIMARY KEY,
+    survivor_id TEXT NOT NULL,
+    duplicate_id TEXT NOT NULL,
+    duplicate_name TEXT,
+    strategy VARCHAR(16) NOT NULL CHECK (strategy IN ('survivor', 'duplicate', 'newest', 'reject')),
+    summary TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_substance_merges_survivor_id ON substance_merges(survivor_id);
+CREATE INDEX idx_substance_merges_duplicate_id ON substance_merges(duplicate_id);
+
+CREATE TABLE substance_redirects (
+    from_id TEXT PRIMARY KEY,
+    to_id TEXT NOT NULL,
+    merge_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (to_id) REFERENCES substances(id) ON DELETE CASCADE,
+    FOREIGN KEY (merge_id) REFERENCES substance_merges(id)
+);
+
+CREATE INDEX idx_substance_redirects_to_id ON substance_redirects(to_id);
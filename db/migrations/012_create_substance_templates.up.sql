@@ -0,0 +1,36 @@
+-- Migration 012: Substance Templates
+-- A template names a sort of substance with the modes and potentialities each
+-- one starts with, so a substance can be created with all of them in one call.
+-- Template modes reference attributes; deleting an attribute deletes the
+-- template modes of it.
+
+CREATE TABLE substance_templates (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    kind TEXT NOT NULL,
+    essence TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE template_modes (
+    id TEXT PRIMARY KEY,
+    template_id TEXT NOT NULL,
+    attribute_id TEXT NOT NULL,
+    value TEXT,
+    FOREIGN KEY (template_id) REFERENCES substance_templates(id) ON DELETE CASCADE,
+    FOREIGN KEY (attribute_id) REFERENCES attributes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_template_modes_template_id ON template_modes(template_id);
+
+CREATE TABLE template_potentialities (
+    id TEXT PRIMARY KEY,
+    template_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    conditions TEXT,
+    FOREIGN KEY (template_id) REFERENCES substance_templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_template_potentialities_template_id ON template_potentialities(template_id);
@@ -0,0 +1,75 @@
+-- Migration 013: Workspaces
+-- A workspace is an isolated namespace: every entity belongs to exactly one,
+-- and requests only see the entities of the workspace they select. Existing
+-- entities go to the default workspace. Kind, attribute, rule and template
+-- names are unique within a workspace rather than across all of them.
+
+CREATE TABLE workspaces (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO workspaces (id, name, description, created_at)
+VALUES ('default', 'default', 'The workspace of requests that select none', CURRENT_TIMESTAMP);
+
+ALTER TABLE kinds ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_kinds_workspace_id ON kinds(workspace_id);
+
+ALTER TABLE attributes ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_attributes_workspace_id ON attributes(workspace_id);
+
+ALTER TABLE substances ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_substances_workspace_id ON substances(workspace_id);
+
+ALTER TABLE modes ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_modes_workspace_id ON modes(workspace_id);
+
+ALTER TABLE potentialities ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_potentialities_workspace_id ON potentialities(workspace_id);
+
+ALTER TABLE actualities ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_actualities_workspace_id ON actualities(workspace_id);
+
+ALTER TABLE causal_relations ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_causal_relations_workspace_id ON causal_relations(workspace_id);
+
+ALTER TABLE rules ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_rules_workspace_id ON rules(workspace_id);
+
+ALTER TABLE parthoods ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_parthoods_workspace_id ON parthoods(workspace_id);
+
+ALTER TABLE dependences ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_dependences_workspace_id ON dependences(workspace_id);
+
+ALTER TABLE mode_bearers ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_mode_bearers_workspace_id ON mode_bearers(workspace_id);
+
+ALTER TABLE substance_merges ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_substance_merges_workspace_id ON substance_merges(workspace_id);
+
+ALTER TABLE substance_redirects ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_substance_redirects_workspace_id ON substance_redirects(workspace_id);
+
+ALTER TABLE substance_templates ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_substance_templates_workspace_id ON substance_templates(workspace_id);
+
+ALTER TABLE template_modes ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_template_modes_workspace_id ON template_modes(workspace_id);
+
+ALTER TABLE template_potentialities ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_template_potentialities_workspace_id ON template_potentialities(workspace_id);
+
+ALTER TABLE kinds DROP CONSTRAINT kinds_name_key;
+CREATE UNIQUE INDEX idx_kinds_workspace_name ON kinds(workspace_id, name);
+
+ALTER TABLE attributes DROP CONSTRAINT attributes_name_key;
+CREATE UNIQUE INDEX idx_attributes_workspace_name ON attributes(workspace_id, name);
+
+ALTER TABLE rules DROP CONSTRAINT rules_name_key;
+CREATE UNIQUE INDEX idx_rules_workspace_name ON rules(workspace_id, name);
+
+ALTER TABLE substance_templates DROP CONSTRAINT substance_templates_name_key;
+CREATE UNIQUE INDEX idx_substance_templates_workspace_name ON substance_templates(workspace_id, name);
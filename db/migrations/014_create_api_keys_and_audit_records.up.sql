@@ -0,0 +1,35 @@
+-- Migration 014: API Keys and Audit Records
+-- API keys authenticate clients; only a SHA-256 hash of each key's secret is
+-- stored, found by the key's public prefix. Keys are shared by all workspaces.
+-- Audit records tell which principal created, updated or deleted an entity.
+
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    hash TEXT NOT NULL,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by TEXT,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE audit_records (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    principal_id TEXT NOT NULL,
+    principal_name TEXT,
+    method TEXT,
+    action TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT,
+    rows BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_records_workspace_id ON audit_records(workspace_id);
+CREATE INDEX idx_audit_records_principal_id ON audit_records(principal_id);
+CREATE INDEX idx_audit_records_entity_id ON audit_records(entity_id);
+CREATE INDEX idx_audit_records_created_at ON audit_records(created_at);
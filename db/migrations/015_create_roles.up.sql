@@ -0,0 +1,14 @@
+-- Migration 015: Roles
+-- Roles name sets of permissions, each an action on a resource, optionally
+-- only for substances of some kinds. Built-in roles live in code; these are
+-- the custom ones, shared by all workspaces. API keys list their roles.
+
+CREATE TABLE roles (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    permissions TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE api_keys ADD COLUMN roles TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Migration 016: Quota Usages
+-- Requests each client made per UTC day, counted against its daily quota. A
+-- client is an API key (key:<id>), a JWT subject (jwt:<sub>) or, without
+-- credentials, an address (ip:<address>). Rate limits are kept in memory.
+
+CREATE TABLE quota_usages (
+    client TEXT NOT NULL,
+    day TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP,
+    PRIMARY KEY (client, day)
+);
@@ -0,0 +1,64 @@
+-- Migration 017: Cascading ORM Foreign Keys
+-- The service's AutoMigrate adds foreign keys of its own, named fk_*, next to
+-- the ones these migrations create. It used to add them without an ON DELETE
+-- action, and it never alters a key that exists, so databases it has already
+-- migrated keep those. Recreate them as it now defines them, cascading like
+-- the migration keys beside them, so every database deletes dependent rows
+-- the same way. It also used to try tying substances.kind, a kind name, to
+-- kinds.id; that key is dropped if it was ever created.
+
+ALTER TABLE substances DROP CONSTRAINT IF EXISTS fk_kinds_substances;
+
+ALTER TABLE substance_attributes DROP CONSTRAINT IF EXISTS fk_substances_attributes;
+ALTER TABLE substance_attributes ADD CONSTRAINT fk_substances_attributes
+    FOREIGN KEY (substance_id) REFERENCES substances(id) ON DELETE CASCADE;
+ALTER TABLE substance_attributes DROP CONSTRAINT IF EXISTS fk_attributes_substances;
+ALTER TABLE substance_attributes ADD CONSTRAINT fk_attributes_substances
+    FOREIGN KEY (attribute_id) REFERENCES attributes(id) ON DELETE CASCADE;
+
+ALTER TABLE modes DROP CONSTRAINT IF EXISTS fk_substances_modes;
+ALTER TABLE modes ADD CONSTRAINT fk_substances_modes
+    FOREIGN KEY (substance_id) REFERENCES substances(id) ON DELETE CASCADE;
+ALTER TABLE modes DROP CONSTRAINT IF EXISTS fk_attributes_modes;
+ALTER TABLE modes ADD CONSTRAINT fk_attributes_modes
+    FOREIGN KEY (attribute_id) REFERENCES attributes(id) ON DELETE CASCADE;
+
+ALTER TABLE potentialities DROP CONSTRAINT IF EXISTS fk_substances_potentialities;
+ALTER TABLE potentialities ADD CONSTRAINT fk_substances_potentialities
+    FOREIGN KEY (substance_id) REFERENCES substances(id) ON DELETE CASCADE;
+
+ALTER TABLE actualities DROP CONSTRAINT IF EXISTS fk_substances_actualities;
+ALTER TABLE actualities ADD CONSTRAINT fk_substances_actualities
+    FOREIGN KEY (substance_id) REFERENCES substances(id) ON DELETE CASCADE;
+ALTER TABLE actualities DROP CONSTRAINT IF EXISTS fk_actualities_potentiality;
+ALTER TABLE actualities ADD CONSTRAINT fk_actualities_potentiality
+    FOREIGN KEY (potentiality_id) REFERENCES potentialities(id) ON DELETE CASCADE;
+
+ALTER TABLE rules DROP CONSTRAINT IF EXISTS fk_rules_attribute;
+ALTER TABLE rules ADD CONSTRAINT fk_rules_attribute
+    FOREIGN KEY (attribute_id) REFERENCES attributes(id) ON DELETE CASCADE;
+
+ALTER TABLE parthoods DROP CONSTRAINT IF EXISTS fk_parthoods_part;
+ALTER TABLE parthoods ADD CONSTRAINT fk_parthoods_part
+    FOREIGN KEY (part_id) REFERENCES substances(id) ON DELETE CASCADE;
+ALTER TABLE parthoods DROP CONSTRAINT IF EXISTS fk_parthoods_whole;
+ALTER TABLE parthoods ADD CONSTRAINT fk_parthoods_whole
+    FOREIGN KEY (whole_id) REFERENCES substances(id) ON DELETE CASCADE;
+
+ALTER TABLE mode_bearers DROP CONSTRAINT IF EXISTS fk_modes_bearers;
+ALTER TABLE mode_bearers ADD CONSTRAINT fk_modes_bearers
+    FOREIGN KEY (mode_id) REFERENCES modes(id) ON DELETE CASCADE;
+ALTER TABLE mode_bearers DROP CONSTRAINT IF EXISTS fk_mode_bearers_substance;
+ALTER TABLE mode_bearers ADD CONSTRAINT fk_mode_bearers_substance
+    FOREIGN KEY (substance_id) REFERENCES substances(id) ON DELETE CASCADE;
+
+ALTER TABLE template_modes DROP CONSTRAINT IF EXISTS fk_substance_templates_modes;
+ALTER TABLE template_modes ADD CONSTRAINT fk_substance_templates_modes
+    FOREIGN KEY (template_id) REFERENCES substance_templates(id) ON DELETE CASCADE;
+ALTER TABLE template_modes DROP CONSTRAINT IF EXISTS fk_template_modes_attribute;
+ALTER TABLE template_modes ADD CONSTRAINT fk_template_modes_attribute
+    FOREIGN KEY (attribute_id) REFERENCES attributes(id) ON DELETE CASCADE;
+
+ALTER TABLE template_potentialities DROP CONSTRAINT IF EXISTS fk_substance_templates_potentialities;
+ALTER TABLE template_potentialities ADD CONSTRAINT fk_substance_templates_potentialities
+    FOREIGN KEY (template_id) REFERENCES substance_templates(id) ON DELETE CASCADE;
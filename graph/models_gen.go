@@ -2,19 +2,163 @@
 
 package graph
 
+type Attribute struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description *string     `json:"description,omitempty"`
+	DataType    string      `json:"dataType"`
+	Cardinality string      `json:"cardinality"`
+	Roles       []string    `json:"roles,omitempty"`
+	CreatedAt   string      `json:"createdAt"`
+	Substances  []Substance `json:"substances"`
+}
+
+type CausalCentrality struct {
+	EntityID    string  `json:"entityId"`
+	InDegree    int     `json:"inDegree"`
+	OutDegree   int     `json:"outDegree"`
+	Degree      int     `json:"degree"`
+	Betweenness float64 `json:"betweenness"`
+	Pagerank    float64 `json:"pagerank"`
+}
+
+type CausalComponent struct {
+	Entities  []string `json:"entities"`
+	Relations int      `json:"relations"`
+}
+
+type CausalCycle struct {
+	Entities  []string         `json:"entities"`
+	Relations []CausalRelation `json:"relations"`
+}
+
+type CausalNode struct {
+	EntityID   string  `json:"entityId"`
+	Depth      int     `json:"depth"`
+	CauseType  string  `json:"causeType"`
+	RelationID string  `json:"relationId"`
+	Via        string  `json:"via"`
+	Strength   float64 `json:"strength"`
+}
+
+type CausalPath struct {
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Entities  []string         `json:"entities"`
+	Relations []CausalRelation `json:"relations"`
+	Strength  float64          `json:"strength"`
+}
+
 type CausalRelation struct {
-	ID         string `json:"id"`
-	CauseType  string `json:"causeType"`
-	FromEntity string `json:"fromEntity"`
-	ToEntity   string `json:"toEntity"`
+	ID         string  `json:"id"`
+	CauseType  string  `json:"causeType"`
+	FromEntity string  `json:"fromEntity"`
+	ToEntity   string  `json:"toEntity"`
+	FromType   string  `json:"fromType"`
+	ToType     string  `json:"toType"`
+	Strength   float64 `json:"strength"`
+	Evidence   *string `json:"evidence,omitempty"`
+	Notes      *string `json:"notes,omitempty"`
+	ValidFrom  *string `json:"validFrom,omitempty"`
+	ValidUntil *string `json:"validUntil,omitempty"`
+}
+
+type EntityRef struct {
+	ID   string  `json:"id"`
+	Type string  `json:"type"`
+	Name *string `json:"name,omitempty"`
+}
+
+type Dependence struct {
+	ID            string  `json:"id"`
+	DependentID   string  `json:"dependentId"`
+	DependentType string  `json:"dependentType"`
+	DependsOnID   string  `json:"dependsOnId"`
+	DependsOnType string  `json:"dependsOnType"`
+	Type          string  `json:"type"`
+	Modality      string  `json:"modality"`
+	Notes         *string `json:"notes,omitempty"`
+	CreatedAt     string  `json:"createdAt"`
+	Depth         *int    `json:"depth,omitempty"`
+}
+
+type Kind struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description *string     `json:"description,omitempty"`
+	Definition  *string     `json:"definition,omitempty"`
+	CreatedAt   string      `json:"createdAt"`
+	Substances  []Substance `json:"substances"`
+}
+
+type Misclassification struct {
+	SubstanceID   string   `json:"substanceId"`
+	SubstanceName string   `json:"substanceName"`
+	AssignedKind  string   `json:"assignedKind"`
+	Unmet         []string `json:"unmet"`
+	MatchingKinds []string `json:"matchingKinds"`
+}
+
+type ModeConflict struct {
+	SubstanceID   string   `json:"substanceId"`
+	AttributeID   string   `json:"attributeId"`
+	AttributeName string   `json:"attributeName"`
+	Values        []string `json:"values"`
+	CurrentModeID string   `json:"currentModeId"`
 }
 
 type Mutation struct {
 }
 
+type PartNode struct {
+	SubstanceID string `json:"substanceId"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Depth       int    `json:"depth"`
+	Via         string `json:"via"`
+}
+
+type Parthood struct {
+	ID        string `json:"id"`
+	PartID    string `json:"partId"`
+	WholeID   string `json:"wholeId"`
+	CreatedAt string `json:"createdAt"`
+}
+
 type Query struct {
 }
 
+type Relation struct {
+	ID            string           `json:"id"`
+	AttributeID   string           `json:"attributeId"`
+	AttributeName string           `json:"attributeName"`
+	Value         *string          `json:"value,omitempty"`
+	Bearers       []RelationBearer `json:"bearers"`
+	CreatedAt     string           `json:"createdAt"`
+}
+
+type RelationBearer struct {
+	Role        string `json:"role"`
+	SubstanceID string `json:"substanceId"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+}
+
+type RelationBearerInput struct {
+	SubstanceID string  `json:"substanceId"`
+	Role        *string `json:"role,omitempty"`
+}
+
+type Rule struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	Conditions  string  `json:"conditions"`
+	AttributeID string  `json:"attributeId"`
+	Value       string  `json:"value"`
+	CreatedAt   string  `json:"createdAt"`
+}
+
 type Substance struct {
 	ID        string           `json:"id"`
 	Name      string           `json:"name"`
@@ -23,3 +167,54 @@ type Substance struct {
 	CreatedAt string           `json:"createdAt"`
 	Causes    []CausalRelation `json:"causes"`
 }
+
+type SubstanceMerge struct {
+	ID            string `json:"id"`
+	SurvivorID    string `json:"survivorId"`
+	DuplicateID   string `json:"duplicateId"`
+	DuplicateName string `json:"duplicateName"`
+	Strategy      string `json:"strategy"`
+	Summary       string `json:"summary"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+type SubstanceTemplate struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Description    *string                `json:"description,omitempty"`
+	Kind           string                 `json:"kind"`
+	Essence        *string                `json:"essence,omitempty"`
+	CreatedAt      string                 `json:"createdAt"`
+	Modes          []TemplateMode         `json:"modes"`
+	Potentialities []TemplatePotentiality `json:"potentialities"`
+}
+
+type TemplateMode struct {
+	AttributeID   string `json:"attributeId"`
+	AttributeName string `json:"attributeName"`
+	Value         string `json:"value"`
+}
+
+type TemplateModeInput struct {
+	AttributeID string `json:"attributeId"`
+	Value       string `json:"value"`
+}
+
+type TemplatePotentiality struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	Conditions  *string `json:"conditions,omitempty"`
+}
+
+type TemplatePotentialityInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	Conditions  *string `json:"conditions,omitempty"`
+}
+
+type Workspace struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	CreatedAt   string  `json:"createdAt"`
+}
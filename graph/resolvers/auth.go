@@ -0,0 +1,56 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// RequireAuthentication returns operation middleware refusing what the
+// authenticator does not admit: mutations and subscriptions without a
+// principal, and queries too unless anonymous reads are allowed
+func RequireAuthentication(authenticator *auth.Authenticator) graphql.OperationMiddleware {
+	return func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		operation := graphql.GetOperationContext(ctx).Operation
+		read := operation != nil && operation.Operation == ast.Query
+		if !authenticator.Admits(auth.FromContext(ctx), read) {
+			return graphql.OneShot(graphql.ErrorResponse(ctx, "%s", auth.ErrUnauthenticated))
+		}
+		return next(ctx)
+	}
+}
+
+// authorize returns an error naming the missing permission unless the
+// principal in ctx may perform the action on the resource for the kinds of
+// the given substances, or of the substances the given modes,
+// potentialities and actualities belong to. It asks what REST handlers ask.
+func (r *Resolver) authorize(ctx context.Context, action, resource string, entityIDs ...string) error {
+	return permissionError(ctx, auth.NewAuthorizer(r.db(ctx)).Authorize(action, resource, entityIDs...))
+}
+
+// authorizeKinds is authorize for the given kinds
+func (r *Resolver) authorizeKinds(ctx context.Context, action, resource string, kinds ...string) error {
+	return permissionError(ctx, auth.NewAuthorizer(r.db(ctx)).AuthorizeKinds(action, resource, kinds...))
+}
+
+// permissionError puts a missing permission in the extensions of the field's
+// error, where the REST API has it in the body of its 403
+func permissionError(ctx context.Context, err error) error {
+	var denied *auth.PermissionError
+	if !errors.As(err, &denied) {
+		return err
+	}
+	return &gqlerror.Error{
+		Path:    graphql.GetPath(ctx),
+		Message: err.Error(),
+		Extensions: map[string]interface{}{
+			"code":               "FORBIDDEN",
+			"missing_permission": denied.Missing,
+			"roles":              denied.Roles,
+		},
+	}
+}
@@ -0,0 +1,293 @@
+package resolvers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apodicticscott/oaas/graph"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/mereology"
+)
+
+// Conversions from internal entities to GraphQL models
+
+// toGraphSubstance converts a substance with the causal relations of which it is the cause
+func toGraphSubstance(substance entities.Substance, causes []entities.CausalRelation) graph.Substance {
+	return graph.Substance{
+		ID:        substance.ID,
+		Name:      substance.Name,
+		Kind:      substance.Kind,
+		Essence:   substance.Essence,
+		CreatedAt: substance.CreatedAt.Format(time.RFC3339),
+		Causes:    toGraphCausalRelations(causes),
+	}
+}
+
+func toGraphKind(kind entities.Kind) graph.Kind {
+	result := graph.Kind{
+		ID:          kind.ID,
+		Name:        kind.Name,
+		Description: optionalString(kind.Description),
+		Definition:  optionalString(kind.Definition),
+		CreatedAt:   kind.CreatedAt.Format(time.RFC3339),
+		Substances:  make([]graph.Substance, 0, len(kind.Substances)),
+	}
+	for _, substance := range kind.Substances {
+		result.Substances = append(result.Substances, toGraphSubstance(substance, nil))
+	}
+	return result
+}
+
+func toGraphAttribute(attribute entities.Attribute) graph.Attribute {
+	result := graph.Attribute{
+		ID:          attribute.ID,
+		Name:        attribute.Name,
+		Description: optionalString(attribute.Description),
+		DataType:    attribute.DataType,
+		Cardinality: attribute.Cardinality,
+		CreatedAt:   attribute.CreatedAt.Format(time.RFC3339),
+		Substances:  make([]graph.Substance, 0, len(attribute.Substances)),
+	}
+	// Roles were validated when the attribute was created
+	result.Roles, _ = attribute.BearerRoles()
+	for _, substance := range attribute.Substances {
+		result.Substances = append(result.Substances, toGraphSubstance(substance, nil))
+	}
+	return result
+}
+
+func toGraphCausalRelation(relation entities.CausalRelation) graph.CausalRelation {
+	return graph.CausalRelation{
+		ID:         relation.ID,
+		CauseType:  relation.CauseType,
+		FromEntity: relation.FromEntity,
+		ToEntity:   relation.ToEntity,
+		FromType:   relation.FromType,
+		ToType:     relation.ToType,
+		Strength:   relation.Strength,
+		Evidence:   optionalString(relation.Evidence),
+		Notes:      optionalString(relation.Notes),
+		ValidFrom:  optionalTime(relation.ValidFrom),
+		ValidUntil: optionalTime(relation.ValidUntil),
+	}
+}
+
+func toGraphCausalRelations(relations []entities.CausalRelation) []graph.CausalRelation {
+	result := make([]graph.CausalRelation, 0, len(relations))
+	for _, relation := range relations {
+		result = append(result, toGraphCausalRelation(relation))
+	}
+	return result
+}
+
+func toGraphCausalNodes(nodes []causality.CausalNode) []graph.CausalNode {
+	result := make([]graph.CausalNode, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, graph.CausalNode{
+			EntityID:   node.EntityID,
+			Depth:      node.Depth,
+			CauseType:  node.CauseType,
+			RelationID: node.RelationID,
+			Via:        node.Via,
+			Strength:   node.Strength,
+		})
+	}
+	return result
+}
+
+func toGraphEntityRefs(refs []causality.EntityRef) []graph.EntityRef {
+	result := make([]graph.EntityRef, 0, len(refs))
+	for _, ref := range refs {
+		result = append(result, graph.EntityRef{ID: ref.ID, Type: ref.Type, Name: optionalString(ref.Name)})
+	}
+	return result
+}
+
+func toGraphRule(rule entities.Rule) graph.Rule {
+	return graph.Rule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: optionalString(rule.Description),
+		Conditions:  rule.Conditions,
+		AttributeID: rule.AttributeID,
+		Value:       rule.Value,
+		CreatedAt:   rule.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toGraphPartNodes(nodes []mereology.PartNode) []graph.PartNode {
+	result := make([]graph.PartNode, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, graph.PartNode{
+			SubstanceID: node.Substance.ID,
+			Name:        node.Substance.Name,
+			Kind:        node.Substance.Kind,
+			Depth:       node.Depth,
+			Via:         node.Via,
+		})
+	}
+	return result
+}
+
+// toGraphRelation converts a relational mode with its attribute and bearers preloaded
+func toGraphRelation(mode entities.Mode) graph.Relation {
+	relation := graph.Relation{
+		ID:          mode.ID,
+		AttributeID: mode.AttributeID,
+		Value:       optionalString(mode.Value),
+		Bearers:     make([]graph.RelationBearer, 0, len(mode.Bearers)),
+		CreatedAt:   mode.CreatedAt.Format(time.RFC3339),
+	}
+	if mode.Attribute != nil {
+		relation.AttributeName = mode.Attribute.Name
+	}
+	for _, bearer := range mode.Bearers {
+		b := graph.RelationBearer{Role: bearer.Role, SubstanceID: bearer.SubstanceID}
+		if bearer.Substance != nil {
+			b.Name, b.Kind = bearer.Substance.Name, bearer.Substance.Kind
+		}
+		relation.Bearers = append(relation.Bearers, b)
+	}
+	return relation
+}
+
+// toGraphDependence converts a dependence; depth is left unset when zero
+func toGraphDependence(d entities.Dependence, depth int) graph.Dependence {
+	result := graph.Dependence{
+		ID:            d.ID,
+		DependentID:   d.DependentID,
+		DependentType: d.DependentType,
+		DependsOnID:   d.DependsOnID,
+		DependsOnType: d.DependsOnType,
+		Type:          d.Type,
+		Modality:      d.Modality,
+		Notes:         optionalString(d.Notes),
+		CreatedAt:     d.CreatedAt.Format(time.RFC3339),
+	}
+	if depth > 0 {
+		result.Depth = &depth
+	}
+	return result
+}
+
+func toGraphDependenceNodes(nodes []dependence.DependenceNode) []graph.Dependence {
+	result := make([]graph.Dependence, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, toGraphDependence(node.Dependence, node.Depth))
+	}
+	return result
+}
+
+func toGraphSubstanceMerge(m entities.SubstanceMerge) graph.SubstanceMerge {
+	return graph.SubstanceMerge{
+		ID:            m.ID,
+		SurvivorID:    m.SurvivorID,
+		DuplicateID:   m.DuplicateID,
+		DuplicateName: m.DuplicateName,
+		Strategy:      m.Strategy,
+		Summary:       m.Summary,
+		CreatedAt:     m.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toGraphTemplate(t entities.SubstanceTemplate) graph.SubstanceTemplate {
+	result := graph.SubstanceTemplate{
+		ID:             t.ID,
+		Name:           t.Name,
+		Description:    optionalString(t.Description),
+		Kind:           t.Kind,
+		Essence:        optionalString(t.Essence),
+		CreatedAt:      t.CreatedAt.Format(time.RFC3339),
+		Modes:          make([]graph.TemplateMode, 0, len(t.Modes)),
+		Potentialities: make([]graph.TemplatePotentiality, 0, len(t.Potentialities)),
+	}
+	for _, mode := range t.Modes {
+		m := graph.TemplateMode{AttributeID: mode.AttributeID, Value: mode.Value}
+		if mode.Attribute != nil {
+			m.AttributeName = mode.Attribute.Name
+		}
+		result.Modes = append(result.Modes, m)
+	}
+	for _, potentiality := range t.Potentialities {
+		result.Potentialities = append(result.Potentialities, graph.TemplatePotentiality{
+			Name:        potentiality.Name,
+			Description: optionalString(potentiality.Description),
+			Conditions:  optionalString(potentiality.Conditions),
+		})
+	}
+	return result
+}
+
+// traversalOptions builds engine options from optional GraphQL arguments
+func traversalOptions(depth *int, causeTypes []string, minStrength *float64, validAt *string) (causality.TraversalOptions, error) {
+	filter, err := causalFilter(nil, causeTypes, minStrength, validAt)
+	if err != nil {
+		return causality.TraversalOptions{}, err
+	}
+
+	opts := causality.TraversalOptions{
+		CauseTypes:  filter.CauseTypes,
+		MinStrength: filter.MinStrength,
+		ValidAt:     filter.ValidAt,
+	}
+	if depth != nil {
+		opts.MaxDepth = *depth
+	}
+	return opts, nil
+}
+
+// causalFilter builds a relation filter from optional GraphQL arguments
+func causalFilter(entityID *string, causeTypes []string, minStrength *float64, validAt *string) (causality.CausalFilter, error) {
+	filter := causality.CausalFilter{CauseTypes: causeTypes}
+	if entityID != nil {
+		filter.EntityID = *entityID
+	}
+	if minStrength != nil {
+		filter.MinStrength = *minStrength
+	}
+
+	parsed, err := parseTime(validAt)
+	if err != nil {
+		return filter, fmt.Errorf("invalid validAt: %w", err)
+	}
+	filter.ValidAt = parsed
+	return filter, nil
+}
+
+// parseTime parses an optional RFC 3339 timestamp
+func parseTime(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func optionalTime(value *time.Time) *string {
+	if value == nil {
+		return nil
+	}
+	formatted := value.Format(time.RFC3339)
+	return &formatted
+}
+
+func toGraphWorkspace(w entities.Workspace) graph.Workspace {
+	return graph.Workspace{
+		ID:          w.ID,
+		Name:        w.Name,
+		Description: optionalString(w.Description),
+		CreatedAt:   w.CreatedAt.Format(time.RFC3339),
+	}
+}
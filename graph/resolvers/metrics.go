@@ -0,0 +1,49 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/apodicticscott/oaas/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "oaas_graphql_operation_duration_seconds",
+	Help:    "Time to execute GraphQL operations, by type, operation name and status (ok or error).",
+	Buckets: metrics.DefaultBuckets,
+}, []string{"type", "operation", "status"})
+
+// InstrumentOperations returns operation middleware timing each operation
+// until its response is written. Unnamed operations count as "anonymous";
+// an operation whose response carries errors counts as an error.
+func InstrumentOperations() graphql.OperationMiddleware {
+	return func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		start := time.Now()
+		operationType, name := "unknown", "anonymous"
+		if oc := graphql.GetOperationContext(ctx); oc != nil {
+			if oc.OperationName != "" {
+				name = oc.OperationName
+			}
+			if oc.Operation != nil {
+				operationType = string(oc.Operation.Operation)
+			}
+		}
+
+		responses := next(ctx)
+		return func(ctx context.Context) *graphql.Response {
+			resp := responses(ctx)
+			if resp == nil {
+				return nil
+			}
+			status := "ok"
+			if len(resp.Errors) > 0 {
+				status = "error"
+			}
+			operationDuration.WithLabelValues(operationType, name, status).Observe(time.Since(start).Seconds())
+			return resp
+		}
+	}
+}
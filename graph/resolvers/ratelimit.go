@@ -0,0 +1,44 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/apodicticscott/oaas/internal/ratelimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ThrottleFields returns field middleware holding the given Query fields to
+// the limiter, as REST holds the routes serving them to its expensive limit.
+// Fields count against the client Throttle put in the request's context; a
+// request without one, or a nil limiter, is not limited.
+func ThrottleFields(limiter *ratelimit.Limiter, fields ...string) graphql.FieldMiddleware {
+	expensive := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		expensive[field] = true
+	}
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if limiter == nil || fc == nil || fc.Object != "Query" || !expensive[fc.Field.Name] {
+			return next(ctx)
+		}
+		client := ratelimit.ClientFromContext(ctx)
+		if client == "" {
+			return next(ctx)
+		}
+		if decision := limiter.Allow(client); !decision.Allowed {
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			return nil, &gqlerror.Error{
+				Path:    graphql.GetPath(ctx),
+				Message: fmt.Sprintf("rate limit exceeded: retry in %ds", retryAfter),
+				Extensions: map[string]interface{}{
+					"code":        "RATE_LIMITED",
+					"retry_after": retryAfter,
+				},
+			}
+		}
+		return next(ctx)
+	}
+}
@@ -1,11 +1,30 @@
 package resolvers
 
-import "gorm.io/gorm"
+import (
+	"context"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"gorm.io/gorm"
+)
 
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	DB *gorm.DB
+	// Store holds substances, kinds, attributes, modes, potentialities,
+	// actualities and causes; DB everything else
+	Store *repository.Store
+	DB    *gorm.DB
+}
+
+// db returns the resolver's database confined to the request's workspace
+func (r *Resolver) db(ctx context.Context) *gorm.DB {
+	return r.DB.WithContext(ctx)
+}
+
+// engine returns a causality engine confined to the request's workspace
+func (r *Resolver) engine(ctx context.Context) *causality.Engine {
+	return causality.NewEngineWith(ctx, r.Store)
 }
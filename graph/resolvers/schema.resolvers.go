@@ -6,30 +6,926 @@ package resolvers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/apodicticscott/oaas/graph"
 	"github.com/apodicticscott/oaas/graph/generated"
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/classification"
+	"github.com/apodicticscott/oaas/internal/cloning"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/mereology"
+	"github.com/apodicticscott/oaas/internal/merge"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/apodicticscott/oaas/internal/workspace"
+	"gorm.io/gorm"
 )
 
 // CreateSubstance is the resolver for the createSubstance field.
 func (r *mutationResolver) CreateSubstance(ctx context.Context, name string, kind string, essence string) (*graph.Substance, error) {
-	panic(fmt.Errorf("not implemented: CreateSubstance - createSubstance"))
+	if err := r.authorizeKinds(ctx, auth.ActionCreate, auth.ResourceSubstance, kind); err != nil {
+		return nil, err
+	}
+	substance := entities.NewSubstance(name, kind, essence)
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewGorm(tx).Substances.Create(ctx, substance); err != nil {
+			return err
+		}
+		_, err := inference.NewReasoner(tx).RefreshSubstance(substance.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphSubstance(*substance, nil)
+	return &result, nil
+}
+
+// DeleteSubstance is the resolver for the deleteSubstance field.
+func (r *mutationResolver) DeleteSubstance(ctx context.Context, id string, dependents *string) (bool, error) {
+	if err := r.authorize(ctx, auth.ActionDelete, auth.ResourceSubstance, id); err != nil {
+		return false, err
+	}
+	policy := dependence.PolicyBlock
+	if dependents != nil {
+		policy = *dependents
+	}
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		tracker := dependence.NewTracker(tx)
+		plan, err := tracker.PlanDeletion(entities.EntityTypeSubstance, id, policy)
+		if err != nil {
+			return err
+		}
+		// Cascading deletes dependents, which the principal must be allowed to delete too
+		if err := auth.NewAuthorizer(tx).AuthorizeEntities(auth.ActionDelete, plan.Entities); err != nil {
+			return permissionError(ctx, err)
+		}
+		affected, err := tracker.Execute(plan)
+		if err != nil {
+			return err
+		}
+		reasoner := inference.NewReasoner(tx)
+		for _, substanceID := range affected {
+			if _, err := reasoner.RefreshSubstance(substanceID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddPart is the resolver for the addPart field.
+func (r *mutationResolver) AddPart(ctx context.Context, wholeID string, partID string) (*graph.Parthood, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourcePart, wholeID, partID); err != nil {
+		return nil, err
+	}
+	var parthood *entities.Parthood
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if parthood, err = mereology.NewMereology(tx).AddPart(partID, wholeID); err != nil {
+			return err
+		}
+		_, err = inference.NewReasoner(tx).RefreshSubstance(wholeID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &graph.Parthood{
+		ID:        parthood.ID,
+		PartID:    parthood.PartID,
+		WholeID:   parthood.WholeID,
+		CreatedAt: parthood.CreatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// RemovePart is the resolver for the removePart field.
+func (r *mutationResolver) RemovePart(ctx context.Context, wholeID string, partID string) (bool, error) {
+	if err := r.authorize(ctx, auth.ActionDelete, auth.ResourcePart, wholeID, partID); err != nil {
+		return false, err
+	}
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := mereology.NewMereology(tx).RemovePart(partID, wholeID); err != nil {
+			return err
+		}
+		_, err := inference.NewReasoner(tx).RefreshSubstance(wholeID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CloneSubstance is the resolver for the cloneSubstance field.
+func (r *mutationResolver) CloneSubstance(ctx context.Context, id string, name *string, deep *bool, causeIds []string) (*graph.Substance, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceSubstance, id); err != nil {
+		return nil, err
+	}
+	options := cloning.CloneOptions{Deep: deep != nil && *deep, CauseIDs: causeIds}
+	if name != nil {
+		options.Name = *name
+	}
+	var clone *entities.Substance
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if clone, err = cloning.NewCloner(tx).Clone(id, options); err != nil {
+			return err
+		}
+		_, err = inference.NewReasoner(tx).RefreshSubstance(clone.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphSubstance(*clone, nil)
+	return &result, nil
+}
+
+// CreateTemplate is the resolver for the createTemplate field.
+func (r *mutationResolver) CreateTemplate(ctx context.Context, name string, description *string, kind string, essence *string, modes []graph.TemplateModeInput, potentialities []graph.TemplatePotentialityInput) (*graph.SubstanceTemplate, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceTemplate); err != nil {
+		return nil, err
+	}
+	input := cloning.TemplateInput{Name: name, Kind: kind}
+	if description != nil {
+		input.Description = *description
+	}
+	if essence != nil {
+		input.Essence = *essence
+	}
+	for _, mode := range modes {
+		input.Modes = append(input.Modes, cloning.ModeInput{AttributeID: mode.AttributeID, Value: mode.Value})
+	}
+	for _, potentiality := range potentialities {
+		p := cloning.PotentialityInput{Name: potentiality.Name}
+		if potentiality.Description != nil {
+			p.Description = *potentiality.Description
+		}
+		if potentiality.Conditions != nil {
+			p.Conditions = *potentiality.Conditions
+		}
+		input.Potentialities = append(input.Potentialities, p)
+	}
+	template, err := cloning.NewCloner(r.db(ctx)).CreateTemplate(input)
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphTemplate(*template)
+	return &result, nil
+}
+
+// DeleteTemplate is the resolver for the deleteTemplate field.
+func (r *mutationResolver) DeleteTemplate(ctx context.Context, id string) (bool, error) {
+	if err := r.authorize(ctx, auth.ActionDelete, auth.ResourceTemplate); err != nil {
+		return false, err
+	}
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		return cloning.NewCloner(tx).DeleteTemplate(id)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MergeSubstances is the resolver for the mergeSubstances field.
+func (r *mutationResolver) MergeSubstances(ctx context.Context, survivorID string, duplicateID string, strategy *string) (*graph.SubstanceMerge, error) {
+	if err := r.authorize(ctx, auth.ActionUpdate, auth.ResourceSubstance, survivorID); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, auth.ActionDelete, auth.ResourceSubstance, duplicateID); err != nil {
+		return nil, err
+	}
+	chosen := ""
+	if strategy != nil {
+		chosen = *strategy
+	}
+	var result *merge.Result
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if result, err = merge.NewMerger(tx).Merge(survivorID, duplicateID, chosen); err != nil {
+			return err
+		}
+		_, err = inference.NewReasoner(tx).RefreshSubstance(result.Survivor.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged := toGraphSubstanceMerge(result.Merge)
+	return &merged, nil
+}
+
+// AddDependence is the resolver for the addDependence field.
+func (r *mutationResolver) AddDependence(ctx context.Context, dependentID string, dependsOnID string, typeArg string, modality *string, dependentType *string, dependsOnType *string, notes *string) (*graph.Dependence, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceDependence, dependentID, dependsOnID); err != nil {
+		return nil, err
+	}
+	input := dependence.Input{DependentID: dependentID, DependsOnID: dependsOnID, Type: typeArg}
+	if modality != nil {
+		input.Modality = *modality
+	}
+	if dependentType != nil {
+		input.DependentType = *dependentType
+	}
+	if dependsOnType != nil {
+		input.DependsOnType = *dependsOnType
+	}
+	if notes != nil {
+		input.Notes = *notes
+	}
+	recorded, err := dependence.NewTracker(r.db(ctx)).Record(input)
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphDependence(*recorded, 0)
+	return &result, nil
+}
+
+// RemoveDependence is the resolver for the removeDependence field.
+func (r *mutationResolver) RemoveDependence(ctx context.Context, id string) (bool, error) {
+	if err := r.authorize(ctx, auth.ActionDelete, auth.ResourceDependence); err != nil {
+		return false, err
+	}
+	if err := dependence.NewTracker(r.db(ctx)).Remove(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateKind is the resolver for the createKind field.
+func (r *mutationResolver) CreateKind(ctx context.Context, name string, description *string, definition *string) (*graph.Kind, error) {
+	if err := r.authorizeKinds(ctx, auth.ActionCreate, auth.ResourceKind, name); err != nil {
+		return nil, err
+	}
+	kind := entities.NewKind(name, "")
+	if description != nil {
+		kind.Description = *description
+	}
+	if definition != nil && *definition != "" {
+		if _, err := classification.ParseDefinition(*definition); err != nil {
+			return nil, err
+		}
+		kind.Definition = *definition
+	}
+	if err := r.Store.Kinds.Create(ctx, kind); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("a kind named %s already exists", name)
+		}
+		return nil, err
+	}
+	result := toGraphKind(*kind)
+	return &result, nil
+}
+
+// CreateAttribute is the resolver for the createAttribute field.
+func (r *mutationResolver) CreateAttribute(ctx context.Context, name string, description *string, dataType string, cardinality *string, roles []string) (*graph.Attribute, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceAttribute); err != nil {
+		return nil, err
+	}
+	attribute := entities.NewAttribute(name, "", dataType)
+	if description != nil {
+		attribute.Description = *description
+	}
+	if cardinality != nil && *cardinality != "" {
+		if *cardinality != entities.CardinalitySingle && *cardinality != entities.CardinalityMultiple {
+			return nil, fmt.Errorf("cardinality must be %s or %s", entities.CardinalitySingle, entities.CardinalityMultiple)
+		}
+		attribute.Cardinality = *cardinality
+	}
+	if len(roles) > 0 {
+		if err := attribute.SetBearerRoles(roles); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.Store.Attributes.Create(ctx, attribute); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("an attribute named %s already exists", name)
+		}
+		return nil, err
+	}
+	result := toGraphAttribute(*attribute)
+	return &result, nil
+}
+
+// CreateRelation is the resolver for the createRelation field.
+func (r *mutationResolver) CreateRelation(ctx context.Context, attributeID string, value *string, bearers []graph.RelationBearerInput) (*graph.Relation, error) {
+	input := causality.RelationInput{AttributeID: attributeID}
+	if value != nil {
+		input.Value = *value
+	}
+	var bearerIDs []string
+	for _, bearer := range bearers {
+		b := causality.BearerInput{SubstanceID: bearer.SubstanceID}
+		if bearer.Role != nil {
+			b.Role = *bearer.Role
+		}
+		input.Bearers = append(input.Bearers, b)
+		bearerIDs = append(bearerIDs, bearer.SubstanceID)
+	}
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceMode, bearerIDs...); err != nil {
+		return nil, err
+	}
+
+	var mode *entities.Mode
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if mode, err = causality.NewEngine(tx).AssertRelation(input); err != nil {
+			return err
+		}
+		_, err = inference.NewReasoner(tx).RefreshSubstance(mode.SubstanceID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphRelation(*mode)
+	return &result, nil
 }
 
 // AddCause is the resolver for the addCause field.
-func (r *mutationResolver) AddCause(ctx context.Context, fromEntity string, toEntity string, causeType string) (*graph.CausalRelation, error) {
-	panic(fmt.Errorf("not implemented: AddCause - addCause"))
+func (r *mutationResolver) AddCause(ctx context.Context, fromEntity string, toEntity string, causeType string, fromType *string, toType *string, strength *float64, evidence *string, notes *string, validFrom *string, validUntil *string) (*graph.CausalRelation, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceCausalRelation, fromEntity, toEntity); err != nil {
+		return nil, err
+	}
+	input := causality.CausalRelationInput{
+		FromEntity: fromEntity,
+		ToEntity:   toEntity,
+		CauseType:  causeType,
+		Strength:   strength,
+	}
+	if fromType != nil {
+		input.FromType = *fromType
+	}
+	if toType != nil {
+		input.ToType = *toType
+	}
+	if evidence != nil {
+		input.Evidence = *evidence
+	}
+	if notes != nil {
+		input.Notes = *notes
+	}
+
+	var err error
+	if input.ValidFrom, err = parseTime(validFrom); err != nil {
+		return nil, fmt.Errorf("invalid validFrom: %w", err)
+	}
+	if input.ValidUntil, err = parseTime(validUntil); err != nil {
+		return nil, fmt.Errorf("invalid validUntil: %w", err)
+	}
+
+	relation, err := r.engine(ctx).RecordCausalRelation(input)
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphCausalRelation(*relation)
+	return &result, nil
+}
+
+// CreateRule is the resolver for the createRule field.
+func (r *mutationResolver) CreateRule(ctx context.Context, name string, description *string, conditions string, attributeID string, value string) (*graph.Rule, error) {
+	if err := r.authorize(ctx, auth.ActionCreate, auth.ResourceRule); err != nil {
+		return nil, err
+	}
+	input := inference.RuleInput{
+		Name:        name,
+		Conditions:  conditions,
+		AttributeID: attributeID,
+		Value:       value,
+	}
+	if description != nil {
+		input.Description = *description
+	}
+
+	var rule *entities.Rule
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		rule, _, err = inference.NewReasoner(tx).CreateRule(input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphRule(*rule)
+	return &result, nil
+}
+
+// DeleteRule is the resolver for the deleteRule field.
+func (r *mutationResolver) DeleteRule(ctx context.Context, id string) (bool, error) {
+	if err := r.authorize(ctx, auth.ActionDelete, auth.ResourceRule); err != nil {
+		return false, err
+	}
+	err := r.db(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := inference.NewReasoner(tx).DeleteRule(id)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // Substance is the resolver for the substance field.
 func (r *queryResolver) Substance(ctx context.Context, id string) (*graph.Substance, error) {
-	panic(fmt.Errorf("not implemented: Substance - substance"))
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceSubstance, id); err != nil {
+		return nil, err
+	}
+	substance, err := r.Store.Substances.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	relations, err := r.Store.Causes.List(ctx, repository.CauseFilter{EntityID: id})
+	if err != nil {
+		return nil, err
+	}
+	var causes []entities.CausalRelation
+	for _, relation := range relations {
+		if relation.FromEntity == id {
+			causes = append(causes, relation)
+		}
+	}
+	result := toGraphSubstance(*substance, causes)
+	return &result, nil
 }
 
 // Substances is the resolver for the substances field.
 func (r *queryResolver) Substances(ctx context.Context) ([]graph.Substance, error) {
-	panic(fmt.Errorf("not implemented: Substances - substances"))
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceSubstance); err != nil {
+		return nil, err
+	}
+	substances, err := r.Store.Substances.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := r.Store.Causes.List(ctx, repository.CauseFilter{})
+	if err != nil {
+		return nil, err
+	}
+	causes := make(map[string][]entities.CausalRelation)
+	for _, relation := range relations {
+		causes[relation.FromEntity] = append(causes[relation.FromEntity], relation)
+	}
+	result := make([]graph.Substance, 0, len(substances))
+	for _, substance := range substances {
+		result = append(result, toGraphSubstance(substance, causes[substance.ID]))
+	}
+	return result, nil
+}
+
+// Parts is the resolver for the parts field.
+func (r *queryResolver) Parts(ctx context.Context, id string, transitive *bool) ([]graph.PartNode, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourcePart, id); err != nil {
+		return nil, err
+	}
+	parts, err := mereology.NewMereology(r.db(ctx)).Parts(id, transitive != nil && *transitive)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphPartNodes(parts), nil
+}
+
+// Wholes is the resolver for the wholes field.
+func (r *queryResolver) Wholes(ctx context.Context, id string, transitive *bool) ([]graph.PartNode, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourcePart, id); err != nil {
+		return nil, err
+	}
+	wholes, err := mereology.NewMereology(r.db(ctx)).Wholes(id, transitive != nil && *transitive)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphPartNodes(wholes), nil
+}
+
+// SubstanceMerges is the resolver for the substanceMerges field.
+func (r *queryResolver) SubstanceMerges(ctx context.Context, id string) ([]graph.SubstanceMerge, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceSubstance, id); err != nil {
+		return nil, err
+	}
+	merges, err := merge.NewMerger(r.db(ctx)).History(id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.SubstanceMerge, 0, len(merges))
+	for _, m := range merges {
+		result = append(result, toGraphSubstanceMerge(m))
+	}
+	return result, nil
+}
+
+// Templates is the resolver for the templates field.
+func (r *queryResolver) Templates(ctx context.Context) ([]graph.SubstanceTemplate, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceTemplate); err != nil {
+		return nil, err
+	}
+	templates, err := cloning.NewCloner(r.db(ctx)).Templates()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.SubstanceTemplate, 0, len(templates))
+	for _, t := range templates {
+		result = append(result, toGraphTemplate(t))
+	}
+	return result, nil
+}
+
+// Template is the resolver for the template field.
+func (r *queryResolver) Template(ctx context.Context, id string) (*graph.SubstanceTemplate, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceTemplate); err != nil {
+		return nil, err
+	}
+	template, err := cloning.NewCloner(r.db(ctx)).Template(id)
+	if errors.Is(err, cloning.ErrTemplateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphTemplate(*template)
+	return &result, nil
+}
+
+// Dependences is the resolver for the dependences field.
+func (r *queryResolver) Dependences(ctx context.Context, entityID *string) ([]graph.Dependence, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceDependence); err != nil {
+		return nil, err
+	}
+	id := ""
+	if entityID != nil {
+		id = *entityID
+	}
+	dependences, err := dependence.NewTracker(r.db(ctx)).List(id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.Dependence, 0, len(dependences))
+	for _, d := range dependences {
+		result = append(result, toGraphDependence(d, 0))
+	}
+	return result, nil
+}
+
+// Dependents is the resolver for the dependents field.
+func (r *queryResolver) Dependents(ctx context.Context, id string, transitive *bool) ([]graph.Dependence, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceDependence, id); err != nil {
+		return nil, err
+	}
+	dependents, err := dependence.NewTracker(r.db(ctx)).Dependents(id, transitive != nil && *transitive)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphDependenceNodes(dependents), nil
+}
+
+// Dependencies is the resolver for the dependencies field.
+func (r *queryResolver) Dependencies(ctx context.Context, id string, transitive *bool) ([]graph.Dependence, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceDependence, id); err != nil {
+		return nil, err
+	}
+	dependencies, err := dependence.NewTracker(r.db(ctx)).Dependencies(id, transitive != nil && *transitive)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphDependenceNodes(dependencies), nil
+}
+
+// ClassifySubstance is the resolver for the classifySubstance field.
+func (r *queryResolver) ClassifySubstance(ctx context.Context, id string) ([]string, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceSubstance, id); err != nil {
+		return nil, err
+	}
+	return classification.NewClassifier(r.db(ctx)).Classify(id)
+}
+
+// MisclassifiedSubstances is the resolver for the misclassifiedSubstances field.
+func (r *queryResolver) MisclassifiedSubstances(ctx context.Context) ([]graph.Misclassification, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceSubstance); err != nil {
+		return nil, err
+	}
+	misclassified, err := classification.NewClassifier(r.db(ctx)).Misclassified()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.Misclassification, 0, len(misclassified))
+	for _, m := range misclassified {
+		result = append(result, graph.Misclassification{
+			SubstanceID:   m.SubstanceID,
+			SubstanceName: m.SubstanceName,
+			AssignedKind:  m.AssignedKind,
+			Unmet:         m.Unmet,
+			MatchingKinds: m.MatchingKinds,
+		})
+	}
+	return result, nil
+}
+
+// ModeConflicts is the resolver for the modeConflicts field.
+func (r *queryResolver) ModeConflicts(ctx context.Context, substanceID *string) ([]graph.ModeConflict, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceMode); err != nil {
+		return nil, err
+	}
+	id := ""
+	if substanceID != nil {
+		id = *substanceID
+	}
+	conflicts, err := r.engine(ctx).ModeConflicts(id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.ModeConflict, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		result = append(result, graph.ModeConflict{
+			SubstanceID:   conflict.SubstanceID,
+			AttributeID:   conflict.AttributeID,
+			AttributeName: conflict.AttributeName,
+			Values:        conflict.Values,
+			CurrentModeID: conflict.CurrentModeID,
+		})
+	}
+	return result, nil
+}
+
+// Relations is the resolver for the relations field.
+func (r *queryResolver) Relations(ctx context.Context, substanceID *string, role *string, attributeID *string) ([]graph.Relation, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceMode); err != nil {
+		return nil, err
+	}
+	var filter causality.RelationFilter
+	if substanceID != nil {
+		filter.SubstanceID = *substanceID
+	}
+	if role != nil {
+		if substanceID == nil {
+			return nil, fmt.Errorf("role requires substanceId")
+		}
+		filter.Role = *role
+	}
+	if attributeID != nil {
+		filter.AttributeID = *attributeID
+	}
+	modes, err := r.engine(ctx).ListRelations(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.Relation, 0, len(modes))
+	for _, mode := range modes {
+		result = append(result, toGraphRelation(mode))
+	}
+	return result, nil
+}
+
+// CausalRelations is the resolver for the causalRelations field.
+func (r *queryResolver) CausalRelations(ctx context.Context, entityID *string, causeTypes []string, minStrength *float64, validAt *string) ([]graph.CausalRelation, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation); err != nil {
+		return nil, err
+	}
+	filter, err := causalFilter(entityID, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := r.engine(ctx).ListCausalRelations(filter)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphCausalRelations(relations), nil
+}
+
+// CausalAncestors is the resolver for the causalAncestors field.
+func (r *queryResolver) CausalAncestors(ctx context.Context, entityID string, depth *int, causeTypes []string, minStrength *float64, validAt *string) ([]graph.CausalNode, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation, entityID); err != nil {
+		return nil, err
+	}
+	opts, err := traversalOptions(depth, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := r.engine(ctx).GetAncestors(entityID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphCausalNodes(nodes), nil
+}
+
+// CausalDescendants is the resolver for the causalDescendants field.
+func (r *queryResolver) CausalDescendants(ctx context.Context, entityID string, depth *int, causeTypes []string, minStrength *float64, validAt *string) ([]graph.CausalNode, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation, entityID); err != nil {
+		return nil, err
+	}
+	opts, err := traversalOptions(depth, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := r.engine(ctx).GetDescendants(entityID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphCausalNodes(nodes), nil
+}
+
+// CausalPath is the resolver for the causalPath field.
+func (r *queryResolver) CausalPath(ctx context.Context, from string, to string, depth *int, causeTypes []string, minStrength *float64, validAt *string, weighted *bool) (*graph.CausalPath, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation, from, to); err != nil {
+		return nil, err
+	}
+	opts, err := traversalOptions(depth, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	opts.Weighted = weighted != nil && *weighted
+
+	path, err := r.engine(ctx).FindCausalPath(from, to, opts)
+	if errors.Is(err, causality.ErrNoCausalPath) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &graph.CausalPath{
+		From:      path.From,
+		To:        path.To,
+		Entities:  path.Entities,
+		Relations: toGraphCausalRelations(path.Relations),
+		Strength:  path.Strength,
+	}, nil
+}
+
+// CausalCycles is the resolver for the causalCycles field.
+func (r *queryResolver) CausalCycles(ctx context.Context, depth *int, causeTypes []string, minStrength *float64, validAt *string) ([]graph.CausalCycle, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation); err != nil {
+		return nil, err
+	}
+	opts, err := traversalOptions(depth, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	cycles, err := r.engine(ctx).DetectCycles(opts)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.CausalCycle, 0, len(cycles))
+	for _, cycle := range cycles {
+		result = append(result, graph.CausalCycle{
+			Entities:  cycle.Entities,
+			Relations: toGraphCausalRelations(cycle.Relations),
+		})
+	}
+	return result, nil
+}
+
+// CausalCentrality is the resolver for the causalCentrality field.
+func (r *queryResolver) CausalCentrality(ctx context.Context, sortBy *string, limit *int, causeTypes []string, minStrength *float64, validAt *string) ([]graph.CausalCentrality, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation); err != nil {
+		return nil, err
+	}
+	filter, err := causalFilter(nil, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	order := ""
+	if sortBy != nil {
+		order = *sortBy
+	}
+
+	scores, err := r.engine(ctx).Centrality(filter, order)
+	if err != nil {
+		return nil, err
+	}
+	if limit != nil && *limit > 0 && len(scores) > *limit {
+		scores = scores[:*limit]
+	}
+
+	result := make([]graph.CausalCentrality, 0, len(scores))
+	for _, score := range scores {
+		result = append(result, graph.CausalCentrality{
+			EntityID:    score.EntityID,
+			InDegree:    score.InDegree,
+			OutDegree:   score.OutDegree,
+			Degree:      score.Degree,
+			Betweenness: score.Betweenness,
+			Pagerank:    score.PageRank,
+		})
+	}
+	return result, nil
+}
+
+// CausalComponents is the resolver for the causalComponents field.
+func (r *queryResolver) CausalComponents(ctx context.Context, strong *bool, causeTypes []string, minStrength *float64, validAt *string) ([]graph.CausalComponent, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation); err != nil {
+		return nil, err
+	}
+	filter, err := causalFilter(nil, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var components []causality.CausalComponent
+	if strong != nil && *strong {
+		components, err = r.engine(ctx).StrongComponents(filter)
+	} else {
+		components, err = r.engine(ctx).WeakComponents(filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]graph.CausalComponent, 0, len(components))
+	for _, component := range components {
+		result = append(result, graph.CausalComponent{Entities: component.Entities, Relations: component.Relations})
+	}
+	return result, nil
+}
+
+// RootCauses is the resolver for the rootCauses field.
+func (r *queryResolver) RootCauses(ctx context.Context, causeTypes []string, minStrength *float64, validAt *string) ([]graph.EntityRef, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation); err != nil {
+		return nil, err
+	}
+	filter, err := causalFilter(nil, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := r.engine(ctx).RootCauses(filter)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphEntityRefs(refs), nil
+}
+
+// TerminalEnds is the resolver for the terminalEnds field.
+func (r *queryResolver) TerminalEnds(ctx context.Context, causeTypes []string, minStrength *float64, validAt *string) ([]graph.EntityRef, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceCausalRelation); err != nil {
+		return nil, err
+	}
+	filter, err := causalFilter(nil, causeTypes, minStrength, validAt)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := r.engine(ctx).TerminalEnds(filter)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphEntityRefs(refs), nil
+}
+
+// Rule is the resolver for the rule field.
+func (r *queryResolver) Rule(ctx context.Context, id string) (*graph.Rule, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceRule); err != nil {
+		return nil, err
+	}
+	rule, err := inference.NewReasoner(r.db(ctx)).GetRule(id)
+	if errors.Is(err, inference.ErrRuleNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := toGraphRule(*rule)
+	return &result, nil
+}
+
+// Rules is the resolver for the rules field.
+func (r *queryResolver) Rules(ctx context.Context) ([]graph.Rule, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceRule); err != nil {
+		return nil, err
+	}
+	rules, err := inference.NewReasoner(r.db(ctx)).ListRules()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.Rule, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, toGraphRule(rule))
+	}
+	return result, nil
+}
+
+// Workspaces is the resolver for the workspaces field.
+func (r *queryResolver) Workspaces(ctx context.Context) ([]graph.Workspace, error) {
+	if err := r.authorize(ctx, auth.ActionRead, auth.ResourceWorkspace); err != nil {
+		return nil, err
+	}
+	workspaces, err := workspace.NewManager(r.db(ctx)).List()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]graph.Workspace, 0, len(workspaces))
+	for _, w := range workspaces {
+		result = append(result, toGraphWorkspace(w))
+	}
+	return result, nil
 }
 
 // Mutation returns generated.MutationResolver implementation.
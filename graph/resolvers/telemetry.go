@@ -0,0 +1,72 @@
+package resolvers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/apodicticscott/oaas/graph/resolvers")
+
+// TraceOperations returns operation middleware running each operation in a
+// span, the parent of its resolvers' spans, and logging operations that
+// return errors
+func TraceOperations() graphql.OperationMiddleware {
+	return func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		operationType, name := "unknown", "anonymous"
+		oc := graphql.GetOperationContext(ctx)
+		if oc.OperationName != "" {
+			name = oc.OperationName
+		}
+		if oc.Operation != nil {
+			operationType = string(oc.Operation.Operation)
+		}
+		ctx, span := tracer.Start(ctx, "graphql."+operationType+" "+name, trace.WithAttributes(
+			attribute.String("graphql.operation.type", operationType),
+			attribute.String("graphql.operation.name", name),
+		))
+
+		responses := next(ctx)
+		return func(ctx context.Context) *graphql.Response {
+			// resolvers run in the context the response handler is given
+			resp := responses(trace.ContextWithSpan(ctx, span))
+			if resp == nil {
+				span.End()
+				return nil
+			}
+			if len(resp.Errors) > 0 {
+				span.SetStatus(codes.Error, resp.Errors.Error())
+				slog.WarnContext(trace.ContextWithSpan(ctx, span), "graphql operation failed",
+					"type", operationType, "operation", name, "errors", resp.Errors.Error())
+			}
+			span.End()
+			return resp
+		}
+	}
+}
+
+// TraceFields returns field middleware running each resolver, but not the
+// plain fields of the objects resolvers return, in a span
+func TraceFields() graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || !fc.IsResolver {
+			return next(ctx)
+		}
+		ctx, span := tracer.Start(ctx, fc.Object+"."+fc.Field.Name, trace.WithAttributes(
+			attribute.String("graphql.field.path", fc.Path().String()),
+		))
+		defer span.End()
+		res, err := next(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return res, err
+	}
+}
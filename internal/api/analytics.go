@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/gin-gonic/gin"
+)
+
+// Causal graph analytics handlers.
+// Each accepts the same cause_type, min_strength and valid_at filters as the traversals.
+
+// GetCausalCentrality ranks entities by centrality (?sort=pagerank|betweenness|degree, ?limit=)
+func (h *Handler) GetCausalCentrality(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+	filter, ok := causalFilter(c)
+	if !ok {
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", causality.SortByPageRank)
+	if sortBy != causality.SortByPageRank && sortBy != causality.SortByBetweenness && sortBy != causality.SortByDegree {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of: pagerank, betweenness, degree"})
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	scores, err := h.engine(c).Centrality(filter, sortBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if limit > 0 && len(scores) > limit {
+		scores = scores[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"sort": sortBy, "centrality": scores})
+}
+
+// GetCausalComponents returns connected components (?connectivity=weak|strong, weak by default)
+func (h *Handler) GetCausalComponents(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+	filter, ok := causalFilter(c)
+	if !ok {
+		return
+	}
+
+	connectivity := c.DefaultQuery("connectivity", "weak")
+	var (
+		components []causality.CausalComponent
+		err        error
+	)
+	switch connectivity {
+	case "weak":
+		components, err = h.engine(c).WeakComponents(filter)
+	case "strong":
+		components, err = h.engine(c).StrongComponents(filter)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "connectivity must be weak or strong"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"connectivity": connectivity, "components": components})
+}
+
+// GetRootCauses returns entities with no cause of their own (efficient causes unless cause_type is given)
+func (h *Handler) GetRootCauses(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+	filter, ok := causalFilter(c)
+	if !ok {
+		return
+	}
+
+	roots, err := h.engine(c).RootCauses(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"root_causes": roots})
+}
+
+// GetTerminalEnds returns final causes nothing points past (final causes unless cause_type is given)
+func (h *Handler) GetTerminalEnds(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+	filter, ok := causalFilter(c)
+	if !ok {
+		return
+	}
+
+	ends, err := h.engine(c).TerminalEnds(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"terminal_ends": ends})
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication handlers
+
+// authError writes the response for an authentication, authorization or key
+// and role management error. A missing permission is spelled out.
+func authError(c *gin.Context, err error) {
+	var denied *auth.PermissionError
+	switch {
+	case errors.As(err, &denied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "missing_permission": denied.Missing, "roles": denied.Roles})
+	case errors.Is(err, auth.ErrUnauthenticated), errors.Is(err, auth.ErrInvalidCredentials):
+		c.Header("WWW-Authenticate", `Bearer realm="oaas"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrKeyNotFound), errors.Is(err, auth.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidKey), errors.Is(err, auth.ErrInvalidRole):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrDuplicateRole):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// isRead reports whether a request method only reads
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// authorize writes a 403 naming the missing permission and returns false
+// unless the request's principal may perform the action on the resource for
+// the kinds of the given substances, or of the substances the given modes,
+// potentialities and actualities belong to
+func (h *Handler) authorize(c *gin.Context, action, resource string, entityIDs ...string) bool {
+	if auth.FromContext(c.Request.Context()) == nil {
+		return true // there are no permissions to check; Authenticate admitted the request
+	}
+	db := h.db(c)
+	if db == nil {
+		return false
+	}
+	if err := auth.NewAuthorizer(db).Authorize(action, resource, entityIDs...); err != nil {
+		authError(c, err)
+		return false
+	}
+	return true
+}
+
+// authorizeKinds is authorize for the given kinds
+func (h *Handler) authorizeKinds(c *gin.Context, action, resource string, kinds ...string) bool {
+	if auth.FromContext(c.Request.Context()) == nil {
+		return true
+	}
+	db := h.db(c)
+	if db == nil {
+		return false
+	}
+	if err := auth.NewAuthorizer(db).AuthorizeKinds(action, resource, kinds...); err != nil {
+		authError(c, err)
+		return false
+	}
+	return true
+}
+
+// authorizeEntities is authorize for entities of mixed types
+func (h *Handler) authorizeEntities(c *gin.Context, action string, refs ...causality.EntityRef) bool {
+	if auth.FromContext(c.Request.Context()) == nil {
+		return true
+	}
+	db := h.db(c)
+	if db == nil {
+		return false
+	}
+	if err := auth.NewAuthorizer(db).AuthorizeEntities(action, refs); err != nil {
+		authError(c, err)
+		return false
+	}
+	return true
+}
+
+// Authenticate is middleware putting the principal of the request's API key
+// or JWT in its context. Invalid credentials are a 401, as are missing ones
+// unless the request only reads and anonymous reads are allowed.
+func (h *Handler) Authenticate(c *gin.Context) {
+	principal, err := h.Auth.Authenticate(c.Request)
+	if err == nil && !h.Auth.Admits(principal, isRead(c.Request.Method)) {
+		err = auth.ErrUnauthenticated
+	}
+	if err != nil {
+		authError(c, err)
+		c.Abort()
+		return
+	}
+	if principal != nil {
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+	}
+	c.Next()
+}
+
+// IdentifyPrincipal is middleware putting the principal of the request's
+// credentials in its context, leaving requests without any to whatever serves
+// them. Invalid credentials are still a 401.
+func (h *Handler) IdentifyPrincipal(c *gin.Context) {
+	principal, err := h.Auth.Authenticate(c.Request)
+	if err != nil {
+		authError(c, err)
+		c.Abort()
+		return
+	}
+	if principal != nil {
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+	}
+	c.Next()
+}
+
+// RequireAdmin is middleware refusing principals that are not administrators.
+// It lets everyone through when authentication is disabled.
+func (h *Handler) RequireAdmin(c *gin.Context) {
+	if h.Auth == nil {
+		c.Next()
+		return
+	}
+	principal := auth.FromContext(c.Request.Context())
+	switch {
+	case principal == nil:
+		authError(c, auth.ErrUnauthenticated)
+	case !principal.Admin:
+		authError(c, fmt.Errorf("%w: administrators only", auth.ErrForbidden))
+	default:
+		c.Next()
+		return
+	}
+	c.Abort()
+}
+
+// GetPrincipal returns who the request acts for
+func (h *Handler) GetPrincipal(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"principal": auth.FromContext(c.Request.Context()), "authentication": h.Auth != nil})
+}
+
+// GetAPIKeys returns every API key, without its secret
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	keys, err := auth.NewKeys(db).List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// CreateAPIKey issues an API key. The key is in the response and nowhere else.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req struct {
+		Name      string     `json:"name" binding:"required"`
+		Admin     bool       `json:"admin"`
+		Roles     []string   `json:"roles"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	key, secret, err := auth.NewKeys(db).Issue(req.Name, req.Admin, req.Roles, req.ExpiresAt)
+	if err != nil {
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": key, "api_key": secret})
+}
+
+// RevokeAPIKey makes an API key unusable
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	key, err := auth.NewKeys(db).Revoke(c.Param("id"))
+	if err != nil {
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// GetRoles returns the built-in and custom roles
+func (h *Handler) GetRoles(c *gin.Context) {
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	roles, err := auth.NewRoles(db).List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// CreateRole defines a custom role
+func (h *Handler) CreateRole(c *gin.Context) {
+	var req struct {
+		Name        string            `json:"name" binding:"required"`
+		Description string            `json:"description"`
+		Permissions []auth.Permission `json:"permissions"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	role, err := auth.NewRoles(db).Create(req.Name, req.Description, req.Permissions)
+	if err != nil {
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, role)
+}
+
+// DeleteRole deletes a custom role
+func (h *Handler) DeleteRole(c *gin.Context) {
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	if err := auth.NewRoles(db).Delete(c.Param("name")); err != nil {
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+}
+
+// GetAuditRecords returns the workspace's audit records, newest first
+func (h *Handler) GetAuditRecords(c *gin.Context) {
+	filter := auth.AuditFilter{
+		PrincipalID: c.Query("principal_id"),
+		EntityType:  c.Query("entity_type"),
+		EntityID:    c.Query("entity_id"),
+		Limit:       100,
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	records, err := auth.NewAuditLog(db).Records(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"records": records})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Cardinality handlers
+
+// UpdateAttributeCardinality makes an attribute single- or multi-valued.
+// Substances already holding several values are not rejected but flagged in the response.
+func (h *Handler) UpdateAttributeCardinality(c *gin.Context) {
+	id := c.Param("id")
+	if !h.authorize(c, auth.ActionUpdate, auth.ResourceAttribute) {
+		return
+	}
+	var req struct {
+		Cardinality string `json:"cardinality" binding:"required,oneof=single multiple"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attribute entities.Attribute
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	if err := db.First(&attribute, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "attribute not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if attribute.IsRelational() && req.Cardinality == entities.CardinalitySingle {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "relational attributes are multi-valued"})
+		return
+	}
+
+	if err := db.Model(&attribute).Update("cardinality", req.Cardinality).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	attribute.Cardinality = req.Cardinality
+
+	conflicts, err := h.engine(c).ModeConflicts("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	filtered := conflicts[:0]
+	for _, conflict := range conflicts {
+		if conflict.AttributeID == id {
+			filtered = append(filtered, conflict)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attribute": attribute, "conflicts": filtered})
+}
+
+// GetModeConflicts lists contradictory modes of single-valued attributes (?substance_id= to narrow)
+func (h *Handler) GetModeConflicts(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceMode) {
+		return
+	}
+	conflicts, err := h.engine(c).ModeConflicts(c.Query("substance_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"conflicts": conflicts})
+}
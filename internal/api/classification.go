@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/classification"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Classification handlers
+
+// UpdateKindDefinition sets or, with an empty definition, clears a kind's defining conditions
+func (h *Handler) UpdateKindDefinition(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Definition string `json:"definition"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Definition != "" {
+		if _, err := classification.ParseDefinition(req.Definition); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var kind entities.Kind
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	if err := db.First(&kind, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "kind not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorizeKinds(c, auth.ActionUpdate, auth.ResourceKind, kind.Name) {
+		return
+	}
+
+	if err := db.Model(&kind).Update("definition", req.Definition).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	kind.Definition = req.Definition
+
+	c.JSON(http.StatusOK, kind)
+}
+
+// GetSubstanceClassification reports the kinds whose definitions a substance meets
+func (h *Handler) GetSubstanceClassification(c *gin.Context) {
+	id := c.Param("id")
+	if !h.authorize(c, auth.ActionRead, auth.ResourceSubstance, id) {
+		return
+	}
+	var substance entities.Substance
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	if err := db.First(&substance, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	kinds, err := classification.NewClassifier(db).Classify(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"substance_id":   id,
+		"assigned_kind":  substance.Kind,
+		"matching_kinds": kinds,
+	})
+}
+
+// GetMisclassifiedSubstances lists substances whose assigned kind's definition they do not meet
+func (h *Handler) GetMisclassifiedSubstances(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceSubstance) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	misclassified, err := classification.NewClassifier(db).Misclassified()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"misclassified": misclassified})
+}
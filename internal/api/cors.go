@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSPolicy says which other origins browsers may call the API from
+type CORSPolicy struct {
+	AllowedOrigins   []string // "*" allows every origin
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // how long a preflight response may be cached
+}
+
+// exposedHeaders are the response headers scripts on other origins may read
+var exposedHeaders = strings.Join([]string{
+	RequestIDHeader, QuotaLimitHeader, QuotaRemainingHeader,
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+}, ", ")
+
+// CORS returns middleware adding CORS headers to requests from allowed
+// origins and answering their preflight requests. It goes before
+// authentication, since browsers send preflight requests without credentials.
+func CORS(policy CORSPolicy) gin.HandlerFunc {
+	anyOrigin := false
+	allowed := make(map[string]bool)
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.ToLower(origin)] = true
+	}
+	methods := strings.Join(policy.AllowedMethods, ", ")
+	headers := strings.Join(policy.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		if !anyOrigin && !allowed[strings.ToLower(origin)] {
+			c.Next()
+			return
+		}
+
+		if anyOrigin && !policy.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if policy.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Header("Access-Control-Expose-Headers", exposedHeaders)
+		c.Next()
+	}
+}
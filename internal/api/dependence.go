@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/gin-gonic/gin"
+)
+
+// Dependence handlers
+
+// dependenceError writes the response for an error from the dependence tracker
+func dependenceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dependence.ErrInvalidDependence), errors.Is(err, causality.ErrEntityNotFound),
+		errors.Is(err, dependence.ErrDependenceCycle):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, dependence.ErrDuplicateDependence):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, dependence.ErrDependenceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// CreateDependence records that one entity depends on another
+func (h *Handler) CreateDependence(c *gin.Context) {
+	var req struct {
+		DependentID   string `json:"dependent_id" binding:"required"`
+		DependentType string `json:"dependent_type"`
+		DependsOnID   string `json:"depends_on_id" binding:"required"`
+		DependsOnType string `json:"depends_on_type"`
+		Type          string `json:"type" binding:"required,oneof=existential identity"`
+		Modality      string `json:"modality" binding:"omitempty,oneof=rigid generic"`
+		Notes         string `json:"notes"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceDependence, req.DependentID, req.DependsOnID) {
+		return
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	recorded, err := dependence.NewTracker(db).Record(dependence.Input{
+		DependentID:   req.DependentID,
+		DependentType: req.DependentType,
+		DependsOnID:   req.DependsOnID,
+		DependsOnType: req.DependsOnType,
+		Type:          req.Type,
+		Modality:      req.Modality,
+		Notes:         req.Notes,
+	})
+	if err != nil {
+		dependenceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, recorded)
+}
+
+// GetDependences lists dependences, optionally those an entity takes part in (?entity_id=)
+func (h *Handler) GetDependences(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceDependence) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	dependences, err := dependence.NewTracker(db).List(c.Query("entity_id"))
+	if err != nil {
+		dependenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dependences)
+}
+
+// DeleteDependence deletes a dependence
+func (h *Handler) DeleteDependence(c *gin.Context) {
+	if !h.authorize(c, auth.ActionDelete, auth.ResourceDependence) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	if err := dependence.NewTracker(db).Remove(c.Param("id")); err != nil {
+		dependenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "dependence deleted"})
+}
+
+// GetDependents answers what depends on an entity, directly or with ?transitive=true
+func (h *Handler) GetDependents(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceDependence, c.Param("id")) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	dependents, err := dependence.NewTracker(db).Dependents(c.Param("id"), c.Query("transitive") == "true")
+	if err != nil {
+		dependenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dependents": dependents})
+}
+
+// GetDependencies lists what an entity depends on, directly or with ?transitive=true
+func (h *Handler) GetDependencies(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceDependence, c.Param("id")) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	dependencies, err := dependence.NewTracker(db).Dependencies(c.Param("id"), c.Query("transitive") == "true")
+	if err != nil {
+		dependenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dependencies": dependencies})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/export"
+	"github.com/gin-gonic/gin"
+)
+
+// Export handlers
+
+// ExportCausalGraph renders the causal graph around a substance, a kind or the whole store
+func (h *Handler) ExportCausalGraph(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+	format := c.DefaultQuery("format", export.FormatDOT)
+	supported := false
+	for _, candidate := range export.Formats {
+		supported = supported || candidate == format
+	}
+	if !supported {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of: " + strings.Join(export.Formats, ", ")})
+		return
+	}
+
+	opts, ok := traversalOptions(c)
+	if !ok {
+		return
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	graph, err := export.Build(db, export.Scope{
+		SubstanceID: c.Query("substance_id"),
+		Kind:        c.Query("kind"),
+		Depth:       opts.MaxDepth,
+		CauseTypes:  opts.CauseTypes,
+	})
+	if err != nil {
+		if errors.Is(err, export.ErrKindNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body bytes.Buffer
+	if err := export.Render(&body, graph, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, export.ContentType(format), body.Bytes())
+}
@@ -1,54 +1,119 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"path"
+	"sync/atomic"
+	"time"
 
+	"github.com/apodicticscott/oaas/internal/auth"
 	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/classification"
+	"github.com/apodicticscott/oaas/internal/cloning"
+	"github.com/apodicticscott/oaas/internal/dependence"
 	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/merge"
+	"github.com/apodicticscott/oaas/internal/ratelimit"
+	"github.com/apodicticscott/oaas/internal/repository"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // Handler contains dependencies for API handlers
 type Handler struct {
-	DB              *gorm.DB
-	CausalityEngine *causality.Engine
+	// Store holds substances, kinds, attributes, modes, potentialities,
+	// actualities and causes. Its database serves everything else: merges,
+	// templates, inference, parts and the like. With a store that has none,
+	// and authentication off, the handlers for those respond 501 and the
+	// rest work, without merge redirects or inferred modes.
+	Store *repository.Store
+	// Auth authenticates requests; nil disables authentication
+	Auth *auth.Authenticator
+	// RateLimits throttles clients; nil disables rate limits and quotas
+	RateLimits *ratelimit.Policy
+
+	draining atomic.Bool // set by Drain
 }
 
 // NewHandler creates a new API handler
 func NewHandler(db *gorm.DB) *Handler {
-	return &Handler{
-		DB:              db,
-		CausalityEngine: causality.NewEngine(db),
+	return &Handler{Store: repository.NewGorm(db)}
+}
+
+// db returns the store's database confined to the request's workspace. Without
+// one it responds 501 and returns nil.
+func (h *Handler) db(c *gin.Context) *gorm.DB {
+	db := h.Store.DB()
+	if db == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": repository.ErrNoDatabase.Error()})
+		return nil
 	}
+	return db.WithContext(c.Request.Context())
 }
 
-// HealthCheck returns the health status of the API
-func (h *Handler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"message": "OaaS API is running",
-	})
+// requireDB reports whether the store has a database, responding 501 if not
+func (h *Handler) requireDB(c *gin.Context) bool {
+	return h.db(c) != nil
+}
+
+// engine returns a causality engine confined to the request's workspace
+func (h *Handler) engine(c *gin.Context) *causality.Engine {
+	return causality.NewEngineWith(c.Request.Context(), h.Store)
+}
+
+// refresh infers the substance's modes anew, in the store's transaction. A
+// store without a database has no rules to infer them by.
+func refresh(store *repository.Store, substanceID string) error {
+	if store.DB() == nil {
+		return nil
+	}
+	_, err := inference.NewReasoner(store.DB()).RefreshSubstance(substanceID)
+	return err
 }
 
 // Substances handlers
 
 // GetSubstances returns all substances
 func (h *Handler) GetSubstances(c *gin.Context) {
-	var substances []entities.Substance
-	if err := h.DB.Preload("Attributes").Preload("Modes").Preload("Potentialities").Preload("Actualities").Find(&substances).Error; err != nil {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceSubstance) {
+		return
+	}
+	substances, err := h.Store.Substances.List(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"substances": substances})
 }
 
-// GetSubstance returns a specific substance by ID
+// GetSubstance returns a specific substance by ID. The ID of a substance merged
+// into another resolves to the survivor, whose URL, under the prefix the
+// request used, is given in Content-Location.
 func (h *Handler) GetSubstance(c *gin.Context) {
 	id := c.Param("id")
-	var substance entities.Substance
-	if err := h.DB.Preload("Attributes").Preload("Modes").Preload("Potentialities").Preload("Actualities").First(&substance, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if db := h.Store.DB(); db != nil {
+		var err error
+		if id, err = merge.NewMerger(db.WithContext(c.Request.Context())).Resolve(id); err != nil {
+			if errors.Is(err, merge.ErrSubstanceNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !h.authorize(c, auth.ActionRead, auth.ResourceSubstance, id) {
+		return
+	}
+	if id != c.Param("id") {
+		c.Header("Content-Location", path.Join(path.Dir(c.Request.URL.Path), id))
+	}
+
+	substance, err := h.Store.Substances.Get(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
 			return
 		}
@@ -58,12 +123,20 @@ func (h *Handler) GetSubstance(c *gin.Context) {
 	c.JSON(http.StatusOK, substance)
 }
 
-// CreateSubstance creates a new substance
+// CreateSubstance creates a new substance, optionally with initial modes.
+// With auto_classify set, the kind is the one kind whose definition the
+// substance meets, falling back to the given kind when none does.
 func (h *Handler) CreateSubstance(c *gin.Context) {
 	var req struct {
-		Name    string `json:"name" binding:"required"`
-		Kind    string `json:"kind" binding:"required"`
-		Essence string `json:"essence" binding:"required"`
+		Name         string `json:"name" binding:"required"`
+		Kind         string `json:"kind" binding:"required_without_all=AutoClassify Template"`
+		Essence      string `json:"essence" binding:"required_without=Template"`
+		AutoClassify bool   `json:"auto_classify"`
+		Template     string `json:"template"` // ID or name of a template supplying defaults
+		Modes        []struct {
+			AttributeID string `json:"attribute_id" binding:"required"`
+			Value       string `json:"value" binding:"required"`
+		} `json:"modes" binding:"dive"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -72,7 +145,76 @@ func (h *Handler) CreateSubstance(c *gin.Context) {
 	}
 
 	substance := entities.NewSubstance(req.Name, req.Kind, req.Essence)
-	if err := h.DB.Create(substance).Error; err != nil {
+	ctx := c.Request.Context()
+	err := h.Store.Transaction(ctx, func(store *repository.Store) error {
+		tx := store.DB()
+		if tx == nil && (req.Template != "" || req.AutoClassify || auth.FromContext(ctx) != nil) {
+			return repository.ErrNoDatabase
+		}
+		if req.Template != "" {
+			instance := cloning.Instance{Name: req.Name, Kind: req.Kind, Essence: req.Essence}
+			for _, m := range req.Modes {
+				instance.Modes = append(instance.Modes, cloning.ModeInput{AttributeID: m.AttributeID, Value: m.Value})
+			}
+			var err error
+			if substance, err = cloning.NewCloner(tx).Instantiate(req.Template, instance); err != nil {
+				return err
+			}
+		} else {
+			if err := store.Substances.Create(ctx, substance); err != nil {
+				return err
+			}
+			engine := causality.NewEngineWith(ctx, store)
+			for _, m := range req.Modes {
+				if err := engine.AssertMode(entities.NewMode(m.Value, substance.ID, m.AttributeID)); err != nil {
+					return err
+				}
+			}
+		}
+		if tx == nil {
+			return nil // there are no rules to infer modes by, nor permissions to check
+		}
+
+		reasoner := inference.NewReasoner(tx)
+		if _, err := reasoner.RefreshSubstance(substance.ID); err != nil {
+			return err
+		}
+		if !req.AutoClassify {
+			return auth.NewAuthorizer(tx).AuthorizeKinds(auth.ActionCreate, auth.ResourceSubstance, substance.Kind)
+		}
+
+		kind, err := classification.NewClassifier(tx).AssignKind(substance.ID)
+		if errors.Is(err, classification.ErrNoMatchingKind) && substance.Kind != "" {
+			return auth.NewAuthorizer(tx).AuthorizeKinds(auth.ActionCreate, auth.ResourceSubstance, substance.Kind)
+		}
+		if err != nil {
+			return err
+		}
+		substance.Kind = kind
+		if _, err := reasoner.RefreshSubstance(substance.ID); err != nil {
+			return err
+		}
+		return auth.NewAuthorizer(tx).AuthorizeKinds(auth.ActionCreate, auth.ResourceSubstance, substance.Kind)
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			authError(c, err)
+			return
+		}
+		if errors.Is(err, classification.ErrNoMatchingKind) || errors.Is(err, classification.ErrAmbiguousKind) ||
+			errors.Is(err, causality.ErrEntityNotFound) || errors.Is(err, causality.ErrInvalidRelation) ||
+			errors.Is(err, cloning.ErrTemplateNotFound) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, causality.ErrConflictingMode) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repository.ErrNoDatabase) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -94,28 +236,42 @@ func (h *Handler) UpdateSubstance(c *gin.Context) {
 		return
 	}
 
-	var substance entities.Substance
-	if err := h.DB.First(&substance, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
-			return
-		}
+	found, err := h.Store.Substances.Find(c.Request.Context(), []string{id})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(found) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+		return
+	}
+	substance := found[0]
+	// Changing the kind needs permission for both the old and the new one
+	kinds := []string{substance.Kind}
+	if req.Kind != nil && *req.Kind != substance.Kind {
+		kinds = append(kinds, *req.Kind)
+	}
+	if !h.authorizeKinds(c, auth.ActionUpdate, auth.ResourceSubstance, kinds...) {
+		return
+	}
 
-	updates := make(map[string]interface{})
 	if req.Name != nil {
-		updates["name"] = *req.Name
+		substance.Name = *req.Name
 	}
 	if req.Kind != nil {
-		updates["kind"] = *req.Kind
+		substance.Kind = *req.Kind
 	}
 	if req.Essence != nil {
-		updates["essence"] = *req.Essence
+		substance.Essence = *req.Essence
 	}
 
-	if err := h.DB.Model(&substance).Updates(updates).Error; err != nil {
+	err = h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		if err := store.Substances.Update(c.Request.Context(), &substance); err != nil {
+			return err
+		}
+		return refresh(store, substance.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -123,22 +279,74 @@ func (h *Handler) UpdateSubstance(c *gin.Context) {
 	c.JSON(http.StatusOK, substance)
 }
 
-// DeleteSubstance deletes a substance
+// DeleteSubstance deletes a substance. Entities depending on it block the deletion
+// unless ?dependents=cascade, which deletes them too.
 func (h *Handler) DeleteSubstance(c *gin.Context) {
+	h.deleteEntity(c, entities.EntityTypeSubstance)
+}
+
+// deleteEntity deletes the entity of the given type named by the :id
+// parameter, honouring ?dependents=block|cascade. The principal must be
+// allowed to delete every entity the deletion removes.
+func (h *Handler) deleteEntity(c *gin.Context, entityType string) {
 	id := c.Param("id")
-	if err := h.DB.Delete(&entities.Substance{}, "id = ?", id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	policy := c.DefaultQuery("dependents", dependence.PolicyBlock)
+	if policy != dependence.PolicyBlock && policy != dependence.PolicyCascade {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dependents must be one of: block, cascade"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "substance deleted"})
+	if !h.authorizeEntities(c, auth.ActionDelete, causality.EntityRef{ID: id, Type: entityType}) {
+		return
+	}
+
+	var plan *dependence.Deletion
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		tracker := dependence.NewTracker(tx)
+		var err error
+		if plan, err = tracker.PlanDeletion(entityType, id, policy); err != nil {
+			return err
+		}
+		// Cascading deletes dependents, which the principal must be allowed to delete too
+		if err := auth.NewAuthorizer(tx).AuthorizeEntities(auth.ActionDelete, plan.Entities); err != nil {
+			return err
+		}
+		affected, err := tracker.Execute(plan)
+		if err != nil {
+			return err
+		}
+		// Wholes of deleted parts and bearers of deleted modes may derive differently
+		reasoner := inference.NewReasoner(tx)
+		for _, substanceID := range affected {
+			if _, err := reasoner.RefreshSubstance(substanceID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, dependence.ErrHasDependents) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "dependents": plan.Dependents})
+			return
+		}
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": entityType + " deleted", "deleted": plan.Entities})
 }
 
 // Kinds handlers
 
 // GetKinds returns all kinds
 func (h *Handler) GetKinds(c *gin.Context) {
-	var kinds []entities.Kind
-	if err := h.DB.Find(&kinds).Error; err != nil {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceKind) {
+		return
+	}
+	kinds, err := h.Store.Kinds.List(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -150,6 +358,7 @@ func (h *Handler) CreateKind(c *gin.Context) {
 	var req struct {
 		Name        string `json:"name" binding:"required"`
 		Description string `json:"description"`
+		Definition  string `json:"definition"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -157,8 +366,23 @@ func (h *Handler) CreateKind(c *gin.Context) {
 		return
 	}
 
+	if !h.authorizeKinds(c, auth.ActionCreate, auth.ResourceKind, req.Name) {
+		return
+	}
+	if req.Definition != "" {
+		if _, err := classification.ParseDefinition(req.Definition); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	kind := entities.NewKind(req.Name, req.Description)
-	if err := h.DB.Create(kind).Error; err != nil {
+	kind.Definition = req.Definition
+	if err := h.Store.Kinds.Create(c.Request.Context(), kind); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a kind named " + req.Name + " already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -166,12 +390,22 @@ func (h *Handler) CreateKind(c *gin.Context) {
 	c.JSON(http.StatusCreated, kind)
 }
 
+// DeleteKind deletes a kind. Entities depending on it block the deletion
+// unless ?dependents=cascade, which deletes them too. Substances of the kind
+// keep its name.
+func (h *Handler) DeleteKind(c *gin.Context) {
+	h.deleteEntity(c, entities.EntityTypeKind)
+}
+
 // Attributes handlers
 
 // GetAttributes returns all attributes
 func (h *Handler) GetAttributes(c *gin.Context) {
-	var attributes []entities.Attribute
-	if err := h.DB.Find(&attributes).Error; err != nil {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceAttribute) {
+		return
+	}
+	attributes, err := h.Store.Attributes.List(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -181,9 +415,11 @@ func (h *Handler) GetAttributes(c *gin.Context) {
 // CreateAttribute creates a new attribute
 func (h *Handler) CreateAttribute(c *gin.Context) {
 	var req struct {
-		Name        string `json:"name" binding:"required"`
-		Description string `json:"description"`
-		DataType    string `json:"data_type" binding:"required"`
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		DataType    string   `json:"data_type" binding:"required"`
+		Cardinality string   `json:"cardinality" binding:"omitempty,oneof=single multiple"`
+		Roles       []string `json:"roles"` // bearer roles, in order, making the attribute relational
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -191,8 +427,25 @@ func (h *Handler) CreateAttribute(c *gin.Context) {
 		return
 	}
 
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceAttribute) {
+		return
+	}
+
 	attribute := entities.NewAttribute(req.Name, req.Description, req.DataType)
-	if err := h.DB.Create(attribute).Error; err != nil {
+	if req.Cardinality != "" {
+		attribute.Cardinality = req.Cardinality
+	}
+	if len(req.Roles) > 0 {
+		if err := attribute.SetBearerRoles(req.Roles); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := h.Store.Attributes.Create(c.Request.Context(), attribute); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an attribute named " + req.Name + " already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -204,8 +457,11 @@ func (h *Handler) CreateAttribute(c *gin.Context) {
 
 // GetModes returns all modes
 func (h *Handler) GetModes(c *gin.Context) {
-	var modes []entities.Mode
-	if err := h.DB.Preload("Substance").Preload("Attribute").Find(&modes).Error; err != nil {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceMode) {
+		return
+	}
+	modes, err := h.Store.Modes.List(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -225,8 +481,26 @@ func (h *Handler) CreateMode(c *gin.Context) {
 		return
 	}
 
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceMode, req.SubstanceID) {
+		return
+	}
+
 	mode := entities.NewMode(req.Value, req.SubstanceID, req.AttributeID)
-	if err := h.DB.Create(mode).Error; err != nil {
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		if err := causality.NewEngineWith(c.Request.Context(), store).AssertMode(mode); err != nil {
+			return err
+		}
+		return refresh(store, mode.SubstanceID)
+	})
+	if err != nil {
+		if errors.Is(err, causality.ErrEntityNotFound) || errors.Is(err, causality.ErrInvalidRelation) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, causality.ErrConflictingMode) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -234,12 +508,21 @@ func (h *Handler) CreateMode(c *gin.Context) {
 	c.JSON(http.StatusCreated, mode)
 }
 
+// DeleteMode deletes a mode. Entities depending on it block the deletion
+// unless ?dependents=cascade, which deletes them too.
+func (h *Handler) DeleteMode(c *gin.Context) {
+	h.deleteEntity(c, entities.EntityTypeMode)
+}
+
 // Causality handlers
 
 // GetCauses returns the four causes for a substance
 func (h *Handler) GetCauses(c *gin.Context) {
 	id := c.Param("id")
-	causes, err := h.CausalityEngine.GetFourCauses(id)
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation, id) {
+		return
+	}
+	causes, err := h.engine(c).GetFourCauses(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -247,12 +530,38 @@ func (h *Handler) GetCauses(c *gin.Context) {
 	c.JSON(http.StatusOK, causes)
 }
 
+// GetCausalRelations lists causal relations, filtered by entity, cause type, strength and validity
+func (h *Handler) GetCausalRelations(c *gin.Context) {
+	filter, ok := causalFilter(c)
+	if !ok {
+		return
+	}
+	filter.EntityID = c.Query("entity_id")
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+
+	relations, err := h.engine(c).ListCausalRelations(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"causal_relations": relations})
+}
+
 // AddCause adds a causal relation
 func (h *Handler) AddCause(c *gin.Context) {
 	var req struct {
-		FromEntity string `json:"from_entity" binding:"required"`
-		ToEntity   string `json:"to_entity" binding:"required"`
-		CauseType  string `json:"cause_type" binding:"required"`
+		FromEntity string     `json:"from_entity" binding:"required"`
+		ToEntity   string     `json:"to_entity" binding:"required"`
+		CauseType  string     `json:"cause_type" binding:"required"`
+		FromType   string     `json:"from_type" binding:"omitempty,oneof=substance kind attribute mode potentiality actuality external"`
+		ToType     string     `json:"to_type" binding:"omitempty,oneof=substance kind attribute mode potentiality actuality external"`
+		Strength   *float64   `json:"strength" binding:"omitempty,gt=0,lte=1"`
+		Evidence   string     `json:"evidence"`
+		Notes      string     `json:"notes"`
+		ValidFrom  *time.Time `json:"valid_from"`
+		ValidUntil *time.Time `json:"valid_until"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -260,8 +569,31 @@ func (h *Handler) AddCause(c *gin.Context) {
 		return
 	}
 
-	relation, err := h.CausalityEngine.AddCausalRelation(req.FromEntity, req.ToEntity, req.CauseType)
+	if req.ValidFrom != nil && req.ValidUntil != nil && req.ValidUntil.Before(*req.ValidFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_until must not be before valid_from"})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceCausalRelation, req.FromEntity, req.ToEntity) {
+		return
+	}
+
+	relation, err := h.engine(c).RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: req.FromEntity,
+		ToEntity:   req.ToEntity,
+		CauseType:  req.CauseType,
+		FromType:   req.FromType,
+		ToType:     req.ToType,
+		Strength:   req.Strength,
+		Evidence:   req.Evidence,
+		Notes:      req.Notes,
+		ValidFrom:  req.ValidFrom,
+		ValidUntil: req.ValidUntil,
+	})
 	if err != nil {
+		if errors.Is(err, causality.ErrEntityNotFound) || errors.Is(err, causality.ErrCauseTypeRule) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -273,8 +605,11 @@ func (h *Handler) AddCause(c *gin.Context) {
 
 // GetPotentialities returns all potentialities
 func (h *Handler) GetPotentialities(c *gin.Context) {
-	var potentialities []entities.Potentiality
-	if err := h.DB.Preload("Substance").Find(&potentialities).Error; err != nil {
+	if !h.authorize(c, auth.ActionRead, auth.ResourcePotentiality) {
+		return
+	}
+	potentialities, err := h.Store.Potentialities.List(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -295,8 +630,16 @@ func (h *Handler) CreatePotentiality(c *gin.Context) {
 		return
 	}
 
-	potentiality, err := h.CausalityEngine.CreatePotentiality(req.Name, req.Description, req.Conditions, req.SubstanceID)
+	if !h.authorize(c, auth.ActionCreate, auth.ResourcePotentiality, req.SubstanceID) {
+		return
+	}
+
+	potentiality, err := h.engine(c).CreatePotentiality(req.Name, req.Description, req.Conditions, req.SubstanceID)
 	if err != nil {
+		if errors.Is(err, causality.ErrInvalidConditions) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -316,7 +659,11 @@ func (h *Handler) ActualizePotentiality(c *gin.Context) {
 		return
 	}
 
-	actuality, err := h.CausalityEngine.ActualizePotentiality(id, req.Description)
+	if !h.authorize(c, auth.ActionActualize, auth.ResourcePotentiality, id) {
+		return
+	}
+
+	actuality, err := h.engine(c).ActualizePotentiality(id, req.Description)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -328,7 +675,10 @@ func (h *Handler) ActualizePotentiality(c *gin.Context) {
 // GetSubstanceEvolution returns the evolution of a substance
 func (h *Handler) GetSubstanceEvolution(c *gin.Context) {
 	id := c.Param("id")
-	evolution, err := h.CausalityEngine.GetSubstanceEvolution(id)
+	if !h.authorize(c, auth.ActionRead, auth.ResourceSubstance, id) {
+		return
+	}
+	evolution, err := h.engine(c).GetSubstanceEvolution(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -339,7 +689,10 @@ func (h *Handler) GetSubstanceEvolution(c *gin.Context) {
 // CheckConditions checks if conditions for a potentiality are met
 func (h *Handler) CheckConditions(c *gin.Context) {
 	id := c.Param("id")
-	canActualize, unmetConditions, err := h.CausalityEngine.CheckConditions(id)
+	if !h.authorize(c, auth.ActionRead, auth.ResourcePotentiality, id) {
+		return
+	}
+	canActualize, unmetConditions, err := h.engine(c).CheckConditions(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/persistence"
+	"github.com/apodicticscott/oaas/internal/version"
+	"github.com/gin-gonic/gin"
+)
+
+// Health, liveness and readiness handlers
+
+// probeTimeout bounds each readiness check, so that a hung database fails
+// the probe rather than the prober
+const probeTimeout = 2 * time.Second
+
+// check is the outcome of one readiness check
+type check struct {
+	Status     string  `json:"status"` // ok or failed
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Drain marks the server as shutting down. Readiness fails from then on, so
+// that load balancers stop sending requests while those in flight finish.
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+// readiness runs the readiness checks: the store's database, if it has one,
+// answers and its schema is up to date
+func (h *Handler) readiness(ctx context.Context) (bool, map[string]check) {
+	checks := make(map[string]check)
+	ready := true
+	run := func(name string, fn func(context.Context) error) {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		start := time.Now()
+		result := check{Status: "ok"}
+		if err := fn(ctx); err != nil {
+			result = check{Status: "failed", Error: err.Error()}
+			ready = false
+		}
+		result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+		checks[name] = result
+	}
+
+	db := h.Store.DB()
+	if db == nil {
+		return ready, checks
+	}
+	run("database", func(ctx context.Context) error { return persistence.Ping(ctx, db) })
+	if ready {
+		run("schema", func(ctx context.Context) error { return persistence.CheckSchema(ctx, db) })
+	}
+	return ready, checks
+}
+
+// HealthCheck returns the health status of the API: healthy while its
+// database answers
+func (h *Handler) HealthCheck(c *gin.Context) {
+	if ready, checks := h.readiness(c.Request.Context()); !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "unhealthy",
+			"message": "OaaS API cannot serve requests",
+			"checks":  checks,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "healthy",
+		"message": "OaaS API is running",
+	})
+}
+
+// Livez answers as long as the process serves HTTP, for restarting it when it does not
+func (h *Handler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// Readyz answers 200 while the server can serve requests, and 503 while its
+// database is unreachable or outdated or the server is shutting down
+func (h *Handler) Readyz(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	ready, checks := h.readiness(c.Request.Context())
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks, "version": version.Get().Version})
+}
+
+// GetVersion returns the build information of the server
+func (h *Handler) GetVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, version.Get())
+}
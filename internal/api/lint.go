@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/lint"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Consistency checking handlers
+
+// lintOptions reads the ?severity= filter shared by the lint endpoints
+func lintOptions(c *gin.Context) (lint.Options, bool) {
+	var opts lint.Options
+	if raw := c.Query("severity"); raw != "" {
+		severity, err := lint.ParseSeverity(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return opts, false
+		}
+		opts.MinSeverity = severity
+	}
+	return opts, true
+}
+
+// GetLintReport scans the whole store for inconsistencies (?severity=error|warning|info)
+func (h *Handler) GetLintReport(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceLint) {
+		return
+	}
+	opts, ok := lintOptions(c)
+	if !ok {
+		return
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	report, err := lint.Run(db, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RepairLintIssues scans the store and applies every safe repair, all or nothing
+func (h *Handler) RepairLintIssues(c *gin.Context) {
+	if !h.authorize(c, auth.ActionUpdate, auth.ResourceLint) {
+		return
+	}
+	opts, ok := lintOptions(c)
+	if !ok {
+		return
+	}
+	opts.Repair = true
+
+	var report *lint.Report
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		report, err = lint.Run(tx, opts)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
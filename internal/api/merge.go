@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/merge"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Merge handlers
+
+// MergeSubstance folds a duplicate substance into the one in the path, which survives.
+// Under the reject strategy, conflicting single-valued modes fail the merge with 409.
+func (h *Handler) MergeSubstance(c *gin.Context) {
+	var req struct {
+		DuplicateID string `json:"duplicate_id" binding:"required"`
+		Strategy    string `json:"strategy" binding:"omitempty,oneof=survivor duplicate newest reject"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionUpdate, auth.ResourceSubstance, c.Param("id")) {
+		return
+	}
+	if !h.authorize(c, auth.ActionDelete, auth.ResourceSubstance, req.DuplicateID) {
+		return
+	}
+
+	var result *merge.Result
+	var refreshed *inference.Result
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		if result, err = merge.NewMerger(tx).Merge(c.Param("id"), req.DuplicateID, req.Strategy); err != nil {
+			return err
+		}
+		// The survivor's rules now see the duplicate's facts too
+		refreshed, err = inference.NewReasoner(tx).RefreshSubstance(result.Survivor.ID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, merge.ErrSubstanceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, merge.ErrMergeConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": result.Summary.Conflicts})
+		case errors.Is(err, merge.ErrInvalidMerge):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"survivor":  result.Survivor,
+		"merge":     result.Merge,
+		"summary":   result.Summary,
+		"derived":   refreshed.Derived,
+		"retracted": refreshed.Retracted,
+	})
+}
+
+// GetSubstanceMerges lists the merges a substance took part in, as survivor or duplicate
+func (h *Handler) GetSubstanceMerges(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceSubstance, c.Param("id")) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	merges, err := merge.NewMerger(db).History(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"merges": merges})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Request metrics
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "oaas_http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, by method, route pattern and status code.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"method", "route", "status"})
+	requestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "oaas_http_requests_in_flight",
+		Help: "HTTP requests being served.",
+	})
+)
+
+// Instrument is middleware timing each request by its route pattern, so that
+// /api/v1/substances/:id is one series however many substances there are.
+// Requests matching no route are counted together as "unmatched".
+func (h *Handler) Instrument(c *gin.Context) {
+	start := time.Now()
+	requestsInFlight.Inc()
+	defer requestsInFlight.Dec()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	requestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+}
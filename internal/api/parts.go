@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/mereology"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Mereology handlers
+
+// parthoodError writes the response for an error from the mereology
+func parthoodError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mereology.ErrSubstanceNotFound), errors.Is(err, mereology.ErrParthoodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, mereology.ErrParthoodCycle):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, mereology.ErrDuplicateParthood):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// AddPart makes a substance a direct part of the substance in the path
+func (h *Handler) AddPart(c *gin.Context) {
+	wholeID := c.Param("id")
+	var req struct {
+		PartID string `json:"part_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourcePart, wholeID, req.PartID) {
+		return
+	}
+
+	var response gin.H
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		parthood, err := mereology.NewMereology(tx).AddPart(req.PartID, wholeID)
+		if err != nil {
+			return err
+		}
+		// The whole's conditions may quantify over its new part
+		result, err := inference.NewReasoner(tx).RefreshSubstance(wholeID)
+		if err != nil {
+			return err
+		}
+		response = gin.H{"parthood": parthood, "derived": result.Derived, "retracted": result.Retracted}
+		return nil
+	})
+	if err != nil {
+		parthoodError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// RemovePart ends a direct parthood between the substances in the path
+func (h *Handler) RemovePart(c *gin.Context) {
+	wholeID, partID := c.Param("id"), c.Param("part_id")
+	if !h.authorize(c, auth.ActionDelete, auth.ResourcePart, wholeID, partID) {
+		return
+	}
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		if err := mereology.NewMereology(tx).RemovePart(partID, wholeID); err != nil {
+			return err
+		}
+		_, err := inference.NewReasoner(tx).RefreshSubstance(wholeID)
+		return err
+	})
+	if err != nil {
+		parthoodError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "part removed"})
+}
+
+// GetParts lists a substance's direct parts, or all of them with ?transitive=true
+func (h *Handler) GetParts(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourcePart, c.Param("id")) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	parts, err := mereology.NewMereology(db).Parts(c.Param("id"), c.Query("transitive") == "true")
+	if err != nil {
+		parthoodError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"parts": parts})
+}
+
+// GetWholes lists the wholes a substance is directly part of, or all of them with ?transitive=true
+func (h *Handler) GetWholes(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourcePart, c.Param("id")) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	wholes, err := mereology.NewMereology(db).Wholes(c.Param("id"), c.Query("transitive") == "true")
+	if err != nil {
+		parthoodError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wholes": wholes})
+}
+
+// AggregateParts summarizes an attribute over a substance's parts (?attribute=, ?kind= to narrow the parts)
+func (h *Handler) AggregateParts(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourcePart, c.Param("id")) {
+		return
+	}
+	attribute := c.Query("attribute")
+	if attribute == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attribute is required"})
+		return
+	}
+
+	facts, err := h.engine(c).LoadFacts(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, facts.AggregateParts(attribute, c.Query("kind")))
+}
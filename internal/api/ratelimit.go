@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limiting middleware
+
+// Response headers telling a client how much of its daily quota is left
+const (
+	QuotaLimitHeader     = "X-Quota-Limit"
+	QuotaRemainingHeader = "X-Quota-Remaining"
+)
+
+// client names who a request counts against: its principal or, without
+// credentials, its address
+func client(c *gin.Context) string {
+	if principal := auth.FromContext(c.Request.Context()); principal != nil {
+		return principal.ID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// seconds rounds a duration up to whole seconds, as rate limit headers give them
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ThrottleAddress is middleware holding each address to a rate limit of its
+// own. It goes before Authenticate, so that requests with bad credentials are
+// limited too and cost no credential lookup past the limit.
+func (h *Handler) ThrottleAddress(c *gin.Context) {
+	if h.RateLimits == nil {
+		c.Next()
+		return
+	}
+	throttle(c, h.RateLimits.Addresses, "ip:"+c.ClientIP())
+}
+
+// Throttle is middleware counting the request against its client's rate
+// limit and daily quota, refusing it with a 429 past either. Only requests
+// the rate limit admits count against the quota. It goes after Authenticate,
+// so that a key's requests count against the key, and puts the client in the
+// request's context for the limits GraphQL fields apply.
+func (h *Handler) Throttle(c *gin.Context) {
+	if h.RateLimits == nil {
+		c.Next()
+		return
+	}
+	who := client(c)
+	c.Request = c.Request.WithContext(ratelimit.WithClient(c.Request.Context(), who))
+	if !admit(c, h.RateLimits.Requests, who) {
+		return
+	}
+	if quotas := h.RateLimits.Quotas; quotas != nil {
+		usage, err := quotas.Use(who)
+		switch {
+		case errors.Is(err, ratelimit.ErrQuotaExceeded):
+			c.Header(QuotaLimitHeader, strconv.FormatInt(usage.Limit, 10))
+			c.Header(QuotaRemainingHeader, "0")
+			c.Header("Retry-After", seconds(usage.Reset))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header(QuotaLimitHeader, strconv.FormatInt(usage.Limit, 10))
+		c.Header(QuotaRemainingHeader, strconv.FormatInt(usage.Remaining(), 10))
+	}
+	c.Next()
+}
+
+// ThrottleExpensive is middleware holding expensive routes to their own,
+// stricter rate limit on top of the one Throttle applies
+func (h *Handler) ThrottleExpensive(c *gin.Context) {
+	if h.RateLimits == nil {
+		c.Next()
+		return
+	}
+	throttle(c, h.RateLimits.Expensive, client(c))
+}
+
+// throttle serves the request if the limiter admits it
+func throttle(c *gin.Context, limiter *ratelimit.Limiter, who string) {
+	if admit(c, limiter, who) {
+		c.Next()
+	}
+}
+
+// admit takes a token for the client from the limiter, describing the bucket
+// in RateLimit-* headers, and refuses the request with a 429 if there is none
+func admit(c *gin.Context, limiter *ratelimit.Limiter, who string) bool {
+	if limiter == nil {
+		return true
+	}
+	decision := limiter.Allow(who)
+	c.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	c.Header("RateLimit-Reset", seconds(decision.Reset))
+	if !decision.Allowed {
+		c.Header("Retry-After", seconds(decision.RetryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf("rate limit exceeded: retry in %ss", seconds(decision.RetryAfter)),
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Relational mode handlers
+
+// CreateRelation asserts a relational mode borne by several substances in the attribute's roles
+func (h *Handler) CreateRelation(c *gin.Context) {
+	var req struct {
+		AttributeID string `json:"attribute_id" binding:"required"`
+		Value       string `json:"value"`
+		Bearers     []struct {
+			SubstanceID string `json:"substance_id" binding:"required"`
+			Role        string `json:"role"`
+		} `json:"bearers" binding:"required,min=2,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := causality.RelationInput{AttributeID: req.AttributeID, Value: req.Value}
+	var bearerIDs []string
+	for _, bearer := range req.Bearers {
+		input.Bearers = append(input.Bearers, causality.BearerInput{SubstanceID: bearer.SubstanceID, Role: bearer.Role})
+		bearerIDs = append(bearerIDs, bearer.SubstanceID)
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceMode, bearerIDs...) {
+		return
+	}
+
+	var mode *entities.Mode
+	var result *inference.Result
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		if mode, err = causality.NewEngineWith(c.Request.Context(), store).AssertRelation(input); err != nil {
+			return err
+		}
+		// Refreshing the first bearer refreshes the others too
+		result, err = inference.NewReasoner(tx).RefreshSubstance(mode.SubstanceID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, causality.ErrEntityNotFound), errors.Is(err, causality.ErrInvalidRelation):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, causality.ErrDuplicateRelation):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"relation": mode, "derived": result.Derived, "retracted": result.Retracted})
+}
+
+// GetRelations lists relational modes (?substance_id=, ?role= with it, ?attribute_id=)
+func (h *Handler) GetRelations(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceMode) {
+		return
+	}
+	filter := causality.RelationFilter{
+		SubstanceID: c.Query("substance_id"),
+		Role:        c.Query("role"),
+		AttributeID: c.Query("attribute_id"),
+	}
+	if filter.Role != "" && filter.SubstanceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role requires substance_id"})
+		return
+	}
+
+	relations, err := h.engine(c).ListRelations(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"relations": relations})
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Inference rule handlers
+
+// GetRules returns all rules in the order they are applied
+func (h *Handler) GetRules(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceRule) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	rules, err := inference.NewReasoner(db).ListRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// GetRule returns a rule with the modes it currently derives
+func (h *Handler) GetRule(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceRule) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	reasoner := inference.NewReasoner(db)
+	rule, err := reasoner.GetRule(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, inference.ErrRuleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	derived, err := reasoner.DerivedModes(rule.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rule": rule, "derived_modes": derived})
+}
+
+// CreateRule creates a rule and applies it to every substance
+func (h *Handler) CreateRule(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Conditions  string `json:"conditions" binding:"required"`
+		AttributeID string `json:"attribute_id" binding:"required"`
+		Value       string `json:"value" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceRule) {
+		return
+	}
+
+	var (
+		rule   *entities.Rule
+		result *inference.Result
+	)
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		rule, result, err = inference.NewReasoner(tx).CreateRule(inference.RuleInput{
+			Name:        req.Name,
+			Description: req.Description,
+			Conditions:  req.Conditions,
+			AttributeID: req.AttributeID,
+			Value:       req.Value,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, inference.ErrInvalidRule) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"rule": rule, "inference": result})
+}
+
+// DeleteRule deletes a rule and retracts the modes it derived
+func (h *Handler) DeleteRule(c *gin.Context) {
+	if !h.authorize(c, auth.ActionDelete, auth.ResourceRule) {
+		return
+	}
+	var result *inference.Result
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		result, err = inference.NewReasoner(tx).DeleteRule(c.Param("id"))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, inference.ErrRuleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "rule deleted", "inference": result})
+}
+
+// RunRules re-evaluates every rule against every substance
+func (h *Handler) RunRules(c *gin.Context) {
+	if !h.authorize(c, auth.ActionUpdate, auth.ResourceRule) {
+		return
+	}
+	var result *inference.Result
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		result, err = inference.NewReasoner(tx).RefreshAll()
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"inference": result})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/telemetry"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request tracing and logging middleware
+
+// RequestIDHeader carries a request's ID, taken from the client when it
+// sends a usable one and generated otherwise
+const RequestIDHeader = "X-Request-ID"
+
+var tracer = otel.Tracer("github.com/apodicticscott/oaas/internal/api")
+
+// requestID returns the client's request ID if it is short, printable
+// ASCII, or a new one
+func requestID(c *gin.Context) string {
+	id := c.GetHeader(RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		return uuid.New().String()
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return uuid.New().String()
+		}
+	}
+	return id
+}
+
+// Trace is middleware giving each request an ID, echoed in the response,
+// and a server span continuing the caller's trace, both carried by the
+// request's context into handlers, resolvers, the engine and GORM. Once the
+// request is served it is logged, as an error if it failed.
+func (h *Handler) Trace(c *gin.Context) {
+	start := time.Now()
+	id := requestID(c)
+	c.Header(RequestIDHeader, id)
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	ctx = telemetry.WithRequestID(ctx, id)
+	ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path),
+			attribute.String("client.address", c.ClientIP()),
+			attribute.String("oaas.request_id", id),
+		))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+
+	// the principal is known once authentication has run
+	attrs := []any{
+		"method", c.Request.Method,
+		"route", route,
+		"path", c.Request.URL.Path,
+		"status", status,
+		"duration", time.Since(start),
+		"client", c.ClientIP(),
+	}
+	if principal := auth.FromContext(c.Request.Context()); principal != nil {
+		attrs = append(attrs, "principal", principal.Name)
+		span.SetAttributes(attribute.String("enduser.id", principal.ID))
+	}
+	if len(c.Errors) > 0 {
+		attrs = append(attrs, "errors", c.Errors.String())
+	}
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+	slog.Log(c.Request.Context(), level, "request served", attrs...)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/cloning"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Template and cloning handlers
+
+// templateError writes the response for an error from the cloner
+func templateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, cloning.ErrSubstanceNotFound), errors.Is(err, cloning.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, cloning.ErrDuplicateTemplate), errors.Is(err, causality.ErrConflictingMode):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, cloning.ErrInvalidTemplate), errors.Is(err, cloning.ErrInvalidClone),
+		errors.Is(err, causality.ErrEntityNotFound), errors.Is(err, causality.ErrInvalidRelation):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// CloneSubstance creates a copy of a substance with its modes; a deep clone
+// also copies its potentialities and the selected causal relations
+func (h *Handler) CloneSubstance(c *gin.Context) {
+	var req struct {
+		Name     string   `json:"name"`
+		Kind     string   `json:"kind"`
+		Essence  string   `json:"essence"`
+		Deep     bool     `json:"deep"`
+		CauseIDs []string `json:"cause_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A clone has the kind asked for, or else its original's
+	if req.Kind != "" && !h.authorizeKinds(c, auth.ActionCreate, auth.ResourceSubstance, req.Kind) {
+		return
+	}
+	if req.Kind == "" && !h.authorize(c, auth.ActionCreate, auth.ResourceSubstance, c.Param("id")) {
+		return
+	}
+
+	options := cloning.CloneOptions{Name: req.Name, Kind: req.Kind, Essence: req.Essence, Deep: req.Deep, CauseIDs: req.CauseIDs}
+	var clone *entities.Substance
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		var err error
+		if clone, err = cloning.NewCloner(tx).Clone(c.Param("id"), options); err != nil {
+			return err
+		}
+		_, err = inference.NewReasoner(tx).RefreshSubstance(clone.ID)
+		return err
+	})
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, clone)
+}
+
+// GetTemplates returns all substance templates
+func (h *Handler) GetTemplates(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceTemplate) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	templates, err := cloning.NewCloner(db).Templates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate returns a substance template by ID or name
+func (h *Handler) GetTemplate(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceTemplate) {
+		return
+	}
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	template, err := cloning.NewCloner(db).Template(c.Param("id"))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// CreateTemplate defines a named substance template with default modes and potentialities
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Kind        string `json:"kind" binding:"required"`
+		Essence     string `json:"essence"`
+		Modes       []struct {
+			AttributeID string `json:"attribute_id" binding:"required"`
+			Value       string `json:"value" binding:"required"`
+		} `json:"modes" binding:"dive"`
+		Potentialities []struct {
+			Name        string `json:"name" binding:"required"`
+			Description string `json:"description"`
+			Conditions  string `json:"conditions"`
+		} `json:"potentialities" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceTemplate) {
+		return
+	}
+
+	input := cloning.TemplateInput{Name: req.Name, Description: req.Description, Kind: req.Kind, Essence: req.Essence}
+	for _, mode := range req.Modes {
+		input.Modes = append(input.Modes, cloning.ModeInput{AttributeID: mode.AttributeID, Value: mode.Value})
+	}
+	for _, potentiality := range req.Potentialities {
+		input.Potentialities = append(input.Potentialities, cloning.PotentialityInput{
+			Name:        potentiality.Name,
+			Description: potentiality.Description,
+			Conditions:  potentiality.Conditions,
+		})
+	}
+
+	db := h.db(c)
+	if db == nil {
+		return
+	}
+	template, err := cloning.NewCloner(db).CreateTemplate(input)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, template)
+}
+
+// DeleteTemplate deletes a substance template by ID or name
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	if !h.authorize(c, auth.ActionDelete, auth.ResourceTemplate) {
+		return
+	}
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.Transaction(c.Request.Context(), func(store *repository.Store) error {
+		tx := store.DB()
+		return cloning.NewCloner(tx).DeleteTemplate(c.Param("id"))
+	})
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "template deleted"})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/gin-gonic/gin"
+)
+
+// Causal traversal handlers
+
+// causalFilter reads ?cause_type= (repeatable or comma separated), ?min_strength= and ?valid_at= from the query
+func causalFilter(c *gin.Context) (causality.CausalFilter, bool) {
+	var filter causality.CausalFilter
+
+	for _, value := range c.QueryArray("cause_type") {
+		for _, causeType := range strings.Split(value, ",") {
+			causeType = strings.TrimSpace(causeType)
+			if causeType == "" {
+				continue
+			}
+			if !causality.IsValidCauseType(causeType) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cause type: " + causeType})
+				return filter, false
+			}
+			filter.CauseTypes = append(filter.CauseTypes, causeType)
+		}
+	}
+
+	if raw := c.Query("min_strength"); raw != "" {
+		strength, err := strconv.ParseFloat(raw, 64)
+		if err != nil || strength < 0 || strength > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_strength must be a number between 0 and 1"})
+			return filter, false
+		}
+		filter.MinStrength = strength
+	}
+
+	if raw := c.Query("valid_at"); raw != "" {
+		validAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "valid_at must be an RFC 3339 timestamp"})
+			return filter, false
+		}
+		filter.ValidAt = &validAt
+	}
+
+	return filter, true
+}
+
+// traversalOptions reads the causal filter plus ?depth= and ?weighted= from the query
+func traversalOptions(c *gin.Context) (causality.TraversalOptions, bool) {
+	var opts causality.TraversalOptions
+
+	filter, ok := causalFilter(c)
+	if !ok {
+		return opts, false
+	}
+	opts.CauseTypes = filter.CauseTypes
+	opts.MinStrength = filter.MinStrength
+	opts.ValidAt = filter.ValidAt
+
+	if raw := c.Query("depth"); raw != "" {
+		depth, err := strconv.Atoi(raw)
+		if err != nil || depth < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "depth must be a positive integer"})
+			return opts, false
+		}
+		opts.MaxDepth = depth
+	}
+
+	if raw := c.Query("weighted"); raw != "" {
+		weighted, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weighted must be a boolean"})
+			return opts, false
+		}
+		opts.Weighted = weighted
+	}
+
+	return opts, true
+}
+
+// GetAncestors returns the transitive causes of an entity
+func (h *Handler) GetAncestors(c *gin.Context) {
+	id := c.Param("id")
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation, id) {
+		return
+	}
+	opts, ok := traversalOptions(c)
+	if !ok {
+		return
+	}
+
+	ancestors, err := h.engine(c).GetAncestors(id, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entity_id": id, "ancestors": ancestors})
+}
+
+// GetDescendants returns the transitive effects of an entity
+func (h *Handler) GetDescendants(c *gin.Context) {
+	id := c.Param("id")
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation, id) {
+		return
+	}
+	opts, ok := traversalOptions(c)
+	if !ok {
+		return
+	}
+
+	descendants, err := h.engine(c).GetDescendants(id, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entity_id": id, "descendants": descendants})
+}
+
+// GetCausalPath returns the shortest causal path between two entities
+func (h *Handler) GetCausalPath(c *gin.Context) {
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+		return
+	}
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation, from, to) {
+		return
+	}
+
+	opts, ok := traversalOptions(c)
+	if !ok {
+		return
+	}
+
+	path, err := h.engine(c).FindCausalPath(from, to, opts)
+	if err != nil {
+		if errors.Is(err, causality.ErrNoCausalPath) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, path)
+}
+
+// GetCausalCycles returns the cycles in the causal graph
+func (h *Handler) GetCausalCycles(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceCausalRelation) {
+		return
+	}
+	opts, ok := traversalOptions(c)
+	if !ok {
+		return
+	}
+
+	cycles, err := h.engine(c).DetectCycles(opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cycles": cycles})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/workspace"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Workspace handlers
+
+// WorkspaceHeader selects the workspace of a request made outside /workspaces/:workspace
+const WorkspaceHeader = "X-Workspace"
+
+// workspaceError writes the response for an error from the workspace manager
+func workspaceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, workspace.ErrWorkspaceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, workspace.ErrDuplicateWorkspace), errors.Is(err, workspace.ErrWorkspaceNotEmpty),
+		errors.Is(err, workspace.ErrNameConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, workspace.ErrInvalidWorkspace):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// SelectWorkspace is middleware confining the request to the workspace named by
+// the :workspace path parameter or, failing that, the X-Workspace header. The
+// default workspace is used when neither is given; an unknown one is a 404.
+func (h *Handler) SelectWorkspace(c *gin.Context) {
+	ref := c.Param("workspace")
+	if ref == "" {
+		ref = c.GetHeader(WorkspaceHeader)
+	}
+	if ref == "" {
+		ref = workspace.FromContext(c.Request.Context())
+	}
+
+	if !h.requireDB(c) {
+		c.Abort()
+		return
+	}
+	selected, err := workspace.NewManager(h.Store.DB()).Get(ref)
+	if err != nil {
+		workspaceError(c, err)
+		c.Abort()
+		return
+	}
+	c.Request = c.Request.WithContext(workspace.WithID(c.Request.Context(), selected.ID))
+	c.Header(WorkspaceHeader, selected.Name)
+	c.Next()
+}
+
+// GetWorkspaces returns all workspaces
+func (h *Handler) GetWorkspaces(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceWorkspace) {
+		return
+	}
+	if !h.requireDB(c) {
+		return
+	}
+	workspaces, err := workspace.NewManager(h.Store.DB()).List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// GetWorkspace returns a workspace by ID or name
+func (h *Handler) GetWorkspace(c *gin.Context) {
+	if !h.authorize(c, auth.ActionRead, auth.ResourceWorkspace) {
+		return
+	}
+	if !h.requireDB(c) {
+		return
+	}
+	found, err := workspace.NewManager(h.Store.DB()).Get(c.Param("workspace"))
+	if err != nil {
+		workspaceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, found)
+}
+
+// CreateWorkspace creates a workspace
+func (h *Handler) CreateWorkspace(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceWorkspace) {
+		return
+	}
+
+	if !h.requireDB(c) {
+		return
+	}
+	created, err := workspace.NewManager(h.Store.DB()).Create(req.Name, req.Description)
+	if err != nil {
+		workspaceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteWorkspace deletes an empty workspace
+func (h *Handler) DeleteWorkspace(c *gin.Context) {
+	if !h.authorize(c, auth.ActionDelete, auth.ResourceWorkspace) {
+		return
+	}
+	if !h.requireDB(c) {
+		return
+	}
+	if err := workspace.NewManager(h.Store.DB()).Delete(c.Param("workspace")); err != nil {
+		workspaceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "workspace deleted"})
+}
+
+// CopyToWorkspace copies kinds and attributes of the request's workspace into another
+func (h *Handler) CopyToWorkspace(c *gin.Context) {
+	var req struct {
+		To           string   `json:"to" binding:"required"`
+		KindIDs      []string `json:"kind_ids"`
+		AttributeIDs []string `json:"attribute_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceKind) {
+		return
+	}
+	if !h.authorize(c, auth.ActionCreate, auth.ResourceAttribute) {
+		return
+	}
+
+	var result *workspace.CopyResult
+	if !h.requireDB(c) {
+		return
+	}
+	err := h.Store.DB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = workspace.NewManager(tx).Copy(workspace.FromContext(c.Request.Context()), req.To, req.KindIDs, req.AttributeIDs)
+		return err
+	})
+	if err != nil {
+		workspaceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/workspace"
+	"gorm.io/gorm"
+)
+
+// Audited actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// auditTable is not audited itself
+const auditTable = "audit_records"
+
+// Record writes an audit record of an action by the principal db's context
+// carries. Nothing is recorded without a principal.
+func Record(db *gorm.DB, action, entityType, entityID string, rows int64) error {
+	principal := FromContext(db.Statement.Context)
+	if principal == nil {
+		return nil
+	}
+	record := entities.NewAuditRecord(principal.ID, action, entityType, entityID)
+	record.WorkspaceID = workspace.ID(db)
+	record.PrincipalName, record.Method, record.Rows = principal.Name, principal.Method, rows
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record audit: %w", err)
+	}
+	return nil
+}
+
+// AuditPlugin records every create, update and delete of a workspace entity
+// made for a principal, in the same transaction as the change
+type AuditPlugin struct{}
+
+// Name implements gorm.Plugin
+func (AuditPlugin) Name() string {
+	return "audit"
+}
+
+// Initialize implements gorm.Plugin
+func (AuditPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("audit:record", audit(ActionCreate)); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("audit:record", audit(ActionUpdate)); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("audit:record", audit(ActionDelete))
+}
+
+// audit returns a callback recording the statement's action, once per row it
+// names by primary key or once for all rows it chose by condition
+func audit(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		if db.Error != nil || db.RowsAffected == 0 || stmt.Schema == nil || stmt.Schema.Table == auditTable ||
+			stmt.Schema.LookUpField("WorkspaceID") == nil || FromContext(stmt.Context) == nil {
+			return
+		}
+
+		var ids []string
+		if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+			add := func(value reflect.Value) {
+				if id, zero := field.ValueOf(stmt.Context, value); !zero {
+					ids = append(ids, fmt.Sprint(id))
+				}
+			}
+			switch stmt.ReflectValue.Kind() {
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < stmt.ReflectValue.Len(); i++ {
+					add(reflect.Indirect(stmt.ReflectValue.Index(i)))
+				}
+			case reflect.Struct:
+				add(stmt.ReflectValue)
+			}
+		}
+
+		switch len(ids) {
+		case 0:
+			if err := Record(db, action, stmt.Schema.Table, "", db.RowsAffected); err != nil {
+				db.AddError(err)
+			}
+		case 1:
+			if err := Record(db, action, stmt.Schema.Table, ids[0], db.RowsAffected); err != nil {
+				db.AddError(err)
+			}
+		default:
+			for _, id := range ids {
+				if err := Record(db, action, stmt.Schema.Table, id, 1); err != nil {
+					db.AddError(err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// AuditFilter narrows the audit records listed; empty fields match everything
+type AuditFilter struct {
+	PrincipalID string
+	EntityType  string
+	EntityID    string
+	Limit       int
+}
+
+// AuditLog lists the audit records of a workspace
+type AuditLog struct {
+	db *gorm.DB
+}
+
+// NewAuditLog creates a new audit log reader
+func NewAuditLog(db *gorm.DB) *AuditLog {
+	return &AuditLog{db: db}
+}
+
+// Records returns the matching audit records, newest first
+func (l *AuditLog) Records(filter AuditFilter) ([]entities.AuditRecord, error) {
+	query := l.db.Order("created_at DESC, id")
+	if filter.PrincipalID != "" {
+		query = query.Where("principal_id = ?", filter.PrincipalID)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var records []entities.AuditRecord
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit records: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyHeader carries an API key; one may also be sent as a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// Authenticator finds the principal of a request from its API key or JWT
+type Authenticator struct {
+	keys     *Keys
+	verifier *Verifier // nil when JWTs are not accepted
+	// AnonymousReads lets requests without credentials read, but not write
+	AnonymousReads bool
+	// DefaultRoles are given to principals whose key or token names none
+	DefaultRoles []string
+}
+
+// NewAuthenticator creates a new authenticator accepting the API keys in db
+// and, unless verifier is nil, JWTs it verifies
+func NewAuthenticator(db *gorm.DB, verifier *Verifier) *Authenticator {
+	return &Authenticator{keys: NewKeys(db), verifier: verifier, DefaultRoles: []string{"editor"}}
+}
+
+// Authenticate returns the principal of the request's credentials, nil if it has none
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if a == nil {
+		return nil, nil
+	}
+	principal, err := a.authenticate(r)
+	if principal != nil && len(principal.Roles) == 0 {
+		principal.Roles = a.DefaultRoles
+	}
+	return principal, err
+}
+
+// authenticate is Authenticate before default roles are given
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	credential := r.Header.Get(APIKeyHeader)
+	if credential == "" {
+		if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+			credential = strings.TrimSpace(token)
+		}
+	}
+	if credential == "" {
+		return nil, nil
+	}
+
+	if IsAPIKey(credential) {
+		key, err := a.keys.Verify(credential)
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{ID: "key:" + key.ID, Name: key.Name, Method: MethodAPIKey, Admin: key.Admin, Roles: ParseRoles(key.Roles)}, nil
+	}
+	if a.verifier == nil {
+		return nil, fmt.Errorf("%w: only api keys are accepted", ErrInvalidCredentials)
+	}
+	claims, err := a.verifier.Verify(credential)
+	if err != nil {
+		return nil, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = claims.Subject
+	}
+	return &Principal{ID: "jwt:" + claims.Subject, Name: name, Method: MethodJWT, Admin: claims.Admin, Roles: claims.Roles}, nil
+}
+
+// Admits reports whether a request for the principal may go ahead; read
+// tells whether it only reads. A nil Authenticator admits every request.
+func (a *Authenticator) Admits(principal *Principal, read bool) bool {
+	return a == nil || principal != nil || (read && a.AnonymousReads)
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// PermissionError is returned when the principal lacks the permission an operation needs
+type PermissionError struct {
+	Principal string     `json:"principal"`
+	Roles     []string   `json:"roles"`
+	Missing   Permission `json:"missing_permission"`
+}
+
+func (e *PermissionError) Error() string {
+	roles := "no roles"
+	if len(e.Roles) > 0 {
+		roles = "roles " + strings.Join(e.Roles, ", ")
+	}
+	return fmt.Sprintf("%s: %s (%s) lacks permission to %s", ErrForbidden, e.Principal, roles, e.Missing)
+}
+
+// Unwrap makes a PermissionError match ErrForbidden
+func (e *PermissionError) Unwrap() error {
+	return ErrForbidden
+}
+
+// Authorizer decides whether the principal in a DB's context may perform an
+// operation. REST handlers and GraphQL resolvers ask it the same questions.
+type Authorizer struct {
+	db *gorm.DB
+}
+
+// NewAuthorizer creates a new authorizer for the principal in db's context,
+// looking up kinds in db's workspace
+func NewAuthorizer(db *gorm.DB) *Authorizer {
+	return &Authorizer{db: db}
+}
+
+// Authorize returns a *PermissionError unless the principal may perform the
+// action on the resource for every kind of the given entities: substances,
+// or the modes, potentialities and actualities of substances. Administrators
+// may do anything. Requests without a principal were already admitted by
+// authentication, which is either disabled or lets them read.
+func (a *Authorizer) Authorize(action, resource string, entityIDs ...string) error {
+	principal := FromContext(a.db.Statement.Context)
+	if principal == nil || principal.Admin {
+		return nil
+	}
+	kinds, err := a.kindsOf(entityIDs)
+	if err != nil {
+		return err
+	}
+	return a.check(principal, action, resource, kinds)
+}
+
+// AuthorizeKinds is Authorize for operations on the given kinds themselves,
+// or on substances of kinds named in the request
+func (a *Authorizer) AuthorizeKinds(action, resource string, kinds ...string) error {
+	principal := FromContext(a.db.Statement.Context)
+	if principal == nil || principal.Admin {
+		return nil
+	}
+	return a.check(principal, action, resource, kinds)
+}
+
+// AuthorizeEntities is Authorize for entities of mixed types, each type
+// checked as its own resource. Kinds are checked by name.
+func (a *Authorizer) AuthorizeEntities(action string, refs []causality.EntityRef) error {
+	principal := FromContext(a.db.Statement.Context)
+	if principal == nil || principal.Admin {
+		return nil
+	}
+	var types []string
+	ids := map[string][]string{}
+	for _, ref := range refs {
+		if _, ok := ids[ref.Type]; !ok {
+			types = append(types, ref.Type)
+		}
+		ids[ref.Type] = append(ids[ref.Type], ref.ID)
+	}
+	for _, entityType := range types {
+		if entityType == entities.EntityTypeKind {
+			var names []string
+			if err := a.db.Model(&entities.Kind{}).Where("id IN ?", ids[entityType]).Pluck("name", &names).Error; err != nil {
+				return fmt.Errorf("failed to get kinds: %w", err)
+			}
+			sort.Strings(names)
+			if err := a.check(principal, action, entityType, names); err != nil {
+				return err
+			}
+			continue
+		}
+		kinds, err := a.kindsOf(ids[entityType])
+		if err != nil {
+			return err
+		}
+		if err := a.check(principal, action, entityType, kinds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check requires some permission of the principal's roles to cover each
+// kind; an operation concerning no kind needs a permission without kinds
+func (a *Authorizer) check(principal *Principal, action, resource string, kinds []string) error {
+	var permissions []Permission
+	roles := NewRoles(a.db.Session(&gorm.Session{NewDB: true}))
+	for _, name := range principal.Roles {
+		role, err := roles.Get(name)
+		if err != nil {
+			// a role deleted since the key was issued grants nothing
+			continue
+		}
+		permissions = append(permissions, role.Permissions...)
+	}
+
+	allowed := func(kind string) bool {
+		for _, p := range permissions {
+			if p.allows(action, resource, kind) {
+				return true
+			}
+		}
+		return false
+	}
+	missing := Permission{Action: action, Resource: resource}
+	if len(kinds) == 0 {
+		if !allowed("") {
+			return &PermissionError{Principal: principal.Name, Roles: principal.Roles, Missing: missing}
+		}
+		return nil
+	}
+	for _, kind := range kinds {
+		if !allowed(kind) {
+			missing.Kinds = append(missing.Kinds, kind)
+		}
+	}
+	if len(missing.Kinds) > 0 {
+		return &PermissionError{Principal: principal.Name, Roles: principal.Roles, Missing: missing}
+	}
+	return nil
+}
+
+// kindsOf returns the distinct kinds of the substances among the IDs and of
+// the substances the other IDs belong to. Unknown IDs have no kind.
+func (a *Authorizer) kindsOf(ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	substanceIDs := append([]string{}, ids...)
+	for _, model := range []interface{}{&entities.Mode{}, &entities.Potentiality{}, &entities.Actuality{}} {
+		var owners []string
+		if err := a.db.Model(model).Where("id IN ?", ids).Pluck("substance_id", &owners).Error; err != nil {
+			return nil, fmt.Errorf("failed to get owning substances: %w", err)
+		}
+		substanceIDs = append(substanceIDs, owners...)
+	}
+	var kinds []string
+	if err := a.db.Model(&entities.Substance{}).Where("id IN ?", substanceIDs).Distinct().Pluck("kind", &kinds).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kinds: %w", err)
+	}
+	sort.Strings(kinds)
+	return kinds, nil
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Leeway is the clock skew allowed when checking a token's validity period
+const Leeway = time.Minute
+
+// algorithms are the JWS algorithms tokens may be signed with; "none" is not one
+var algorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+	"HS256", "HS384", "HS512",
+}
+
+// Claims are what a verified token says about its principal
+type Claims struct {
+	Subject   string
+	Name      string
+	Issuer    string
+	Audience  []string
+	Admin     bool
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+// verificationKey is a key tokens may be signed with
+type verificationKey struct {
+	id        string      // kid; a key without one is tried for any token
+	algorithm string      // alg the key is restricted to, if any
+	key       interface{} // *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or []byte
+}
+
+// Verifier checks JWTs against keys read from local files. Nothing is fetched
+// over the network: JWKS files are refreshed by replacing them and restarting.
+type Verifier struct {
+	keys     []verificationKey
+	issuer   string
+	audience string
+}
+
+// NewVerifier loads the keys in the files, each either PEM (public keys or
+// certificates) or a JWK set. Tokens must come from issuer and be meant for
+// audience unless these are empty.
+func NewVerifier(paths []string, issuer, audience string) (*Verifier, error) {
+	v := &Verifier{issuer: issuer, audience: audience}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		var keys []verificationKey
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			keys, err = parseJWKS(trimmed)
+		} else {
+			keys, err = parsePEM(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		v.keys = append(v.keys, keys...)
+	}
+	if len(v.keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", strings.Join(paths, ", "))
+	}
+	return v, nil
+}
+
+func parsePEM(data []byte) ([]verificationKey, error) {
+	var keys []verificationKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return keys, nil
+		}
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+			if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = certificate.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", strings.ToLower(block.Type), err)
+		}
+		keys = append(keys, verificationKey{key: key})
+	}
+}
+
+// jwk is a JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS reads a JWK set, or a single JWK. Keys for encryption are skipped.
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWK set: %w", err)
+	}
+	if set.Keys == nil {
+		var single jwk
+		if err := json.Unmarshal(data, &single); err != nil || single.Kty == "" {
+			return nil, fmt.Errorf("invalid JWK set: no keys")
+		}
+		set.Keys = []jwk{single}
+	}
+
+	var keys []verificationKey
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK %q: %w", k.Kid, err)
+		}
+		keys = append(keys, verificationKey{id: k.Kid, algorithm: k.Alg, key: key})
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 2 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("unusable RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("coordinates must be %d bytes", size)
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) < 32 {
+			return nil, fmt.Errorf("symmetric keys must be at least 32 bytes")
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// tokenClaims are the claims a token is parsed into
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Name  string     `json:"name"`
+	Admin bool       `json:"admin"`
+	Roles roleClaims `json:"roles"`
+}
+
+// Validate implements jwt.ClaimsValidator: a token must name its principal
+func (c tokenClaims) Validate() error {
+	if c.Subject == "" {
+		return errors.New("token has no subject")
+	}
+	return nil
+}
+
+// roleClaims are an array of role names or one string of them, as in an OAuth scope
+type roleClaims []string
+
+func (r *roleClaims) UnmarshalJSON(data []byte) error {
+	var list string
+	if json.Unmarshal(data, &list) == nil {
+		*r = ParseRoles(list)
+		return nil
+	}
+	var roles []string
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return errors.New("malformed roles")
+	}
+	*r = roles
+	return nil
+}
+
+// Verify checks a token's signature and validity and returns its claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(algorithms), jwt.WithExpirationRequired(), jwt.WithLeeway(Leeway)}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+	var parsed tokenClaims
+	if _, err := jwt.ParseWithClaims(token, &parsed, v.keysFor, options...); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return &Claims{
+		Subject:   parsed.Subject,
+		Name:      parsed.Name,
+		Issuer:    parsed.Issuer,
+		Audience:  parsed.Audience,
+		Admin:     parsed.Admin,
+		Roles:     parsed.Roles,
+		ExpiresAt: parsed.ExpiresAt.Time,
+	}, nil
+}
+
+// keysFor returns the keys that may have signed the token: those with its
+// kid, or with none, not restricted to another algorithm
+func (v *Verifier) keysFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	var set jwt.VerificationKeySet
+	for _, key := range v.keys {
+		if (key.id != "" && kid != "" && key.id != kid) || (key.algorithm != "" && key.algorithm != token.Method.Alg()) {
+			continue
+		}
+		set.Keys = append(set.Keys, key.key)
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("no key matches the token")
+	}
+	return set, nil
+}
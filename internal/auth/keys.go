@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrKeyNotFound is returned when no API key has the given ID
+	ErrKeyNotFound = errors.New("api key not found")
+	// ErrInvalidKey is returned when a key to issue has no name, has already expired or has an unknown role
+	ErrInvalidKey = errors.New("invalid api key")
+)
+
+// keyPrefix starts every API key, telling keys from JWTs. A key reads
+// oaas_<prefix>_<secret>: the prefix finds its record, the secret proves it.
+const keyPrefix = "oaas_"
+
+// Keys issues, lists, revokes and verifies API keys
+type Keys struct {
+	db *gorm.DB
+}
+
+// NewKeys creates a new API key store
+func NewKeys(db *gorm.DB) *Keys {
+	return &Keys{db: db}
+}
+
+// IsAPIKey reports whether a credential has the form of an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, keyPrefix)
+}
+
+// Issue creates an API key and returns its record with the key itself, which
+// is not stored and cannot be shown again. The key has the given roles.
+func (k *Keys) Issue(name string, admin bool, roles []string, expiresAt *time.Time) (*entities.APIKey, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidKey)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiry is in the past", ErrInvalidKey)
+	}
+	if err := NewRoles(k.db).validate(roles); errors.Is(err, ErrRoleNotFound) {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	prefix, err := randomString(6, hex.EncodeToString)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, "", err
+	}
+	key := entities.NewAPIKey(name, prefix, hash(secret), admin)
+	key.Roles = strings.Join(roles, ",")
+	key.ExpiresAt = expiresAt
+	if principal := FromContext(k.db.Statement.Context); principal != nil {
+		key.CreatedBy = principal.ID
+	}
+	if err := k.db.Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	if err := Record(k.db, ActionCreate, "api_keys", key.ID, 1); err != nil {
+		return nil, "", err
+	}
+	return key, keyPrefix + prefix + "_" + secret, nil
+}
+
+// List returns every API key, newest first
+func (k *Keys) List() ([]entities.APIKey, error) {
+	var keys []entities.APIKey
+	if err := k.db.Order("created_at DESC, id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke makes an API key unusable. Revoking a revoked key changes nothing.
+func (k *Keys) Revoke(id string) (*entities.APIKey, error) {
+	var key entities.APIKey
+	if err := k.db.Where("id = ?", id).Limit(1).Find(&key).Error; err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if key.ID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	if key.RevokedAt != nil {
+		return &key, nil
+	}
+	now := time.Now()
+	if err := k.db.Model(&key).Update("revoked_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	key.RevokedAt = &now
+	if err := Record(k.db, ActionUpdate, "api_keys", key.ID, 1); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Verify returns the record of a usable API key
+func (k *Keys) Verify(credential string) (*entities.APIKey, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(credential, keyPrefix), "_")
+	if !IsAPIKey(credential) || !ok || prefix == "" || secret == "" {
+		return nil, fmt.Errorf("%w: malformed api key", ErrInvalidCredentials)
+	}
+
+	var key entities.APIKey
+	if err := k.db.Where("prefix = ?", prefix).Limit(1).Find(&key).Error; err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if key.ID == "" || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash(secret))) != 1 {
+		return nil, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	now := time.Now()
+	switch {
+	case key.RevokedAt != nil:
+		return nil, fmt.Errorf("%w: api key revoked", ErrInvalidCredentials)
+	case key.ExpiresAt != nil && !now.Before(*key.ExpiresAt):
+		return nil, fmt.Errorf("%w: api key expired", ErrInvalidCredentials)
+	}
+	if err := k.db.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to update api key: %w", err)
+	}
+	key.LastUsedAt = &now
+	return &key, nil
+}
+
+// hash returns the stored form of a key's secret. The secret is random, so a
+// plain digest is as hard to reverse as a slow password hash.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return encode(buf), nil
+}
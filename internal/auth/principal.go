@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// How a principal authenticated
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodCLI    = "cli"
+)
+
+var (
+	// ErrUnauthenticated is returned when a request that needs a principal has no credentials
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrInvalidCredentials is returned for an unknown, revoked or expired key or a token that fails verification
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrForbidden is returned when the principal may not do what it asked
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Principal is who a request acts for
+type Principal struct {
+	ID     string   `json:"id"` // key:<key ID>, jwt:<subject> or cli:<user>
+	Name   string   `json:"name"`
+	Method string   `json:"method"`
+	Admin  bool     `json:"admin"` // allowed everything
+	Roles  []string `json:"roles"` // names of the roles whose permissions it has
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a context carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal the context carries, nil if none
+func FromContext(ctx context.Context) *Principal {
+	if ctx == nil {
+		return nil
+	}
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// Actions a permission grants, besides the audited create, update and delete
+const (
+	ActionRead      = "read"
+	ActionActualize = "actualize"
+)
+
+// Resources a permission grants actions on
+const (
+	ResourceSubstance      = entities.EntityTypeSubstance
+	ResourceKind           = entities.EntityTypeKind
+	ResourceAttribute      = entities.EntityTypeAttribute
+	ResourceMode           = entities.EntityTypeMode
+	ResourcePotentiality   = entities.EntityTypePotentiality
+	ResourceActuality      = entities.EntityTypeActuality
+	ResourceCausalRelation = "causal_relation"
+	ResourceRule           = "rule"
+	ResourceTemplate       = "template"
+	ResourcePart           = "part"
+	ResourceDependence     = "dependence"
+	ResourceWorkspace      = "workspace"
+	ResourceLint           = "lint"
+)
+
+// Any matches every action or resource in a permission
+const Any = "*"
+
+var (
+	actions   = []string{ActionRead, ActionCreate, ActionUpdate, ActionDelete, ActionActualize}
+	resources = []string{
+		ResourceSubstance, ResourceKind, ResourceAttribute, ResourceMode, ResourcePotentiality, ResourceActuality,
+		ResourceCausalRelation, ResourceRule, ResourceTemplate, ResourcePart, ResourceDependence, ResourceWorkspace, ResourceLint,
+	}
+)
+
+var (
+	// ErrRoleNotFound is returned when no role has the given name
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrInvalidRole is returned for a malformed role or permission, or a change to a built-in role
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrDuplicateRole is returned when another role has the name
+	ErrDuplicateRole = errors.New("role name already taken")
+)
+
+// Permission grants an action on a resource. With kinds, it only covers
+// substances of those kinds and what belongs to them — their modes,
+// potentialities and actualities — or, for the kind resource, those kinds.
+type Permission struct {
+	Action   string   `json:"action"`
+	Resource string   `json:"resource"`
+	Kinds    []string `json:"kinds,omitempty"`
+}
+
+// String describes the permission, as in "create mode of kind Oak"
+func (p Permission) String() string {
+	s := p.Action + " " + p.Resource
+	if len(p.Kinds) > 0 {
+		s += " of kind " + strings.Join(p.Kinds, ", ")
+	}
+	return s
+}
+
+// allows reports whether the permission covers the action on the resource, of the kind if any
+func (p Permission) allows(action, resource, kind string) bool {
+	if (p.Action != Any && p.Action != action) || (p.Resource != Any && p.Resource != resource) {
+		return false
+	}
+	if len(p.Kinds) == 0 {
+		return true
+	}
+	for _, k := range p.Kinds {
+		if kind != "" && k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions given to principals
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Builtin     bool         `json:"builtin"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// BuiltinRoles exist in every deployment and cannot be changed
+var BuiltinRoles = []Role{
+	{
+		Name:        "viewer",
+		Description: "Reads everything",
+		Builtin:     true,
+		Permissions: []Permission{{Action: ActionRead, Resource: Any}},
+	},
+	{
+		Name:        "editor",
+		Description: "Does everything but administration",
+		Builtin:     true,
+		Permissions: []Permission{{Action: Any, Resource: Any}},
+	},
+	{
+		Name:        "taxonomist",
+		Description: "Reads everything and edits kinds and attributes",
+		Builtin:     true,
+		Permissions: []Permission{
+			{Action: ActionRead, Resource: Any},
+			{Action: Any, Resource: ResourceKind},
+			{Action: Any, Resource: ResourceAttribute},
+		},
+	},
+	{
+		Name:        "field_staff",
+		Description: "Reads everything, adds modes and actualizes potentialities",
+		Builtin:     true,
+		Permissions: []Permission{
+			{Action: ActionRead, Resource: Any},
+			{Action: ActionCreate, Resource: ResourceMode},
+			{Action: ActionActualize, Resource: ResourcePotentiality},
+		},
+	},
+}
+
+// validRoleName matches role names, which appear in URLs and token claims
+var validRoleName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+
+// Roles lists, creates and deletes roles. Custom roles are shared by all workspaces.
+type Roles struct {
+	db *gorm.DB
+}
+
+// NewRoles creates a new role store
+func NewRoles(db *gorm.DB) *Roles {
+	return &Roles{db: db}
+}
+
+// List returns the built-in roles and then the custom ones, by name
+func (r *Roles) List() ([]Role, error) {
+	var stored []entities.Role
+	if err := r.db.Order("name").Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	roles := append([]Role{}, BuiltinRoles...)
+	for _, role := range stored {
+		converted, err := fromEntity(role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, converted)
+	}
+	return roles, nil
+}
+
+// Get returns the role with the given name
+func (r *Roles) Get(name string) (*Role, error) {
+	for _, role := range BuiltinRoles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+	var stored entities.Role
+	if err := r.db.Where("name = ?", name).Limit(1).Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	if stored.ID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+	}
+	role, err := fromEntity(stored)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Create stores a custom role
+func (r *Roles) Create(name, description string, permissions []Permission) (*Role, error) {
+	if !validRoleName.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be lowercase letters, digits, '-' or '_'", ErrInvalidRole)
+	}
+	if len(permissions) == 0 {
+		return nil, fmt.Errorf("%w: a role needs at least one permission", ErrInvalidRole)
+	}
+	for _, permission := range permissions {
+		if err := validatePermission(permission); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := r.Get(name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateRole, name)
+	} else if !errors.Is(err, ErrRoleNotFound) {
+		return nil, err
+	}
+
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode permissions: %w", err)
+	}
+	stored := entities.NewRole(name, description, string(data))
+	if err := r.db.Create(stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	if err := Record(r.db, ActionCreate, "roles", stored.ID, 1); err != nil {
+		return nil, err
+	}
+	return &Role{Name: name, Description: description, Permissions: permissions}, nil
+}
+
+// Delete deletes a custom role. Principals that have it lose its permissions.
+func (r *Roles) Delete(name string) error {
+	role, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return fmt.Errorf("%w: built-in role %s cannot be deleted", ErrInvalidRole, name)
+	}
+	result := r.db.Where("name = ?", name).Delete(&entities.Role{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete role: %w", result.Error)
+	}
+	return Record(r.db, ActionDelete, "roles", name, result.RowsAffected)
+}
+
+// validate returns an error naming the first of the roles that does not exist
+func (r *Roles) validate(names []string) error {
+	for _, name := range names {
+		if _, err := r.Get(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePermission(p Permission) error {
+	if p.Action != Any && !slices.Contains(actions, p.Action) {
+		return fmt.Errorf("%w: unknown action %q; must be %s or %s", ErrInvalidRole, p.Action, strings.Join(actions, ", "), Any)
+	}
+	if p.Resource != Any && !slices.Contains(resources, p.Resource) {
+		return fmt.Errorf("%w: unknown resource %q; must be %s or %s", ErrInvalidRole, p.Resource, strings.Join(resources, ", "), Any)
+	}
+	return nil
+}
+
+func fromEntity(role entities.Role) (Role, error) {
+	converted := Role{Name: role.Name, Description: role.Description}
+	if err := json.Unmarshal([]byte(role.Permissions), &converted.Permissions); err != nil {
+		return Role{}, fmt.Errorf("role %s has malformed permissions: %w", role.Name, err)
+	}
+	return converted, nil
+}
+
+// ParseRoles splits a comma- or space-separated list of role names, dropping duplicates
+func ParseRoles(list string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, name := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !seen[name] {
+			seen[name] = true
+			roles = append(roles, name)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
@@ -0,0 +1,425 @@
+package causality
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Causal graph analytics.
+// Every measure here treats the causal graph as directed from cause to effect
+// (relation ToEntity -> FromEntity) and runs in memory over the filtered relations.
+
+// Centrality sort orders
+const (
+	SortByPageRank    = "pagerank"
+	SortByBetweenness = "betweenness"
+	SortByDegree      = "degree"
+)
+
+const (
+	pageRankDamping    = 0.85
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-10
+)
+
+// Centrality holds the centrality measures of one entity in the causal graph
+type Centrality struct {
+	EntityID    string  `json:"entity_id"`
+	InDegree    int     `json:"in_degree"`   // relations naming a cause of this entity
+	OutDegree   int     `json:"out_degree"`  // relations naming this entity as a cause
+	Degree      int     `json:"degree"`      // in plus out
+	Betweenness float64 `json:"betweenness"` // normalised to [0, 1]
+	PageRank    float64 `json:"pagerank"`    // sums to 1 over the graph
+}
+
+// CausalComponent is a set of entities connected through causal relations
+type CausalComponent struct {
+	Entities  []string `json:"entities"`
+	Relations int      `json:"relations"` // relations with both ends inside the component
+}
+
+// analyticsGraph is the simple directed graph the analytics run on:
+// parallel relations between the same pair of entities collapse into one edge.
+type analyticsGraph struct {
+	nodes     []string
+	index     map[string]int
+	effects   [][]int // effects[i] are the entities node i causes
+	causes    [][]int // causes[i] are the entities that cause node i
+	inDegree  []int
+	outDegree []int
+	relations [][2]int // every relation as (cause, effect), parallels included
+}
+
+func (e *Engine) loadAnalyticsGraph(filter CausalFilter) (*analyticsGraph, error) {
+	if err := validateCauseTypes(filter.CauseTypes); err != nil {
+		return nil, err
+	}
+	graph, err := e.loadCausalGraph(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load causal graph: %w", err)
+	}
+
+	g := &analyticsGraph{index: make(map[string]int)}
+	add := func(id string) {
+		if _, ok := g.index[id]; !ok {
+			g.index[id] = len(g.nodes)
+			g.nodes = append(g.nodes, id)
+		}
+	}
+
+	// Relations are loaded ordered by ID; visit them by entity for stable node numbering
+	froms := make([]string, 0, len(graph.up))
+	for from := range graph.up {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	for _, from := range froms {
+		for _, relation := range graph.up[from] {
+			add(relation.FromEntity)
+			add(relation.ToEntity)
+		}
+	}
+
+	n := len(g.nodes)
+	g.effects = make([][]int, n)
+	g.causes = make([][]int, n)
+	g.inDegree = make([]int, n)
+	g.outDegree = make([]int, n)
+
+	type edge struct{ cause, effect int }
+	seen := make(map[edge]bool)
+	for _, from := range froms {
+		for _, relation := range graph.up[from] {
+			cause, effect := g.index[relation.ToEntity], g.index[relation.FromEntity]
+			g.relations = append(g.relations, [2]int{cause, effect})
+			g.inDegree[effect]++
+			g.outDegree[cause]++
+			if seen[edge{cause, effect}] {
+				continue
+			}
+			seen[edge{cause, effect}] = true
+			g.effects[cause] = append(g.effects[cause], effect)
+			g.causes[effect] = append(g.causes[effect], cause)
+		}
+	}
+	return g, nil
+}
+
+// Centrality computes degree, betweenness and PageRank centrality for every
+// entity in the causal graph, sorted by the requested measure (PageRank by default)
+func (e *Engine) Centrality(filter CausalFilter, sortBy string) ([]Centrality, error) {
+	if sortBy == "" {
+		sortBy = SortByPageRank
+	}
+	if sortBy != SortByPageRank && sortBy != SortByBetweenness && sortBy != SortByDegree {
+		return nil, fmt.Errorf("invalid centrality sort: %s. Must be one of: %s, %s, %s", sortBy, SortByPageRank, SortByBetweenness, SortByDegree)
+	}
+
+	g, err := e.loadAnalyticsGraph(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	betweenness := g.betweenness()
+	pageRank := g.pageRank()
+	scores := make([]Centrality, len(g.nodes))
+	for i, id := range g.nodes {
+		scores[i] = Centrality{
+			EntityID:    id,
+			InDegree:    g.inDegree[i],
+			OutDegree:   g.outDegree[i],
+			Degree:      g.inDegree[i] + g.outDegree[i],
+			Betweenness: betweenness[i],
+			PageRank:    pageRank[i],
+		}
+	}
+
+	measure := func(c Centrality) float64 {
+		switch sortBy {
+		case SortByBetweenness:
+			return c.Betweenness
+		case SortByDegree:
+			return float64(c.Degree)
+		}
+		return c.PageRank
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if mi, mj := measure(scores[i]), measure(scores[j]); mi != mj {
+			return mi > mj
+		}
+		return scores[i].EntityID < scores[j].EntityID
+	})
+	return scores, nil
+}
+
+// betweenness computes Brandes' betweenness centrality, normalised for a directed graph
+func (g *analyticsGraph) betweenness() []float64 {
+	n := len(g.nodes)
+	centrality := make([]float64, n)
+
+	for s := 0; s < n; s++ {
+		stack := make([]int, 0, n)
+		predecessors := make([][]int, n)
+		paths := make([]float64, n)
+		distance := make([]int, n)
+		for i := range distance {
+			distance[i] = -1
+		}
+		paths[s] = 1
+		distance[s] = 0
+
+		queue := []int{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range g.effects[v] {
+				if distance[w] < 0 {
+					distance[w] = distance[v] + 1
+					queue = append(queue, w)
+				}
+				if distance[w] == distance[v]+1 {
+					paths[w] += paths[v]
+					predecessors[w] = append(predecessors[w], v)
+				}
+			}
+		}
+
+		dependency := make([]float64, n)
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range predecessors[w] {
+				dependency[v] += paths[v] / paths[w] * (1 + dependency[w])
+			}
+			if w != s {
+				centrality[w] += dependency[w]
+			}
+		}
+	}
+
+	if n > 2 {
+		scale := 1 / float64((n-1)*(n-2))
+		for i := range centrality {
+			centrality[i] *= scale
+		}
+	}
+	return centrality
+}
+
+// pageRank runs power iteration along cause -> effect edges, so rank flows
+// towards entities with many well-caused causes. Entities without effects
+// spread their rank evenly over the graph.
+func (g *analyticsGraph) pageRank() []float64 {
+	n := len(g.nodes)
+	if n == 0 {
+		return nil
+	}
+
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+
+	for iteration := 0; iteration < pageRankIterations; iteration++ {
+		dangling := 0.0
+		for i := range g.nodes {
+			if len(g.effects[i]) == 0 {
+				dangling += rank[i]
+			}
+		}
+
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		next := make([]float64, n)
+		for i := range next {
+			next[i] = base
+		}
+		for i := range g.nodes {
+			if len(g.effects[i]) == 0 {
+				continue
+			}
+			share := pageRankDamping * rank[i] / float64(len(g.effects[i]))
+			for _, effect := range g.effects[i] {
+				next[effect] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank = next
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// WeakComponents returns the weakly connected components of the causal graph,
+// largest first: entities linked by relations in either direction
+func (e *Engine) WeakComponents(filter CausalFilter) ([]CausalComponent, error) {
+	g, err := e.loadAnalyticsGraph(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	component := make([]int, len(g.nodes))
+	for i := range component {
+		component[i] = -1
+	}
+	count := 0
+	for start := range g.nodes {
+		if component[start] >= 0 {
+			continue
+		}
+		component[start] = count
+		stack := []int{start}
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, neighbours := range [][]int{g.effects[v], g.causes[v]} {
+				for _, w := range neighbours {
+					if component[w] < 0 {
+						component[w] = count
+						stack = append(stack, w)
+					}
+				}
+			}
+		}
+		count++
+	}
+	return g.components(component, count), nil
+}
+
+// StrongComponents returns the strongly connected components of the causal graph,
+// largest first: entities that are each, transitively, causes of one another.
+// Entities outside any cycle form components of their own.
+func (e *Engine) StrongComponents(filter CausalFilter) ([]CausalComponent, error) {
+	g, err := e.loadAnalyticsGraph(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tarjan's algorithm
+	n := len(g.nodes)
+	index := make([]int, n)
+	lowlink := make([]int, n)
+	onStack := make([]bool, n)
+	component := make([]int, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	next, count := 0, 0
+
+	var connect func(v int)
+	connect = func(v int) {
+		index[v] = next
+		lowlink[v] = next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.effects[v] {
+			if index[w] < 0 {
+				connect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+
+		if lowlink[v] == index[v] {
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component[w] = count
+				if w == v {
+					break
+				}
+			}
+			count++
+		}
+	}
+	for v := 0; v < n; v++ {
+		if index[v] < 0 {
+			connect(v)
+		}
+	}
+	return g.components(component, count), nil
+}
+
+// components groups nodes by component number, largest component first
+func (g *analyticsGraph) components(component []int, count int) []CausalComponent {
+	result := make([]CausalComponent, count)
+	for i := range result {
+		result[i].Entities = []string{}
+	}
+	for i, id := range g.nodes {
+		result[component[i]].Entities = append(result[component[i]].Entities, id)
+	}
+	for _, relation := range g.relations {
+		if component[relation[0]] == component[relation[1]] {
+			result[component[relation[0]]].Relations++
+		}
+	}
+
+	for i := range result {
+		sort.Strings(result[i].Entities)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Entities) != len(result[j].Entities) {
+			return len(result[i].Entities) > len(result[j].Entities)
+		}
+		return result[i].Entities[0] < result[j].Entities[0]
+	})
+	return result
+}
+
+// RootCauses returns the entities that cause something but have no cause of their own.
+// Only efficient causes are considered unless the filter names other cause types.
+func (e *Engine) RootCauses(filter CausalFilter) ([]EntityRef, error) {
+	if len(filter.CauseTypes) == 0 {
+		filter.CauseTypes = []string{"efficient"}
+	}
+	return e.unexplainedCauses(filter)
+}
+
+// TerminalEnds returns the final causes that have no further final cause:
+// the ends nothing points past. Other cause types may be named in the filter.
+func (e *Engine) TerminalEnds(filter CausalFilter) ([]EntityRef, error) {
+	if len(filter.CauseTypes) == 0 {
+		filter.CauseTypes = []string{"final"}
+	}
+	return e.unexplainedCauses(filter)
+}
+
+// unexplainedCauses returns, sorted by ID, the entities that are causes in the
+// filtered graph without being caused by anything in it
+func (e *Engine) unexplainedCauses(filter CausalFilter) ([]EntityRef, error) {
+	g, err := e.loadAnalyticsGraph(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for i, id := range g.nodes {
+		if g.outDegree[i] > 0 && g.inDegree[i] == 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	refs, err := e.ResolveEntities(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]EntityRef, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, refs[id])
+	}
+	return result, nil
+}
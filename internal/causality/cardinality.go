@@ -0,0 +1,177 @@
+package causality
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/repository"
+)
+
+// ErrConflictingMode is returned when a mode would give a substance a second value for a single-valued attribute
+var ErrConflictingMode = errors.New("conflicting mode")
+
+// ModeConflict reports a substance holding different values for a single-valued attribute.
+// Such contradictions can predate the attribute being made single-valued; they are
+// flagged rather than resolved, and conditions see only the current mode.
+type ModeConflict struct {
+	SubstanceID   string          `json:"substance_id"`
+	AttributeID   string          `json:"attribute_id"`
+	AttributeName string          `json:"attribute_name"`
+	Values        []string        `json:"values"`          // distinct values, oldest first
+	CurrentModeID string          `json:"current_mode_id"` // the mode conditions are evaluated against
+	Modes         []entities.Mode `json:"modes"`
+}
+
+// CurrentMode picks the mode that holds for a single-valued attribute among a
+// substance's modes of it, ordered oldest first: asserted modes take precedence
+// over inferred ones, and the newest of those wins.
+func CurrentMode(modes []entities.Mode) *entities.Mode {
+	var current *entities.Mode
+	for i := range modes {
+		mode := &modes[i]
+		if current == nil || !mode.Inferred || current.Inferred {
+			current = mode
+		}
+	}
+	return current
+}
+
+// FactsFromModes snapshots a substance given its modes, ordered oldest first, with their
+// attributes preloaded. A single-valued attribute contributes only its current mode.
+// Relational modes are left out; they are facts about all their bearers, not values.
+func FactsFromModes(substance entities.Substance, modes []entities.Mode) *SubstanceFacts {
+	facts := NewSubstanceFacts(substance.ID, substance.Kind)
+	single := make(map[string][]entities.Mode)
+	var order []string
+	for _, mode := range modes {
+		if mode.Attribute == nil || mode.Attribute.IsRelational() {
+			continue
+		}
+		name := mode.Attribute.Name
+		if mode.Attribute.Cardinality != entities.CardinalitySingle {
+			facts.Add(name, mode.Value)
+			continue
+		}
+		if _, seen := single[name]; !seen {
+			order = append(order, name)
+		}
+		single[name] = append(single[name], mode)
+	}
+	for _, name := range order {
+		facts.Add(name, CurrentMode(single[name]).Value)
+	}
+	return facts
+}
+
+// CheckAssertion returns ErrConflictingMode when asserting value for the attribute
+// would contradict a value the substance is already asserted to have, and
+// ErrInvalidRelation when the attribute is relational. For a single-valued
+// attribute it locks the substance, so that in a transaction no concurrent
+// assertion can contradict the value before it is stored.
+func (e *Engine) CheckAssertion(substanceID, attributeID, value string) error {
+	attribute, err := e.store.Attributes.Get(e.ctx, attributeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: attribute %s", ErrEntityNotFound, attributeID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get attribute: %w", err)
+	}
+	if attribute.IsRelational() {
+		return fmt.Errorf("%w: attribute '%s' is relational; its modes need a bearer for each role", ErrInvalidRelation, attribute.Name)
+	}
+	if attribute.Cardinality != entities.CardinalitySingle {
+		return nil
+	}
+
+	err = e.store.Substances.Lock(e.ctx, substanceID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: substance %s", ErrEntityNotFound, substanceID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock substance: %w", err)
+	}
+	modes, err := e.store.Modes.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
+		return fmt.Errorf("failed to get modes: %w", err)
+	}
+	for _, existing := range modes {
+		if existing.AttributeID == attributeID && !existing.Inferred && existing.Value != value {
+			return fmt.Errorf("%w: attribute '%s' is single-valued and the substance already has value '%s'", ErrConflictingMode, attribute.Name, existing.Value)
+		}
+	}
+	return nil
+}
+
+// AssertMode stores an asserted mode, rejecting it if it contradicts one the substance already has
+func (e *Engine) AssertMode(mode *entities.Mode) error {
+	if err := e.CheckAssertion(mode.SubstanceID, mode.AttributeID, mode.Value); err != nil {
+		return err
+	}
+	if err := e.store.Modes.Create(e.ctx, mode); err != nil {
+		return fmt.Errorf("failed to create mode: %w", err)
+	}
+	return nil
+}
+
+// ModeConflicts finds every single-valued attribute for which a substance, or
+// every substance when substanceID is empty, has more than one distinct value
+func (e *Engine) ModeConflicts(substanceID string) ([]ModeConflict, error) {
+	var modes []entities.Mode
+	var err error
+	if substanceID != "" {
+		modes, err = e.store.Modes.ListBySubstance(e.ctx, substanceID)
+	} else {
+		modes, err = e.store.Modes.List(e.ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get modes: %w", err)
+	}
+
+	type key struct{ substance, attribute string }
+	groups := make(map[key][]entities.Mode)
+	var keys []key
+	for _, mode := range modes {
+		if mode.Attribute == nil || mode.Attribute.Cardinality != entities.CardinalitySingle {
+			continue
+		}
+		mode.Substance = nil
+		k := key{mode.SubstanceID, mode.AttributeID}
+		if _, seen := groups[k]; !seen {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], mode)
+	}
+
+	conflicts := []ModeConflict{}
+	for _, k := range keys {
+		group := groups[k]
+		var values []string
+		seen := make(map[string]bool)
+		for _, mode := range group {
+			if !seen[mode.Value] {
+				seen[mode.Value] = true
+				values = append(values, mode.Value)
+			}
+		}
+		if len(values) < 2 {
+			continue
+		}
+		conflicts = append(conflicts, ModeConflict{
+			SubstanceID:   k.substance,
+			AttributeID:   k.attribute,
+			AttributeName: group[0].Attribute.Name,
+			Values:        values,
+			CurrentModeID: CurrentMode(group).ID,
+			Modes:         group,
+		})
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].SubstanceID != conflicts[j].SubstanceID {
+			return conflicts[i].SubstanceID < conflicts[j].SubstanceID
+		}
+		return conflicts[i].AttributeName < conflicts[j].AttributeName
+	})
+	return conflicts, nil
+}
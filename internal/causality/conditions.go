@@ -0,0 +1,284 @@
+package causality
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/repository"
+)
+
+// ErrInvalidConditions is returned when a potentiality's conditions do not parse
+var ErrInvalidConditions = errors.New("invalid conditions")
+
+// Condition types
+const (
+	ConditionAttribute = "attribute"
+	ConditionMode      = "mode"
+	ConditionKind      = "kind"
+	ConditionRelation  = "relation"
+	ConditionExternal  = "external"
+)
+
+// Part quantifiers: how many of a substance's parts must meet a condition
+const (
+	PartsAll  = "all"  // every part, and there is at least one
+	PartsAny  = "any"  // at least one part
+	PartsNone = "none" // no part
+)
+
+// validOperators are the comparisons a condition may make; "=" is assumed when none is given
+var validOperators = map[string]bool{
+	"=":  true,
+	"!=": true,
+	">":  true,
+	">=": true,
+	"<":  true,
+	"<=": true,
+}
+
+// SubstanceFacts is a snapshot of what is known about a substance.
+// Conditions are evaluated against it rather than against the database so that
+// a set of conditions sees one consistent state, and so the rule engine can
+// evaluate conditions against facts it has derived but not yet stored.
+type SubstanceFacts struct {
+	SubstanceID string
+	Kind        string
+	Values      map[string][]string // mode values by attribute name, oldest first
+	Parts       []*SubstanceFacts   // facts about every part, direct and indirect, nearest first
+	Relations   []RelationFact      // relational modes the substance bears, oldest first
+}
+
+// NewSubstanceFacts creates an empty snapshot for a substance of a kind
+func NewSubstanceFacts(substanceID, kind string) *SubstanceFacts {
+	return &SubstanceFacts{SubstanceID: substanceID, Kind: kind, Values: make(map[string][]string)}
+}
+
+// Add records that the substance has a mode with this value for the attribute
+func (f *SubstanceFacts) Add(attribute, value string) {
+	f.Values[attribute] = append(f.Values[attribute], value)
+}
+
+// LoadFacts snapshots a substance's kind, every mode it has, asserted or inferred, the
+// relations it bears and its parts; for a single-valued attribute only the current mode counts
+func (e *Engine) LoadFacts(substanceID string) (*SubstanceFacts, error) {
+	substances, err := e.store.Substances.Find(e.ctx, []string{substanceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get substance: %w", err)
+	}
+	if len(substances) == 0 {
+		return nil, fmt.Errorf("substance not found: %w", repository.ErrNotFound)
+	}
+
+	modes, err := e.store.Modes.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get modes: %w", err)
+	}
+
+	facts := FactsFromModes(substances[0], modes)
+	if e.db == nil {
+		return facts, nil
+	}
+	if facts.Relations, err = e.LoadRelationFacts(substanceID); err != nil {
+		return nil, err
+	}
+	if facts.Parts, err = e.LoadPartFacts(substanceID); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// EvaluateAll checks every condition and returns whether all are met, with a reason for each that is not
+func (f *SubstanceFacts) EvaluateAll(conditions []Condition) (bool, []string) {
+	var unmet []string
+	for _, condition := range conditions {
+		if met, reason := f.Evaluate(condition); !met {
+			unmet = append(unmet, reason)
+		}
+	}
+	return len(unmet) == 0, unmet
+}
+
+// Evaluate checks a single condition, returning the reason when it is not met
+func (f *SubstanceFacts) Evaluate(condition Condition) (bool, string) {
+	if condition.Parts != "" {
+		return f.evaluateParts(condition)
+	}
+	operator := condition.Operator
+	if operator == "" {
+		operator = "="
+	}
+	if !validOperators[operator] {
+		return false, fmt.Sprintf("unknown condition operator: %s", condition.Operator)
+	}
+
+	switch condition.Type {
+	case ConditionAttribute:
+		values, ok := f.Values[condition.Name]
+		if !ok {
+			return false, fmt.Sprintf("attribute '%s' not found for substance", condition.Name)
+		}
+		if !anyMatches(values, operator, condition.Value) {
+			return false, fmt.Sprintf("attribute '%s' has value '%s', expected %s '%v'", condition.Name, strings.Join(values, "', '"), operator, condition.Value)
+		}
+		return true, ""
+	case ConditionMode:
+		if !anyMatches(f.Values[condition.Name], operator, condition.Value) {
+			return false, fmt.Sprintf("mode condition not met: %s %s %v", condition.Name, operator, condition.Value)
+		}
+		return true, ""
+	case ConditionKind:
+		if !anyMatches([]string{f.Kind}, operator, condition.Value) {
+			return false, fmt.Sprintf("kind condition not met: substance is of kind '%s', expected %s '%v'", f.Kind, operator, condition.Value)
+		}
+		return true, ""
+	case ConditionRelation:
+		return f.evaluateRelation(condition, operator)
+	case ConditionExternal:
+		// External conditions would be checked against external systems
+		// For now, we'll assume they're always met
+		return true, ""
+	default:
+		return false, fmt.Sprintf("unknown condition type: %s", condition.Type)
+	}
+}
+
+// evaluateParts checks a condition quantified over the substance's parts
+func (f *SubstanceFacts) evaluateParts(condition Condition) (bool, string) {
+	inner := condition
+	inner.Parts, inner.PartKind = "", ""
+
+	scope := "parts"
+	if condition.PartKind != "" {
+		scope = fmt.Sprintf("parts of kind '%s'", condition.PartKind)
+	}
+	considered, meeting := 0, 0
+	var failure string
+	for _, part := range f.Parts {
+		if condition.PartKind != "" && part.Kind != condition.PartKind {
+			continue
+		}
+		considered++
+		if met, reason := part.Evaluate(inner); met {
+			meeting++
+		} else if failure == "" {
+			failure = fmt.Sprintf("part %s: %s", part.SubstanceID, reason)
+		}
+	}
+
+	switch condition.Parts {
+	case PartsAll:
+		if considered == 0 {
+			return false, fmt.Sprintf("substance has no %s", scope)
+		}
+		if meeting < considered {
+			return false, fmt.Sprintf("%d of %d %s do not meet the condition; %s", considered-meeting, considered, scope, failure)
+		}
+		return true, ""
+	case PartsAny:
+		if meeting == 0 {
+			return false, fmt.Sprintf("none of the %d %s meets the condition", considered, scope)
+		}
+		return true, ""
+	case PartsNone:
+		if meeting > 0 {
+			return false, fmt.Sprintf("%d of %d %s meet the condition", meeting, considered, scope)
+		}
+		return true, ""
+	default:
+		return false, fmt.Sprintf("unknown part quantifier: %s", condition.Parts)
+	}
+}
+
+// anyMatches reports whether any of the values compares to expected as the operator requires
+func anyMatches(values []string, operator string, expected interface{}) bool {
+	for _, value := range values {
+		if compare(value, operator, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare applies an operator to a stored value and an expected one.
+// Values compare numerically when both are numbers and as strings otherwise;
+// ordering operators only hold between numbers.
+func compare(actual, operator string, expected interface{}) bool {
+	want := fmt.Sprintf("%v", expected)
+	a, errA := strconv.ParseFloat(actual, 64)
+	b, errB := strconv.ParseFloat(want, 64)
+	numeric := errA == nil && errB == nil
+
+	switch operator {
+	case "=":
+		if numeric {
+			return a == b
+		}
+		return actual == want
+	case "!=":
+		if numeric {
+			return a != b
+		}
+		return actual != want
+	}
+
+	if !numeric {
+		return false
+	}
+	switch operator {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// ParseConditions decodes a JSON condition list and rejects unknown types and operators
+func ParseConditions(raw string) ([]Condition, error) {
+	var conditions []Condition
+	if err := json.Unmarshal([]byte(raw), &conditions); err != nil {
+		return nil, fmt.Errorf("invalid conditions JSON format: %w", err)
+	}
+	for _, condition := range conditions {
+		switch condition.Type {
+		case ConditionAttribute, ConditionMode:
+			if condition.Name == "" {
+				return nil, fmt.Errorf("%s condition requires a name", condition.Type)
+			}
+		case ConditionKind, ConditionExternal:
+		case ConditionRelation:
+			if condition.Name == "" {
+				return nil, fmt.Errorf("relation condition requires a name")
+			}
+		default:
+			return nil, fmt.Errorf("unknown condition type: %s", condition.Type)
+		}
+		if condition.Type != ConditionRelation &&
+			(condition.Role != "" || condition.Related != "" || condition.RelatedRole != "" || condition.RelatedKind != "") {
+			return nil, fmt.Errorf("role, related, related_role and related_kind only apply to relation conditions")
+		}
+		if condition.Operator != "" && !validOperators[condition.Operator] {
+			return nil, fmt.Errorf("unknown condition operator: %s", condition.Operator)
+		}
+		switch condition.Parts {
+		case "":
+			if condition.PartKind != "" {
+				return nil, fmt.Errorf("part_kind requires a parts quantifier")
+			}
+		case PartsAll, PartsAny, PartsNone:
+			if condition.Type == ConditionExternal {
+				return nil, fmt.Errorf("external conditions cannot quantify over parts")
+			}
+		default:
+			return nil, fmt.Errorf("unknown part quantifier: %s. Must be one of: all, any, none", condition.Parts)
+		}
+	}
+	return conditions, nil
+}
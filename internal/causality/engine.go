@@ -1,129 +1,148 @@
 package causality
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/apodicticscott/oaas/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
 // Engine handles potentiality → actuality transitions
 type Engine struct {
-	db *gorm.DB
+	ctx   context.Context
+	store *repository.Store
+	db    *gorm.DB // for what lies beyond the repositories; nil if there is no database
 }
 
-// NewEngine creates a new causality engine
-func NewEngine(db *gorm.DB) *Engine {
-	return &Engine{db: db}
+// ErrNoDatabase is returned by the methods that query the database directly,
+// for relations and parts, when the engine's store has none
+var ErrNoDatabase = repository.ErrNoDatabase
+
+// validCauseTypes are the four Aristotelian causes
+var validCauseTypes = map[string]bool{
+	"material":  true,
+	"formal":    true,
+	"efficient": true,
+	"final":     true,
 }
 
-// Condition represents a condition that must be met for actualization
-type Condition struct {
-	Type  string      `json:"type"`  // "attribute", "mode", "external"
-	Name  string      `json:"name"`  // attribute name or condition name
-	Value interface{} `json:"value"` // expected value
+// IsValidCauseType reports whether causeType is one of the four causes
+func IsValidCauseType(causeType string) bool {
+	return validCauseTypes[causeType]
 }
 
-// CheckConditions verifies if all conditions for a potentiality are met
-func (e *Engine) CheckConditions(potentialityID string) (bool, []string, error) {
-	var potentiality entities.Potentiality
-	if err := e.db.Preload("Substance").First(&potentiality, "id = ?", potentialityID).Error; err != nil {
-		return false, nil, fmt.Errorf("potentiality not found: %w", err)
-	}
+// NewEngine creates a new causality engine over db, in the workspace and
+// trace of db's context
+func NewEngine(db *gorm.DB) *Engine {
+	return &Engine{ctx: db.Statement.Context, store: repository.NewGorm(db), db: db}
+}
 
-	// Parse conditions from JSON
-	var conditions []Condition
-	if err := json.Unmarshal([]byte(potentiality.Conditions), &conditions); err != nil {
-		return false, nil, fmt.Errorf("invalid conditions format: %w", err)
+// NewEngineWith creates a causality engine in ctx's workspace that reads and
+// writes substances, modes, potentialities, actualities and causes through
+// store, which also serves traversal and analytics. The store's database
+// serves relations and parts; without one the engine knows of no relational
+// modes or parts, and the methods that need them return ErrNoDatabase.
+func NewEngineWith(ctx context.Context, store *repository.Store) *Engine {
+	db := store.DB()
+	if db != nil {
+		db = db.WithContext(ctx)
 	}
+	return &Engine{ctx: ctx, store: store, db: db}
+}
 
-	var unmetConditions []string
-	allMet := true
-
-	for _, condition := range conditions {
-		met, reason := e.checkSingleCondition(potentiality.SubstanceID, condition)
-		if !met {
-			allMet = false
-			unmetConditions = append(unmetConditions, reason)
-		}
-	}
+var tracer = otel.Tracer("github.com/apodicticscott/oaas/internal/causality")
 
-	return allMet, unmetConditions, nil
+// startSpan starts a span for an engine method, in the trace of the engine's
+// context, and returns an engine whose queries are children of the span
+func (e *Engine) startSpan(method string, attrs ...attribute.KeyValue) (*Engine, trace.Span) {
+	ctx, span := tracer.Start(e.ctx, "causality."+method, trace.WithAttributes(attrs...))
+	return NewEngineWith(ctx, e.store), span
 }
 
-// checkSingleCondition checks if a single condition is met
-func (e *Engine) checkSingleCondition(substanceID string, condition Condition) (bool, string) {
-	switch condition.Type {
-	case "attribute":
-		return e.checkAttributeCondition(substanceID, condition)
-	case "mode":
-		return e.checkModeCondition(substanceID, condition)
-	case "external":
-		// External conditions would be checked against external systems
-		// For now, we'll assume they're always met
-		return true, ""
-	default:
-		return false, fmt.Sprintf("unknown condition type: %s", condition.Type)
-	}
+// Condition represents a condition that must be met for actualization
+type Condition struct {
+	Type     string      `json:"type"`               // "attribute", "mode", "kind", "relation", "external"
+	Name     string      `json:"name"`               // attribute name or condition name
+	Operator string      `json:"operator,omitempty"` // "=" (default), "!=", ">", ">=", "<", "<="
+	Value    interface{} `json:"value"`              // expected value
+
+	// Parts makes the condition quantify over the substance's parts, direct and
+	// indirect, instead of the substance itself: "all", "any" or "none" of them
+	// must meet it. PartKind restricts the parts considered to one kind.
+	Parts    string `json:"parts,omitempty"`
+	PartKind string `json:"part_kind,omitempty"`
+
+	// A relation condition holds when the substance bears a relational mode of
+	// attribute Name, in Role if set, alongside another bearer that is the
+	// substance Related, plays RelatedRole and is of RelatedKind, as far as each
+	// is set. Value, when set, is compared to the relational mode's value.
+	Role        string `json:"role,omitempty"`
+	Related     string `json:"related,omitempty"`
+	RelatedRole string `json:"related_role,omitempty"`
+	RelatedKind string `json:"related_kind,omitempty"`
 }
 
-// checkAttributeCondition checks if a substance has a specific attribute value
-func (e *Engine) checkAttributeCondition(substanceID string, condition Condition) (bool, string) {
-	var mode entities.Mode
-	err := e.db.Joins("JOIN attributes ON modes.attribute_id = attributes.id").
-		Where("modes.substance_id = ? AND attributes.name = ?", substanceID, condition.Name).
-		First(&mode).Error
+// CheckConditions verifies if all conditions for a potentiality are met
+func (e *Engine) CheckConditions(potentialityID string) (_ bool, _ []string, err error) {
+	e, span := e.startSpan("CheckConditions", attribute.String("oaas.potentiality_id", potentialityID))
+	defer telemetry.EndSpan(span, &err)
 
+	potentiality, err := e.store.Potentialities.Get(e.ctx, potentialityID)
 	if err != nil {
-		return false, fmt.Sprintf("attribute '%s' not found for substance", condition.Name)
+		return false, nil, fmt.Errorf("potentiality not found: %w", err)
 	}
 
-	// Simple string comparison for now
-	if mode.Value != fmt.Sprintf("%v", condition.Value) {
-		return false, fmt.Sprintf("attribute '%s' has value '%s', expected '%v'", condition.Name, mode.Value, condition.Value)
+	// Parse conditions from JSON
+	var conditions []Condition
+	if err := json.Unmarshal([]byte(potentiality.Conditions), &conditions); err != nil {
+		return false, nil, fmt.Errorf("invalid conditions format: %w", err)
 	}
 
-	return true, ""
-}
-
-// checkModeCondition checks if a substance has a specific mode
-func (e *Engine) checkModeCondition(substanceID string, condition Condition) (bool, string) {
-	var count int64
-	err := e.db.Model(&entities.Mode{}).
-		Joins("JOIN attributes ON modes.attribute_id = attributes.id").
-		Where("modes.substance_id = ? AND attributes.name = ? AND modes.value = ?",
-			substanceID, condition.Name, condition.Value).
-		Count(&count).Error
-
+	facts, err := e.LoadFacts(potentiality.SubstanceID)
 	if err != nil {
-		return false, fmt.Sprintf("error checking mode condition: %v", err)
+		return false, nil, err
 	}
 
-	if count == 0 {
-		return false, fmt.Sprintf("mode condition not met: %s = %v", condition.Name, condition.Value)
+	allMet, unmetConditions := facts.EvaluateAll(conditions)
+	span.SetAttributes(attribute.Bool("oaas.conditions_met", allMet))
+	if allMet {
+		conditionChecks.WithLabelValues("met").Inc()
+	} else {
+		conditionChecks.WithLabelValues("unmet").Inc()
 	}
-
-	return true, ""
+	return allMet, unmetConditions, nil
 }
 
 // ActualizePotentiality converts a potentiality to an actuality
-func (e *Engine) ActualizePotentiality(potentialityID, description string) (*entities.Actuality, error) {
+func (e *Engine) ActualizePotentiality(potentialityID, description string) (_ *entities.Actuality, err error) {
+	e, span := e.startSpan("ActualizePotentiality", attribute.String("oaas.potentiality_id", potentialityID))
+	defer telemetry.EndSpan(span, &err)
+
 	// Check if conditions are met
 	canActualize, unmetConditions, err := e.CheckConditions(potentialityID)
 	if err != nil {
+		actualizations.WithLabelValues("failed").Inc()
 		return nil, err
 	}
 
 	if !canActualize {
+		actualizations.WithLabelValues("unmet").Inc()
 		return nil, fmt.Errorf("cannot actualize potentiality: conditions not met: %v", unmetConditions)
 	}
 
 	// Get the potentiality
-	var potentiality entities.Potentiality
-	if err := e.db.First(&potentiality, "id = ?", potentialityID).Error; err != nil {
+	potentiality, err := e.store.Potentialities.Get(e.ctx, potentialityID)
+	if err != nil {
+		actualizations.WithLabelValues("failed").Inc()
 		return nil, fmt.Errorf("potentiality not found: %w", err)
 	}
 
@@ -131,59 +150,212 @@ func (e *Engine) ActualizePotentiality(potentialityID, description string) (*ent
 	actuality := entities.NewActuality(description, potentiality.SubstanceID, potentialityID)
 
 	// Save to database
-	if err := e.db.Create(actuality).Error; err != nil {
+	if err := e.store.Actualities.Create(e.ctx, actuality); err != nil {
+		actualizations.WithLabelValues("failed").Inc()
 		return nil, fmt.Errorf("failed to create actuality: %w", err)
 	}
+	actualizations.WithLabelValues("succeeded").Inc()
+	span.SetAttributes(attribute.String("oaas.actuality_id", actuality.ID))
 
-	log.Printf("Potentiality '%s' actualized as '%s'", potentiality.Name, description)
+	slog.InfoContext(e.ctx, "potentiality actualized",
+		"potentiality_id", potentialityID, "potentiality", potentiality.Name,
+		"actuality_id", actuality.ID, "description", description)
 	return actuality, nil
 }
 
-// GetFourCauses returns the four Aristotelian causes for a substance
-func (e *Engine) GetFourCauses(substanceID string) (map[string]string, error) {
-	causes := make(map[string]string)
+// CauseTypes lists the four Aristotelian causes in their conventional order
+var CauseTypes = []string{"material", "formal", "efficient", "final"}
+
+// CauseEntry is one causal relation seen from a substance, with the entity on its other side
+type CauseEntry struct {
+	RelationID string    `json:"relation_id"`
+	Entity     EntityRef `json:"entity"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FourCauses groups every causal relation touching a substance by cause type
+type FourCauses struct {
+	SubstanceID string                  `json:"substance_id"`
+	Causes      map[string][]CauseEntry `json:"causes"`  // what causes the substance
+	Effects     map[string][]CauseEntry `json:"effects"` // what the substance is a cause of
+	Missing     []string                `json:"missing"` // cause types with no recorded cause
+}
+
+// GetFourCauses returns the four Aristotelian causes for a substance.
+// Relations whose FromEntity is the substance are its causes; relations whose
+// ToEntity is the substance are things it causes.
+func (e *Engine) GetFourCauses(substanceID string) (_ *FourCauses, err error) {
+	e, span := e.startSpan("GetFourCauses", attribute.String("oaas.substance_id", substanceID))
+	defer telemetry.EndSpan(span, &err)
 
 	// Get all causal relations for this substance
-	var relations []entities.CausalRelation
-	if err := e.db.Where("from_entity = ? OR to_entity = ?", substanceID, substanceID).Find(&relations).Error; err != nil {
+	relations, err := e.store.Causes.List(e.ctx, CausalFilter{EntityID: substanceID})
+	if err != nil {
 		return nil, fmt.Errorf("failed to get causal relations: %w", err)
 	}
 
-	// Group by cause type
+	ids := make([]string, 0, len(relations))
+	for _, relation := range relations {
+		ids = append(ids, relation.FromEntity, relation.ToEntity)
+	}
+	refs, err := e.ResolveEntities(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FourCauses{
+		SubstanceID: substanceID,
+		Causes:      make(map[string][]CauseEntry, len(CauseTypes)),
+		Effects:     make(map[string][]CauseEntry, len(CauseTypes)),
+		Missing:     []string{},
+	}
+	for _, causeType := range CauseTypes {
+		result.Causes[causeType] = []CauseEntry{}
+		result.Effects[causeType] = []CauseEntry{}
+	}
+
+	// Group by cause type and direction; a self-relation counts both ways
 	for _, relation := range relations {
-		causes[relation.CauseType] = relation.ToEntity
+		if relation.FromEntity == substanceID {
+			result.Causes[relation.CauseType] = append(result.Causes[relation.CauseType], CauseEntry{
+				RelationID: relation.ID,
+				Entity:     refs[relation.ToEntity],
+				CreatedAt:  relation.CreatedAt,
+			})
+		}
+		if relation.ToEntity == substanceID {
+			result.Effects[relation.CauseType] = append(result.Effects[relation.CauseType], CauseEntry{
+				RelationID: relation.ID,
+				Entity:     refs[relation.FromEntity],
+				CreatedAt:  relation.CreatedAt,
+			})
+		}
+	}
+
+	for _, causeType := range CauseTypes {
+		if len(result.Causes[causeType]) == 0 {
+			result.Missing = append(result.Missing, causeType)
+		}
+	}
+
+	return result, nil
+}
+
+// Cause returns the ID of the first recorded cause of the given type, or "" if there is none
+func (f *FourCauses) Cause(causeType string) string {
+	if entries := f.Causes[causeType]; len(entries) > 0 {
+		return entries[0].Entity.ID
 	}
+	return ""
+}
 
-	return causes, nil
+// CausalRelationInput describes a causal relation to record
+type CausalRelationInput struct {
+	FromEntity string
+	ToEntity   string
+	CauseType  string
+	FromType   string // entity type of FromEntity; inferred when empty
+	ToType     string // entity type of ToEntity; inferred when empty
+
+	Strength   *float64 // defaults to 1 when nil
+	Evidence   string
+	Notes      string
+	ValidFrom  *time.Time
+	ValidUntil *time.Time
 }
 
 // AddCausalRelation adds a new causal relation
 func (e *Engine) AddCausalRelation(fromEntity, toEntity, causeType string) (*entities.CausalRelation, error) {
+	return e.RecordCausalRelation(CausalRelationInput{
+		FromEntity: fromEntity,
+		ToEntity:   toEntity,
+		CauseType:  causeType,
+	})
+}
+
+// RecordCausalRelation adds a new causal relation with its strength, evidence and validity period
+func (e *Engine) RecordCausalRelation(input CausalRelationInput) (_ *entities.CausalRelation, err error) {
+	e, span := e.startSpan("RecordCausalRelation", attribute.String("oaas.cause_type", input.CauseType))
+	defer telemetry.EndSpan(span, &err)
+
 	// Validate cause type
-	validTypes := map[string]bool{
-		"material":  true,
-		"formal":    true,
-		"efficient": true,
-		"final":     true,
+	if !validCauseTypes[input.CauseType] {
+		return nil, fmt.Errorf("invalid cause type: %s. Must be one of: material, formal, efficient, final", input.CauseType)
 	}
 
-	if !validTypes[causeType] {
-		return nil, fmt.Errorf("invalid cause type: %s. Must be one of: material, formal, efficient, final", causeType)
+	if input.Strength != nil && (*input.Strength <= 0 || *input.Strength > 1) {
+		return nil, fmt.Errorf("invalid strength: %v. Must be greater than 0 and at most 1", *input.Strength)
 	}
 
-	relation := entities.NewCausalRelation(causeType, fromEntity, toEntity)
+	if input.ValidFrom != nil && input.ValidUntil != nil && input.ValidUntil.Before(*input.ValidFrom) {
+		return nil, fmt.Errorf("invalid validity period: valid_until is before valid_from")
+	}
 
-	if err := e.db.Create(relation).Error; err != nil {
+	// Verify or infer what each side refers to
+	fromType, err := e.resolveEndpoint(input.FromEntity, input.FromType)
+	if err != nil {
+		return nil, err
+	}
+	toType, err := e.resolveEndpoint(input.ToEntity, input.ToType)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCauseTarget(input.CauseType, toType); err != nil {
+		return nil, err
+	}
+
+	relation := entities.NewCausalRelation(input.CauseType, input.FromEntity, input.ToEntity)
+	relation.FromType = fromType
+	relation.ToType = toType
+	if input.Strength != nil {
+		relation.Strength = *input.Strength
+	}
+	relation.Evidence = input.Evidence
+	relation.Notes = input.Notes
+	relation.ValidFrom = input.ValidFrom
+	relation.ValidUntil = input.ValidUntil
+
+	if err := e.store.Causes.Create(e.ctx, relation); err != nil {
 		return nil, fmt.Errorf("failed to create causal relation: %w", err)
 	}
+	causalRelationsCreated.WithLabelValues(relation.CauseType).Inc()
 
 	return relation, nil
 }
 
+// CausalFilter restricts which causal relations a query considers
+type CausalFilter = repository.CauseFilter
+
+// ListCausalRelations returns the causal relations matching a filter
+func (e *Engine) ListCausalRelations(filter CausalFilter) (_ []entities.CausalRelation, err error) {
+	e, span := e.startSpan("ListCausalRelations", attribute.String("oaas.entity_id", filter.EntityID))
+	defer telemetry.EndSpan(span, &err)
+
+	if err := validateCauseTypes(filter.CauseTypes); err != nil {
+		return nil, err
+	}
+
+	relations, err := e.store.Causes.List(e.ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get causal relations: %w", err)
+	}
+	return relations, nil
+}
+
+// validateCauseTypes rejects any cause type outside the four causes
+func validateCauseTypes(causeTypes []string) error {
+	for _, causeType := range causeTypes {
+		if !validCauseTypes[causeType] {
+			return fmt.Errorf("invalid cause type: %s. Must be one of: material, formal, efficient, final", causeType)
+		}
+	}
+	return nil
+}
+
 // GetPotentialitiesForSubstance returns all potentialities for a substance
 func (e *Engine) GetPotentialitiesForSubstance(substanceID string) ([]entities.Potentiality, error) {
-	var potentialities []entities.Potentiality
-	if err := e.db.Where("substance_id = ?", substanceID).Find(&potentialities).Error; err != nil {
+	potentialities, err := e.store.Potentialities.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get potentialities: %w", err)
 	}
 	return potentialities, nil
@@ -191,32 +363,37 @@ func (e *Engine) GetPotentialitiesForSubstance(substanceID string) ([]entities.P
 
 // GetActualitiesForSubstance returns all actualities for a substance
 func (e *Engine) GetActualitiesForSubstance(substanceID string) ([]entities.Actuality, error) {
-	var actualities []entities.Actuality
-	if err := e.db.Preload("Potentiality").Where("substance_id = ?", substanceID).Find(&actualities).Error; err != nil {
+	actualities, err := e.store.Actualities.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get actualities: %w", err)
 	}
 	return actualities, nil
 }
 
 // CreatePotentiality creates a new potentiality for a substance
-func (e *Engine) CreatePotentiality(name, description, conditions string, substanceID string) (*entities.Potentiality, error) {
+func (e *Engine) CreatePotentiality(name, description, conditions string, substanceID string) (_ *entities.Potentiality, err error) {
+	e, span := e.startSpan("CreatePotentiality", attribute.String("oaas.substance_id", substanceID))
+	defer telemetry.EndSpan(span, &err)
+
 	// Validate that the substance exists
-	var substance entities.Substance
-	if err := e.db.First(&substance, "id = ?", substanceID).Error; err != nil {
-		return nil, fmt.Errorf("substance not found: %w", err)
+	substances, err := e.store.Substances.Find(e.ctx, []string{substanceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get substance: %w", err)
+	}
+	if len(substances) == 0 {
+		return nil, fmt.Errorf("substance not found: %w", repository.ErrNotFound)
 	}
 
-	// Validate conditions JSON format
-	var testConditions []Condition
+	// Reject conditions the engine could not evaluate
 	if conditions != "" {
-		if err := json.Unmarshal([]byte(conditions), &testConditions); err != nil {
-			return nil, fmt.Errorf("invalid conditions JSON format: %w", err)
+		if _, err := ParseConditions(conditions); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConditions, err)
 		}
 	}
 
 	potentiality := entities.NewPotentiality(name, description, conditions, substanceID)
 
-	if err := e.db.Create(potentiality).Error; err != nil {
+	if err := e.store.Potentialities.Create(e.ctx, potentiality); err != nil {
 		return nil, fmt.Errorf("failed to create potentiality: %w", err)
 	}
 
@@ -224,7 +401,10 @@ func (e *Engine) CreatePotentiality(name, description, conditions string, substa
 }
 
 // GetSubstanceEvolution returns the evolution of a substance from potentialities to actualities
-func (e *Engine) GetSubstanceEvolution(substanceID string) (*SubstanceEvolution, error) {
+func (e *Engine) GetSubstanceEvolution(substanceID string) (_ *SubstanceEvolution, err error) {
+	e, span := e.startSpan("GetSubstanceEvolution", attribute.String("oaas.substance_id", substanceID))
+	defer telemetry.EndSpan(span, &err)
+
 	potentialities, err := e.GetPotentialitiesForSubstance(substanceID)
 	if err != nil {
 		return nil, err
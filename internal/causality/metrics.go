@@ -0,0 +1,22 @@
+package causality
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Engine metrics in the default registry
+var (
+	conditionChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oaas_condition_checks_total",
+		Help: "Potentialities whose conditions were checked, by whether all were met (met or unmet).",
+	}, []string{"result"})
+	actualizations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oaas_actualizations_total",
+		Help: "Attempts to actualize a potentiality, by result (succeeded, unmet or failed).",
+	}, []string{"result"})
+	causalRelationsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oaas_causal_relations_created_total",
+		Help: "Causal relations recorded, by cause type.",
+	}, []string{"cause_type"})
+)
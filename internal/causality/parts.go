@@ -0,0 +1,119 @@
+package causality
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/mereology"
+)
+
+// LoadPartFacts snapshots every part of a substance, direct and indirect, nearest first
+func (e *Engine) LoadPartFacts(wholeID string) ([]*SubstanceFacts, error) {
+	if e.db == nil {
+		return nil, ErrNoDatabase
+	}
+	ids, err := mereology.NewMereology(e.db).PartIDs(wholeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var substances []entities.Substance
+	if err := e.db.Where("id IN ?", ids).Find(&substances).Error; err != nil {
+		return nil, fmt.Errorf("failed to get parts: %w", err)
+	}
+	var modes []entities.Mode
+	if err := e.db.Preload("Attribute").Where("substance_id IN ?", ids).
+		Order("created_at, id").Find(&modes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get modes: %w", err)
+	}
+
+	bySubstance := make(map[string][]entities.Mode, len(ids))
+	for _, mode := range modes {
+		bySubstance[mode.SubstanceID] = append(bySubstance[mode.SubstanceID], mode)
+	}
+	byID := make(map[string]entities.Substance, len(substances))
+	for _, substance := range substances {
+		byID[substance.ID] = substance
+	}
+
+	relations, err := e.loadRelations(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]*SubstanceFacts, 0, len(ids))
+	for _, id := range ids {
+		if substance, ok := byID[id]; ok {
+			facts := FactsFromModes(substance, bySubstance[id])
+			facts.Relations = relations[id]
+			parts = append(parts, facts)
+		}
+	}
+	return parts, nil
+}
+
+// PartAggregate summarizes the values a substance's parts have for an attribute
+type PartAggregate struct {
+	SubstanceID string         `json:"substance_id"`
+	Attribute   string         `json:"attribute"`
+	PartKind    string         `json:"part_kind,omitempty"`
+	Parts       int            `json:"parts"`      // parts considered
+	WithValue   int            `json:"with_value"` // parts having a value for the attribute
+	Values      map[string]int `json:"values"`     // number of parts having each value
+
+	// Numeric summaries, present when every value is a number
+	Sum  *float64 `json:"sum,omitempty"`
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+	Mean *float64 `json:"mean,omitempty"`
+}
+
+// AggregateParts summarizes an attribute over the substance's parts, optionally only those of one kind
+func (f *SubstanceFacts) AggregateParts(attribute, partKind string) PartAggregate {
+	aggregate := PartAggregate{SubstanceID: f.SubstanceID, Attribute: attribute, PartKind: partKind, Values: map[string]int{}}
+
+	var numbers []float64
+	numeric := true
+	for _, part := range f.Parts {
+		if partKind != "" && part.Kind != partKind {
+			continue
+		}
+		aggregate.Parts++
+		values := part.Values[attribute]
+		if len(values) == 0 {
+			continue
+		}
+		aggregate.WithValue++
+		seen := make(map[string]bool, len(values))
+		for _, value := range values {
+			if !seen[value] {
+				seen[value] = true
+				aggregate.Values[value]++
+			}
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				numeric = false
+				continue
+			}
+			numbers = append(numbers, number)
+		}
+	}
+
+	if !numeric || len(numbers) == 0 {
+		return aggregate
+	}
+	sum, low, high := 0.0, math.Inf(1), math.Inf(-1)
+	for _, number := range numbers {
+		sum += number
+		low = math.Min(low, number)
+		high = math.Max(high, number)
+	}
+	mean := sum / float64(len(numbers))
+	aggregate.Sum, aggregate.Min, aggregate.Max, aggregate.Mean = &sum, &low, &high, &mean
+	return aggregate
+}
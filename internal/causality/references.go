@@ -0,0 +1,223 @@
+package causality
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+)
+
+// EntityRef identifies an entity referenced by ID from a causal relation
+type EntityRef struct {
+	ID   string `json:"id"`
+	Type string `json:"type"` // one of the entities.EntityType* names
+	Name string `json:"name,omitempty"`
+}
+
+// ResolveEntities looks up each ID across every entity table and reports its type and name.
+// IDs that match no stored entity resolve to entities.EntityTypeExternal with an empty name.
+func (e *Engine) ResolveEntities(ids []string) (map[string]EntityRef, error) {
+	refs := make(map[string]EntityRef, len(ids))
+	pending := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, seen := refs[id]; seen {
+			continue
+		}
+		refs[id] = EntityRef{ID: id, Type: entities.EntityTypeExternal}
+		pending = append(pending, id)
+	}
+	if len(pending) == 0 {
+		return refs, nil
+	}
+
+	substances, err := e.store.Substances.Find(e.ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve substances: %w", err)
+	}
+	for _, substance := range substances {
+		refs[substance.ID] = EntityRef{ID: substance.ID, Type: entities.EntityTypeSubstance, Name: substance.Name}
+	}
+
+	kinds, err := e.store.Kinds.Find(e.ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve kinds: %w", err)
+	}
+	for _, kind := range kinds {
+		refs[kind.ID] = EntityRef{ID: kind.ID, Type: entities.EntityTypeKind, Name: kind.Name}
+	}
+
+	attributes, err := e.store.Attributes.Find(e.ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve attributes: %w", err)
+	}
+	for _, attribute := range attributes {
+		refs[attribute.ID] = EntityRef{ID: attribute.ID, Type: entities.EntityTypeAttribute, Name: attribute.Name}
+	}
+
+	modes, err := e.store.Modes.Find(e.ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve modes: %w", err)
+	}
+	for _, mode := range modes {
+		name := mode.Value
+		if mode.Attribute != nil {
+			name = mode.Attribute.Name + "=" + mode.Value
+		}
+		refs[mode.ID] = EntityRef{ID: mode.ID, Type: entities.EntityTypeMode, Name: name}
+	}
+
+	potentialities, err := e.store.Potentialities.Find(e.ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve potentialities: %w", err)
+	}
+	for _, potentiality := range potentialities {
+		refs[potentiality.ID] = EntityRef{ID: potentiality.ID, Type: entities.EntityTypePotentiality, Name: potentiality.Name}
+	}
+
+	actualities, err := e.store.Actualities.Find(e.ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve actualities: %w", err)
+	}
+	for _, actuality := range actualities {
+		refs[actuality.ID] = EntityRef{ID: actuality.ID, Type: entities.EntityTypeActuality, Name: actuality.Description}
+	}
+
+	return refs, nil
+}
+
+var (
+	// ErrEntityNotFound is returned when a reference names an entity that does not exist
+	ErrEntityNotFound = errors.New("referenced entity not found")
+	// ErrCauseTypeRule is returned when a cause type may not point at the referenced kind of entity
+	ErrCauseTypeRule = errors.New("cause type does not permit this entity type")
+)
+
+// causeTargetTypes lists the stored entity types each cause type may point at.
+// External references are always permitted since they name concepts outside the store.
+var causeTargetTypes = map[string][]string{
+	"material":  {entities.EntityTypeSubstance, entities.EntityTypeKind},
+	"formal":    {entities.EntityTypeKind},
+	"efficient": {entities.EntityTypeSubstance, entities.EntityTypeMode, entities.EntityTypeActuality},
+	"final":     {entities.EntityTypePotentiality, entities.EntityTypeActuality},
+}
+
+// EntityExists reports whether an entity of the given stored type exists
+func (e *Engine) EntityExists(entityType, id string) (bool, error) {
+	ids := []string{id}
+	var found int
+	var err error
+	switch entityType {
+	case entities.EntityTypeSubstance:
+		var substances []entities.Substance
+		substances, err = e.store.Substances.Find(e.ctx, ids)
+		found = len(substances)
+	case entities.EntityTypeKind:
+		var kinds []entities.Kind
+		kinds, err = e.store.Kinds.Find(e.ctx, ids)
+		found = len(kinds)
+	case entities.EntityTypeAttribute:
+		var attributes []entities.Attribute
+		attributes, err = e.store.Attributes.Find(e.ctx, ids)
+		found = len(attributes)
+	case entities.EntityTypeMode:
+		var modes []entities.Mode
+		modes, err = e.store.Modes.Find(e.ctx, ids)
+		found = len(modes)
+	case entities.EntityTypePotentiality:
+		var potentialities []entities.Potentiality
+		potentialities, err = e.store.Potentialities.Find(e.ctx, ids)
+		found = len(potentialities)
+	case entities.EntityTypeActuality:
+		var actualities []entities.Actuality
+		actualities, err = e.store.Actualities.Find(e.ctx, ids)
+		found = len(actualities)
+	default:
+		return false, fmt.Errorf("invalid entity type: %s", entityType)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %s: %w", entityType, err)
+	}
+	return found > 0, nil
+}
+
+// resolveEndpoint determines the entity type of one side of a causal relation.
+// A declared type is verified against the store and an undeclared one inferred
+// from it. An ID matching no stored entity is only accepted as external when
+// declared so, so that a mistyped ID is not silently taken for one.
+func (e *Engine) resolveEndpoint(id, declaredType string) (string, error) {
+	switch {
+	case declaredType == entities.EntityTypeExternal:
+		return declaredType, nil
+	case declaredType != "":
+		exists, err := e.EntityExists(declaredType, id)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "", fmt.Errorf("%w: %s %s", ErrEntityNotFound, declaredType, id)
+		}
+		return declaredType, nil
+	}
+
+	refs, err := e.ResolveEntities([]string{id})
+	if err != nil {
+		return "", err
+	}
+	if refs[id].Type == entities.EntityTypeExternal {
+		return "", fmt.Errorf("%w: %s matches no stored entity; declare its type as external to reference something outside the store",
+			ErrEntityNotFound, id)
+	}
+	return refs[id].Type, nil
+}
+
+// checkCauseTarget enforces which entity types a cause type may point at
+func checkCauseTarget(causeType, targetType string) error {
+	if targetType == entities.EntityTypeExternal {
+		return nil
+	}
+	for _, allowed := range causeTargetTypes[causeType] {
+		if allowed == targetType {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: a %s cause cannot be a %s", ErrCauseTypeRule, causeType, targetType)
+}
+
+// DetachEntities deletes every causal relation that references any of the given IDs.
+// It is called when entities are deleted so no relation is left pointing at nothing.
+func (e *Engine) DetachEntities(ids ...string) (int64, error) {
+	detached, err := e.store.Causes.Detach(e.ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to detach causal relations: %w", err)
+	}
+	return detached, nil
+}
+
+// DetachSubstance deletes causal relations referencing a substance or any of its
+// modes, potentialities and actualities, which are deleted along with it
+func (e *Engine) DetachSubstance(substanceID string) (int64, error) {
+	modes, err := e.store.Modes.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find dependents of substance: %w", err)
+	}
+	potentialities, err := e.store.Potentialities.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find dependents of substance: %w", err)
+	}
+	actualities, err := e.store.Actualities.ListBySubstance(e.ctx, substanceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find dependents of substance: %w", err)
+	}
+
+	ids := []string{substanceID}
+	for _, mode := range modes {
+		ids = append(ids, mode.ID)
+	}
+	for _, potentiality := range potentialities {
+		ids = append(ids, potentiality.ID)
+	}
+	for _, actuality := range actualities {
+		ids = append(ids, actuality.ID)
+	}
+	return e.DetachEntities(ids...)
+}
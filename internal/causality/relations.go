@@ -0,0 +1,334 @@
+package causality
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRelation is returned when a relational mode does not fit its attribute's roles
+	ErrInvalidRelation = errors.New("invalid relation")
+	// ErrDuplicateRelation is returned when the same bearers already bear the relational mode
+	ErrDuplicateRelation = errors.New("relation already asserted")
+)
+
+// BearerInput names a substance bearing a relational mode and, optionally, its role
+type BearerInput struct {
+	SubstanceID string
+	Role        string // taken from the bearer's position when empty
+}
+
+// RelationInput describes a relational mode to assert
+type RelationInput struct {
+	AttributeID string
+	Value       string // qualifies the relation, e.g. "by 10cm"; may be empty
+	Bearers     []BearerInput
+}
+
+// RelationFilter narrows a listing of relational modes
+type RelationFilter struct {
+	SubstanceID string // only relations the substance bears
+	Role        string // only those it bears in this role; requires SubstanceID
+	AttributeID string
+}
+
+// RelationFact is a relational mode as seen from one of its bearers
+type RelationFact struct {
+	ModeID    string
+	Attribute string
+	Value     string
+	Role      string          // role the substance plays
+	Position  int             // position of that role
+	Bearers   []RelatedBearer // every bearer, in role order
+}
+
+// RelatedBearer is a bearer of a relational mode
+type RelatedBearer struct {
+	Role        string
+	SubstanceID string
+	Kind        string
+}
+
+// AssertRelation stores a relational mode borne by one substance per role of a
+// relational attribute. Bearers either all name their roles, in any order, or
+// none do and take the attribute's roles in order.
+func (e *Engine) AssertRelation(input RelationInput) (*entities.Mode, error) {
+	if e.db == nil {
+		return nil, ErrNoDatabase
+	}
+	var attribute entities.Attribute
+	if err := e.db.Where("id = ?", input.AttributeID).Limit(1).Find(&attribute).Error; err != nil {
+		return nil, fmt.Errorf("failed to get attribute: %w", err)
+	}
+	if attribute.ID == "" {
+		return nil, fmt.Errorf("%w: attribute %s", ErrEntityNotFound, input.AttributeID)
+	}
+	roles, err := attribute.BearerRoles()
+	if err != nil {
+		return nil, fmt.Errorf("%w: attribute '%s' has malformed roles: %v", ErrInvalidRelation, attribute.Name, err)
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("%w: attribute '%s' is not relational", ErrInvalidRelation, attribute.Name)
+	}
+	if len(input.Bearers) != len(roles) {
+		return nil, fmt.Errorf("%w: attribute '%s' takes %d bearers, got %d", ErrInvalidRelation, attribute.Name, len(roles), len(input.Bearers))
+	}
+
+	ordered, err := orderBearers(roles, input.Bearers)
+	if err != nil {
+		return nil, err
+	}
+	var found []entities.Substance
+	if err := e.db.Where("id IN ?", ordered).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substances: %w", err)
+	}
+	substances := make(map[string]entities.Substance, len(found))
+	for _, substance := range found {
+		substances[substance.ID] = substance
+	}
+	for _, bearer := range ordered {
+		if _, ok := substances[bearer]; !ok {
+			return nil, fmt.Errorf("%w: substance %s", ErrEntityNotFound, bearer)
+		}
+	}
+
+	existing, err := e.ListRelations(RelationFilter{SubstanceID: ordered[0], Role: roles[0], AttributeID: attribute.ID})
+	if err != nil {
+		return nil, err
+	}
+	for _, mode := range existing {
+		if mode.Value == input.Value && sameBearers(mode.Bearers, ordered) {
+			return nil, ErrDuplicateRelation
+		}
+	}
+
+	mode := entities.NewMode(input.Value, ordered[0], attribute.ID)
+	if err := e.db.Create(mode).Error; err != nil {
+		return nil, fmt.Errorf("failed to create mode: %w", err)
+	}
+	for position, substanceID := range ordered {
+		bearer := entities.NewModeBearer(mode.ID, substanceID, roles[position], position)
+		if err := e.db.Create(bearer).Error; err != nil {
+			return nil, fmt.Errorf("failed to create mode bearer: %w", err)
+		}
+		substance := substances[substanceID]
+		bearer.Substance = &substance
+		mode.Bearers = append(mode.Bearers, *bearer)
+	}
+	mode.Attribute = &attribute
+	return mode, nil
+}
+
+// orderBearers returns the bearers' substance IDs in the order of the roles they fill
+func orderBearers(roles []string, bearers []BearerInput) ([]string, error) {
+	named := 0
+	for _, bearer := range bearers {
+		if bearer.Role != "" {
+			named++
+		}
+	}
+	if named == 0 {
+		ordered := make([]string, len(bearers))
+		for i, bearer := range bearers {
+			ordered[i] = bearer.SubstanceID
+		}
+		return ordered, nil
+	}
+	if named < len(bearers) {
+		return nil, fmt.Errorf("%w: either every bearer names its role or none does", ErrInvalidRelation)
+	}
+
+	position := make(map[string]int, len(roles))
+	for i, role := range roles {
+		position[role] = i
+	}
+	ordered := make([]string, len(roles))
+	for _, bearer := range bearers {
+		i, ok := position[bearer.Role]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown role '%s'", ErrInvalidRelation, bearer.Role)
+		}
+		if ordered[i] != "" {
+			return nil, fmt.Errorf("%w: role '%s' is filled twice", ErrInvalidRelation, bearer.Role)
+		}
+		ordered[i] = bearer.SubstanceID
+	}
+	return ordered, nil
+}
+
+func sameBearers(bearers []entities.ModeBearer, ordered []string) bool {
+	if len(bearers) != len(ordered) {
+		return false
+	}
+	for i, bearer := range bearers {
+		if bearer.SubstanceID != ordered[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ListRelations returns relational modes with their attribute and bearers, oldest first
+func (e *Engine) ListRelations(filter RelationFilter) ([]entities.Mode, error) {
+	if e.db == nil {
+		return nil, ErrNoDatabase
+	}
+	query := e.db.Preload("Attribute").
+		Preload("Bearers", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Bearers.Substance").
+		Where("id IN (?)", e.db.Model(&entities.ModeBearer{}).Select("mode_id"))
+	if filter.SubstanceID != "" {
+		bearing := e.db.Model(&entities.ModeBearer{}).Select("mode_id").Where("substance_id = ?", filter.SubstanceID)
+		if filter.Role != "" {
+			bearing = bearing.Where("role = ?", filter.Role)
+		}
+		query = query.Where("id IN (?)", bearing)
+	}
+	if filter.AttributeID != "" {
+		query = query.Where("attribute_id = ?", filter.AttributeID)
+	}
+
+	var modes []entities.Mode
+	if err := query.Order("created_at, id").Find(&modes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get relations: %w", err)
+	}
+	return modes, nil
+}
+
+// LoadRelationFacts snapshots the relational modes a substance bears
+func (e *Engine) LoadRelationFacts(substanceID string) ([]RelationFact, error) {
+	facts, err := e.loadRelations([]string{substanceID})
+	if err != nil {
+		return nil, err
+	}
+	return facts[substanceID], nil
+}
+
+// loadRelations snapshots the relational modes each of the substances bears
+func (e *Engine) loadRelations(ids []string) (map[string][]RelationFact, error) {
+	if e.db == nil {
+		return nil, ErrNoDatabase
+	}
+	var modes []entities.Mode
+	err := e.db.Preload("Attribute").
+		Preload("Bearers", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Bearers.Substance").
+		Where("id IN (?)", e.db.Model(&entities.ModeBearer{}).Select("mode_id").Where("substance_id IN ?", ids)).
+		Order("created_at, id").Find(&modes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relations: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	facts := make(map[string][]RelationFact)
+	for _, mode := range modes {
+		if mode.Attribute == nil {
+			continue
+		}
+		related := make([]RelatedBearer, len(mode.Bearers))
+		for i, bearer := range mode.Bearers {
+			related[i] = RelatedBearer{Role: bearer.Role, SubstanceID: bearer.SubstanceID}
+			if bearer.Substance != nil {
+				related[i].Kind = bearer.Substance.Kind
+			}
+		}
+		for _, bearer := range mode.Bearers {
+			if !wanted[bearer.SubstanceID] {
+				continue
+			}
+			facts[bearer.SubstanceID] = append(facts[bearer.SubstanceID], RelationFact{
+				ModeID:    mode.ID,
+				Attribute: mode.Attribute.Name,
+				Value:     mode.Value,
+				Role:      bearer.Role,
+				Position:  bearer.Position,
+				Bearers:   related,
+			})
+		}
+	}
+	return facts, nil
+}
+
+// CoBearerIDs returns the other substances bearing a relational mode with the substance
+func (e *Engine) CoBearerIDs(substanceID string) ([]string, error) {
+	if e.db == nil {
+		return nil, ErrNoDatabase
+	}
+	var ids []string
+	err := e.db.Model(&entities.ModeBearer{}).Distinct("substance_id").
+		Where("mode_id IN (?) AND substance_id <> ?",
+			e.db.Model(&entities.ModeBearer{}).Select("mode_id").Where("substance_id = ?", substanceID), substanceID).
+		Order("substance_id").Pluck("substance_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get related substances: %w", err)
+	}
+	return ids, nil
+}
+
+// evaluateRelation checks a relation condition against the relations the substance bears
+func (f *SubstanceFacts) evaluateRelation(condition Condition, operator string) (bool, string) {
+	for _, relation := range f.Relations {
+		if relation.Attribute != condition.Name || (condition.Role != "" && relation.Role != condition.Role) {
+			continue
+		}
+		if condition.Value != nil && !compare(relation.Value, operator, condition.Value) {
+			continue
+		}
+		if relation.relates(condition) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("relation condition not met: substance bears no '%s' relation%s", condition.Name, describeRelation(condition, operator))
+}
+
+// relates reports whether another bearer of the relation is the one the condition asks for
+func (r RelationFact) relates(condition Condition) bool {
+	if condition.Related == "" && condition.RelatedRole == "" && condition.RelatedKind == "" {
+		return true
+	}
+	for position, bearer := range r.Bearers {
+		if position == r.Position {
+			continue
+		}
+		if (condition.RelatedRole == "" || bearer.Role == condition.RelatedRole) &&
+			(condition.Related == "" || bearer.SubstanceID == condition.Related) &&
+			(condition.RelatedKind == "" || bearer.Kind == condition.RelatedKind) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeRelation spells out the qualifications of a relation condition
+func describeRelation(condition Condition, operator string) string {
+	description := ""
+	if condition.Role != "" {
+		description += fmt.Sprintf(" as %s", condition.Role)
+	}
+	if condition.Related != "" || condition.RelatedKind != "" || condition.RelatedRole != "" {
+		description += " with"
+		if condition.Related != "" {
+			description += " " + condition.Related
+		} else if condition.RelatedKind != "" {
+			description += fmt.Sprintf(" a %s", condition.RelatedKind)
+		} else {
+			description += " another substance"
+		}
+		if condition.RelatedRole != "" {
+			description += fmt.Sprintf(" as %s", condition.RelatedRole)
+		}
+		if condition.Related != "" && condition.RelatedKind != "" {
+			description += fmt.Sprintf(" of kind %s", condition.RelatedKind)
+		}
+	}
+	if condition.Value != nil {
+		description += fmt.Sprintf(" valued %s '%v'", operator, condition.Value)
+	}
+	return description
+}
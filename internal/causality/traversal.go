@@ -0,0 +1,583 @@
+package causality
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/workspace"
+)
+
+// A causal relation reads "ToEntity is a <CauseType> cause of FromEntity".
+// Walking from FromEntity to ToEntity therefore climbs towards ancestors
+// (causes), and walking the other way descends towards effects.
+
+const (
+	// DefaultTraversalDepth is used when no depth is requested
+	DefaultTraversalDepth = 10
+	// MaxTraversalDepth bounds every traversal to keep queries cheap
+	MaxTraversalDepth = 50
+	// MaxCycleLength bounds cycle detection, which considers every simple
+	// path up to its depth and so grows exponentially with it
+	MaxCycleLength = 10
+	// MaxCycles bounds how many cycles one detection reports
+	MaxCycles = 1000
+
+	// frontierChunk is how many entities one query of a level-by-level traversal expands
+	frontierChunk = 500
+
+	// pathSeparator joins entity IDs inside recursive CTE path columns
+	pathSeparator = "\x1f"
+)
+
+// ErrNoCausalPath is returned when two entities are not causally connected
+var ErrNoCausalPath = errors.New("no causal path")
+
+// TraversalOptions limits how far and along which relations a traversal walks
+type TraversalOptions struct {
+	MaxDepth    int        `json:"max_depth"`
+	CauseTypes  []string   `json:"cause_types,omitempty"` // empty means all cause types
+	MinStrength float64    `json:"min_strength,omitempty"`
+	ValidAt     *time.Time `json:"valid_at,omitempty"` // only follow relations that held at this time
+
+	// Weighted makes FindCausalPath return the strongest path, the one whose
+	// product of relation strengths is highest, instead of the shortest one
+	Weighted bool `json:"weighted,omitempty"`
+}
+
+// filter returns the relation filter implied by the options
+func (o TraversalOptions) filter() CausalFilter {
+	return CausalFilter{CauseTypes: o.CauseTypes, MinStrength: o.MinStrength, ValidAt: o.ValidAt}
+}
+
+// CausalNode is an entity reached during a traversal
+type CausalNode struct {
+	EntityID   string `json:"entity_id"`
+	Depth      int    `json:"depth"`       // number of relations between the start entity and this one
+	CauseType  string `json:"cause_type"`  // cause type of the relation that reached this entity
+	RelationID string `json:"relation_id"` // relation that reached this entity
+	Via        string `json:"via"`         // entity this one was reached from
+
+	// Strength is the product of relation strengths along the path that reached this entity
+	Strength float64 `json:"strength"`
+}
+
+// CausalPath is a chain of causal relations leading from one entity to another
+type CausalPath struct {
+	From      string                    `json:"from"`
+	To        string                    `json:"to"`
+	Entities  []string                  `json:"entities"`
+	Relations []entities.CausalRelation `json:"relations"`
+	Strength  float64                   `json:"strength"` // product of relation strengths along the path
+}
+
+// CausalCycle is a chain of causal relations that returns to its first entity
+type CausalCycle struct {
+	Entities  []string                  `json:"entities"`
+	Relations []entities.CausalRelation `json:"relations"`
+}
+
+// normalize applies defaults, bounds the depth by limit and validates cause types
+func (o TraversalOptions) normalize(limit int) (TraversalOptions, error) {
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = DefaultTraversalDepth
+	}
+	if o.MaxDepth > limit {
+		o.MaxDepth = limit
+	}
+	return o, validateCauseTypes(o.CauseTypes)
+}
+
+// GetAncestors returns the transitive causes of an entity up to the given depth
+func (e *Engine) GetAncestors(entityID string, opts TraversalOptions) ([]CausalNode, error) {
+	return e.traverse(entityID, opts, true)
+}
+
+// GetDescendants returns the transitive effects of an entity up to the given depth
+func (e *Engine) GetDescendants(entityID string, opts TraversalOptions) ([]CausalNode, error) {
+	return e.traverse(entityID, opts, false)
+}
+
+// traverse walks the causal graph from an entity, towards causes when up is
+// true. It expands a level at a time, from the entities first reached at the
+// level before, so that it visits each entity once however many paths lead
+// there. An entity is reached at its least depth, through the strongest of
+// the relations reaching it there.
+func (e *Engine) traverse(entityID string, opts TraversalOptions, up bool) ([]CausalNode, error) {
+	opts, err := opts.normalize(MaxTraversalDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	strength := map[string]float64{entityID: 1}
+	frontier := []string{entityID}
+	var nodes []CausalNode
+	for depth := 1; depth <= opts.MaxDepth && len(frontier) > 0; depth++ {
+		relations, err := e.relationsFrom(frontier, opts.filter(), up)
+		if err != nil {
+			return nil, fmt.Errorf("failed to traverse causal graph: %w", err)
+		}
+
+		reached := map[string]CausalNode{}
+		for _, current := range frontier {
+			for _, relation := range relations[current] {
+				target := relation.ToEntity
+				if !up {
+					target = relation.FromEntity
+				}
+				if _, visited := strength[target]; visited {
+					continue
+				}
+				node := CausalNode{
+					EntityID:   target,
+					Depth:      depth,
+					CauseType:  relation.CauseType,
+					RelationID: relation.ID,
+					Via:        current,
+					Strength:   strength[current] * relation.Strength,
+				}
+				if known, ok := reached[target]; ok && (known.Strength > node.Strength ||
+					known.Strength == node.Strength && known.RelationID < node.RelationID) {
+					continue
+				}
+				reached[target] = node
+			}
+		}
+
+		frontier = frontier[:0]
+		for target, node := range reached {
+			strength[target] = node.Strength
+			frontier = append(frontier, target)
+			nodes = append(nodes, node)
+		}
+		sort.Strings(frontier)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Depth != nodes[j].Depth {
+			return nodes[i].Depth < nodes[j].Depth
+		}
+		return nodes[i].EntityID < nodes[j].EntityID
+	})
+	return nodes, nil
+}
+
+// FindCausalPath returns the shortest chain of causal relations leading from one entity to another.
+// The path follows relations from effect to cause, so it answers "how is to a cause of from".
+// Like traversals, the search expands a level at a time and never enumerates paths.
+func (e *Engine) FindCausalPath(from, to string, opts TraversalOptions) (*CausalPath, error) {
+	opts, err := opts.normalize(MaxTraversalDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	var relationIDs []string
+	if opts.Weighted {
+		relationIDs, err = e.strongestPath(from, to, opts)
+	} else {
+		relationIDs, err = e.shortestPath(from, to, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search causal graph: %w", err)
+	}
+	if relationIDs == nil {
+		return nil, fmt.Errorf("%w from %s to %s within depth %d", ErrNoCausalPath, from, to, opts.MaxDepth)
+	}
+
+	relations, err := e.loadRelationsInOrder(relationIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	path := &CausalPath{From: from, To: to, Entities: []string{from}, Relations: relations, Strength: 1}
+	for _, relation := range relations {
+		path.Entities = append(path.Entities, relation.ToEntity)
+		path.Strength *= relation.Strength
+	}
+	return path, nil
+}
+
+// DetectCycles returns the causal cycles of at most MaxDepth relations, up to
+// MaxCycleLength, and at most MaxCycles of them. Each cycle is reported once,
+// starting from its lexically smallest entity.
+func (e *Engine) DetectCycles(opts TraversalOptions) ([]CausalCycle, error) {
+	opts, err := opts.normalize(MaxCycleLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var relationChains [][]string
+	if e.supportsRecursiveCTE() {
+		relationChains, err = e.cyclesCTE(opts)
+	} else {
+		relationChains, err = e.cyclesInMemory(opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect causal cycles: %w", err)
+	}
+
+	cycles := make([]CausalCycle, 0, len(relationChains))
+	for _, chain := range relationChains {
+		relations, err := e.loadRelationsInOrder(chain)
+		if err != nil {
+			return nil, err
+		}
+		cycle := CausalCycle{Relations: relations}
+		for _, relation := range relations {
+			cycle.Entities = append(cycle.Entities, relation.FromEntity)
+		}
+		cycles = append(cycles, cycle)
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return strings.Join(cycles[i].Entities, pathSeparator) < strings.Join(cycles[j].Entities, pathSeparator)
+	})
+	return cycles, nil
+}
+
+// supportsRecursiveCTE reports whether cycle detection can be pushed down to the database
+func (e *Engine) supportsRecursiveCTE() bool {
+	return e.db != nil && e.db.Dialector.Name() == "postgres"
+}
+
+// relationsFrom returns the relations the filter allows leaving each of the
+// entities, towards causes when up is true, in ID order
+func (e *Engine) relationsFrom(entityIDs []string, filter CausalFilter, up bool) (map[string][]entities.CausalRelation, error) {
+	var relations []entities.CausalRelation
+	for start := 0; start < len(entityIDs); start += frontierChunk {
+		chunk := entityIDs[start:min(start+frontierChunk, len(entityIDs))]
+		if up {
+			filter.FromEntities = chunk
+		} else {
+			filter.ToEntities = chunk
+		}
+		found, err := e.store.Causes.List(e.ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, found...)
+	}
+	sort.Slice(relations, func(i, j int) bool { return relations[i].ID < relations[j].ID })
+
+	leaving := make(map[string][]entities.CausalRelation)
+	for _, relation := range relations {
+		near := relation.FromEntity
+		if !up {
+			near = relation.ToEntity
+		}
+		leaving[near] = append(leaving[near], relation)
+	}
+	return leaving, nil
+}
+
+// loadRelationsInOrder loads causal relations by ID, preserving the order of ids
+func (e *Engine) loadRelationsInOrder(ids []string) ([]entities.CausalRelation, error) {
+	relations, err := e.store.Causes.Find(e.ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get causal relations: %w", err)
+	}
+
+	byID := make(map[string]entities.CausalRelation, len(relations))
+	for _, relation := range relations {
+		byID[relation.ID] = relation
+	}
+
+	ordered := make([]entities.CausalRelation, 0, len(ids))
+	for _, id := range ids {
+		relation, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("causal relation %s disappeared during traversal", id)
+		}
+		ordered = append(ordered, relation)
+	}
+	return ordered, nil
+}
+
+// Postgres implementation
+
+// scopedPredicate is filter.Predicate confined to the engine's workspace, which
+// raw SQL has to filter on itself
+func (e *Engine) scopedPredicate(alias string, filter CausalFilter) (string, []interface{}) {
+	predicate, args := filter.Predicate(alias)
+	return "(" + predicate + " AND " + alias + "workspace_id = ?)", append(args, workspace.ID(e.db))
+}
+
+func (e *Engine) cyclesCTE(opts TraversalOptions) ([][]string, error) {
+	seedFilter, seedArgs := e.scopedPredicate("", opts.filter())
+	stepFilter, stepArgs := e.scopedPredicate("cr.", opts.filter())
+
+	// Cycles are only grown through entities greater than their start,
+	// so each one is found exactly once from its smallest entity.
+	query := fmt.Sprintf(`
+WITH RECURSIVE walk(start_id, entity_id, depth, path, relations, closed) AS (
+	SELECT from_entity, to_entity, 1, ARRAY[from_entity, to_entity]::text[], ARRAY[id]::text[], from_entity = to_entity
+	FROM causal_relations
+	WHERE to_entity >= from_entity AND %s
+	UNION ALL
+	SELECT w.start_id, cr.to_entity, w.depth + 1, w.path || cr.to_entity::text, w.relations || cr.id::text, cr.to_entity = w.start_id
+	FROM causal_relations cr
+	JOIN walk w ON cr.from_entity = w.entity_id
+	WHERE NOT w.closed AND w.depth < ?
+		AND (cr.to_entity = w.start_id OR (cr.to_entity > w.start_id AND NOT cr.to_entity = ANY(w.path)))
+		AND %s
+)
+SELECT array_to_string(relations, ?) AS relations
+FROM walk
+WHERE closed
+LIMIT ?`, seedFilter, stepFilter)
+
+	args := append([]interface{}{}, seedArgs...)
+	args = append(args, opts.MaxDepth)
+	args = append(args, stepArgs...)
+	args = append(args, pathSeparator, MaxCycles)
+
+	var rows []struct{ Relations string }
+	if err := e.db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	chains := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		chains = append(chains, strings.Split(row.Relations, pathSeparator))
+	}
+	return chains, nil
+}
+
+// In-memory implementation used by stores without recursive CTE support
+
+// causalGraph is an adjacency list of causal relations keyed by entity
+type causalGraph struct {
+	up map[string][]entities.CausalRelation // relations keyed by FromEntity
+}
+
+// loadCausalGraph reads every relation matching the filter into memory, in ID order
+func (e *Engine) loadCausalGraph(filter CausalFilter) (*causalGraph, error) {
+	relations, err := e.store.Causes.List(e.ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(relations, func(i, j int) bool { return relations[i].ID < relations[j].ID })
+
+	graph := &causalGraph{
+		up: make(map[string][]entities.CausalRelation),
+	}
+	for _, relation := range relations {
+		graph.up[relation.FromEntity] = append(graph.up[relation.FromEntity], relation)
+	}
+	return graph, nil
+}
+
+// shortestPath searches breadth first for the fewest relations leading from one entity to another
+func (e *Engine) shortestPath(from, to string, opts TraversalOptions) ([]string, error) {
+	// reachedBy records the relation used to first reach each entity
+	reachedBy := map[string]entities.CausalRelation{}
+	visited := map[string]bool{from: true}
+	frontier := []string{from}
+
+	for depth := 1; depth <= opts.MaxDepth && len(frontier) > 0; depth++ {
+		relations, err := e.relationsFrom(frontier, opts.filter(), true)
+		if err != nil {
+			return nil, err
+		}
+		var next []string
+		for _, current := range frontier {
+			for _, relation := range relations[current] {
+				if relation.ToEntity == to {
+					return unwindPath(reachedBy, from, relation), nil
+				}
+				if visited[relation.ToEntity] {
+					continue
+				}
+				visited[relation.ToEntity] = true
+				reachedBy[relation.ToEntity] = relation
+				next = append(next, relation.ToEntity)
+			}
+		}
+		frontier = next
+	}
+
+	return nil, nil
+}
+
+// unwindPath rebuilds the relation IDs leading from start through last
+func unwindPath(reachedBy map[string]entities.CausalRelation, start string, last entities.CausalRelation) []string {
+	ids := []string{last.ID}
+	for current := last.FromEntity; current != start; {
+		relation := reachedBy[current]
+		ids = append(ids, relation.ID)
+		current = relation.FromEntity
+	}
+
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return ids
+}
+
+// pathStep is one relation of a walk, linked back to the step before it
+type pathStep struct {
+	strength float64
+	relation entities.CausalRelation
+	prev     *pathStep
+}
+
+// strongestPath finds the path of at most MaxDepth relations whose product of
+// strengths is highest, expanding each round only the entities the round
+// before reached more strongly than ever. Strengths never exceed 1, so
+// revisiting an entity can never strengthen a walk and the result is always
+// a simple path.
+func (e *Engine) strongestPath(from, to string, opts TraversalOptions) ([]string, error) {
+	best := map[string]*pathStep{from: {strength: 1}}
+	frontier := []string{from}
+
+	for depth := 1; depth <= opts.MaxDepth && len(frontier) > 0; depth++ {
+		relations, err := e.relationsFrom(frontier, opts.filter(), true)
+		if err != nil {
+			return nil, err
+		}
+
+		// Snapshot the frontier so every walk extended this round has exactly depth relations
+		reached := make(map[string]*pathStep, len(frontier))
+		for _, entityID := range frontier {
+			reached[entityID] = best[entityID]
+		}
+
+		improved := map[string]bool{}
+		for _, current := range frontier {
+			for _, relation := range relations[current] {
+				candidate := &pathStep{
+					strength: reached[current].strength * relation.Strength,
+					relation: relation,
+					prev:     reached[current],
+				}
+				if known, ok := best[relation.ToEntity]; ok && known.strength >= candidate.strength {
+					continue
+				}
+				best[relation.ToEntity] = candidate
+				improved[relation.ToEntity] = true
+			}
+		}
+
+		frontier = frontier[:0]
+		for entityID := range improved {
+			frontier = append(frontier, entityID)
+		}
+		sort.Strings(frontier)
+	}
+
+	last, ok := best[to]
+	if !ok || to == from {
+		return nil, nil
+	}
+
+	var ids []string
+	for step := last; step.prev != nil; step = step.prev {
+		ids = append(ids, step.relation.ID)
+	}
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return ids, nil
+}
+
+// trimAcyclic drops every relation lying on no cycle: repeatedly, those from
+// an entity nothing causes or to one that causes nothing. What remains of a
+// graph without cycles is empty, so searching it enumerates no paths.
+func (g *causalGraph) trimAcyclic() {
+	leaving := map[string]int{}  // relations walked from each entity
+	entering := map[string]int{} // relations walked to each entity
+	down := map[string][]entities.CausalRelation{}
+	for _, relations := range g.up {
+		for _, relation := range relations {
+			leaving[relation.FromEntity]++
+			entering[relation.ToEntity]++
+			down[relation.ToEntity] = append(down[relation.ToEntity], relation)
+		}
+	}
+
+	removed := map[string]bool{}
+	var queue []string
+	remove := func(entityID string) {
+		if !removed[entityID] && (leaving[entityID] == 0 || entering[entityID] == 0) {
+			removed[entityID] = true
+			queue = append(queue, entityID)
+		}
+	}
+	for entityID := range leaving {
+		remove(entityID)
+	}
+	for entityID := range entering {
+		remove(entityID)
+	}
+	for len(queue) > 0 {
+		entityID := queue[0]
+		queue = queue[1:]
+		for _, relation := range g.up[entityID] {
+			entering[relation.ToEntity]--
+			remove(relation.ToEntity)
+		}
+		for _, relation := range down[entityID] {
+			leaving[relation.FromEntity]--
+			remove(relation.FromEntity)
+		}
+	}
+
+	for entityID := range removed {
+		delete(g.up, entityID)
+	}
+	for entityID, relations := range g.up {
+		kept := relations[:0]
+		for _, relation := range relations {
+			if !removed[relation.ToEntity] {
+				kept = append(kept, relation)
+			}
+		}
+		g.up[entityID] = kept
+	}
+}
+
+func (e *Engine) cyclesInMemory(opts TraversalOptions) ([][]string, error) {
+	graph, err := e.loadCausalGraph(opts.filter())
+	if err != nil {
+		return nil, err
+	}
+	graph.trimAcyclic()
+
+	starts := make([]string, 0, len(graph.up))
+	for entityID := range graph.up {
+		starts = append(starts, entityID)
+	}
+	sort.Strings(starts)
+
+	var chains [][]string
+	for _, start := range starts {
+		onPath := map[string]bool{start: true}
+		var relationIDs []string
+
+		var walk func(current string)
+		walk = func(current string) {
+			for _, relation := range graph.up[current] {
+				next := relation.ToEntity
+				switch {
+				case len(chains) >= MaxCycles:
+					return
+				case next == start:
+					chain := append(append([]string{}, relationIDs...), relation.ID)
+					chains = append(chains, chain)
+				case next > start && !onPath[next] && len(relationIDs)+1 < opts.MaxDepth:
+					onPath[next] = true
+					relationIDs = append(relationIDs, relation.ID)
+					walk(next)
+					relationIDs = relationIDs[:len(relationIDs)-1]
+					onPath[next] = false
+				}
+			}
+		}
+		walk(start)
+	}
+
+	return chains, nil
+}
@@ -0,0 +1,178 @@
+package classification
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// Classifier sorts substances into kinds by the kinds' defining attributes.
+//
+// A kind's definition is a list of conditions on a substance's modes that are
+// together necessary and sufficient for membership: a substance belongs to the
+// kind exactly when all of them hold. Kinds without a definition are only ever
+// assigned by hand.
+type Classifier struct {
+	db     *gorm.DB
+	engine *causality.Engine
+}
+
+// NewClassifier creates a new classifier
+func NewClassifier(db *gorm.DB) *Classifier {
+	return &Classifier{db: db, engine: causality.NewEngine(db)}
+}
+
+var (
+	// ErrInvalidDefinition is returned when a kind definition is malformed
+	ErrInvalidDefinition = errors.New("invalid kind definition")
+	// ErrNoMatchingKind is returned when auto-assignment finds no kind whose definition a substance meets
+	ErrNoMatchingKind = errors.New("no kind definition matches the substance")
+	// ErrAmbiguousKind is returned when auto-assignment finds more than one kind whose definition a substance meets
+	ErrAmbiguousKind = errors.New("substance matches more than one kind definition")
+)
+
+// Misclassification reports a substance whose assigned kind's definition it does not meet
+type Misclassification struct {
+	SubstanceID   string   `json:"substance_id"`
+	SubstanceName string   `json:"substance_name"`
+	AssignedKind  string   `json:"assigned_kind"`
+	Unmet         []string `json:"unmet"`          // why the assigned kind's definition fails
+	MatchingKinds []string `json:"matching_kinds"` // kinds whose definitions the substance does meet
+}
+
+// ParseDefinition decodes a kind definition. Definitions speak only about a
+// substance's modes, so kind conditions, which would make membership circular,
+// and external conditions, which the store cannot check, are rejected.
+func ParseDefinition(raw string) ([]causality.Condition, error) {
+	conditions, err := causality.ParseConditions(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("%w: a definition needs at least one condition", ErrInvalidDefinition)
+	}
+	for _, condition := range conditions {
+		if condition.Type != causality.ConditionAttribute && condition.Type != causality.ConditionMode {
+			return nil, fmt.Errorf("%w: %s conditions cannot define a kind", ErrInvalidDefinition, condition.Type)
+		}
+	}
+	return conditions, nil
+}
+
+// definedKind is a kind with its definition decoded
+type definedKind struct {
+	name       string
+	conditions []causality.Condition
+}
+
+// definedKinds returns every kind that has a definition, ordered by name
+func (c *Classifier) definedKinds() ([]definedKind, error) {
+	var kinds []entities.Kind
+	if err := c.db.Where("definition IS NOT NULL AND definition <> ''").Order("name").Find(&kinds).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kinds: %w", err)
+	}
+
+	defined := make([]definedKind, 0, len(kinds))
+	for _, kind := range kinds {
+		conditions, err := ParseDefinition(kind.Definition)
+		if err != nil {
+			return nil, fmt.Errorf("kind %s: %w", kind.Name, err)
+		}
+		defined = append(defined, definedKind{name: kind.Name, conditions: conditions})
+	}
+	return defined, nil
+}
+
+// matching returns the names of the kinds whose definitions the facts meet
+func matching(kinds []definedKind, facts *causality.SubstanceFacts) []string {
+	names := []string{}
+	for _, kind := range kinds {
+		if met, _ := facts.EvaluateAll(kind.conditions); met {
+			names = append(names, kind.name)
+		}
+	}
+	return names
+}
+
+// Classify returns the names of the kinds a substance satisfies, ordered by name
+func (c *Classifier) Classify(substanceID string) ([]string, error) {
+	kinds, err := c.definedKinds()
+	if err != nil {
+		return nil, err
+	}
+	facts, err := c.engine.LoadFacts(substanceID)
+	if err != nil {
+		return nil, err
+	}
+	return matching(kinds, facts), nil
+}
+
+// AssignKind sets a substance's kind to the single kind whose definition it meets
+func (c *Classifier) AssignKind(substanceID string) (string, error) {
+	kinds, err := c.Classify(substanceID)
+	if err != nil {
+		return "", err
+	}
+	switch len(kinds) {
+	case 0:
+		return "", ErrNoMatchingKind
+	case 1:
+	default:
+		return "", fmt.Errorf("%w: %s", ErrAmbiguousKind, strings.Join(kinds, ", "))
+	}
+
+	if err := c.db.Model(&entities.Substance{}).Where("id = ?", substanceID).Update("kind", kinds[0]).Error; err != nil {
+		return "", fmt.Errorf("failed to assign kind: %w", err)
+	}
+	return kinds[0], nil
+}
+
+// Misclassified returns every substance assigned to a defined kind whose definition it does not meet
+func (c *Classifier) Misclassified() ([]Misclassification, error) {
+	kinds, err := c.definedKinds()
+	if err != nil {
+		return nil, err
+	}
+	if len(kinds) == 0 {
+		return []Misclassification{}, nil
+	}
+	byName := make(map[string]definedKind, len(kinds))
+	names := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		byName[kind.name] = kind
+		names = append(names, kind.name)
+	}
+
+	var substances []entities.Substance
+	if err := c.db.Where("kind IN ?", names).Order("kind, id").Find(&substances).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substances: %w", err)
+	}
+
+	result := []Misclassification{}
+	for _, substance := range substances {
+		assigned, ok := byName[substance.Kind]
+		if !ok {
+			continue
+		}
+		facts, err := c.engine.LoadFacts(substance.ID)
+		if err != nil {
+			return nil, err
+		}
+		met, unmet := facts.EvaluateAll(assigned.conditions)
+		if met {
+			continue
+		}
+		result = append(result, Misclassification{
+			SubstanceID:   substance.ID,
+			SubstanceName: substance.Name,
+			AssignedKind:  substance.Kind,
+			Unmet:         unmet,
+			MatchingKinds: matching(kinds, facts),
+		})
+	}
+	return result, nil
+}
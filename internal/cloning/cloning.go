@@ -0,0 +1,192 @@
+package cloning
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cloner creates substances from existing ones and from templates.
+//
+// A clone is a new individual of the same sort: it gets the source's asserted
+// modes — only the current value of a single-valued attribute — and attribute
+// links. A deep clone also gets the source's potentialities and any of the
+// causal relations involving it that are asked for, rewritten to involve the
+// clone. Inferred modes are left for the reasoner to derive, and actualities,
+// parts, dependences and relations to other substances stay with the source:
+// they belong to the individual, not its sort.
+type Cloner struct {
+	db     *gorm.DB
+	engine *causality.Engine
+}
+
+// NewCloner creates a new cloner
+func NewCloner(db *gorm.DB) *Cloner {
+	return &Cloner{db: db, engine: causality.NewEngine(db)}
+}
+
+var (
+	// ErrSubstanceNotFound is returned when the substance to clone does not exist
+	ErrSubstanceNotFound = errors.New("substance not found")
+	// ErrInvalidClone is returned when a selected cause does not involve the source,
+	// or causes are selected for a shallow clone
+	ErrInvalidClone = errors.New("invalid clone")
+	// ErrTemplateNotFound is returned when no template has the given ID or name
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrInvalidTemplate is returned when a template's modes or potentialities are unusable
+	ErrInvalidTemplate = errors.New("invalid template")
+	// ErrDuplicateTemplate is returned when another template has the name
+	ErrDuplicateTemplate = errors.New("template name already taken")
+)
+
+// CloneOptions describe the clone; empty fields take the source's values
+type CloneOptions struct {
+	Name     string
+	Kind     string
+	Essence  string
+	Deep     bool     // copy potentialities too
+	CauseIDs []string // causal relations involving the source, its modes or potentialities to copy
+}
+
+// Clone creates a copy of the substance. Callers should run it in a transaction
+// and refresh the clone's inferred modes.
+func (c *Cloner) Clone(sourceID string, options CloneOptions) (*entities.Substance, error) {
+	var source entities.Substance
+	if err := c.db.Preload("Attributes").Where("id = ?", sourceID).Limit(1).Find(&source).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substance: %w", err)
+	}
+	if source.ID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrSubstanceNotFound, sourceID)
+	}
+	if len(options.CauseIDs) > 0 && !options.Deep {
+		return nil, fmt.Errorf("%w: causes are only copied by a deep clone", ErrInvalidClone)
+	}
+
+	clone := entities.NewSubstance(source.Name, source.Kind, source.Essence)
+	if options.Name != "" {
+		clone.Name = options.Name
+	}
+	if options.Kind != "" {
+		clone.Kind = options.Kind
+	}
+	if options.Essence != "" {
+		clone.Essence = options.Essence
+	}
+	if err := c.db.Omit("Attributes").Create(clone).Error; err != nil {
+		return nil, fmt.Errorf("failed to create substance: %w", err)
+	}
+	if len(source.Attributes) > 0 {
+		if err := c.db.Model(clone).Association("Attributes").Append(source.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to link attributes: %w", err)
+		}
+	}
+
+	// IDs of the source's entities to those of the clone's copies
+	copies := map[string]string{source.ID: clone.ID}
+	modes, err := c.clonedModes(source.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, mode := range modes {
+		copied := entities.NewMode(mode.Value, clone.ID, mode.AttributeID)
+		if err := c.db.Create(copied).Error; err != nil {
+			return nil, fmt.Errorf("failed to create mode: %w", err)
+		}
+		copies[mode.ID] = copied.ID
+		clone.Modes = append(clone.Modes, *copied)
+	}
+	if !options.Deep {
+		return clone, nil
+	}
+
+	var potentialities []entities.Potentiality
+	if err := c.db.Where("substance_id = ?", source.ID).Order("created_at, id").Find(&potentialities).Error; err != nil {
+		return nil, fmt.Errorf("failed to get potentialities: %w", err)
+	}
+	for _, potentiality := range potentialities {
+		copied := entities.NewPotentiality(potentiality.Name, potentiality.Description, potentiality.Conditions, clone.ID)
+		if err := c.db.Create(copied).Error; err != nil {
+			return nil, fmt.Errorf("failed to create potentiality: %w", err)
+		}
+		copies[potentiality.ID] = copied.ID
+		clone.Potentialities = append(clone.Potentialities, *copied)
+	}
+	if err := c.cloneCauses(options.CauseIDs, copies); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// clonedModes returns the asserted monadic modes a clone copies, oldest first
+func (c *Cloner) clonedModes(sourceID string) ([]entities.Mode, error) {
+	var modes []entities.Mode
+	if err := c.db.Preload("Attribute").Where("substance_id = ? AND inferred = ?", sourceID, false).
+		Order("created_at, id").Find(&modes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get modes: %w", err)
+	}
+
+	singles := make(map[string][]entities.Mode)
+	var cloned []entities.Mode
+	for _, mode := range modes {
+		switch {
+		case mode.Attribute == nil || mode.Attribute.IsRelational():
+		case mode.Attribute.Cardinality == entities.CardinalitySingle:
+			singles[mode.AttributeID] = append(singles[mode.AttributeID], mode)
+		default:
+			cloned = append(cloned, mode)
+		}
+	}
+	for _, mode := range modes {
+		if group, ok := singles[mode.AttributeID]; ok {
+			if current := causality.CurrentMode(group); current.ID == mode.ID {
+				cloned = append(cloned, mode)
+			}
+		}
+	}
+	return cloned, nil
+}
+
+// cloneCauses copies the causal relations, replacing the source's entities with the clone's
+func (c *Cloner) cloneCauses(ids []string, copies map[string]string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var relations []entities.CausalRelation
+	if err := c.db.Where("id IN ?", ids).Order("created_at, id").Find(&relations).Error; err != nil {
+		return fmt.Errorf("failed to get causal relations: %w", err)
+	}
+	found := make(map[string]bool, len(relations))
+	for _, relation := range relations {
+		found[relation.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return fmt.Errorf("%w: %s", causality.ErrEntityNotFound, id)
+		}
+	}
+
+	for _, relation := range relations {
+		from, fromCopied := copies[relation.FromEntity]
+		to, toCopied := copies[relation.ToEntity]
+		if !fromCopied && !toCopied {
+			return fmt.Errorf("%w: causal relation %s does not involve the substance", ErrInvalidClone, relation.ID)
+		}
+		copied := relation
+		copied.ID, copied.CreatedAt = uuid.New().String(), time.Now()
+		if fromCopied {
+			copied.FromEntity = from
+		}
+		if toCopied {
+			copied.ToEntity = to
+		}
+		if err := c.db.Create(&copied).Error; err != nil {
+			return fmt.Errorf("failed to create causal relation: %w", err)
+		}
+	}
+	return nil
+}
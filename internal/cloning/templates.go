@@ -0,0 +1,225 @@
+package cloning
+
+import (
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// ModeInput is a mode to give a substance
+type ModeInput struct {
+	AttributeID string
+	Value       string
+}
+
+// PotentialityInput is a potentiality to give a substance
+type PotentialityInput struct {
+	Name        string
+	Description string
+	Conditions  string // JSON string of required conditions; may be empty
+}
+
+// TemplateInput describes a template to define
+type TemplateInput struct {
+	Name           string
+	Description    string
+	Kind           string
+	Essence        string
+	Modes          []ModeInput
+	Potentialities []PotentialityInput
+}
+
+// Instance describes a substance to create from a template. Empty fields take
+// the template's values; a mode of a single-valued attribute replaces the
+// template's default for it, other modes are added to the defaults.
+type Instance struct {
+	Name    string
+	Kind    string
+	Essence string
+	Modes   []ModeInput
+}
+
+// CreateTemplate defines a named template. Its modes must be of existing,
+// monadic attributes, with at most one value for a single-valued attribute.
+func (c *Cloner) CreateTemplate(input TemplateInput) (*entities.SubstanceTemplate, error) {
+	var taken int64
+	if err := c.db.Model(&entities.SubstanceTemplate{}).Where("name = ?", input.Name).Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("failed to get templates: %w", err)
+	}
+	if taken > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateTemplate, input.Name)
+	}
+
+	template := entities.NewSubstanceTemplate(input.Name, input.Description, input.Kind, input.Essence)
+	attributes, err := c.attributes(input.Modes)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, mode := range input.Modes {
+		attribute := attributes[mode.AttributeID]
+		if attribute.IsRelational() {
+			return nil, fmt.Errorf("%w: attribute '%s' is relational", ErrInvalidTemplate, attribute.Name)
+		}
+		if value, ok := values[mode.AttributeID]; ok && attribute.Cardinality == entities.CardinalitySingle && value != mode.Value {
+			return nil, fmt.Errorf("%w: attribute '%s' is single-valued but given '%s' and '%s'", ErrInvalidTemplate, attribute.Name, value, mode.Value)
+		}
+		values[mode.AttributeID] = mode.Value
+		template.Modes = append(template.Modes, *entities.NewTemplateMode(template.ID, mode.AttributeID, mode.Value))
+	}
+	for _, potentiality := range input.Potentialities {
+		if potentiality.Conditions != "" {
+			if _, err := causality.ParseConditions(potentiality.Conditions); err != nil {
+				return nil, fmt.Errorf("%w: potentiality '%s' has invalid conditions: %v", ErrInvalidTemplate, potentiality.Name, err)
+			}
+		}
+		template.Potentialities = append(template.Potentialities,
+			*entities.NewTemplatePotentiality(template.ID, potentiality.Name, potentiality.Description, potentiality.Conditions))
+	}
+
+	if err := c.db.Create(template).Error; err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	return c.Template(template.ID)
+}
+
+// attributes loads the attributes of the modes, failing if any is missing
+func (c *Cloner) attributes(modes []ModeInput) (map[string]entities.Attribute, error) {
+	ids := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		ids = append(ids, mode.AttributeID)
+	}
+	var found []entities.Attribute
+	if len(ids) > 0 {
+		if err := c.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+			return nil, fmt.Errorf("failed to get attributes: %w", err)
+		}
+	}
+	attributes := make(map[string]entities.Attribute, len(found))
+	for _, attribute := range found {
+		attributes[attribute.ID] = attribute
+	}
+	for _, id := range ids {
+		if _, ok := attributes[id]; !ok {
+			return nil, fmt.Errorf("%w: attribute %s", causality.ErrEntityNotFound, id)
+		}
+	}
+	return attributes, nil
+}
+
+// Templates returns every template with its modes and potentialities, by name
+func (c *Cloner) Templates() ([]entities.SubstanceTemplate, error) {
+	var templates []entities.SubstanceTemplate
+	if err := c.preloaded().Order("name").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get templates: %w", err)
+	}
+	return templates, nil
+}
+
+// Template returns the template with the given ID or, failing that, name
+func (c *Cloner) Template(ref string) (*entities.SubstanceTemplate, error) {
+	var template entities.SubstanceTemplate
+	if err := c.preloaded().Where("id = ?", ref).Limit(1).Find(&template).Error; err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	if template.ID == "" {
+		if err := c.preloaded().Where("name = ?", ref).Limit(1).Find(&template).Error; err != nil {
+			return nil, fmt.Errorf("failed to get template: %w", err)
+		}
+	}
+	if template.ID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, ref)
+	}
+	return &template, nil
+}
+
+func (c *Cloner) preloaded() *gorm.DB {
+	return c.db.Preload("Modes.Attribute").Preload("Potentialities")
+}
+
+// DeleteTemplate deletes a template; substances created from it are unaffected
+func (c *Cloner) DeleteTemplate(ref string) error {
+	template, err := c.Template(ref)
+	if err != nil {
+		return err
+	}
+	if err := c.db.Where("template_id = ?", template.ID).Delete(&entities.TemplateMode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete template modes: %w", err)
+	}
+	if err := c.db.Where("template_id = ?", template.ID).Delete(&entities.TemplatePotentiality{}).Error; err != nil {
+		return fmt.Errorf("failed to delete template potentialities: %w", err)
+	}
+	if err := c.db.Delete(&entities.SubstanceTemplate{}, "id = ?", template.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	return nil
+}
+
+// Instantiate creates a substance from the template with the given ID or name,
+// with the template's modes and potentialities. Callers should run it in a
+// transaction and refresh the substance's inferred modes.
+func (c *Cloner) Instantiate(ref string, instance Instance) (*entities.Substance, error) {
+	template, err := c.Template(ref)
+	if err != nil {
+		return nil, err
+	}
+	substance := entities.NewSubstance(instance.Name, template.Kind, template.Essence)
+	if instance.Kind != "" {
+		substance.Kind = instance.Kind
+	}
+	if instance.Essence != "" {
+		substance.Essence = instance.Essence
+	}
+	if err := c.db.Create(substance).Error; err != nil {
+		return nil, fmt.Errorf("failed to create substance: %w", err)
+	}
+
+	attributes, err := c.attributes(instance.Modes)
+	if err != nil {
+		return nil, err
+	}
+	overridden := make(map[string]bool)
+	for _, mode := range instance.Modes {
+		if attributes[mode.AttributeID].Cardinality == entities.CardinalitySingle {
+			overridden[mode.AttributeID] = true
+		}
+	}
+	type key struct{ attribute, value string }
+	asserted := make(map[key]bool)
+	assert := func(attributeID, value string) error {
+		if asserted[key{attributeID, value}] {
+			return nil
+		}
+		asserted[key{attributeID, value}] = true
+		mode := entities.NewMode(value, substance.ID, attributeID)
+		if err := c.engine.AssertMode(mode); err != nil {
+			return err
+		}
+		substance.Modes = append(substance.Modes, *mode)
+		return nil
+	}
+	for _, mode := range template.Modes {
+		if overridden[mode.AttributeID] {
+			continue
+		}
+		if err := assert(mode.AttributeID, mode.Value); err != nil {
+			return nil, err
+		}
+	}
+	for _, mode := range instance.Modes {
+		if err := assert(mode.AttributeID, mode.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, potentiality := range template.Potentialities {
+		created := entities.NewPotentiality(potentiality.Name, potentiality.Description, potentiality.Conditions, substance.ID)
+		if err := c.db.Create(created).Error; err != nil {
+			return nil, fmt.Errorf("failed to create potentiality: %w", err)
+		}
+		substance.Potentialities = append(substance.Potentialities, *created)
+	}
+	return substance, nil
+}
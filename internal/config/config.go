@@ -0,0 +1,289 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned for settings that cannot be parsed or do not make sense together
+var ErrInvalid = errors.New("invalid configuration")
+
+// Config holds every setting of the server. Each comes, by increasing
+// precedence, from its default, the config file, its environment variable
+// and its command-line flag, named after the variable: DB_HOST is -db-host.
+type Config struct {
+	Server    Server    `yaml:"server" toml:"server"`
+	Database  Database  `yaml:"database" toml:"database"`
+	Auth      Auth      `yaml:"auth" toml:"auth"`
+	RateLimit RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	Logging   Logging   `yaml:"logging" toml:"logging"`
+	Tracing   Tracing   `yaml:"tracing" toml:"tracing"`
+	Features  Features  `yaml:"features" toml:"features"`
+}
+
+// Server configures the HTTP listener
+type Server struct {
+	Address           string   `yaml:"address" toml:"address" env:"LISTEN_ADDR" usage:"address to listen on, host:port"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout" env:"READ_TIMEOUT" usage:"time to read a whole request; 0 for none"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" usage:"time to read request headers"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout" env:"WRITE_TIMEOUT" usage:"time to write a response; 0 for none"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"IDLE_TIMEOUT" usage:"time to keep idle connections open"`
+	ShutdownDelay     Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY" usage:"time between failing readiness and closing the listener on SIGTERM"`
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"time requests in flight get to finish on SIGTERM"`
+	TLS               TLS      `yaml:"tls" toml:"tls"`
+	CORS              CORS     `yaml:"cors" toml:"cors"`
+}
+
+// TLS serves HTTPS when both files are set
+type TLS struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE" usage:"PEM certificate chain; serves HTTPS with -tls-key-file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE" usage:"PEM private key of the certificate"`
+}
+
+// Enabled reports whether the server is to serve HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// CORS lets browsers on other origins call the API. Without allowed origins
+// no CORS headers are sent.
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"comma-separated origins allowed to call the API, or *"`
+	AllowedMethods   []string `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_ALLOWED_METHODS" usage:"comma-separated methods allowed in CORS requests"`
+	AllowedHeaders   []string `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" usage:"comma-separated headers allowed in CORS requests"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" usage:"let CORS requests carry cookies and credentials"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE" usage:"how long browsers may cache preflight responses"`
+}
+
+// Database says which database to use: a Postgres database, either as a DSN
+// or field by field, or a SQLite file
+type Database struct {
+	Driver          string   `yaml:"driver" toml:"driver" env:"DB_DRIVER" usage:"postgres or sqlite"`
+	Path            string   `yaml:"path" toml:"path" env:"DB_PATH" usage:"SQLite database file, created if missing"`
+	DSN             string   `yaml:"dsn" toml:"dsn" env:"DATABASE_URL" secret:"true" usage:"Postgres DSN or URL; overrides the other database fields"`
+	Host            string   `yaml:"host" toml:"host" env:"DB_HOST" usage:"database host"`
+	Port            int      `yaml:"port" toml:"port" env:"DB_PORT" usage:"database port"`
+	User            string   `yaml:"user" toml:"user" env:"DB_USER" usage:"database user"`
+	Password        string   `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true" usage:"database password"`
+	Name            string   `yaml:"name" toml:"name" env:"DB_NAME" usage:"database name"`
+	SSLMode         string   `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE" usage:"disable, require, verify-ca or verify-full"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"most open connections; 0 for no limit"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"most idle connections kept"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"time after which connections are closed; 0 for never"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" usage:"idle time after which connections are closed; 0 for never"`
+}
+
+// ConnectionString returns the SQLite path, the DSN, or a DSN built from the
+// individual fields
+func (d Database) ConnectionString() string {
+	if d.Driver == "sqlite" {
+		return d.Path
+	}
+	if d.DSN != "" {
+		return d.DSN
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		d.Host, d.User, d.Password, d.Name, d.Port, d.SSLMode)
+}
+
+// Auth configures authentication
+type Auth struct {
+	Disabled       bool     `yaml:"disabled" toml:"disabled" env:"AUTH_DISABLED" usage:"allow every request without credentials"`
+	AnonymousReads bool     `yaml:"anonymous_reads" toml:"anonymous_reads" env:"AUTH_ANONYMOUS_READS" usage:"let requests without credentials read"`
+	DefaultRoles   []string `yaml:"default_roles" toml:"default_roles" env:"AUTH_DEFAULT_ROLES" usage:"roles of keys and tokens that name none"`
+	JWTKeyFiles    []string `yaml:"jwt_key_files" toml:"jwt_key_files" env:"JWT_KEY_FILES" usage:"comma-separated PEM or JWKS files verifying JWTs"`
+	JWTIssuer      string   `yaml:"jwt_issuer" toml:"jwt_issuer" env:"JWT_ISSUER" usage:"required iss of JWTs"`
+	JWTAudience    string   `yaml:"jwt_audience" toml:"jwt_audience" env:"JWT_AUDIENCE" usage:"required aud of JWTs"`
+}
+
+// RateLimit configures the per-client limits; a rate of 0 turns a limit off
+type RateLimit struct {
+	Rate           float64 `yaml:"rate" toml:"rate" env:"RATE_LIMIT" usage:"requests per second per client"`
+	Burst          int     `yaml:"burst" toml:"burst" env:"RATE_LIMIT_BURST" usage:"requests a client may make at once"`
+	ExpensiveRate  float64 `yaml:"expensive_rate" toml:"expensive_rate" env:"RATE_LIMIT_EXPENSIVE" usage:"requests per second per client to expensive routes"`
+	ExpensiveBurst int     `yaml:"expensive_burst" toml:"expensive_burst" env:"RATE_LIMIT_EXPENSIVE_BURST" usage:"expensive requests a client may make at once"`
+	AddressRate    float64 `yaml:"address_rate" toml:"address_rate" env:"RATE_LIMIT_ADDRESS" usage:"requests per second per address, before credentials are checked"`
+	AddressBurst   int     `yaml:"address_burst" toml:"address_burst" env:"RATE_LIMIT_ADDRESS_BURST" usage:"requests an address may make at once"`
+	DailyQuota     int64   `yaml:"daily_quota" toml:"daily_quota" env:"DAILY_QUOTA" usage:"requests per client per UTC day; 0 for no quota"`
+}
+
+// Logging configures the structured logs
+type Logging struct {
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" usage:"json or text"`
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+}
+
+// Tracing says where spans go
+type Tracing struct {
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACE_EXPORTER" usage:"none, stdout or otlp"`
+	Endpoint string `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"OTLP/HTTP collector URL"`
+}
+
+// Features turns parts of the server on and off
+type Features struct {
+	GraphQL    bool `yaml:"graphql" toml:"graphql" env:"ENABLE_GRAPHQL" usage:"serve GraphQL at /query"`
+	Playground bool `yaml:"playground" toml:"playground" env:"ENABLE_PLAYGROUND" usage:"serve the GraphQL playground at /playground"`
+	Metrics    bool `yaml:"metrics" toml:"metrics" env:"ENABLE_METRICS" usage:"serve Prometheus metrics at /metrics"`
+}
+
+// Default returns the settings used where nothing else is given
+func Default() Config {
+	return Config{
+		Server: Server{
+			Address:           ":8080",
+			ReadHeaderTimeout: Duration{10 * time.Second},
+			IdleTimeout:       Duration{2 * time.Minute},
+			ShutdownTimeout:   Duration{30 * time.Second},
+			CORS: CORS{
+				AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders: []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "X-Workspace"},
+				MaxAge:         Duration{10 * time.Minute},
+			},
+		},
+		Database: Database{
+			Driver:       "postgres",
+			Host:         "localhost",
+			Port:         5433,
+			User:         "postgres",
+			Password:     "postgres",
+			Name:         "ontology",
+			SSLMode:      "disable",
+			MaxOpenConns: 25,
+			MaxIdleConns: 5,
+		},
+		Auth: Auth{
+			DefaultRoles: []string{"editor"},
+		},
+		RateLimit: RateLimit{
+			Rate:           10,
+			Burst:          20,
+			ExpensiveRate:  1,
+			ExpensiveBurst: 5,
+			AddressRate:    50,
+			AddressBurst:   100,
+		},
+		Logging: Logging{Format: "json", Level: "info"},
+		Tracing: Tracing{Exporter: "none"},
+		Features: Features{
+			GraphQL:    true,
+			Playground: true,
+			Metrics:    true,
+		},
+	}
+}
+
+// Validate returns an ErrInvalid error listing every problem with the settings
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Address); err != nil || port == "" {
+		add("server.address %q must be host:port", c.Server.Address)
+	}
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_delay", c.Server.ShutdownDelay},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"server.cors.max_age", c.Server.CORS.MaxAge},
+		{"database.conn_max_lifetime", c.Database.ConnMaxLifetime},
+		{"database.conn_max_idle_time", c.Database.ConnMaxIdleTime},
+	} {
+		if d.value.Duration < 0 {
+			add("%s must not be negative", d.name)
+		}
+	}
+	if tls := c.Server.TLS; tls.Enabled() {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			add("server.tls needs both cert_file and key_file")
+		}
+		for _, file := range []string{tls.CertFile, tls.KeyFile} {
+			if _, err := os.Stat(file); file != "" && err != nil {
+				add("server.tls: %v", err)
+			}
+		}
+	}
+	for _, origin := range c.Server.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.Server.CORS.AllowCredentials {
+				add("server.cors cannot allow credentials from every origin")
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			add("server.cors.allowed_origins: %q must be scheme://host[:port] or *", origin)
+		}
+	}
+
+	switch db := c.Database; {
+	case db.Driver == "sqlite":
+		if db.Path == "" {
+			add("database.path is needed with the sqlite driver")
+		}
+	case db.Driver != "postgres":
+		add("database.driver %q must be postgres or sqlite", db.Driver)
+	case db.DSN == "":
+		if db.Host == "" || db.Name == "" || db.User == "" {
+			add("database needs a dsn, or a host, name and user")
+		}
+		if db.Port < 1 || db.Port > 65535 {
+			add("database.port %d must be between 1 and 65535", db.Port)
+		}
+		if !contains([]string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}, db.SSLMode) {
+			add("database.sslmode %q is not a Postgres sslmode", db.SSLMode)
+		}
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		add("database pool sizes must not be negative")
+	} else if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns %d exceeds max_open_conns %d", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+
+	if rl := c.RateLimit; rl.Rate < 0 || rl.Burst < 0 || rl.ExpensiveRate < 0 || rl.ExpensiveBurst < 0 || rl.AddressRate < 0 || rl.AddressBurst < 0 || rl.DailyQuota < 0 {
+		add("rate_limit settings must not be negative")
+	}
+
+	if !contains([]string{"json", "text"}, c.Logging.Format) {
+		add("logging.format %q must be json or text", c.Logging.Format)
+	}
+	if !contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.Logging.Level)) {
+		add("logging.level %q must be debug, info, warn or error", c.Logging.Level)
+	}
+	if !contains([]string{"", "none", "stdout", "otlp"}, c.Tracing.Exporter) {
+		add("tracing.exporter %q must be none, stdout or otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Host == "" {
+			add("tracing.endpoint %q must be a URL", c.Tracing.Endpoint)
+		}
+	}
+	if c.Features.Playground && !c.Features.GraphQL {
+		add("features.playground needs features.graphql")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w:\n  %s", ErrInvalid, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the config file when the -config flag does not
+const FileEnv = "OAAS_CONFIG"
+
+// Duration is a time.Duration written as "30s" or "5m" in files, variables and flags
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration such as "1m30s"
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText writes the duration as UnmarshalText reads it
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// setting is one leaf of the configuration, with where it can be set from
+type setting struct {
+	path   string // as in the file: server.tls.cert_file
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// settings lists the leaves of the struct v points to, depth first
+func settings(v reflect.Value, prefix string) []setting {
+	var found []setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(Duration{}) {
+			found = append(found, settings(v.Field(i), path+".")...)
+			continue
+		}
+		s := setting{
+			path:   path,
+			env:    field.Tag.Get("env"),
+			flag:   field.Tag.Get("flag"),
+			usage:  field.Tag.Get("usage"),
+			secret: field.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		}
+		if s.flag == "" {
+			s.flag = strings.ReplaceAll(strings.ToLower(s.env), "_", "-")
+		}
+		found = append(found, s)
+	}
+	return found
+}
+
+// set parses raw into the setting
+func (s setting) set(raw string) error {
+	switch v := s.value.Addr().Interface().(type) {
+	case *string:
+		*v = raw
+	case *bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		*v = parsed
+	case *int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*v = parsed
+	case *int64:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		*v = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		*v = parsed
+	case *[]string:
+		*v = splitList(raw)
+	case *Duration:
+		if err := v.UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", raw)
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping blanks
+func splitList(raw string) []string {
+	list := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Load reads the settings, each from the first source that has it: the flags
+// in args, the environment as lookupEnv sees it, the config file named by the
+// -config flag or OAAS_CONFIG, and the defaults. Callers may define flags of
+// their own on fs first. Settings that do not validate are an ErrInvalid error.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	leaves := settings(reflect.ValueOf(&cfg).Elem(), "")
+
+	file := fs.String("config", "", "YAML or TOML config file (default $"+FileEnv+")")
+	flagged := make(map[string]string)
+	for _, s := range leaves {
+		s := s
+		usage := s.usage
+		if s.env != "" {
+			usage += " ($" + s.env + ")"
+		}
+		fs.Func(s.flag, usage, func(raw string) error {
+			// check now so that the error names the flag, but apply after the file and environment
+			probe := s
+			probe.value = reflect.New(s.value.Type()).Elem()
+			if err := probe.set(raw); err != nil {
+				return err
+			}
+			flagged[s.flag] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *file
+	if path == "" {
+		path, _ = lookupEnv(FileEnv)
+	}
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range leaves {
+		raw, ok := lookupEnv(s.env)
+		if s.env == "" || !ok || (raw == "" && s.value.Kind() != reflect.Slice) {
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			return nil, fmt.Errorf("%w: $%s: %v", ErrInvalid, s.env, err)
+		}
+	}
+	for _, s := range leaves {
+		if raw, ok := flagged[s.flag]; ok {
+			if err := s.set(raw); err != nil {
+				return nil, fmt.Errorf("%w: -%s: %v", ErrInvalid, s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// readFile decodes a YAML or TOML file, by its extension, over cfg. Keys the
+// configuration does not have are errors rather than typos silently ignored.
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %s: %v", ErrInvalid, path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalid, path, err)
+		}
+	default:
+		return fmt.Errorf("%w: %s: config files must be .yaml, .yml or .toml", ErrInvalid, path)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets wherever the configuration is shown
+const redacted = "REDACTED"
+
+// dsnPassword matches the password of a key=value DSN, quoted or not
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Redacted returns a copy of the settings with secrets masked. Only a DSN's
+// password is masked, so that the database it names still shows.
+func (c Config) Redacted() Config {
+	for _, s := range settings(reflect.ValueOf(&c).Elem(), "") {
+		if !s.secret || s.value.String() == "" {
+			continue
+		}
+		if s.path == "database.dsn" {
+			s.value.SetString(redactDSN(s.value.String()))
+		} else {
+			s.value.SetString(redacted)
+		}
+	}
+	return c
+}
+
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return redacted
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// LogValue logs the redacted settings, one attribute per setting, named as in the file
+func (c Config) LogValue() slog.Value {
+	redactedConfig := c.Redacted()
+	var attrs []slog.Attr
+	for _, s := range settings(reflect.ValueOf(&redactedConfig).Elem(), "") {
+		value := s.value.Interface()
+		if d, ok := value.(Duration); ok {
+			value = d.String()
+		}
+		attrs = append(attrs, slog.Any(s.path, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// WriteYAML writes the redacted settings as a YAML config file
+func (c Config) WriteYAML(w io.Writer) error {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
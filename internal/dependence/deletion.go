@@ -0,0 +1,289 @@
+package dependence
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/mereology"
+)
+
+// Deletion is what deleting an entity would take with it
+type Deletion struct {
+	Entities   []causality.EntityRef `json:"entities"`   // every entity to delete, the requested one first
+	Dependents []entities.Dependence `json:"dependents"` // dependences that the deletion breaks
+}
+
+// PlanDeletion works out what deleting an entity affects. Entities rigidly
+// dependent on it, or on a substance's modes which go with the substance, lose
+// what they depend on; so do entities generically dependent on a kind when its
+// last instance is deleted. Under PolicyBlock any such dependent makes the plan
+// fail with ErrHasDependents; under PolicyCascade the dependents are deleted
+// too, and so on transitively.
+func (t *Tracker) PlanDeletion(entityType, id, policy string) (*Deletion, error) {
+	if policy != PolicyBlock && policy != PolicyCascade {
+		return nil, fmt.Errorf("invalid deletion policy: %s. Must be one of: block, cascade", policy)
+	}
+
+	plan := &Deletion{Entities: []causality.EntityRef{}, Dependents: []entities.Dependence{}}
+	planned := map[string]bool{id: true} // entities to delete, with the modes of substances to delete
+	broken := map[string]bool{}
+	kinds := map[string]bool{} // names of kinds that lose instances
+	queue := []causality.EntityRef{{ID: id, Type: entityType}}
+
+	breaks := func(dependences []entities.Dependence) {
+		for _, dependence := range dependences {
+			if broken[dependence.ID] || planned[dependence.DependentID] {
+				continue
+			}
+			broken[dependence.ID] = true
+			plan.Dependents = append(plan.Dependents, dependence)
+			if policy == PolicyCascade {
+				planned[dependence.DependentID] = true
+				queue = append(queue, causality.EntityRef{ID: dependence.DependentID, Type: dependence.DependentType})
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			plan.Entities = append(plan.Entities, current)
+
+			threatened, err := t.threatened(current, planned, kinds)
+			if err != nil {
+				return nil, err
+			}
+			breaks(threatened)
+		}
+
+		// Kinds left without instances break generic dependence on them
+		orphaned, err := t.genericallyThreatened(kinds, planned)
+		if err != nil {
+			return nil, err
+		}
+		breaks(orphaned)
+	}
+
+	if policy == PolicyBlock && len(plan.Dependents) > 0 {
+		return plan, fmt.Errorf("%w: %d dependents", ErrHasDependents, len(plan.Dependents))
+	}
+	return plan, nil
+}
+
+// threatened returns the dependences on an entity, and on a substance's modes,
+// which it plans for deletion too, noting the kind a deleted substance belonged to
+func (t *Tracker) threatened(ref causality.EntityRef, planned, kinds map[string]bool) ([]entities.Dependence, error) {
+	ids := []string{ref.ID}
+	if ref.Type == entities.EntityTypeSubstance {
+		var substance entities.Substance
+		if err := t.db.Where("id = ?", ref.ID).Limit(1).Find(&substance).Error; err != nil {
+			return nil, fmt.Errorf("failed to get substance: %w", err)
+		}
+		if substance.Kind != "" {
+			kinds[substance.Kind] = true
+		}
+		modes, err := t.modeIDs(ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, mode := range modes {
+			planned[mode] = true
+		}
+		ids = append(ids, modes...)
+	}
+
+	query := t.db.Where("depends_on_id IN ?", ids)
+	if ref.Type != entities.EntityTypeKind {
+		query = query.Where("modality = ?", entities.ModalityRigid)
+	}
+	var dependences []entities.Dependence
+	if err := query.Order("created_at, id").Find(&dependences).Error; err != nil {
+		return nil, fmt.Errorf("failed to get dependences: %w", err)
+	}
+	return dependences, nil
+}
+
+// genericallyThreatened returns generic dependences on kinds whose every instance is planned for deletion
+func (t *Tracker) genericallyThreatened(kinds, planned map[string]bool) ([]entities.Dependence, error) {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []entities.Dependence
+	for _, name := range names {
+		var instances []string
+		if err := t.db.Model(&entities.Substance{}).Where("kind = ?", name).Pluck("id", &instances).Error; err != nil {
+			return nil, fmt.Errorf("failed to get substances: %w", err)
+		}
+		survivors := false
+		for _, id := range instances {
+			if !planned[id] {
+				survivors = true
+				break
+			}
+		}
+		if survivors {
+			continue
+		}
+
+		var kind entities.Kind
+		if err := t.db.Where("name = ?", name).Limit(1).Find(&kind).Error; err != nil {
+			return nil, fmt.Errorf("failed to get kind: %w", err)
+		}
+		if kind.ID == "" {
+			continue
+		}
+		var dependences []entities.Dependence
+		if err := t.db.Where("depends_on_id = ? AND modality = ?", kind.ID, entities.ModalityGeneric).
+			Order("created_at, id").Find(&dependences).Error; err != nil {
+			return nil, fmt.Errorf("failed to get dependences: %w", err)
+		}
+		result = append(result, dependences...)
+	}
+	return result, nil
+}
+
+// Execute deletes every entity in a plan along with the causal relations,
+// parthoods, dependences and relational modes that reference them. It returns
+// the surviving substances whose facts changed: wholes and relata of deleted
+// substances and bearers of deleted modes, whose inferred modes the caller
+// should refresh.
+func (t *Tracker) Execute(plan *Deletion) ([]string, error) {
+	deleted := make(map[string]bool, len(plan.Entities))
+	for _, ref := range plan.Entities {
+		deleted[ref.ID] = true
+	}
+
+	parts := mereology.NewMereology(t.db)
+	var affected []string
+	seen := map[string]bool{}
+	affect := func(ids ...string) {
+		for _, id := range ids {
+			if id != "" && !deleted[id] && !seen[id] {
+				seen[id] = true
+				affected = append(affected, id)
+			}
+		}
+	}
+
+	for _, ref := range plan.Entities {
+		ids := []string{ref.ID}
+		switch ref.Type {
+		case entities.EntityTypeSubstance:
+			wholes, err := parts.WholeIDs(ref.ID)
+			if err != nil {
+				return nil, err
+			}
+			affect(wholes...)
+			related, err := t.engine.CoBearerIDs(ref.ID)
+			if err != nil {
+				return nil, err
+			}
+			affect(related...)
+			modes, err := t.modeIDs(ref.ID)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, modes...)
+			if _, err := t.engine.DetachSubstance(ref.ID); err != nil {
+				return nil, err
+			}
+			if _, err := parts.DetachSubstance(ref.ID); err != nil {
+				return nil, err
+			}
+			if err := t.deleteRelations(ref.ID); err != nil {
+				return nil, err
+			}
+			// IDs merged into the substance go with it
+			if err := t.db.Where("to_id = ?", ref.ID).Delete(&entities.SubstanceRedirect{}).Error; err != nil {
+				return nil, fmt.Errorf("failed to delete redirects: %w", err)
+			}
+		case entities.EntityTypeMode:
+			var mode entities.Mode
+			if err := t.db.Where("id = ?", ref.ID).Limit(1).Find(&mode).Error; err != nil {
+				return nil, fmt.Errorf("failed to get mode: %w", err)
+			}
+			var bearers []string
+			if err := t.db.Model(&entities.ModeBearer{}).Where("mode_id = ?", ref.ID).Pluck("substance_id", &bearers).Error; err != nil {
+				return nil, fmt.Errorf("failed to get mode bearers: %w", err)
+			}
+			affect(append([]string{mode.SubstanceID}, bearers...)...)
+			if _, err := t.engine.DetachEntities(ref.ID); err != nil {
+				return nil, err
+			}
+			if err := t.db.Where("mode_id = ?", ref.ID).Delete(&entities.ModeBearer{}).Error; err != nil {
+				return nil, fmt.Errorf("failed to delete mode bearers: %w", err)
+			}
+		case entities.EntityTypeKind:
+			if _, err := t.engine.DetachEntities(ref.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := t.db.Where("dependent_id IN ? OR depends_on_id IN ?", ids, ids).Delete(&entities.Dependence{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete dependences: %w", err)
+		}
+		if err := t.db.Delete(model(ref.Type), "id = ?", ref.ID).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete %s: %w", ref.Type, err)
+		}
+	}
+
+	// Bearers may themselves have been deleted after their mode was
+	surviving := affected[:0]
+	for _, id := range affected {
+		if !deleted[id] {
+			surviving = append(surviving, id)
+		}
+	}
+	return surviving, nil
+}
+
+// modeIDs returns the modes that go with a substance: its own and the relational modes it bears
+func (t *Tracker) modeIDs(substanceID string) ([]string, error) {
+	var ids []string
+	err := t.db.Model(&entities.Mode{}).
+		Where("substance_id = ? OR id IN (?)", substanceID,
+			t.db.Model(&entities.ModeBearer{}).Select("mode_id").Where("substance_id = ?", substanceID)).
+		Order("created_at, id").Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get modes: %w", err)
+	}
+	return ids, nil
+}
+
+// deleteRelations deletes the relational modes a substance bears, which cannot outlive any of their bearers
+func (t *Tracker) deleteRelations(substanceID string) error {
+	var modes []string
+	if err := t.db.Model(&entities.ModeBearer{}).Where("substance_id = ?", substanceID).Pluck("mode_id", &modes).Error; err != nil {
+		return fmt.Errorf("failed to get relations: %w", err)
+	}
+	if len(modes) == 0 {
+		return nil
+	}
+	if _, err := t.engine.DetachEntities(modes...); err != nil {
+		return err
+	}
+	if err := t.db.Where("mode_id IN ?", modes).Delete(&entities.ModeBearer{}).Error; err != nil {
+		return fmt.Errorf("failed to delete mode bearers: %w", err)
+	}
+	if err := t.db.Where("id IN ?", modes).Delete(&entities.Mode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete relations: %w", err)
+	}
+	return nil
+}
+
+// model returns an empty model for a dependable entity type
+func model(entityType string) interface{} {
+	switch entityType {
+	case entities.EntityTypeMode:
+		return &entities.Mode{}
+	case entities.EntityTypeKind:
+		return &entities.Kind{}
+	}
+	return &entities.Substance{}
+}
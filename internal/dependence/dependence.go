@@ -0,0 +1,238 @@
+package dependence
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// Tracker records ontological dependence between substances, modes and kinds
+// and decides what deleting an entity does to the entities depending on it.
+//
+// Following Lowe, an entity may depend on another for its existence or for its
+// identity, and either rigidly, on that very entity, or generically, on there
+// being some instance of a kind. Identity dependence is always rigid and must
+// be well-founded, so it may not form cycles; existential dependence may be
+// mutual.
+type Tracker struct {
+	db     *gorm.DB
+	engine *causality.Engine
+}
+
+// NewTracker creates a new dependence tracker
+func NewTracker(db *gorm.DB) *Tracker {
+	return &Tracker{db: db, engine: causality.NewEngine(db)}
+}
+
+// Deletion policies for entities that others depend on
+const (
+	PolicyBlock   = "block"   // refuse the deletion
+	PolicyCascade = "cascade" // delete the dependents too
+)
+
+var (
+	// ErrInvalidDependence is returned when a dependence is malformed
+	ErrInvalidDependence = errors.New("invalid dependence")
+	// ErrDependenceNotFound is returned when a dependence does not exist
+	ErrDependenceNotFound = errors.New("dependence not found")
+	// ErrDuplicateDependence is returned when the same dependence is recorded twice
+	ErrDuplicateDependence = errors.New("dependence already recorded")
+	// ErrDependenceCycle is returned when identity dependence would become circular
+	ErrDependenceCycle = errors.New("identity dependence cannot be circular")
+	// ErrHasDependents is returned when a blocked deletion would leave dependents without what they depend on
+	ErrHasDependents = errors.New("other entities depend on it")
+)
+
+// dependableTypes are the entity types that may depend or be depended on
+var dependableTypes = map[string]bool{
+	entities.EntityTypeSubstance: true,
+	entities.EntityTypeMode:      true,
+	entities.EntityTypeKind:      true,
+}
+
+// Input describes a dependence to record
+type Input struct {
+	DependentID   string
+	DependentType string // inferred when empty
+	DependsOnID   string
+	DependsOnType string // inferred when empty
+	Type          string // existential or identity
+	Modality      string // rigid (default) or generic
+	Notes         string
+}
+
+// DependenceNode is a dependence reached while walking from an entity
+type DependenceNode struct {
+	entities.Dependence
+	Depth int `json:"depth"` // 1 for direct dependence
+}
+
+// Record validates and stores a dependence
+func (t *Tracker) Record(input Input) (*entities.Dependence, error) {
+	if input.Type != entities.DependenceExistential && input.Type != entities.DependenceIdentity {
+		return nil, fmt.Errorf("%w: type must be one of: existential, identity", ErrInvalidDependence)
+	}
+	if input.Modality == "" {
+		input.Modality = entities.ModalityRigid
+	}
+	if input.Modality != entities.ModalityRigid && input.Modality != entities.ModalityGeneric {
+		return nil, fmt.Errorf("%w: modality must be one of: rigid, generic", ErrInvalidDependence)
+	}
+	if input.DependentID == input.DependsOnID {
+		return nil, fmt.Errorf("%w: nothing depends on itself", ErrInvalidDependence)
+	}
+
+	var err error
+	if input.DependentType, err = t.resolve(input.DependentID, input.DependentType); err != nil {
+		return nil, err
+	}
+	if input.DependsOnType, err = t.resolve(input.DependsOnID, input.DependsOnType); err != nil {
+		return nil, err
+	}
+	if input.Modality == entities.ModalityGeneric {
+		if input.Type == entities.DependenceIdentity {
+			return nil, fmt.Errorf("%w: identity dependence is always rigid", ErrInvalidDependence)
+		}
+		if input.DependsOnType != entities.EntityTypeKind {
+			return nil, fmt.Errorf("%w: generic dependence is on a kind, not a %s", ErrInvalidDependence, input.DependsOnType)
+		}
+	}
+
+	var existing int64
+	if err := t.db.Model(&entities.Dependence{}).
+		Where("dependent_id = ? AND depends_on_id = ? AND type = ? AND modality = ?", input.DependentID, input.DependsOnID, input.Type, input.Modality).
+		Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to get dependences: %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrDuplicateDependence
+	}
+
+	if input.Type == entities.DependenceIdentity {
+		upstream, err := t.walk(input.DependsOnID, true, true, func(d entities.Dependence) bool {
+			return d.Type == entities.DependenceIdentity
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range upstream {
+			if node.DependsOnID == input.DependentID {
+				return nil, fmt.Errorf("%w: %s already depends for its identity on %s", ErrDependenceCycle, input.DependsOnID, input.DependentID)
+			}
+		}
+	}
+
+	dependence := entities.NewDependence(input.Type, input.DependentID, input.DependsOnID)
+	dependence.DependentType = input.DependentType
+	dependence.DependsOnType = input.DependsOnType
+	dependence.Modality = input.Modality
+	dependence.Notes = input.Notes
+	if err := t.db.Create(dependence).Error; err != nil {
+		return nil, fmt.Errorf("failed to create dependence: %w", err)
+	}
+	return dependence, nil
+}
+
+// resolve verifies or infers the type of a dependence endpoint
+func (t *Tracker) resolve(id, declared string) (string, error) {
+	if declared == "" {
+		refs, err := t.engine.ResolveEntities([]string{id})
+		if err != nil {
+			return "", err
+		}
+		declared = refs[id].Type
+		if declared == entities.EntityTypeExternal {
+			return "", fmt.Errorf("%w: %s", causality.ErrEntityNotFound, id)
+		}
+	}
+	if !dependableTypes[declared] {
+		return "", fmt.Errorf("%w: only substances, modes and kinds take part in dependence, not a %s", ErrInvalidDependence, declared)
+	}
+
+	exists, err := t.engine.EntityExists(declared, id)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("%w: %s %s", causality.ErrEntityNotFound, declared, id)
+	}
+	return declared, nil
+}
+
+// List returns every dependence, or those an entity takes part in when entityID is set
+func (t *Tracker) List(entityID string) ([]entities.Dependence, error) {
+	query := t.db.Order("created_at, id")
+	if entityID != "" {
+		query = query.Where("dependent_id = ? OR depends_on_id = ?", entityID, entityID)
+	}
+	var dependences []entities.Dependence
+	if err := query.Find(&dependences).Error; err != nil {
+		return nil, fmt.Errorf("failed to get dependences: %w", err)
+	}
+	return dependences, nil
+}
+
+// Remove deletes a dependence
+func (t *Tracker) Remove(id string) error {
+	result := t.db.Delete(&entities.Dependence{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete dependence: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrDependenceNotFound, id)
+	}
+	return nil
+}
+
+// Dependents answers "what depends on X": the dependences on an entity or,
+// when transitive, also those on its dependents, nearest first
+func (t *Tracker) Dependents(entityID string, transitive bool) ([]DependenceNode, error) {
+	return t.walk(entityID, false, transitive, nil)
+}
+
+// Dependencies returns what an entity depends on or, when transitive, also what that depends on, nearest first
+func (t *Tracker) Dependencies(entityID string, transitive bool) ([]DependenceNode, error) {
+	return t.walk(entityID, true, transitive, nil)
+}
+
+// walk follows dependences from start, towards what it depends on when upstream
+// and towards its dependents otherwise, keeping those accepted by follow
+func (t *Tracker) walk(start string, upstream, transitive bool, follow func(entities.Dependence) bool) ([]DependenceNode, error) {
+	from := "depends_on_id"
+	if upstream {
+		from = "dependent_id"
+	}
+
+	visited := map[string]bool{start: true}
+	frontier := []string{start}
+	result := []DependenceNode{}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var dependences []entities.Dependence
+		if err := t.db.Where(from+" IN ?", frontier).Order("created_at, id").Find(&dependences).Error; err != nil {
+			return nil, fmt.Errorf("failed to get dependences: %w", err)
+		}
+
+		frontier = nil
+		for _, dependence := range dependences {
+			if follow != nil && !follow(dependence) {
+				continue
+			}
+			result = append(result, DependenceNode{Dependence: dependence, Depth: depth})
+			next := dependence.DependentID
+			if upstream {
+				next = dependence.DependsOnID
+			}
+			if !visited[next] {
+				visited[next] = true
+				frontier = append(frontier, next)
+			}
+		}
+		if !transitive {
+			break
+		}
+	}
+	return result, nil
+}
@@ -1,77 +1,312 @@
 package entities
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Entity type names used when an ID may refer to any kind of entity
+const (
+	EntityTypeSubstance    = "substance"
+	EntityTypeKind         = "kind"
+	EntityTypeAttribute    = "attribute"
+	EntityTypeMode         = "mode"
+	EntityTypePotentiality = "potentiality"
+	EntityTypeActuality    = "actuality"
+
+	// EntityTypeExternal marks references to concepts outside the store, such as "wood_water"
+	EntityTypeExternal = "external"
+)
+
+// IsStoredEntityType reports whether entityType names a table of stored entities
+func IsStoredEntityType(entityType string) bool {
+	switch entityType {
+	case EntityTypeSubstance, EntityTypeKind, EntityTypeAttribute, EntityTypeMode, EntityTypePotentiality, EntityTypeActuality:
+		return true
+	}
+	return false
+}
+
+// Attribute cardinalities: how many values a substance may have for an attribute at once
+const (
+	CardinalitySingle   = "single"   // one value; a second, different value contradicts the first
+	CardinalityMultiple = "multiple" // any number of values
+)
+
+// DefaultWorkspace is the ID and name of the workspace used when none is selected
+const DefaultWorkspace = "default"
+
+// Workspace = Isolated namespace of entities; every other entity belongs to exactly one
+type Workspace struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex" json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// APIKey = Credential of a client; only a hash of its secret is stored
+type APIKey struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"uniqueIndex" json:"prefix"` // public part of the key, by which it is looked up
+	Hash       string     `gorm:"not null" json:"-"`         // hex SHA-256 of the secret part
+	Admin      bool       `json:"admin"`                     // may issue and revoke keys and read audit records
+	Roles      string     `json:"roles"`                     // comma-separated names of the key's roles
+	CreatedBy  string     `json:"created_by"`                // principal that issued the key
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Role = Named set of permissions defined by an administrator, beside the built-in roles
+type Role struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
+	Description string    `json:"description"`
+	Permissions string    `gorm:"not null" json:"permissions"` // JSON array of {action, resource, kinds}
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// QuotaUsage = Requests a client made on a UTC day, counted against its daily quota
+type QuotaUsage struct {
+	Client    string    `gorm:"primaryKey" json:"client"` // principal ID, or ip:<address> without credentials
+	Day       string    `gorm:"primaryKey" json:"day"`    // YYYY-MM-DD
+	Requests  int64     `gorm:"not null" json:"requests"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditRecord = Who created, updated or deleted an entity, and when
+type AuditRecord struct {
+	ID            string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID   string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	PrincipalID   string    `gorm:"not null;index" json:"principal_id"`
+	PrincipalName string    `json:"principal_name"`
+	Method        string    `json:"method"`                           // how the principal authenticated: api_key, jwt or cli
+	Action        string    `gorm:"not null" json:"action"`           // create, update or delete
+	EntityType    string    `gorm:"not null" json:"entity_type"`      // table of the entity
+	EntityID      string    `gorm:"index" json:"entity_id,omitempty"` // empty when rows were chosen by a condition
+	Rows          int64     `json:"rows"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
 // Substance = Neo-Aristotelian "independent entity"
 type Substance struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	Name      string    `json:"name"`
-	Kind      string    `json:"kind"` // This will store the kind name, not ID for simplicity
-	Essence   string    `json:"essence"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"` // This will store the kind name, not ID for simplicity
+	Essence     string    `json:"essence"`
+	CreatedAt   time.Time `json:"created_at"`
 
 	// Relationships
-	Attributes     []Attribute    `gorm:"many2many:substance_attributes;" json:"attributes,omitempty"`
-	Modes          []Mode         `gorm:"foreignKey:SubstanceID" json:"modes,omitempty"`
-	Potentialities []Potentiality `gorm:"foreignKey:SubstanceID" json:"potentialities,omitempty"`
-	Actualities    []Actuality    `gorm:"foreignKey:SubstanceID" json:"actualities,omitempty"`
+	Attributes     []Attribute    `gorm:"many2many:substance_attributes;constraint:OnDelete:CASCADE" json:"attributes,omitempty"`
+	Modes          []Mode         `gorm:"foreignKey:SubstanceID;constraint:OnDelete:CASCADE" json:"modes,omitempty"`
+	Potentialities []Potentiality `gorm:"foreignKey:SubstanceID;constraint:OnDelete:CASCADE" json:"potentialities,omitempty"`
+	Actualities    []Actuality    `gorm:"foreignKey:SubstanceID;constraint:OnDelete:CASCADE" json:"actualities,omitempty"`
 }
 
 // Kind = Natural classification (e.g., oak, human)
 type Kind struct {
 	ID          string    `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"uniqueIndex" json:"name"`
+	WorkspaceID string    `gorm:"not null;default:default;uniqueIndex:idx_kinds_workspace_name,priority:1" json:"workspace_id"`
+	Name        string    `gorm:"uniqueIndex:idx_kinds_workspace_name,priority:2" json:"name"`
 	Description string    `json:"description"`
+	Definition  string    `json:"definition,omitempty"` // JSON string of necessary-and-sufficient conditions, empty if undefined
 	CreatedAt   time.Time `json:"created_at"`
 
 	// Relationships
-	Substances []Substance `gorm:"foreignKey:Kind" json:"substances,omitempty"`
+	Substances []Substance `gorm:"foreignKey:Kind;constraint:-" json:"substances,omitempty"`
 }
 
 // Attribute = General property (e.g., color, weight)
 type Attribute struct {
 	ID          string    `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"uniqueIndex" json:"name"`
+	WorkspaceID string    `gorm:"not null;default:default;uniqueIndex:idx_attributes_workspace_name,priority:1" json:"workspace_id"`
+	Name        string    `gorm:"uniqueIndex:idx_attributes_workspace_name,priority:2" json:"name"`
 	Description string    `json:"description"`
-	DataType    string    `json:"data_type"` // string, number, boolean, etc.
+	DataType    string    `json:"data_type"`                                    // string, number, boolean, etc.
+	Cardinality string    `gorm:"not null;default:multiple" json:"cardinality"` // single or multiple values per substance
+	Roles       string    `json:"roles,omitempty"`                              // JSON array of bearer roles, in order, if relational
 	CreatedAt   time.Time `json:"created_at"`
 
 	// Relationships
-	Substances []Substance `gorm:"many2many:substance_attributes;" json:"substances,omitempty"`
-	Modes      []Mode      `gorm:"foreignKey:AttributeID" json:"modes,omitempty"`
+	Substances []Substance `gorm:"many2many:substance_attributes;constraint:OnDelete:CASCADE" json:"substances,omitempty"`
+	Modes      []Mode      `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE" json:"modes,omitempty"`
 }
 
 // Mode = Particular way a substance instantiates an attribute (e.g., this tree's green leaf)
 type Mode struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	Value     string    `json:"value"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	Value       string    `json:"value"`
+	CreatedAt   time.Time `json:"created_at"`
 
 	// Foreign Keys
 	SubstanceID string `gorm:"not null" json:"substance_id"`
 	AttributeID string `gorm:"not null" json:"attribute_id"`
 
+	// Provenance: inferred modes are derived by a rule rather than asserted
+	Inferred bool    `gorm:"not null;default:false" json:"inferred"`
+	RuleID   *string `gorm:"index" json:"rule_id,omitempty"` // rule that derived the mode, nil if asserted
+
 	// Relationships
-	Substance *Substance `gorm:"foreignKey:SubstanceID" json:"substance,omitempty"`
-	Attribute *Attribute `gorm:"foreignKey:AttributeID" json:"attribute,omitempty"`
+	Substance *Substance   `gorm:"foreignKey:SubstanceID" json:"substance,omitempty"`
+	Attribute *Attribute   `gorm:"foreignKey:AttributeID" json:"attribute,omitempty"`
+	Bearers   []ModeBearer `gorm:"foreignKey:ModeID;constraint:OnDelete:CASCADE" json:"bearers,omitempty"` // every bearer of a relational mode, in role order
+}
+
+// ModeBearer = A substance bearing a relational mode in one of its roles (e.g., the taller in "taller than").
+// A relational mode's SubstanceID is its first bearer.
+type ModeBearer struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	WorkspaceID string `gorm:"not null;default:default;index" json:"workspace_id"`
+	ModeID      string `gorm:"not null;uniqueIndex:idx_mode_bearers_mode_position" json:"mode_id"`
+	Position    int    `gorm:"not null;uniqueIndex:idx_mode_bearers_mode_position" json:"position"` // index of the role among the attribute's roles
+	Role        string `gorm:"not null" json:"role"`
+	SubstanceID string `gorm:"not null;index" json:"substance_id"`
+
+	// Relationships
+	Substance *Substance `gorm:"foreignKey:SubstanceID;constraint:OnDelete:CASCADE" json:"substance,omitempty"`
+}
+
+// Rule = Inference rule: whenever its conditions hold for a substance, the substance has a derived mode
+type Rule struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;uniqueIndex:idx_rules_workspace_name,priority:1" json:"workspace_id"`
+	Name        string    `gorm:"uniqueIndex:idx_rules_workspace_name,priority:2" json:"name"`
+	Description string    `json:"description"`
+	Conditions  string    `json:"conditions"` // JSON string of conditions, all of which must hold
+	Value       string    `json:"value"`      // value of the derived mode
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Foreign Key
+	AttributeID string `gorm:"not null" json:"attribute_id"` // attribute of the derived mode
+
+	// Relationships
+	Attribute *Attribute `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE" json:"attribute,omitempty"`
 }
 
 // CausalRelation = Aristotelian causes (material, formal, efficient, final)
 type CausalRelation struct {
-	ID         string    `gorm:"primaryKey" json:"id"`
-	CauseType  string    `json:"cause_type"` // material, formal, efficient, final
-	FromEntity string    `json:"from_entity"`
-	ToEntity   string    `json:"to_entity"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	CauseType   string    `json:"cause_type"` // material, formal, efficient, final
+	FromEntity  string    `json:"from_entity"`
+	ToEntity    string    `json:"to_entity"`
+	FromType    string    `gorm:"not null;default:external" json:"from_type"` // entity type of FromEntity
+	ToType      string    `gorm:"not null;default:external" json:"to_type"`   // entity type of ToEntity
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Qualification of the link
+	Strength   float64    `gorm:"not null;default:1" json:"strength"` // how strong or certain the link is, in (0, 1]
+	Evidence   string     `json:"evidence,omitempty"`                 // citation supporting the link
+	Notes      string     `json:"notes,omitempty"`                    // free-text justification
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`               // start of the period the link held, nil if unbounded
+	ValidUntil *time.Time `json:"valid_until,omitempty"`              // end of the period the link held, nil if unbounded
+}
+
+// Parthood = Part-whole relation: Part is a proper part of Whole (e.g., a leaf of a tree)
+type Parthood struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	PartID      string    `gorm:"not null;uniqueIndex:idx_parthoods_part_whole" json:"part_id"`
+	WholeID     string    `gorm:"not null;uniqueIndex:idx_parthoods_part_whole;index" json:"whole_id"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Relationships
+	Part  *Substance `gorm:"foreignKey:PartID;constraint:OnDelete:CASCADE" json:"part,omitempty"`
+	Whole *Substance `gorm:"foreignKey:WholeID;constraint:OnDelete:CASCADE" json:"whole,omitempty"`
+}
+
+// Kinds of ontological dependence
+const (
+	DependenceExistential = "existential" // the dependent cannot exist without what it depends on
+	DependenceIdentity    = "identity"    // the dependent is the entity it is in virtue of what it depends on
+
+	ModalityRigid   = "rigid"   // on this very entity
+	ModalityGeneric = "generic" // on there being some instance of a kind
+)
+
+// Dependence = Ontological dependence between substances, modes and kinds (e.g., a smile on a face)
+type Dependence struct {
+	ID            string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID   string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	DependentID   string    `gorm:"not null;index" json:"dependent_id"`
+	DependentType string    `gorm:"not null" json:"dependent_type"` // substance, mode or kind
+	DependsOnID   string    `gorm:"not null;index" json:"depends_on_id"`
+	DependsOnType string    `gorm:"not null" json:"depends_on_type"` // substance, mode or kind; always kind when generic
+	Type          string    `gorm:"not null" json:"type"`            // existential or identity
+	Modality      string    `gorm:"not null;default:rigid" json:"modality"`
+	Notes         string    `json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SubstanceRedirect = Where the ID of a substance merged into another now leads
+type SubstanceRedirect struct {
+	FromID      string    `gorm:"primaryKey" json:"from_id"` // ID of the merged duplicate
+	WorkspaceID string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	ToID        string    `gorm:"not null;index" json:"to_id"`
+	MergeID     string    `gorm:"not null" json:"merge_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SubstanceMerge = Record of a duplicate substance merged into a survivor
+type SubstanceMerge struct {
+	ID            string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID   string    `gorm:"not null;default:default;index" json:"workspace_id"`
+	SurvivorID    string    `gorm:"not null;index" json:"survivor_id"`
+	DuplicateID   string    `gorm:"not null;index" json:"duplicate_id"`
+	DuplicateName string    `json:"duplicate_name"`
+	Strategy      string    `gorm:"not null" json:"strategy"` // how conflicting single-valued modes were resolved
+	Summary       string    `json:"summary"`                  // JSON string of what was moved, dropped and resolved
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SubstanceTemplate = Named blueprint for substances of a sort, with the modes and potentialities each starts with
+type SubstanceTemplate struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;uniqueIndex:idx_substance_templates_workspace_name,priority:1" json:"workspace_id"`
+	Name        string    `gorm:"uniqueIndex:idx_substance_templates_workspace_name,priority:2" json:"name"`
+	Description string    `json:"description"`
+	Kind        string    `gorm:"not null" json:"kind"` // kind of the substances created from it
+	Essence     string    `json:"essence"`              // default essence, overridable per substance
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Relationships
+	Modes          []TemplateMode         `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"modes"`
+	Potentialities []TemplatePotentiality `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"potentialities"`
+}
+
+// TemplateMode = Default mode of the substances created from a template
+type TemplateMode struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	WorkspaceID string `gorm:"not null;default:default;index" json:"workspace_id"`
+	TemplateID  string `gorm:"not null;index" json:"template_id"`
+	AttributeID string `gorm:"not null" json:"attribute_id"`
+	Value       string `json:"value"`
+
+	// Relationships
+	Attribute *Attribute `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE" json:"attribute,omitempty"`
+}
+
+// TemplatePotentiality = Potentiality each substance created from a template starts with
+type TemplatePotentiality struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	WorkspaceID string `gorm:"not null;default:default;index" json:"workspace_id"`
+	TemplateID  string `gorm:"not null;index" json:"template_id"`
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+	Conditions  string `json:"conditions"` // JSON string of required conditions
 }
 
 // Potentiality = What a substance can become
 type Potentiality struct {
 	ID          string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID string    `gorm:"not null;default:default;index" json:"workspace_id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Conditions  string    `json:"conditions"` // JSON string of required conditions
@@ -87,6 +322,7 @@ type Potentiality struct {
 // Actuality = Realized potentiality
 type Actuality struct {
 	ID           string    `gorm:"primaryKey" json:"id"`
+	WorkspaceID  string    `gorm:"not null;default:default;index" json:"workspace_id"`
 	Description  string    `json:"description"`
 	ActualizedAt time.Time `json:"actualized_at"`
 
@@ -96,7 +332,52 @@ type Actuality struct {
 
 	// Relationships
 	Substance    *Substance    `gorm:"foreignKey:SubstanceID" json:"substance,omitempty"`
-	Potentiality *Potentiality `gorm:"foreignKey:PotentialityID" json:"potentiality,omitempty"`
+	Potentiality *Potentiality `gorm:"foreignKey:PotentialityID;constraint:OnDelete:CASCADE" json:"potentiality,omitempty"`
+}
+
+// NewWorkspace creates a new workspace with generated ID
+func NewWorkspace(name, description string) *Workspace {
+	return &Workspace{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// NewAPIKey creates a new API key record with generated ID
+func NewAPIKey(name, prefix, hash string, admin bool) *APIKey {
+	return &APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Admin:     admin,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewRole creates a new role with generated ID
+func NewRole(name, description, permissions string) *Role {
+	return &Role{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// NewAuditRecord creates a new audit record with generated ID
+func NewAuditRecord(principalID, action, entityType, entityID string) *AuditRecord {
+	return &AuditRecord{
+		ID:          uuid.New().String(),
+		PrincipalID: principalID,
+		Action:      action,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		CreatedAt:   time.Now(),
+	}
 }
 
 // NewSubstance creates a new substance with generated ID
@@ -127,6 +408,7 @@ func NewAttribute(name, description, dataType string) *Attribute {
 		Name:        name,
 		Description: description,
 		DataType:    dataType,
+		Cardinality: CardinalityMultiple,
 		CreatedAt:   time.Now(),
 	}
 }
@@ -142,6 +424,74 @@ func NewMode(value, substanceID, attributeID string) *Mode {
 	}
 }
 
+// IsRelational reports whether the attribute's modes are borne by several substances in roles
+func (a *Attribute) IsRelational() bool {
+	return a.Roles != ""
+}
+
+// BearerRoles decodes the ordered roles of a relational attribute, nil if monadic
+func (a *Attribute) BearerRoles() ([]string, error) {
+	if a.Roles == "" {
+		return nil, nil
+	}
+	var roles []string
+	if err := json.Unmarshal([]byte(a.Roles), &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// SetBearerRoles validates the ordered roles of a relational attribute and
+// encodes them for storage
+func (a *Attribute) SetBearerRoles(roles []string) error {
+	if len(roles) < 2 {
+		return fmt.Errorf("a relational attribute needs at least two roles")
+	}
+	if a.Cardinality == CardinalitySingle {
+		return fmt.Errorf("relational attributes are multi-valued")
+	}
+	seen := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		if role == "" {
+			return fmt.Errorf("roles cannot be empty")
+		}
+		if seen[role] {
+			return fmt.Errorf("role '%s' is listed twice", role)
+		}
+		seen[role] = true
+	}
+	encoded, err := json.Marshal(roles)
+	if err != nil {
+		return err
+	}
+	a.Roles = string(encoded)
+	return nil
+}
+
+// NewModeBearer creates a new bearer of a relational mode with generated ID
+func NewModeBearer(modeID, substanceID, role string, position int) *ModeBearer {
+	return &ModeBearer{
+		ID:          uuid.New().String(),
+		ModeID:      modeID,
+		Position:    position,
+		Role:        role,
+		SubstanceID: substanceID,
+	}
+}
+
+// NewRule creates a new inference rule with generated ID
+func NewRule(name, description, conditions, attributeID, value string) *Rule {
+	return &Rule{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		Conditions:  conditions,
+		AttributeID: attributeID,
+		Value:       value,
+		CreatedAt:   time.Now(),
+	}
+}
+
 // NewCausalRelation creates a new causal relation with generated ID
 func NewCausalRelation(causeType, fromEntity, toEntity string) *CausalRelation {
 	return &CausalRelation{
@@ -149,10 +499,24 @@ func NewCausalRelation(causeType, fromEntity, toEntity string) *CausalRelation {
 		CauseType:  causeType,
 		FromEntity: fromEntity,
 		ToEntity:   toEntity,
+		FromType:   EntityTypeExternal,
+		ToType:     EntityTypeExternal,
 		CreatedAt:  time.Now(),
+		Strength:   1,
 	}
 }
 
+// HoldsAt reports whether the relation's validity period includes t
+func (r *CausalRelation) HoldsAt(t time.Time) bool {
+	if r.ValidFrom != nil && t.Before(*r.ValidFrom) {
+		return false
+	}
+	if r.ValidUntil != nil && t.After(*r.ValidUntil) {
+		return false
+	}
+	return true
+}
+
 // NewPotentiality creates a new potentiality with generated ID
 func NewPotentiality(name, description, conditions, substanceID string) *Potentiality {
 	return &Potentiality{
@@ -175,3 +539,70 @@ func NewActuality(description, substanceID, potentialityID string) *Actuality {
 		PotentialityID: potentialityID,
 	}
 }
+
+// NewParthood creates a new part-whole relation with generated ID
+func NewParthood(partID, wholeID string) *Parthood {
+	return &Parthood{
+		ID:        uuid.New().String(),
+		PartID:    partID,
+		WholeID:   wholeID,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewDependence creates a new rigid dependence with generated ID
+func NewDependence(dependenceType, dependentID, dependsOnID string) *Dependence {
+	return &Dependence{
+		ID:          uuid.New().String(),
+		DependentID: dependentID,
+		DependsOnID: dependsOnID,
+		Type:        dependenceType,
+		Modality:    ModalityRigid,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// NewSubstanceMerge creates a new merge record with generated ID
+func NewSubstanceMerge(survivorID, duplicateID, duplicateName, strategy string) *SubstanceMerge {
+	return &SubstanceMerge{
+		ID:            uuid.New().String(),
+		SurvivorID:    survivorID,
+		DuplicateID:   duplicateID,
+		DuplicateName: duplicateName,
+		Strategy:      strategy,
+		CreatedAt:     time.Now(),
+	}
+}
+
+// NewSubstanceTemplate creates a new substance template with generated ID
+func NewSubstanceTemplate(name, description, kind, essence string) *SubstanceTemplate {
+	return &SubstanceTemplate{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		Kind:        kind,
+		Essence:     essence,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// NewTemplateMode creates a new default mode of a template with generated ID
+func NewTemplateMode(templateID, attributeID, value string) *TemplateMode {
+	return &TemplateMode{
+		ID:          uuid.New().String(),
+		TemplateID:  templateID,
+		AttributeID: attributeID,
+		Value:       value,
+	}
+}
+
+// NewTemplatePotentiality creates a new potentiality of a template with generated ID
+func NewTemplatePotentiality(templateID, name, description, conditions string) *TemplatePotentiality {
+	return &TemplatePotentiality{
+		ID:          uuid.New().String(),
+		TemplateID:  templateID,
+		Name:        name,
+		Description: description,
+		Conditions:  conditions,
+	}
+}
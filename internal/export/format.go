@@ -0,0 +1,231 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Supported export formats
+const (
+	FormatDOT       = "dot"
+	FormatGraphML   = "graphml"
+	FormatMermaid   = "mermaid"
+	FormatCytoscape = "cytoscape"
+)
+
+// Formats lists every supported export format
+var Formats = []string{FormatDOT, FormatGraphML, FormatMermaid, FormatCytoscape}
+
+// ContentType returns the MIME type of a rendered format
+func ContentType(format string) string {
+	switch format {
+	case FormatDOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	case FormatGraphML:
+		return "application/graphml+xml; charset=utf-8"
+	case FormatCytoscape:
+		return "application/json; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Render writes the graph in the requested format
+func Render(w io.Writer, graph *Graph, format string) error {
+	switch format {
+	case FormatDOT:
+		return renderDOT(w, graph)
+	case FormatGraphML:
+		return renderGraphML(w, graph)
+	case FormatMermaid:
+		return renderMermaid(w, graph)
+	case FormatCytoscape:
+		return renderCytoscape(w, graph)
+	}
+	return fmt.Errorf("unsupported export format: %s. Must be one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// Graphviz DOT
+
+func renderDOT(w io.Writer, graph *Graph) error {
+	var b bytes.Buffer
+	b.WriteString("digraph causes {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%s, tooltip=%s];\n", dotQuote(node.ID), dotQuote(node.Label), dotQuote(node.Type))
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, color=%s, fontcolor=%s];\n",
+			dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(edge.CauseType),
+			dotQuote(CauseColor(edge.CauseType)), dotQuote(CauseColor(edge.CauseType)))
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// dotQuote renders a DOT double-quoted string
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+// GraphML
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderGraphML(w io.Writer, graph *Graph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "cause_type", For: "edge", AttrName: "cause_type", AttrType: "string"},
+			{ID: "strength", For: "edge", AttrName: "strength", AttrType: "double"},
+			{ID: "color", For: "edge", AttrName: "color", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "causes", EdgeDefault: "directed"},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ID,
+			Data: []graphMLData{
+				{Key: "label", Value: node.Label},
+				{Key: "type", Value: node.Type},
+			},
+		})
+	}
+	for _, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     edge.ID,
+			Source: edge.Source,
+			Target: edge.Target,
+			Data: []graphMLData{
+				{Key: "cause_type", Value: edge.CauseType},
+				{Key: "strength", Value: fmt.Sprintf("%g", edge.Strength)},
+				{Key: "color", Value: CauseColor(edge.CauseType)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Mermaid flowchart
+
+func renderMermaid(w io.Writer, graph *Graph) error {
+	// Mermaid node IDs must be plain identifiers, so entities are numbered
+	aliases := make(map[string]string, len(graph.Nodes))
+
+	var b bytes.Buffer
+	b.WriteString("flowchart LR\n")
+	for i, node := range graph.Nodes {
+		aliases[node.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", aliases[node.ID], mermaidEscape(node.Label))
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", aliases[edge.Source], edge.CauseType, aliases[edge.Target])
+	}
+	for i, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  linkStyle %d stroke:%s,color:%s\n", i, CauseColor(edge.CauseType), CauseColor(edge.CauseType))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// mermaidEscape replaces characters that end a quoted Mermaid label
+func mermaidEscape(value string) string {
+	value = strings.ReplaceAll(value, `"`, "#quot;")
+	value = strings.ReplaceAll(value, "\n", " ")
+	return value
+}
+
+// Cytoscape.js JSON
+
+type cytoscapeElement struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+func renderCytoscape(w io.Writer, graph *Graph) error {
+	elements := struct {
+		Nodes []cytoscapeElement `json:"nodes"`
+		Edges []cytoscapeElement `json:"edges"`
+	}{
+		Nodes: make([]cytoscapeElement, 0, len(graph.Nodes)),
+		Edges: make([]cytoscapeElement, 0, len(graph.Edges)),
+	}
+	for _, node := range graph.Nodes {
+		elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: map[string]interface{}{
+			"id":    node.ID,
+			"label": node.Label,
+			"type":  node.Type,
+		}})
+	}
+	for _, edge := range graph.Edges {
+		elements.Edges = append(elements.Edges, cytoscapeElement{Data: map[string]interface{}{
+			"id":         edge.ID,
+			"source":     edge.Source,
+			"target":     edge.Target,
+			"label":      edge.CauseType,
+			"cause_type": edge.CauseType,
+			"strength":   edge.Strength,
+			"color":      CauseColor(edge.CauseType),
+		}})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{"elements": elements})
+}
@@ -0,0 +1,190 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// Graph is a causal network ready to be rendered.
+// Edges point from cause to effect, the way causal diagrams are usually drawn.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node is an entity in the exported graph
+type Node struct {
+	ID    string `json:"id"`
+	Label string `json:"label"` // entity name, or its ID when it has none
+	Type  string `json:"type"`  // one of the entities.EntityType* names
+}
+
+// Edge is a causal relation in the exported graph
+type Edge struct {
+	ID        string  `json:"id"`
+	Source    string  `json:"source"` // the cause (relation ToEntity)
+	Target    string  `json:"target"` // the effect (relation FromEntity)
+	CauseType string  `json:"cause_type"`
+	Strength  float64 `json:"strength"`
+}
+
+// ErrKindNotFound is returned when a kind scope matches neither a kind nor any substance
+var ErrKindNotFound = errors.New("kind not found")
+
+// Scope selects the part of the causal graph to export.
+// With neither SubstanceID nor Kind set the whole store is exported.
+type Scope struct {
+	SubstanceID string   // export the neighbourhood of one substance
+	Kind        string   // export the neighbourhood of every substance of a kind, by kind name or ID
+	Depth       int      // how many relations away from the scope to reach
+	CauseTypes  []string // empty means all cause types
+}
+
+// causeColors gives each cause type a stable colour across formats
+var causeColors = map[string]string{
+	"material":  "#8b4513",
+	"formal":    "#4169e1",
+	"efficient": "#b22222",
+	"final":     "#228b22",
+}
+
+// CauseColor returns the colour used for edges of a cause type
+func CauseColor(causeType string) string {
+	if color, ok := causeColors[causeType]; ok {
+		return color
+	}
+	return "#808080"
+}
+
+// Build collects the causal graph within a scope
+func Build(db *gorm.DB, scope Scope) (*Graph, error) {
+	engine := causality.NewEngine(db)
+
+	var (
+		relations []entities.CausalRelation
+		seeds     []string
+		err       error
+	)
+	switch {
+	case scope.SubstanceID != "":
+		seeds = []string{scope.SubstanceID}
+	case scope.Kind != "":
+		seeds, err = kindSeeds(db, scope.Kind)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if seeds == nil {
+		relations, err = engine.ListCausalRelations(causality.CausalFilter{CauseTypes: scope.CauseTypes})
+	} else {
+		relations, err = neighbourhood(db, engine, seeds, scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := append([]string{}, seeds...)
+	for _, relation := range relations {
+		ids = append(ids, relation.FromEntity, relation.ToEntity)
+	}
+	refs, err := engine.ResolveEntities(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	added := make(map[string]bool, len(refs))
+	for _, id := range ids {
+		if added[id] {
+			continue
+		}
+		added[id] = true
+		ref := refs[id]
+		label := ref.Name
+		if label == "" {
+			label = ref.ID
+		}
+		graph.Nodes = append(graph.Nodes, Node{ID: ref.ID, Label: label, Type: ref.Type})
+	}
+	for _, relation := range relations {
+		graph.Edges = append(graph.Edges, Edge{
+			ID:        relation.ID,
+			Source:    relation.ToEntity,
+			Target:    relation.FromEntity,
+			CauseType: relation.CauseType,
+			Strength:  relation.Strength,
+		})
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool { return graph.Edges[i].ID < graph.Edges[j].ID })
+	return graph, nil
+}
+
+// kindSeeds returns the kind itself and every substance assigned to it
+func kindSeeds(db *gorm.DB, kindRef string) ([]string, error) {
+	var kind entities.Kind
+	err := db.Where("id = ? OR name = ?", kindRef, kindRef).First(&kind).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get kind: %w", err)
+	}
+
+	kindName := kindRef
+	seeds := []string{}
+	if err == nil {
+		kindName = kind.Name
+		seeds = append(seeds, kind.ID)
+	}
+
+	var substanceIDs []string
+	if err := db.Model(&entities.Substance{}).Where("kind = ?", kindName).Pluck("id", &substanceIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substances of kind: %w", err)
+	}
+	if len(seeds) == 0 && len(substanceIDs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrKindNotFound, kindRef)
+	}
+	return append(seeds, substanceIDs...), nil
+}
+
+// neighbourhood returns every relation among the entities reachable from the seeds
+func neighbourhood(db *gorm.DB, engine *causality.Engine, seeds []string, scope Scope) ([]entities.CausalRelation, error) {
+	opts := causality.TraversalOptions{MaxDepth: scope.Depth, CauseTypes: scope.CauseTypes}
+
+	reached := make(map[string]bool, len(seeds))
+	for _, seed := range seeds {
+		reached[seed] = true
+		ancestors, err := engine.GetAncestors(seed, opts)
+		if err != nil {
+			return nil, err
+		}
+		descendants, err := engine.GetDescendants(seed, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range append(ancestors, descendants...) {
+			reached[node.EntityID] = true
+		}
+	}
+
+	ids := make([]string, 0, len(reached))
+	for id := range reached {
+		ids = append(ids, id)
+	}
+
+	query := db.Where("from_entity IN ? AND to_entity IN ?", ids, ids)
+	if len(scope.CauseTypes) > 0 {
+		query = query.Where("cause_type IN ?", scope.CauseTypes)
+	}
+
+	var relations []entities.CausalRelation
+	if err := query.Find(&relations).Error; err != nil {
+		return nil, fmt.Errorf("failed to get causal relations: %w", err)
+	}
+	return relations, nil
+}
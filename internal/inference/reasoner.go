@@ -0,0 +1,362 @@
+package inference
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/mereology"
+	"gorm.io/gorm"
+)
+
+// Reasoner derives modes from inference rules by forward chaining.
+//
+// Every condition a rule can state concerns a single substance, its parts and
+// the substances it is related to, so a change to one substance only needs it,
+// its wholes and its relata re-evaluated: RefreshSubstance is the incremental
+// step, RefreshAll the full run used when rules change. Rules derive monadic
+// modes only.
+// Rules never contradict a single-valued attribute: asserted values win, and
+// among rules the one created first wins.
+type Reasoner struct {
+	db     *gorm.DB
+	engine *causality.Engine
+}
+
+// NewReasoner creates a new reasoner
+func NewReasoner(db *gorm.DB) *Reasoner {
+	return &Reasoner{db: db, engine: causality.NewEngine(db)}
+}
+
+var (
+	// ErrRuleNotFound is returned when a rule ID matches no rule
+	ErrRuleNotFound = errors.New("rule not found")
+	// ErrInvalidRule is returned when a rule's conditions or conclusion are malformed
+	ErrInvalidRule = errors.New("invalid rule")
+)
+
+// RuleInput describes a rule to create: when all conditions hold, the substance has AttributeID = Value
+type RuleInput struct {
+	Name        string
+	Description string
+	Conditions  string // JSON list of causality.Condition
+	AttributeID string
+	Value       string
+}
+
+// Result reports the modes a run derived and retracted
+type Result struct {
+	Derived   []entities.Mode `json:"derived"`
+	Retracted []entities.Mode `json:"retracted"`
+}
+
+func newResult() *Result {
+	return &Result{Derived: []entities.Mode{}, Retracted: []entities.Mode{}}
+}
+
+func (r *Result) merge(other *Result) {
+	r.Derived = append(r.Derived, other.Derived...)
+	r.Retracted = append(r.Retracted, other.Retracted...)
+}
+
+// CreateRule stores a rule and applies it, along with any rules it enables, to every substance
+func (r *Reasoner) CreateRule(input RuleInput) (*entities.Rule, *Result, error) {
+	conditions, err := causality.ParseConditions(input.Conditions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	if len(conditions) == 0 {
+		return nil, nil, fmt.Errorf("%w: a rule needs at least one condition", ErrInvalidRule)
+	}
+
+	var attribute entities.Attribute
+	if err := r.db.First(&attribute, "id = ?", input.AttributeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%w: attribute %s not found", ErrInvalidRule, input.AttributeID)
+		}
+		return nil, nil, fmt.Errorf("failed to get attribute: %w", err)
+	}
+	if attribute.IsRelational() {
+		return nil, nil, fmt.Errorf("%w: attribute '%s' is relational; rules derive monadic modes", ErrInvalidRule, attribute.Name)
+	}
+
+	rule := entities.NewRule(input.Name, input.Description, input.Conditions, input.AttributeID, input.Value)
+	if err := r.db.Create(rule).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to create rule: %w", err)
+	}
+	rule.Attribute = &attribute
+
+	result, err := r.RefreshAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rule, result, nil
+}
+
+// ListRules returns every rule in the order rules are applied
+func (r *Reasoner) ListRules() ([]entities.Rule, error) {
+	var rules []entities.Rule
+	if err := r.db.Preload("Attribute").Order("created_at, id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err)
+	}
+	return rules, nil
+}
+
+// GetRule returns a rule by ID
+func (r *Reasoner) GetRule(id string) (*entities.Rule, error) {
+	var rule entities.Rule
+	if err := r.db.Preload("Attribute").First(&rule, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// DerivedModes returns the modes a rule currently derives
+func (r *Reasoner) DerivedModes(ruleID string) ([]entities.Mode, error) {
+	var modes []entities.Mode
+	if err := r.db.Where("rule_id = ?", ruleID).Order("substance_id").Find(&modes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get derived modes: %w", err)
+	}
+	return modes, nil
+}
+
+// DeleteRule removes a rule and retracts everything that depended on it
+func (r *Reasoner) DeleteRule(id string) (*Result, error) {
+	// Retract the rule's modes first: the database would otherwise delete
+	// them with the rule, leaving what referenced them behind
+	derived, err := r.DerivedModes(id)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(derived))
+	for i, mode := range derived {
+		ids[i] = mode.ID
+	}
+	if err := r.retract(ids); err != nil {
+		return nil, err
+	}
+
+	deleted := r.db.Delete(&entities.Rule{}, "id = ?", id)
+	if deleted.Error != nil {
+		return nil, fmt.Errorf("failed to delete rule: %w", deleted.Error)
+	}
+	if deleted.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	result, err := r.RefreshAll()
+	if err != nil {
+		return nil, err
+	}
+	result.Retracted = append(derived, result.Retracted...)
+	return result, nil
+}
+
+// retract deletes inferred modes along with the causal relations and
+// dependences that reference them
+func (r *Reasoner) retract(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := r.engine.DetachEntities(ids...); err != nil {
+		return err
+	}
+	if err := r.db.Where("dependent_id IN ? OR depends_on_id IN ?", ids, ids).Delete(&entities.Dependence{}).Error; err != nil {
+		return fmt.Errorf("failed to delete dependences: %w", err)
+	}
+	if err := r.db.Delete(&entities.Mode{}, "id IN ?", ids).Error; err != nil {
+		return fmt.Errorf("failed to retract inferred modes: %w", err)
+	}
+	return nil
+}
+
+// RefreshAll re-evaluates every rule against every substance
+func (r *Reasoner) RefreshAll() (*Result, error) {
+	rules, err := r.loadRules()
+	if err != nil {
+		return nil, err
+	}
+
+	var substances []entities.Substance
+	if err := r.db.Order("id").Find(&substances).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substances: %w", err)
+	}
+	if err := mereology.NewMereology(r.db).SortPartsFirst(substances); err != nil {
+		return nil, err
+	}
+
+	result := newResult()
+	for _, substance := range substances {
+		changes, err := r.refresh(substance, rules)
+		if err != nil {
+			return nil, err
+		}
+		result.merge(changes)
+	}
+	return result, nil
+}
+
+// RefreshSubstance re-evaluates every rule against one substance after its kind, modes,
+// relations or parts change, and then against every whole it is part of and every
+// substance it is related to, since their conditions may quantify over it or test its kind
+func (r *Reasoner) RefreshSubstance(substanceID string) (*Result, error) {
+	var substance entities.Substance
+	if err := r.db.First(&substance, "id = ?", substanceID).Error; err != nil {
+		return nil, fmt.Errorf("substance not found: %w", err)
+	}
+
+	rules, err := r.loadRules()
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.refresh(substance, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := mereology.NewMereology(r.db)
+	wholeIDs, err := parts.WholeIDs(substanceID)
+	if err != nil {
+		return nil, err
+	}
+	relatedIDs, err := r.engine.CoBearerIDs(substanceID)
+	if err != nil {
+		return nil, err
+	}
+	affectedIDs := append(wholeIDs, relatedIDs...)
+	if len(affectedIDs) == 0 {
+		return result, nil
+	}
+	var affected []entities.Substance
+	if err := r.db.Where("id IN ?", affectedIDs).Order("id").Find(&affected).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substances: %w", err)
+	}
+	if err := parts.SortPartsFirst(affected); err != nil {
+		return nil, err
+	}
+	for _, other := range affected {
+		changes, err := r.refresh(other, rules)
+		if err != nil {
+			return nil, err
+		}
+		result.merge(changes)
+	}
+	return result, nil
+}
+
+// parsedRule is a rule with its conditions decoded
+type parsedRule struct {
+	entities.Rule
+	conditions []causality.Condition
+}
+
+func (r *Reasoner) loadRules() ([]parsedRule, error) {
+	rules, err := r.ListRules()
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make([]parsedRule, 0, len(rules))
+	for _, rule := range rules {
+		conditions, err := causality.ParseConditions(rule.Conditions)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		parsed = append(parsed, parsedRule{Rule: rule, conditions: conditions})
+	}
+	return parsed, nil
+}
+
+// refresh brings a substance's inferred modes in line with what the rules derive from its asserted facts.
+//
+// Derivation starts from asserted modes only and fires rules in order until none
+// adds anything new, so a mode is only kept while a chain of rules still supports
+// it from asserted facts. Stored inferred modes that match a derivation are kept
+// as they are; the rest are retracted and missing derivations are created.
+func (r *Reasoner) refresh(substance entities.Substance, rules []parsedRule) (*Result, error) {
+	var asserted []entities.Mode
+	if err := r.db.Preload("Attribute").Where("substance_id = ? AND inferred = ?", substance.ID, false).
+		Order("created_at, id").Find(&asserted).Error; err != nil {
+		return nil, fmt.Errorf("failed to get modes: %w", err)
+	}
+
+	facts := causality.FactsFromModes(substance, asserted)
+	parts, err := r.engine.LoadPartFacts(substance.ID)
+	if err != nil {
+		return nil, err
+	}
+	facts.Parts = parts
+	if facts.Relations, err = r.engine.LoadRelationFacts(substance.ID); err != nil {
+		return nil, err
+	}
+
+	fired := make(map[string]bool, len(rules))
+	derives := make(map[string]*parsedRule)
+	for changed := true; changed; {
+		changed = false
+		for i := range rules {
+			rule := &rules[i]
+			if fired[rule.ID] || rule.Attribute == nil {
+				continue
+			}
+			if met, _ := facts.EvaluateAll(rule.conditions); !met {
+				continue
+			}
+			fired[rule.ID] = true
+			changed = true
+
+			attribute := rule.Attribute.Name
+			if holds, _ := facts.Evaluate(causality.Condition{Type: causality.ConditionMode, Name: attribute, Value: rule.Value}); holds {
+				continue // already asserted or derived by an earlier rule
+			}
+			if rule.Attribute.Cardinality == entities.CardinalitySingle && len(facts.Values[attribute]) > 0 {
+				continue // would contradict the value asserted or derived first
+			}
+			facts.Add(attribute, rule.Value)
+			derives[rule.ID] = rule
+		}
+	}
+
+	var stored []entities.Mode
+	if err := r.db.Where("substance_id = ? AND inferred = ?", substance.ID, true).
+		Order("created_at, id").Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to get inferred modes: %w", err)
+	}
+
+	result := newResult()
+	kept := make(map[string]bool, len(stored))
+	var retracted []string
+	for _, mode := range stored {
+		if mode.RuleID != nil && !kept[*mode.RuleID] {
+			if rule, ok := derives[*mode.RuleID]; ok && rule.AttributeID == mode.AttributeID && rule.Value == mode.Value {
+				kept[*mode.RuleID] = true
+				continue
+			}
+		}
+		retracted = append(retracted, mode.ID)
+		result.Retracted = append(result.Retracted, mode)
+	}
+
+	if err := r.retract(retracted); err != nil {
+		return nil, err
+	}
+
+	for i := range rules {
+		rule, ok := derives[rules[i].ID]
+		if !ok || kept[rule.ID] {
+			continue
+		}
+		mode := entities.NewMode(rule.Value, substance.ID, rule.AttributeID)
+		mode.Inferred = true
+		mode.RuleID = &rule.ID
+		if err := r.db.Create(mode).Error; err != nil {
+			return nil, fmt.Errorf("failed to store inferred mode: %w", err)
+		}
+		result.Derived = append(result.Derived, *mode)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,544 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/classification"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// scan holds a snapshot of the whole store and the issues found in it
+type scan struct {
+	kinds          []entities.Kind
+	attributes     []entities.Attribute
+	substances     []entities.Substance
+	modes          []entities.Mode
+	relations      []entities.CausalRelation
+	potentialities []entities.Potentiality
+	actualities    []entities.Actuality
+	rules          []entities.Rule
+	parthoods      []entities.Parthood
+	dependences    []entities.Dependence
+	bearers        []entities.ModeBearer
+
+	// IDs of stored entities by entities.EntityType* name, plus kind names
+	ids       map[string]map[string]bool
+	kindNames map[string]bool
+
+	issues []Issue
+}
+
+func load(db *gorm.DB) (*scan, error) {
+	s := &scan{}
+	tables := []struct {
+		name string
+		dest interface{}
+	}{
+		{"kinds", &s.kinds},
+		{"attributes", &s.attributes},
+		{"substances", &s.substances},
+		{"modes", &s.modes},
+		{"causal relations", &s.relations},
+		{"potentialities", &s.potentialities},
+		{"actualities", &s.actualities},
+		{"rules", &s.rules},
+		{"parthoods", &s.parthoods},
+		{"dependences", &s.dependences},
+		{"mode bearers", &s.bearers},
+	}
+	for _, table := range tables {
+		if err := db.Order("id").Find(table.dest).Error; err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", table.name, err)
+		}
+	}
+
+	s.ids = map[string]map[string]bool{}
+	add := func(entityType, id string) {
+		if s.ids[entityType] == nil {
+			s.ids[entityType] = map[string]bool{}
+		}
+		s.ids[entityType][id] = true
+	}
+	s.kindNames = make(map[string]bool, len(s.kinds))
+	for _, kind := range s.kinds {
+		add(entities.EntityTypeKind, kind.ID)
+		s.kindNames[kind.Name] = true
+	}
+	for _, attribute := range s.attributes {
+		add(entities.EntityTypeAttribute, attribute.ID)
+	}
+	for _, substance := range s.substances {
+		add(entities.EntityTypeSubstance, substance.ID)
+	}
+	for _, mode := range s.modes {
+		add(entities.EntityTypeMode, mode.ID)
+	}
+	for _, potentiality := range s.potentialities {
+		add(entities.EntityTypePotentiality, potentiality.ID)
+	}
+	for _, actuality := range s.actualities {
+		add(entities.EntityTypeActuality, actuality.ID)
+	}
+	return s, nil
+}
+
+func (s *scan) exists(entityType, id string) bool {
+	return s.ids[entityType][id]
+}
+
+// storedType returns the type of the stored entity with this ID, or external
+func (s *scan) storedType(id string) string {
+	for _, entityType := range []string{
+		entities.EntityTypeSubstance, entities.EntityTypeKind, entities.EntityTypeAttribute,
+		entities.EntityTypeMode, entities.EntityTypePotentiality, entities.EntityTypeActuality,
+	} {
+		if s.exists(entityType, id) {
+			return entityType
+		}
+	}
+	return entities.EntityTypeExternal
+}
+
+func (s *scan) report(issue Issue) {
+	s.issues = append(s.issues, issue)
+}
+
+func ref(entityType, id, name string) causality.EntityRef {
+	return causality.EntityRef{ID: id, Type: entityType, Name: name}
+}
+
+// deleteFix removes entities of a model and detaches causal relations that cite them
+func deleteFix(model interface{}, ids ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if _, err := causality.NewEngine(tx).DetachEntities(ids...); err != nil {
+			return err
+		}
+		return tx.Delete(model, "id IN ?", ids).Error
+	}
+}
+
+// checks run in order; each appends what it finds to the scan
+var checks = []func(*scan){
+	checkModes,
+	checkModeConflicts,
+	checkRelations,
+	checkSubstanceKinds,
+	checkKindDefinitions,
+	checkPotentialities,
+	checkActualities,
+	checkCausalRelations,
+	checkRules,
+	checkParthoods,
+	checkDependences,
+}
+
+func checkModes(s *scan) {
+	rules := make(map[string]bool, len(s.rules))
+	for _, rule := range s.rules {
+		rules[rule.ID] = true
+	}
+
+	for _, mode := range s.modes {
+		self := ref(entities.EntityTypeMode, mode.ID, mode.Value)
+		if !s.exists(entities.EntityTypeAttribute, mode.AttributeID) {
+			s.report(Issue{
+				Code:     "mode.missing_attribute",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mode '%s' instantiates attribute %s, which does not exist", mode.Value, mode.AttributeID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeAttribute, mode.AttributeID, "")},
+				Repair:   "delete the mode",
+				fix:      deleteFix(&entities.Mode{}, mode.ID),
+			})
+		}
+		if !s.exists(entities.EntityTypeSubstance, mode.SubstanceID) {
+			s.report(Issue{
+				Code:     "mode.missing_substance",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mode '%s' belongs to substance %s, which does not exist", mode.Value, mode.SubstanceID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeSubstance, mode.SubstanceID, "")},
+				Repair:   "delete the mode",
+				fix:      deleteFix(&entities.Mode{}, mode.ID),
+			})
+		}
+		if mode.Inferred && (mode.RuleID == nil || !rules[*mode.RuleID]) {
+			s.report(Issue{
+				Code:     "mode.orphaned_inference",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("inferred mode '%s' was derived by a rule that no longer exists", mode.Value),
+				Entities: []causality.EntityRef{self},
+				Repair:   "retract the mode",
+				fix:      deleteFix(&entities.Mode{}, mode.ID),
+			})
+		}
+	}
+}
+
+func checkModeConflicts(s *scan) {
+	single := map[string]string{}
+	for _, attribute := range s.attributes {
+		if attribute.Cardinality == entities.CardinalitySingle {
+			single[attribute.ID] = attribute.Name
+		}
+	}
+
+	type key struct{ substance, attribute string }
+	groups := map[key][]entities.Mode{}
+	var keys []key
+	for _, mode := range s.modes {
+		if _, ok := single[mode.AttributeID]; !ok {
+			continue
+		}
+		k := key{mode.SubstanceID, mode.AttributeID}
+		if _, seen := groups[k]; !seen {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], mode)
+	}
+
+	for _, k := range keys {
+		group := groups[k]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].CreatedAt.Before(group[j].CreatedAt) })
+		distinct := map[string]bool{}
+		for _, mode := range group {
+			distinct[mode.Value] = true
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+		current := causality.CurrentMode(group)
+		refs := []causality.EntityRef{ref(entities.EntityTypeSubstance, k.substance, "")}
+		for _, mode := range group {
+			refs = append(refs, ref(entities.EntityTypeMode, mode.ID, mode.Value))
+		}
+		s.report(Issue{
+			Code:     "mode.conflicting_values",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("substance %s has %d different values for single-valued attribute '%s'; '%s' is used",
+				k.substance, len(distinct), single[k.attribute], current.Value),
+			Entities: refs,
+		})
+	}
+}
+
+func checkRelations(s *scan) {
+	relational := map[string]entities.Attribute{}
+	for _, attribute := range s.attributes {
+		if attribute.IsRelational() {
+			relational[attribute.ID] = attribute
+		}
+	}
+	bearers := map[string][]entities.ModeBearer{}
+	for _, bearer := range s.bearers {
+		bearers[bearer.ModeID] = append(bearers[bearer.ModeID], bearer)
+	}
+
+	for _, bearer := range s.bearers {
+		if !s.exists(entities.EntityTypeMode, bearer.ModeID) {
+			s.report(Issue{
+				Code:     "relation.orphaned_bearer",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("substance %s bears mode %s as %s, which does not exist", bearer.SubstanceID, bearer.ModeID, bearer.Role),
+				Entities: []causality.EntityRef{ref(entities.EntityTypeMode, bearer.ModeID, "")},
+				Repair:   "delete the bearer",
+				fix: func(tx *gorm.DB) error {
+					return tx.Delete(&entities.ModeBearer{}, "id = ?", bearer.ID).Error
+				},
+			})
+		}
+	}
+
+	for _, mode := range s.modes {
+		attribute, ok := relational[mode.AttributeID]
+		if !ok {
+			if len(bearers[mode.ID]) > 0 {
+				s.report(Issue{
+					Code:     "relation.monadic_attribute",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("mode '%s' has several bearers but attribute %s is not relational", mode.Value, mode.AttributeID),
+					Entities: []causality.EntityRef{ref(entities.EntityTypeMode, mode.ID, mode.Value)},
+				})
+			}
+			continue
+		}
+		self := ref(entities.EntityTypeMode, mode.ID, mode.Value)
+		roles, _ := attribute.BearerRoles()
+		if len(bearers[mode.ID]) != len(roles) {
+			s.report(Issue{
+				Code:     "relation.incomplete",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("'%s' relation has %d bearers for %d roles", attribute.Name, len(bearers[mode.ID]), len(roles)),
+				Entities: []causality.EntityRef{self},
+				Repair:   "delete the relation",
+				fix:      relationFix(mode.ID),
+			})
+		}
+		for _, bearer := range bearers[mode.ID] {
+			if s.exists(entities.EntityTypeSubstance, bearer.SubstanceID) {
+				continue
+			}
+			s.report(Issue{
+				Code:     "relation.missing_bearer",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("'%s' relation is borne as %s by substance %s, which does not exist", attribute.Name, bearer.Role, bearer.SubstanceID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeSubstance, bearer.SubstanceID, "")},
+				Repair:   "delete the relation",
+				fix:      relationFix(mode.ID),
+			})
+		}
+	}
+}
+
+// relationFix deletes a relational mode with its bearers
+func relationFix(modeID string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if err := tx.Delete(&entities.ModeBearer{}, "mode_id = ?", modeID).Error; err != nil {
+			return err
+		}
+		return deleteFix(&entities.Mode{}, modeID)(tx)
+	}
+}
+
+func checkSubstanceKinds(s *scan) {
+	// Several substances usually share a missing kind; create each kind once
+	created := map[string]bool{}
+	for _, substance := range s.substances {
+		if substance.Kind == "" || s.kindNames[substance.Kind] {
+			continue
+		}
+		kindName := substance.Kind
+		s.report(Issue{
+			Code:     "substance.unknown_kind",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("substance '%s' is of kind '%s', which matches no kind", substance.Name, kindName),
+			Entities: []causality.EntityRef{
+				ref(entities.EntityTypeSubstance, substance.ID, substance.Name),
+				ref(entities.EntityTypeKind, "", kindName),
+			},
+			Repair: fmt.Sprintf("create kind '%s'", kindName),
+			fix: func(tx *gorm.DB) error {
+				if created[kindName] {
+					return nil
+				}
+				created[kindName] = true
+				return tx.Create(entities.NewKind(kindName, "")).Error
+			},
+		})
+	}
+}
+
+func checkKindDefinitions(s *scan) {
+	for _, kind := range s.kinds {
+		if kind.Definition == "" {
+			continue
+		}
+		if _, err := classification.ParseDefinition(kind.Definition); err != nil {
+			s.report(Issue{
+				Code:     "kind.invalid_definition",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("kind '%s' has an unusable definition: %v", kind.Name, err),
+				Entities: []causality.EntityRef{ref(entities.EntityTypeKind, kind.ID, kind.Name)},
+			})
+		}
+	}
+}
+
+func checkPotentialities(s *scan) {
+	for _, potentiality := range s.potentialities {
+		self := ref(entities.EntityTypePotentiality, potentiality.ID, potentiality.Name)
+		if !s.exists(entities.EntityTypeSubstance, potentiality.SubstanceID) {
+			s.report(Issue{
+				Code:     "potentiality.missing_substance",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("potentiality '%s' belongs to substance %s, which does not exist", potentiality.Name, potentiality.SubstanceID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeSubstance, potentiality.SubstanceID, "")},
+			})
+		}
+
+		id := potentiality.ID
+		if strings.TrimSpace(potentiality.Conditions) == "" {
+			s.report(Issue{
+				Code:     "potentiality.empty_conditions",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("potentiality '%s' has no conditions, so it can never be checked or actualized", potentiality.Name),
+				Entities: []causality.EntityRef{self},
+				Repair:   "set conditions to [] (no conditions)",
+				fix: func(tx *gorm.DB) error {
+					return tx.Model(&entities.Potentiality{}).Where("id = ?", id).Update("conditions", "[]").Error
+				},
+			})
+			continue
+		}
+
+		var raw []json.RawMessage
+		if err := json.Unmarshal([]byte(potentiality.Conditions), &raw); err != nil {
+			s.report(Issue{
+				Code:     "potentiality.malformed_conditions",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("potentiality '%s' has conditions that are not a JSON list: %v", potentiality.Name, err),
+				Entities: []causality.EntityRef{self},
+			})
+			continue
+		}
+		if _, err := causality.ParseConditions(potentiality.Conditions); err != nil {
+			s.report(Issue{
+				Code:     "potentiality.invalid_condition",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("potentiality '%s' has a condition that can never be met: %v", potentiality.Name, err),
+				Entities: []causality.EntityRef{self},
+			})
+		}
+	}
+}
+
+func checkActualities(s *scan) {
+	for _, actuality := range s.actualities {
+		self := ref(entities.EntityTypeActuality, actuality.ID, actuality.Description)
+		if !s.exists(entities.EntityTypeSubstance, actuality.SubstanceID) {
+			s.report(Issue{
+				Code:     "actuality.missing_substance",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("actuality '%s' belongs to substance %s, which does not exist", actuality.Description, actuality.SubstanceID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeSubstance, actuality.SubstanceID, "")},
+			})
+		}
+		if !s.exists(entities.EntityTypePotentiality, actuality.PotentialityID) {
+			s.report(Issue{
+				Code:     "actuality.missing_potentiality",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("actuality '%s' realizes potentiality %s, which does not exist", actuality.Description, actuality.PotentialityID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypePotentiality, actuality.PotentialityID, "")},
+			})
+		}
+	}
+}
+
+func checkCausalRelations(s *scan) {
+	for _, relation := range s.relations {
+		self := ref("causal_relation", relation.ID, relation.CauseType)
+		if !causality.IsValidCauseType(relation.CauseType) {
+			s.report(Issue{
+				Code:     "causal_relation.invalid_cause_type",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("causal relation has cause type '%s', which is not one of the four causes", relation.CauseType),
+				Entities: []causality.EntityRef{self},
+			})
+		}
+
+		endpoints := []struct{ id, declared string }{
+			{relation.FromEntity, relation.FromType},
+			{relation.ToEntity, relation.ToType},
+		}
+		for _, endpoint := range endpoints {
+			switch {
+			case entities.IsStoredEntityType(endpoint.declared) && !s.exists(endpoint.declared, endpoint.id):
+				s.report(Issue{
+					Code:     "causal_relation.missing_entity",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("causal relation references %s %s, which does not exist", endpoint.declared, endpoint.id),
+					Entities: []causality.EntityRef{self, ref(endpoint.declared, endpoint.id, "")},
+					Repair:   "delete the causal relation",
+					fix:      deleteFix(&entities.CausalRelation{}, relation.ID),
+				})
+			case endpoint.declared == entities.EntityTypeExternal && s.storedType(endpoint.id) != entities.EntityTypeExternal:
+				id, actual, relationID := endpoint.id, s.storedType(endpoint.id), relation.ID
+				column := "from_type"
+				if id != relation.FromEntity {
+					column = "to_type"
+				}
+				s.report(Issue{
+					Code:     "causal_relation.untyped_reference",
+					Severity: SeverityInfo,
+					Message:  fmt.Sprintf("causal relation treats %s as external, but it is a stored %s", id, actual),
+					Entities: []causality.EntityRef{self, ref(actual, id, "")},
+					Repair:   fmt.Sprintf("record the reference as a %s", actual),
+					fix: func(tx *gorm.DB) error {
+						return tx.Model(&entities.CausalRelation{}).Where("id = ?", relationID).Update(column, actual).Error
+					},
+				})
+			case endpoint.declared == entities.EntityTypeExternal:
+				if _, err := uuid.Parse(endpoint.id); err == nil {
+					s.report(Issue{
+						Code:     "causal_relation.unresolved_id",
+						Severity: SeverityWarning,
+						Message:  fmt.Sprintf("causal relation references %s, which looks like an entity ID but matches nothing", endpoint.id),
+						Entities: []causality.EntityRef{self, ref(entities.EntityTypeExternal, endpoint.id, "")},
+					})
+				}
+			}
+		}
+	}
+}
+
+func checkRules(s *scan) {
+	for _, rule := range s.rules {
+		self := ref("rule", rule.ID, rule.Name)
+		if !s.exists(entities.EntityTypeAttribute, rule.AttributeID) {
+			s.report(Issue{
+				Code:     "rule.missing_attribute",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("rule '%s' derives attribute %s, which does not exist", rule.Name, rule.AttributeID),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeAttribute, rule.AttributeID, "")},
+			})
+		}
+		if _, err := causality.ParseConditions(rule.Conditions); err != nil {
+			s.report(Issue{
+				Code:     "rule.invalid_conditions",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("rule '%s' has unusable conditions: %v", rule.Name, err),
+				Entities: []causality.EntityRef{self},
+			})
+		}
+	}
+}
+
+func checkParthoods(s *scan) {
+	for _, parthood := range s.parthoods {
+		self := ref("parthood", parthood.ID, "")
+		for _, id := range []string{parthood.PartID, parthood.WholeID} {
+			if s.exists(entities.EntityTypeSubstance, id) {
+				continue
+			}
+			s.report(Issue{
+				Code:     "parthood.missing_substance",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("parthood relates substance %s, which does not exist", id),
+				Entities: []causality.EntityRef{self, ref(entities.EntityTypeSubstance, id, "")},
+				Repair:   "delete the parthood",
+				fix: func(tx *gorm.DB) error {
+					return tx.Delete(&entities.Parthood{}, "id = ?", parthood.ID).Error
+				},
+			})
+		}
+	}
+}
+
+func checkDependences(s *scan) {
+	for _, dependence := range s.dependences {
+		self := ref("dependence", dependence.ID, "")
+		endpoints := [][2]string{
+			{dependence.DependentType, dependence.DependentID},
+			{dependence.DependsOnType, dependence.DependsOnID},
+		}
+		for _, endpoint := range endpoints {
+			if s.exists(endpoint[0], endpoint[1]) {
+				continue
+			}
+			s.report(Issue{
+				Code:     "dependence.missing_entity",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("dependence relates %s %s, which does not exist", endpoint[0], endpoint[1]),
+				Entities: []causality.EntityRef{self, ref(endpoint[0], endpoint[1], "")},
+				Repair:   "delete the dependence",
+				fix: func(tx *gorm.DB) error {
+					return tx.Delete(&entities.Dependence{}, "id = ?", dependence.ID).Error
+				},
+			})
+		}
+	}
+}
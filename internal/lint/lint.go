@@ -0,0 +1,100 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"gorm.io/gorm"
+)
+
+// Severity ranks how badly an issue breaks the ontology
+type Severity string
+
+// Severities, from most to least serious
+const (
+	SeverityError   Severity = "error"   // data the engine cannot use or that points at nothing
+	SeverityWarning Severity = "warning" // data that is usable but probably wrong
+	SeverityInfo    Severity = "info"    // worth a look, often intentional
+)
+
+var severityRank = map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// ParseSeverity validates a severity name
+func ParseSeverity(name string) (Severity, error) {
+	severity := Severity(name)
+	if _, ok := severityRank[severity]; !ok {
+		return "", fmt.Errorf("invalid severity: %s. Must be one of: error, warning, info", name)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether s is as serious as other or more
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRank[s] <= severityRank[other]
+}
+
+// Issue is one inconsistency found in the store
+type Issue struct {
+	Code     string                `json:"code"` // stable identifier such as "mode.missing_attribute"
+	Severity Severity              `json:"severity"`
+	Message  string                `json:"message"`
+	Entities []causality.EntityRef `json:"entities"`         // the offending entity first, then what it references
+	Repair   string                `json:"repair,omitempty"` // the safe repair available, empty if none
+	Repaired bool                  `json:"repaired"`
+
+	fix func(tx *gorm.DB) error
+}
+
+// Report is the result of a scan
+type Report struct {
+	Issues   []Issue          `json:"issues"`
+	Counts   map[Severity]int `json:"counts"`
+	Repaired int              `json:"repaired"`
+}
+
+// Options control a scan
+type Options struct {
+	MinSeverity Severity // report only issues at least this serious; empty means all
+	Repair      bool     // apply every available safe repair
+}
+
+// Run scans the whole store for inconsistencies and, when asked, repairs what it safely can.
+// Repairs write through db, so callers wanting all-or-nothing repairs pass a transaction.
+func Run(db *gorm.DB, opts Options) (*Report, error) {
+	s, err := load(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range checks {
+		check(s)
+	}
+
+	report := &Report{Issues: []Issue{}, Counts: map[Severity]int{SeverityError: 0, SeverityWarning: 0, SeverityInfo: 0}}
+	for _, issue := range s.issues {
+		if opts.MinSeverity != "" && !issue.Severity.AtLeast(opts.MinSeverity) {
+			continue
+		}
+		if opts.Repair && issue.fix != nil {
+			if err := issue.fix(db); err != nil {
+				return nil, fmt.Errorf("failed to repair %s: %w", issue.Code, err)
+			}
+			issue.Repaired = true
+			report.Repaired++
+		}
+		report.Counts[issue.Severity]++
+		report.Issues = append(report.Issues, issue)
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Severity != b.Severity {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Entities[0].ID < b.Entities[0].ID
+	})
+	return report, nil
+}
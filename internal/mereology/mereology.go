@@ -0,0 +1,261 @@
+package mereology
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+// Mereology manages part-whole relations between substances.
+//
+// Only direct parthood is stored. Parthood is transitive — a cell of a leaf of
+// a tree is part of the tree — so transitive parts and wholes are derived by
+// walking the stored relation, which is kept acyclic: nothing is part of
+// itself, directly or through its parts.
+type Mereology struct {
+	db *gorm.DB
+}
+
+// NewMereology creates a new mereology
+func NewMereology(db *gorm.DB) *Mereology {
+	return &Mereology{db: db}
+}
+
+var (
+	// ErrSubstanceNotFound is returned when a part or whole does not exist
+	ErrSubstanceNotFound = errors.New("substance not found")
+	// ErrParthoodNotFound is returned when a parthood does not exist
+	ErrParthoodNotFound = errors.New("parthood not found")
+	// ErrParthoodCycle is returned when a parthood would make a substance part of itself
+	ErrParthoodCycle = errors.New("parthood would make a substance part of itself")
+	// ErrDuplicateParthood is returned when a substance is already a direct part of the whole
+	ErrDuplicateParthood = errors.New("substance is already a direct part of the whole")
+)
+
+// PartNode is a substance reached by walking parthood from another
+type PartNode struct {
+	Substance  entities.Substance `json:"substance"`
+	Depth      int                `json:"depth"`       // 1 for direct parts or wholes
+	Via        string             `json:"via"`         // substance this one was reached from
+	ParthoodID string             `json:"parthood_id"` // parthood that reached this substance
+}
+
+// direction names the column a walk starts from and the one it moves to
+type direction struct{ from, to string }
+
+var (
+	down = direction{from: "whole_id", to: "part_id"}
+	up   = direction{from: "part_id", to: "whole_id"}
+)
+
+// AddPart records that part is a direct part of whole
+func (m *Mereology) AddPart(partID, wholeID string) (*entities.Parthood, error) {
+	for _, id := range []string{partID, wholeID} {
+		var count int64
+		if err := m.db.Model(&entities.Substance{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to get substance: %w", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrSubstanceNotFound, id)
+		}
+	}
+	if partID == wholeID {
+		return nil, fmt.Errorf("%w: %s", ErrParthoodCycle, partID)
+	}
+
+	var existing int64
+	if err := m.db.Model(&entities.Parthood{}).Where("part_id = ? AND whole_id = ?", partID, wholeID).
+		Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to get parthoods: %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrDuplicateParthood
+	}
+
+	// The whole must not already be among the part's parts
+	below, err := m.walk(partID, down, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range below {
+		if node.id == wholeID {
+			return nil, fmt.Errorf("%w: %s is already part of %s", ErrParthoodCycle, wholeID, partID)
+		}
+	}
+
+	parthood := entities.NewParthood(partID, wholeID)
+	if err := m.db.Create(parthood).Error; err != nil {
+		return nil, fmt.Errorf("failed to create parthood: %w", err)
+	}
+	return parthood, nil
+}
+
+// RemovePart deletes the direct parthood between part and whole
+func (m *Mereology) RemovePart(partID, wholeID string) error {
+	result := m.db.Where("part_id = ? AND whole_id = ?", partID, wholeID).Delete(&entities.Parthood{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete parthood: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s is not a direct part of %s", ErrParthoodNotFound, partID, wholeID)
+	}
+	return nil
+}
+
+// DetachSubstance deletes every parthood a substance takes part in, as part or as whole
+func (m *Mereology) DetachSubstance(id string) (int64, error) {
+	result := m.db.Where("part_id = ? OR whole_id = ?", id, id).Delete(&entities.Parthood{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to detach parthoods: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// Parts returns a substance's direct parts or, when transitive, all of its parts, nearest first
+func (m *Mereology) Parts(wholeID string, transitive bool) ([]PartNode, error) {
+	return m.nodes(wholeID, down, transitive)
+}
+
+// Wholes returns the wholes a substance is directly part of or, when transitive, all of them, nearest first
+func (m *Mereology) Wholes(partID string, transitive bool) ([]PartNode, error) {
+	return m.nodes(partID, up, transitive)
+}
+
+// PartIDs returns the IDs of all of a substance's parts, direct and indirect
+func (m *Mereology) PartIDs(wholeID string) ([]string, error) {
+	return m.ids(wholeID, down)
+}
+
+// WholeIDs returns the IDs of every whole a substance is part of, direct and indirect
+func (m *Mereology) WholeIDs(partID string) ([]string, error) {
+	return m.ids(partID, up)
+}
+
+func (m *Mereology) ids(start string, dir direction) ([]string, error) {
+	reached, err := m.walk(start, dir, true)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(reached))
+	for i, node := range reached {
+		ids[i] = node.id
+	}
+	return ids, nil
+}
+
+func (m *Mereology) nodes(start string, dir direction, transitive bool) ([]PartNode, error) {
+	var count int64
+	if err := m.db.Model(&entities.Substance{}).Where("id = ?", start).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substance: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSubstanceNotFound, start)
+	}
+
+	reached, err := m.walk(start, dir, transitive)
+	if err != nil {
+		return nil, err
+	}
+	if len(reached) == 0 {
+		return []PartNode{}, nil
+	}
+
+	ids := make([]string, len(reached))
+	for i, node := range reached {
+		ids[i] = node.id
+	}
+	var substances []entities.Substance
+	if err := m.db.Where("id IN ?", ids).Find(&substances).Error; err != nil {
+		return nil, fmt.Errorf("failed to get substances: %w", err)
+	}
+	byID := make(map[string]entities.Substance, len(substances))
+	for _, substance := range substances {
+		byID[substance.ID] = substance
+	}
+
+	nodes := make([]PartNode, 0, len(reached))
+	for _, node := range reached {
+		substance, ok := byID[node.id]
+		if !ok {
+			continue
+		}
+		nodes = append(nodes, PartNode{Substance: substance, Depth: node.depth, Via: node.via, ParthoodID: node.parthoodID})
+	}
+	return nodes, nil
+}
+
+// reached is a substance found by walk
+type reached struct {
+	id, via, parthoodID string
+	depth               int
+}
+
+// walk follows parthood from start one level at a time, visiting each substance once at its nearest depth
+func (m *Mereology) walk(start string, dir direction, transitive bool) ([]reached, error) {
+	visited := map[string]bool{start: true}
+	frontier := []string{start}
+	var result []reached
+	for depth := 1; len(frontier) > 0; depth++ {
+		var parthoods []entities.Parthood
+		if err := m.db.Where(dir.from+" IN ?", frontier).Order("created_at, id").Find(&parthoods).Error; err != nil {
+			return nil, fmt.Errorf("failed to get parthoods: %w", err)
+		}
+
+		frontier = nil
+		for _, parthood := range parthoods {
+			from, to := parthood.WholeID, parthood.PartID
+			if dir == up {
+				from, to = parthood.PartID, parthood.WholeID
+			}
+			if visited[to] {
+				continue
+			}
+			visited[to] = true
+			frontier = append(frontier, to)
+			result = append(result, reached{id: to, via: from, parthoodID: parthood.ID, depth: depth})
+		}
+		if !transitive {
+			break
+		}
+	}
+	return result, nil
+}
+
+// SortPartsFirst orders substances so that every substance comes after all of its parts,
+// keeping the given order otherwise. Rules that quantify over parts need them evaluated first.
+func (m *Mereology) SortPartsFirst(substances []entities.Substance) error {
+	var parthoods []entities.Parthood
+	if err := m.db.Find(&parthoods).Error; err != nil {
+		return fmt.Errorf("failed to get parthoods: %w", err)
+	}
+	parts := make(map[string][]string)
+	for _, parthood := range parthoods {
+		parts[parthood.WholeID] = append(parts[parthood.WholeID], parthood.PartID)
+	}
+
+	// height is the length of the longest chain of parts below a substance
+	height := make(map[string]int)
+	var measure func(id string) int
+	measure = func(id string) int {
+		if h, ok := height[id]; ok {
+			return h
+		}
+		height[id] = 0 // parthood is acyclic; this only guards against corrupt data
+		h := 0
+		for _, part := range parts[id] {
+			if ph := measure(part) + 1; ph > h {
+				h = ph
+			}
+		}
+		height[id] = h
+		return h
+	}
+
+	sort.SliceStable(substances, func(i, j int) bool {
+		return measure(substances[i].ID) < measure(substances[j].ID)
+	})
+	return nil
+}
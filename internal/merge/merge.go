@@ -0,0 +1,492 @@
+package merge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/mereology"
+	"gorm.io/gorm"
+)
+
+// Merger folds duplicate substances into a survivor.
+//
+// Everything that referred to the duplicate — its modes, potentialities,
+// actualities, attribute links, causal relations, parthoods, dependences and
+// the relations it bears — is moved to the survivor, the duplicate is deleted,
+// and its ID is left redirecting to the survivor. Modes the survivor already
+// has are not duplicated; where both give a single-valued attribute different
+// values, the merge strategy decides which side's modes are kept. References
+// to a dropped mode are moved to the mode that replaced it.
+type Merger struct {
+	db     *gorm.DB
+	engine *causality.Engine
+}
+
+// NewMerger creates a new merger
+func NewMerger(db *gorm.DB) *Merger {
+	return &Merger{db: db, engine: causality.NewEngine(db)}
+}
+
+// Strategies for single-valued attributes the survivor and duplicate disagree on
+const (
+	StrategySurvivor  = "survivor"  // keep the survivor's value (default)
+	StrategyDuplicate = "duplicate" // keep the duplicate's value
+	StrategyNewest    = "newest"    // keep whichever value was asserted last
+	StrategyReject    = "reject"    // refuse the merge
+)
+
+var (
+	// ErrSubstanceNotFound is returned when the survivor or duplicate does not exist
+	ErrSubstanceNotFound = errors.New("substance not found")
+	// ErrInvalidMerge is returned when two substances cannot be merged
+	ErrInvalidMerge = errors.New("invalid merge")
+	// ErrMergeConflict is returned by StrategyReject when the substances disagree on a single-valued attribute
+	ErrMergeConflict = errors.New("substances have conflicting single-valued modes")
+)
+
+// Conflict is a single-valued attribute the survivor and duplicate give different values
+type Conflict struct {
+	AttributeID    string `json:"attribute_id"`
+	AttributeName  string `json:"attribute_name"`
+	SurvivorValue  string `json:"survivor_value"`
+	DuplicateValue string `json:"duplicate_value"`
+	Kept           string `json:"kept,omitempty"` // survivor or duplicate; empty when the merge was rejected
+}
+
+// Summary counts what a merge moved to the survivor
+type Summary struct {
+	Modes           int        `json:"modes"`
+	DroppedModes    int        `json:"dropped_modes"` // already held by the survivor, losing a conflict, or inferred
+	Potentialities  int        `json:"potentialities"`
+	Actualities     int        `json:"actualities"`
+	Attributes      int        `json:"attributes"`
+	CausalRelations int        `json:"causal_relations"`
+	Parthoods       int        `json:"parthoods"`
+	Dependences     int        `json:"dependences"`
+	Relations       int        `json:"relations"` // relational modes the duplicate bore
+	Redirects       int        `json:"redirects"` // earlier redirects to the duplicate, now to the survivor
+	Conflicts       []Conflict `json:"conflicts"`
+}
+
+// Result is the outcome of a merge
+type Result struct {
+	Survivor entities.Substance      `json:"survivor"`
+	Merge    entities.SubstanceMerge `json:"merge"`
+	Summary  Summary                 `json:"summary"`
+}
+
+// Resolve returns the ID of the substance an ID refers to, following the
+// redirect left by a merge when the substance itself is gone
+func (m *Merger) Resolve(id string) (string, error) {
+	var count int64
+	if err := m.db.Model(&entities.Substance{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return "", fmt.Errorf("failed to get substance: %w", err)
+	}
+	if count > 0 {
+		return id, nil
+	}
+	var redirect entities.SubstanceRedirect
+	if err := m.db.Where("from_id = ?", id).Limit(1).Find(&redirect).Error; err != nil {
+		return "", fmt.Errorf("failed to get redirect: %w", err)
+	}
+	if redirect.ToID == "" {
+		return "", fmt.Errorf("%w: %s", ErrSubstanceNotFound, id)
+	}
+	return redirect.ToID, nil
+}
+
+// History returns the merges a substance took part in, as survivor or duplicate, oldest first
+func (m *Merger) History(substanceID string) ([]entities.SubstanceMerge, error) {
+	var merges []entities.SubstanceMerge
+	if err := m.db.Where("survivor_id = ? OR duplicate_id = ?", substanceID, substanceID).
+		Order("created_at, id").Find(&merges).Error; err != nil {
+		return nil, fmt.Errorf("failed to get merges: %w", err)
+	}
+	return merges, nil
+}
+
+// Merge folds the duplicate into the survivor and deletes it. Under StrategyReject
+// a disagreement fails with ErrMergeConflict, returning the conflicts in the result.
+// Callers should run it in a transaction and refresh the survivor's inferred modes.
+func (m *Merger) Merge(survivorID, duplicateID, strategy string) (*Result, error) {
+	if strategy == "" {
+		strategy = StrategySurvivor
+	}
+	switch strategy {
+	case StrategySurvivor, StrategyDuplicate, StrategyNewest, StrategyReject:
+	default:
+		return nil, fmt.Errorf("%w: strategy must be one of: survivor, duplicate, newest, reject", ErrInvalidMerge)
+	}
+
+	resolved, err := m.Resolve(survivorID)
+	if err != nil {
+		return nil, err
+	}
+	var survivor, duplicate entities.Substance
+	if err := m.db.First(&survivor, "id = ?", resolved).Error; err != nil {
+		return nil, fmt.Errorf("failed to get survivor: %w", err)
+	}
+	if err := m.db.Where("id = ?", duplicateID).Limit(1).Find(&duplicate).Error; err != nil {
+		return nil, fmt.Errorf("failed to get duplicate: %w", err)
+	}
+	if duplicate.ID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrSubstanceNotFound, duplicateID)
+	}
+	if survivor.ID == duplicate.ID {
+		return nil, fmt.Errorf("%w: a substance cannot be merged into itself", ErrInvalidMerge)
+	}
+
+	summary := Summary{Conflicts: []Conflict{}}
+	if err := m.mergeModes(survivor.ID, duplicate.ID, strategy, &summary); err != nil {
+		if errors.Is(err, ErrMergeConflict) {
+			return &Result{Survivor: survivor, Summary: summary}, err
+		}
+		return nil, err
+	}
+	if err := m.moveReferences(survivor.ID, duplicate.ID, &summary); err != nil {
+		return nil, err
+	}
+
+	// The survivor takes what it lacks from the duplicate
+	updates := map[string]interface{}{}
+	if survivor.Kind == "" && duplicate.Kind != "" {
+		updates["kind"], survivor.Kind = duplicate.Kind, duplicate.Kind
+	}
+	if survivor.Essence == "" && duplicate.Essence != "" {
+		updates["essence"], survivor.Essence = duplicate.Essence, duplicate.Essence
+	}
+	if len(updates) > 0 {
+		if err := m.db.Model(&entities.Substance{}).Where("id = ?", survivor.ID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update survivor: %w", err)
+		}
+	}
+
+	// Earlier redirects to the duplicate now lead to the survivor, keeping them one hop long
+	redirected := m.db.Model(&entities.SubstanceRedirect{}).Where("to_id = ?", duplicate.ID).Update("to_id", survivor.ID)
+	if redirected.Error != nil {
+		return nil, fmt.Errorf("failed to repoint redirects: %w", redirected.Error)
+	}
+	summary.Redirects = int(redirected.RowsAffected)
+
+	record := entities.NewSubstanceMerge(survivor.ID, duplicate.ID, duplicate.Name, strategy)
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+	record.Summary = string(encoded)
+	if err := m.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to record merge: %w", err)
+	}
+	redirect := entities.SubstanceRedirect{FromID: duplicate.ID, ToID: survivor.ID, MergeID: record.ID, CreatedAt: record.CreatedAt}
+	if err := m.db.Create(&redirect).Error; err != nil {
+		return nil, fmt.Errorf("failed to create redirect: %w", err)
+	}
+
+	if err := m.db.Delete(&entities.Substance{}, "id = ?", duplicate.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete duplicate: %w", err)
+	}
+	return &Result{Survivor: survivor, Merge: *record, Summary: summary}, nil
+}
+
+// mergeModes moves the duplicate's modes to the survivor, dropping those the
+// survivor already has and those losing a conflict, and its inferred modes,
+// which the reasoner derives again for the survivor
+func (m *Merger) mergeModes(survivorID, duplicateID, strategy string, summary *Summary) error {
+	load := func(id string) ([]entities.Mode, error) {
+		var modes []entities.Mode
+		if err := m.db.Preload("Attribute").Where("substance_id = ?", id).Order("created_at, id").Find(&modes).Error; err != nil {
+			return nil, fmt.Errorf("failed to get modes: %w", err)
+		}
+		return modes, nil
+	}
+	survivorModes, err := load(survivorID)
+	if err != nil {
+		return err
+	}
+	duplicateModes, err := load(duplicateID)
+	if err != nil {
+		return err
+	}
+
+	replace := map[string]string{} // dropped mode ID to the mode its references move to, empty to detach them
+	type key struct{ attribute, value string }
+	held := map[key]string{}
+	singles := map[string][2][]entities.Mode{} // asserted modes of single-valued attributes, by side
+	var order []string
+	group := func(mode entities.Mode, side int) bool {
+		if mode.Attribute == nil || mode.Attribute.Cardinality != entities.CardinalitySingle || mode.Attribute.IsRelational() {
+			return false
+		}
+		groups, seen := singles[mode.AttributeID]
+		if !seen {
+			order = append(order, mode.AttributeID)
+		}
+		groups[side] = append(groups[side], mode)
+		singles[mode.AttributeID] = groups
+		return true
+	}
+
+	for _, mode := range survivorModes {
+		if mode.Inferred || group(mode, 0) {
+			continue
+		}
+		if _, ok := held[key{mode.AttributeID, mode.Value}]; !ok {
+			held[key{mode.AttributeID, mode.Value}] = mode.ID
+		}
+	}
+	for _, mode := range duplicateModes {
+		if mode.Inferred {
+			replace[mode.ID] = ""
+			continue
+		}
+		if group(mode, 1) || (mode.Attribute != nil && mode.Attribute.IsRelational()) {
+			continue
+		}
+		if id, ok := held[key{mode.AttributeID, mode.Value}]; ok {
+			replace[mode.ID] = id
+		}
+	}
+
+	for _, attributeID := range order {
+		groups := singles[attributeID]
+		if len(groups[0]) == 0 || len(groups[1]) == 0 {
+			continue
+		}
+		kept, lost := causality.CurrentMode(groups[0]), causality.CurrentMode(groups[1])
+		losers := groups[1]
+		if kept.Value != lost.Value {
+			conflict := Conflict{
+				AttributeID:    attributeID,
+				AttributeName:  kept.Attribute.Name,
+				SurvivorValue:  kept.Value,
+				DuplicateValue: lost.Value,
+			}
+			if strategy != StrategyReject {
+				conflict.Kept = StrategySurvivor
+				if strategy == StrategyDuplicate || (strategy == StrategyNewest && lost.CreatedAt.After(kept.CreatedAt)) {
+					conflict.Kept = StrategyDuplicate
+					kept, losers = lost, groups[0]
+				}
+			}
+			summary.Conflicts = append(summary.Conflicts, conflict)
+		}
+		for _, mode := range losers {
+			replace[mode.ID] = kept.ID
+		}
+	}
+	if strategy == StrategyReject && len(summary.Conflicts) > 0 {
+		return fmt.Errorf("%w: %d attributes", ErrMergeConflict, len(summary.Conflicts))
+	}
+
+	dropped := make([]string, 0, len(replace))
+	var detached []string
+	for id, replacement := range replace {
+		dropped = append(dropped, id)
+		if replacement == "" {
+			detached = append(detached, id)
+			continue
+		}
+		if err := m.repoint(id, replacement); err != nil {
+			return err
+		}
+	}
+	if len(detached) > 0 {
+		if _, err := m.engine.DetachEntities(detached...); err != nil {
+			return err
+		}
+		if err := m.db.Where("dependent_id IN ? OR depends_on_id IN ?", detached, detached).Delete(&entities.Dependence{}).Error; err != nil {
+			return fmt.Errorf("failed to delete dependences: %w", err)
+		}
+	}
+	if len(dropped) > 0 {
+		if err := m.db.Delete(&entities.Mode{}, "id IN ?", dropped).Error; err != nil {
+			return fmt.Errorf("failed to drop modes: %w", err)
+		}
+	}
+	summary.DroppedModes = len(dropped)
+
+	moved := m.db.Model(&entities.Mode{}).Where("substance_id = ?", duplicateID).Update("substance_id", survivorID)
+	if moved.Error != nil {
+		return fmt.Errorf("failed to move modes: %w", moved.Error)
+	}
+	summary.Modes = int(moved.RowsAffected)
+	return nil
+}
+
+// moveReferences points everything else that referred to the duplicate at the survivor
+func (m *Merger) moveReferences(survivorID, duplicateID string, summary *Summary) error {
+	for _, model := range []struct {
+		value interface{}
+		count *int
+		name  string
+	}{
+		{&entities.Potentiality{}, &summary.Potentialities, "potentialities"},
+		{&entities.Actuality{}, &summary.Actualities, "actualities"},
+		{&entities.ModeBearer{}, &summary.Relations, "relations"},
+	} {
+		moved := m.db.Model(model.value).Where("substance_id = ?", duplicateID).Update("substance_id", survivorID)
+		if moved.Error != nil {
+			return fmt.Errorf("failed to move %s: %w", model.name, moved.Error)
+		}
+		*model.count = int(moved.RowsAffected)
+	}
+
+	// Attribute links, without linking the survivor to an attribute twice
+	var linked []string
+	if err := m.db.Table("substance_attributes").Where("substance_id = ?", duplicateID).Pluck("attribute_id", &linked).Error; err != nil {
+		return fmt.Errorf("failed to get attribute links: %w", err)
+	}
+	for _, attributeID := range linked {
+		var count int64
+		if err := m.db.Table("substance_attributes").Where("substance_id = ? AND attribute_id = ?", survivorID, attributeID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to get attribute links: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+		if err := m.db.Table("substance_attributes").Create(map[string]interface{}{"substance_id": survivorID, "attribute_id": attributeID}).Error; err != nil {
+			return fmt.Errorf("failed to link attribute: %w", err)
+		}
+		summary.Attributes++
+	}
+	if err := m.db.Table("substance_attributes").Where("substance_id = ?", duplicateID).Delete(nil).Error; err != nil {
+		return fmt.Errorf("failed to unlink attributes: %w", err)
+	}
+
+	moved, err := m.moveCausalRelations(duplicateID, survivorID)
+	if err != nil {
+		return err
+	}
+	summary.CausalRelations = moved
+	if summary.Parthoods, err = m.moveParthoods(survivorID, duplicateID); err != nil {
+		return err
+	}
+	if summary.Dependences, err = m.moveDependences(duplicateID, survivorID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// repoint moves the references to a dropped mode to the mode replacing it
+func (m *Merger) repoint(fromID, toID string) error {
+	if _, err := m.moveCausalRelations(fromID, toID); err != nil {
+		return err
+	}
+	_, err := m.moveDependences(fromID, toID)
+	return err
+}
+
+// moveCausalRelations makes relations that cite one entity cite another instead
+func (m *Merger) moveCausalRelations(fromID, toID string) (int, error) {
+	moved := 0
+	for _, column := range []string{"from_entity", "to_entity"} {
+		result := m.db.Model(&entities.CausalRelation{}).Where(column+" = ?", fromID).Update(column, toID)
+		if result.Error != nil {
+			return 0, fmt.Errorf("failed to move causal relations: %w", result.Error)
+		}
+		moved += int(result.RowsAffected)
+	}
+	return moved, nil
+}
+
+// moveParthoods makes the survivor part of, and whole to, what the duplicate was.
+// Parthood between the two disappears, and a merge that would make the survivor
+// part of itself through other substances is refused.
+func (m *Merger) moveParthoods(survivorID, duplicateID string) (int, error) {
+	if err := m.db.Where("(part_id = ? AND whole_id = ?) OR (part_id = ? AND whole_id = ?)",
+		duplicateID, survivorID, survivorID, duplicateID).Delete(&entities.Parthood{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to delete parthoods: %w", err)
+	}
+
+	var parthoods []entities.Parthood
+	if err := m.db.Where("part_id = ? OR whole_id = ?", duplicateID, duplicateID).Find(&parthoods).Error; err != nil {
+		return 0, fmt.Errorf("failed to get parthoods: %w", err)
+	}
+	moved := 0
+	for _, parthood := range parthoods {
+		part, whole := parthood.PartID, parthood.WholeID
+		if part == duplicateID {
+			part = survivorID
+		} else {
+			whole = survivorID
+		}
+		var count int64
+		if err := m.db.Model(&entities.Parthood{}).Where("part_id = ? AND whole_id = ?", part, whole).Count(&count).Error; err != nil {
+			return 0, fmt.Errorf("failed to get parthoods: %w", err)
+		}
+		if count > 0 {
+			if err := m.db.Delete(&entities.Parthood{}, "id = ?", parthood.ID).Error; err != nil {
+				return 0, fmt.Errorf("failed to delete parthood: %w", err)
+			}
+			continue
+		}
+		if err := m.db.Model(&entities.Parthood{}).Where("id = ?", parthood.ID).
+			Updates(map[string]interface{}{"part_id": part, "whole_id": whole}).Error; err != nil {
+			return 0, fmt.Errorf("failed to move parthood: %w", err)
+		}
+		moved++
+	}
+
+	parts := mereology.NewMereology(m.db)
+	below, err := parts.PartIDs(survivorID)
+	if err != nil {
+		return 0, err
+	}
+	above, err := parts.WholeIDs(survivorID)
+	if err != nil {
+		return 0, err
+	}
+	isPart := make(map[string]bool, len(below))
+	for _, id := range below {
+		isPart[id] = true
+	}
+	for _, id := range above {
+		if isPart[id] {
+			return 0, fmt.Errorf("%w: %s would be both part and whole of the survivor", ErrInvalidMerge, id)
+		}
+	}
+	return moved, nil
+}
+
+// moveDependences makes dependences on or of one entity concern another, dropping
+// those that would become self-dependence or repeat an existing dependence
+func (m *Merger) moveDependences(fromID, toID string) (int, error) {
+	var dependences []entities.Dependence
+	if err := m.db.Where("dependent_id = ? OR depends_on_id = ?", fromID, fromID).Find(&dependences).Error; err != nil {
+		return 0, fmt.Errorf("failed to get dependences: %w", err)
+	}
+	moved := 0
+	for _, dependence := range dependences {
+		dependent, dependsOn := dependence.DependentID, dependence.DependsOnID
+		if dependent == fromID {
+			dependent = toID
+		}
+		if dependsOn == fromID {
+			dependsOn = toID
+		}
+
+		var count int64
+		if dependent != dependsOn {
+			if err := m.db.Model(&entities.Dependence{}).
+				Where("dependent_id = ? AND depends_on_id = ? AND type = ? AND modality = ?", dependent, dependsOn, dependence.Type, dependence.Modality).
+				Count(&count).Error; err != nil {
+				return 0, fmt.Errorf("failed to get dependences: %w", err)
+			}
+		}
+		if dependent == dependsOn || count > 0 {
+			if err := m.db.Delete(&entities.Dependence{}, "id = ?", dependence.ID).Error; err != nil {
+				return 0, fmt.Errorf("failed to delete dependence: %w", err)
+			}
+			continue
+		}
+		if err := m.db.Model(&entities.Dependence{}).Where("id = ?", dependence.ID).
+			Updates(map[string]interface{}{"dependent_id": dependent, "depends_on_id": dependsOn}).Error; err != nil {
+			return 0, fmt.Errorf("failed to move dependence: %w", err)
+		}
+		moved++
+	}
+	return moved, nil
+}
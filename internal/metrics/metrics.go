@@ -0,0 +1,18 @@
+// Package metrics exports the server's metrics to Prometheus. Metrics are
+// registered with promauto in the default registry, which also carries the
+// Go runtime and process collectors.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, from 1ms to 10s
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Handler serves the default registry's metrics to a Prometheus scraper
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
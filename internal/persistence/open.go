@@ -0,0 +1,58 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/metrics"
+	"github.com/apodicticscott/oaas/internal/telemetry"
+	"github.com/apodicticscott/oaas/internal/workspace"
+
+	"gorm.io/gorm"
+)
+
+// Open connects to a database with the named driver: a Postgres DSN or URL for
+// "postgres", a file path or file: URI for "sqlite"
+func Open(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case "postgres":
+		return NewPostgres(dsn)
+	case "sqlite":
+		return NewSQLite(dsn)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+}
+
+// open connects through the dialector, installs the plugins every statement
+// goes through and migrates the tables
+func open(dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: telemetry.NewGormLogger(200 * time.Millisecond)})
+	if err != nil {
+		return nil, err
+	}
+
+	// Confine every statement to the workspace selected by its context
+	if err := db.Use(workspace.Plugin{}); err != nil {
+		return nil, err
+	}
+
+	// Record who changes what
+	if err := db.Use(auth.AuditPlugin{}); err != nil {
+		return nil, err
+	}
+
+	// Time and trace every query
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(telemetry.GormPlugin{}); err != nil {
+		return nil, err
+	}
+
+	// Auto-migrate tables
+	db.AutoMigrate(Models()...)
+
+	return db, nil
+}
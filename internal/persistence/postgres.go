@@ -8,13 +8,17 @@ import (
 )
 
 func NewPostgres(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
+	return open(postgres.Open(dsn))
+}
 
-	// Auto-migrate tables
-	db.AutoMigrate(
+// Models lists every table's model, in an order that creates referenced tables first
+func Models() []interface{} {
+	return []interface{}{
+		&entities.Workspace{},
+		&entities.APIKey{},
+		&entities.Role{},
+		&entities.AuditRecord{},
+		&entities.QuotaUsage{},
 		&entities.Kind{},
 		&entities.Attribute{},
 		&entities.Substance{},
@@ -22,7 +26,14 @@ func NewPostgres(dsn string) (*gorm.DB, error) {
 		&entities.CausalRelation{},
 		&entities.Potentiality{},
 		&entities.Actuality{},
-	)
-
-	return db, nil
+		&entities.Rule{},
+		&entities.Parthood{},
+		&entities.Dependence{},
+		&entities.ModeBearer{},
+		&entities.SubstanceRedirect{},
+		&entities.SubstanceMerge{},
+		&entities.SubstanceTemplate{},
+		&entities.TemplateMode{},
+		&entities.TemplatePotentiality{},
+	}
 }
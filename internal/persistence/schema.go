@@ -0,0 +1,58 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrSchemaOutdated is returned when the database lacks tables the server needs
+// or a SQL migration was left half applied
+var ErrSchemaOutdated = errors.New("database schema is not up to date")
+
+// Ping checks that the database answers within ctx's deadline
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get connection pool: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckSchema returns ErrSchemaOutdated unless every model has its table and
+// no migration applied with the migrate CLI, which records them in
+// schema_migrations, is dirty
+func CheckSchema(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+	var missing []string
+	for _, model := range Models() {
+		if !migrator.HasTable(model) {
+			statement := &gorm.Statement{DB: db}
+			if err := statement.Parse(model); err != nil {
+				return fmt.Errorf("failed to parse model: %w", err)
+			}
+			missing = append(missing, statement.Schema.Table)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing tables %s", ErrSchemaOutdated, strings.Join(missing, ", "))
+	}
+
+	if migrator.HasTable("schema_migrations") {
+		var state struct {
+			Version int64
+			Dirty   bool
+		}
+		if err := db.Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&state).Error; err != nil {
+			return fmt.Errorf("failed to read migration state: %w", err)
+		}
+		if state.Dirty {
+			return fmt.Errorf("%w: migration %d is dirty", ErrSchemaOutdated, state.Version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package persistence
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqlitePragmas are the connection settings NewSQLite applies unless the DSN
+// sets them itself. WAL lets readers run alongside the single writer, and
+// taking the write lock when a transaction begins, rather than at its first
+// write, lets the busy timeout queue concurrent writers instead of failing
+// them with SQLITE_BUSY when two transactions try to upgrade their locks.
+var sqlitePragmas = []struct{ name, alias, value string }{
+	{"_journal_mode", "_journal", "WAL"},
+	{"_synchronous", "_sync", "NORMAL"},
+	{"_foreign_keys", "_fk", "on"},
+	{"_busy_timeout", "_timeout", "5000"},
+	{"_txlock", "", "immediate"},
+}
+
+// NewSQLite opens the SQLite database in the file at path, creating it if need
+// be. path may be a file: URI whose parameters override the defaults.
+func NewSQLite(path string) (*gorm.DB, error) {
+	dsn, err := sqliteDSN(path)
+	if err != nil {
+		return nil, err
+	}
+	return open(sqlite.Open(dsn))
+}
+
+// sqliteDSN adds the default pragmas path does not set
+func sqliteDSN(path string) (string, error) {
+	name, query, _ := strings.Cut(path, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid SQLite parameters in %q: %w", path, err)
+	}
+	for _, pragma := range sqlitePragmas {
+		if !params.Has(pragma.name) && (pragma.alias == "" || !params.Has(pragma.alias)) {
+			params.Set(pragma.name, pragma.value)
+		}
+	}
+	if !strings.HasPrefix(name, "file:") {
+		name = "file:" + name
+	}
+	return name + "?" + params.Encode(), nil
+}
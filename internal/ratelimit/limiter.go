@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit lets a client make Rate requests per second on average, in bursts
+// of up to Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of asking a limiter for one request
+type Decision struct {
+	Allowed    bool
+	Limit      int           // requests a full bucket holds
+	Remaining  int           // requests left in the bucket
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request would be allowed, when this one was not
+}
+
+// bucket holds a client's tokens as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// sweepInterval is how often buckets that have filled up again are dropped
+const sweepInterval = time.Minute
+
+// Limiter is an in-memory token bucket per client. Buckets are not shared
+// between processes: each replica of the server limits on its own.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// Now tells the time; tests replace it
+	Now func() time.Time
+}
+
+// NewLimiter creates a new limiter, nil if the limit allows nothing to be limited
+func NewLimiter(limit Limit) *Limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	if limit.Burst < 1 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket), Now: time.Now}
+}
+
+// Allow takes a token from the client's bucket if it has one
+func (l *Limiter) Allow(client string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	l.sweep(now)
+	capacity := float64(l.limit.Burst)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate)
+	b.updated = now
+
+	decision := Decision{Limit: l.limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = l.duration(1 - b.tokens)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = l.duration(capacity - b.tokens)
+	return decision
+}
+
+// duration is how long the bucket takes to gain the tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// sweep drops buckets that would be full by now, which a new bucket equals
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	capacity := float64(l.limit.Burst)
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate >= capacity {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// Policy is what the API enforces per client; nil parts enforce nothing
+type Policy struct {
+	Addresses *Limiter // every request by its address, before its credentials are checked
+	Requests  *Limiter // every request
+	Expensive *Limiter // expensive routes, on top of Requests
+	Quotas    *Quotas  // requests per UTC day
+}
+
+type contextKey struct{}
+
+// WithClient returns a context carrying the client a request counts against
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// ClientFromContext returns the client the context carries, empty if none
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(contextKey{}).(string)
+	return client
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded is returned when a client has used up its requests for the day
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// Usage is how much of its daily quota a client has used
+type Usage struct {
+	Limit int64
+	Used  int64
+	Reset time.Duration // until the quota renews at midnight UTC
+}
+
+// Remaining returns the requests left today
+func (u Usage) Remaining() int64 {
+	if u.Used >= u.Limit {
+		return 0
+	}
+	return u.Limit - u.Used
+}
+
+// Quotas counts each client's requests per UTC day in the database, so that
+// every replica of the server sees the same counts
+type Quotas struct {
+	db    *gorm.DB
+	limit int64
+
+	// Now tells the time; tests replace it
+	Now func() time.Time
+}
+
+// NewQuotas creates new quotas of limit requests a day, nil if limit is not positive
+func NewQuotas(db *gorm.DB, limit int64) *Quotas {
+	if limit <= 0 {
+		return nil
+	}
+	return &Quotas{db: db, limit: limit, Now: time.Now}
+}
+
+// Use counts a request against the client's quota. Requests over the quota
+// are counted too, and fail with ErrQuotaExceeded.
+func (q *Quotas) Use(client string) (Usage, error) {
+	now := q.Now().UTC()
+	day := now.Format(time.DateOnly)
+	usage := entities.QuotaUsage{Client: client, Day: day, Requests: 1, UpdatedAt: now}
+	err := q.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "client"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":   gorm.Expr("quota_usages.requests + 1"),
+			"updated_at": now,
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to count request: %w", err)
+	}
+	if err := q.db.Where("client = ? AND day = ?", client, day).First(&usage).Error; err != nil {
+		return Usage{}, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	result := Usage{Limit: q.limit, Used: usage.Requests, Reset: midnight.Sub(now)}
+	if result.Used > q.limit {
+		return result, fmt.Errorf("%w: %d requests a day", ErrQuotaExceeded, q.limit)
+	}
+	return result, nil
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGorm creates a store over a database, or over a transaction to take part in it
+func NewGorm(db *gorm.DB) *Store {
+	g := gormDB{db}
+	return &Store{
+		Substances:     gormSubstances{g},
+		Kinds:          gormKinds{g},
+		Attributes:     gormAttributes{g},
+		Modes:          gormModes{g},
+		Potentialities: gormPotentialities{g},
+		Actualities:    gormActualities{g},
+		Causes:         gormCauses{g},
+		db:             db,
+		transact: func(ctx context.Context, fn func(*Store) error) error {
+			return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return fn(NewGorm(tx))
+			})
+		},
+	}
+}
+
+type gormDB struct {
+	db *gorm.DB
+}
+
+// with returns the database in ctx's workspace and trace
+func (g gormDB) with(ctx context.Context) *gorm.DB {
+	return g.db.WithContext(ctx)
+}
+
+// first loads the record with the ID, returning ErrNotFound if there is none
+func first(query *gorm.DB, dest interface{}, id string) error {
+	err := query.First(dest, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// create inserts the record, reporting a violated unique constraint as ErrDuplicate
+func create(db *gorm.DB, record interface{}) error {
+	err := db.Create(record).Error
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok && err != nil &&
+		errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	}
+	return err
+}
+
+// find loads the records among ids into dest
+func find(query *gorm.DB, ids []string, dest interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return query.Where("id IN ?", ids).Find(dest).Error
+}
+
+type gormSubstances struct{ gormDB }
+
+// withAssociations preloads what Get and List return with a substance
+func withAssociations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Attributes").Preload("Modes").Preload("Potentialities").Preload("Actualities")
+}
+
+func (r gormSubstances) List(ctx context.Context) ([]entities.Substance, error) {
+	substances := []entities.Substance{}
+	if err := withAssociations(r.with(ctx)).Order("created_at, id").Find(&substances).Error; err != nil {
+		return nil, err
+	}
+	return substances, nil
+}
+
+func (r gormSubstances) Get(ctx context.Context, id string) (*entities.Substance, error) {
+	var substance entities.Substance
+	if err := first(withAssociations(r.with(ctx)), &substance, id); err != nil {
+		return nil, err
+	}
+	return &substance, nil
+}
+
+func (r gormSubstances) Find(ctx context.Context, ids []string) ([]entities.Substance, error) {
+	substances := []entities.Substance{}
+	if err := find(r.with(ctx), ids, &substances); err != nil {
+		return nil, err
+	}
+	return substances, nil
+}
+
+func (r gormSubstances) Create(ctx context.Context, substance *entities.Substance) error {
+	return create(r.with(ctx), substance)
+}
+
+func (r gormSubstances) Update(ctx context.Context, substance *entities.Substance) error {
+	result := r.with(ctx).Model(substance).Select("name", "kind", "essence").Updates(substance)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (r gormSubstances) Lock(ctx context.Context, id string) error {
+	var substance entities.Substance
+	// SQLite has no row locks, and needs none: its transactions take the write lock when they begin
+	return first(r.with(ctx).Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Select("id"), &substance, id)
+}
+
+type gormKinds struct{ gormDB }
+
+func (r gormKinds) List(ctx context.Context) ([]entities.Kind, error) {
+	kinds := []entities.Kind{}
+	if err := r.with(ctx).Order("created_at, id").Find(&kinds).Error; err != nil {
+		return nil, err
+	}
+	return kinds, nil
+}
+
+func (r gormKinds) Find(ctx context.Context, ids []string) ([]entities.Kind, error) {
+	kinds := []entities.Kind{}
+	if err := find(r.with(ctx), ids, &kinds); err != nil {
+		return nil, err
+	}
+	return kinds, nil
+}
+
+func (r gormKinds) Create(ctx context.Context, kind *entities.Kind) error {
+	return create(r.with(ctx), kind)
+}
+
+type gormAttributes struct{ gormDB }
+
+func (r gormAttributes) List(ctx context.Context) ([]entities.Attribute, error) {
+	attributes := []entities.Attribute{}
+	if err := r.with(ctx).Order("created_at, id").Find(&attributes).Error; err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+func (r gormAttributes) Get(ctx context.Context, id string) (*entities.Attribute, error) {
+	var attribute entities.Attribute
+	if err := first(r.with(ctx), &attribute, id); err != nil {
+		return nil, err
+	}
+	return &attribute, nil
+}
+
+func (r gormAttributes) Find(ctx context.Context, ids []string) ([]entities.Attribute, error) {
+	attributes := []entities.Attribute{}
+	if err := find(r.with(ctx), ids, &attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+func (r gormAttributes) Create(ctx context.Context, attribute *entities.Attribute) error {
+	return create(r.with(ctx), attribute)
+}
+
+type gormModes struct{ gormDB }
+
+func (r gormModes) List(ctx context.Context) ([]entities.Mode, error) {
+	modes := []entities.Mode{}
+	if err := r.with(ctx).Preload("Substance").Preload("Attribute").Order("created_at, id").Find(&modes).Error; err != nil {
+		return nil, err
+	}
+	return modes, nil
+}
+
+func (r gormModes) ListBySubstance(ctx context.Context, substanceID string) ([]entities.Mode, error) {
+	modes := []entities.Mode{}
+	err := r.with(ctx).Preload("Attribute").Where("substance_id = ?", substanceID).Order("created_at, id").Find(&modes).Error
+	if err != nil {
+		return nil, err
+	}
+	return modes, nil
+}
+
+func (r gormModes) Find(ctx context.Context, ids []string) ([]entities.Mode, error) {
+	modes := []entities.Mode{}
+	if err := find(r.with(ctx).Preload("Attribute"), ids, &modes); err != nil {
+		return nil, err
+	}
+	return modes, nil
+}
+
+func (r gormModes) Create(ctx context.Context, mode *entities.Mode) error {
+	return create(r.with(ctx), mode)
+}
+
+type gormPotentialities struct{ gormDB }
+
+func (r gormPotentialities) List(ctx context.Context) ([]entities.Potentiality, error) {
+	potentialities := []entities.Potentiality{}
+	if err := r.with(ctx).Preload("Substance").Order("created_at, id").Find(&potentialities).Error; err != nil {
+		return nil, err
+	}
+	return potentialities, nil
+}
+
+func (r gormPotentialities) Get(ctx context.Context, id string) (*entities.Potentiality, error) {
+	var potentiality entities.Potentiality
+	if err := first(r.with(ctx).Preload("Substance"), &potentiality, id); err != nil {
+		return nil, err
+	}
+	return &potentiality, nil
+}
+
+func (r gormPotentialities) Find(ctx context.Context, ids []string) ([]entities.Potentiality, error) {
+	potentialities := []entities.Potentiality{}
+	if err := find(r.with(ctx), ids, &potentialities); err != nil {
+		return nil, err
+	}
+	return potentialities, nil
+}
+
+func (r gormPotentialities) ListBySubstance(ctx context.Context, substanceID string) ([]entities.Potentiality, error) {
+	potentialities := []entities.Potentiality{}
+	err := r.with(ctx).Where("substance_id = ?", substanceID).Order("created_at, id").Find(&potentialities).Error
+	if err != nil {
+		return nil, err
+	}
+	return potentialities, nil
+}
+
+func (r gormPotentialities) Create(ctx context.Context, potentiality *entities.Potentiality) error {
+	return create(r.with(ctx), potentiality)
+}
+
+type gormActualities struct{ gormDB }
+
+func (r gormActualities) Find(ctx context.Context, ids []string) ([]entities.Actuality, error) {
+	actualities := []entities.Actuality{}
+	if err := find(r.with(ctx), ids, &actualities); err != nil {
+		return nil, err
+	}
+	return actualities, nil
+}
+
+func (r gormActualities) ListBySubstance(ctx context.Context, substanceID string) ([]entities.Actuality, error) {
+	actualities := []entities.Actuality{}
+	err := r.with(ctx).Preload("Potentiality").Where("substance_id = ?", substanceID).
+		Order("actualized_at, id").Find(&actualities).Error
+	if err != nil {
+		return nil, err
+	}
+	return actualities, nil
+}
+
+func (r gormActualities) Create(ctx context.Context, actuality *entities.Actuality) error {
+	return create(r.with(ctx), actuality)
+}
+
+type gormCauses struct{ gormDB }
+
+func (r gormCauses) List(ctx context.Context, filter CauseFilter) ([]entities.CausalRelation, error) {
+	predicate, args := filter.Predicate("")
+	query := r.with(ctx).Where(predicate, args...)
+	if filter.EntityID != "" {
+		query = query.Where("from_entity = ? OR to_entity = ?", filter.EntityID, filter.EntityID)
+	}
+	relations := []entities.CausalRelation{}
+	if err := query.Order("created_at, id").Find(&relations).Error; err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+func (r gormCauses) Find(ctx context.Context, ids []string) ([]entities.CausalRelation, error) {
+	relations := []entities.CausalRelation{}
+	if err := find(r.with(ctx), ids, &relations); err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+func (r gormCauses) Create(ctx context.Context, relation *entities.CausalRelation) error {
+	return create(r.with(ctx), relation)
+}
+
+func (r gormCauses) Detach(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.with(ctx).Where("from_entity IN ? OR to_entity IN ?", ids, ids).Delete(&entities.CausalRelation{})
+	return result.RowsAffected, result.Error
+}
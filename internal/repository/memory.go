@@ -0,0 +1,536 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/workspace"
+)
+
+// NewMemory creates an empty store that keeps records in memory, for tests
+// and tools that need no database. It checks that IDs and unique names are
+// not taken, but not that referenced records exist. Its transactions run one
+// at a time; one that fails puts back every record as it was when it began,
+// undoing writes made meanwhile outside it too.
+func NewMemory() *Store {
+	m := &memory{
+		substances:     make(map[string]entities.Substance),
+		kinds:          make(map[string]entities.Kind),
+		attributes:     make(map[string]entities.Attribute),
+		modes:          make(map[string]entities.Mode),
+		potentialities: make(map[string]entities.Potentiality),
+		actualities:    make(map[string]entities.Actuality),
+		causes:         make(map[string]entities.CausalRelation),
+	}
+	store := m.store()
+	store.transact = m.transaction
+	return store
+}
+
+// store returns a store over the records, without transactions
+func (m *memory) store() *Store {
+	return &Store{
+		Substances:     memorySubstances{m},
+		Kinds:          memoryKinds{m},
+		Attributes:     memoryAttributes{m},
+		Modes:          memoryModes{m},
+		Potentialities: memoryPotentialities{m},
+		Actualities:    memoryActualities{m},
+		Causes:         memoryCauses{m},
+	}
+}
+
+// transaction runs fn with a store whose transactions join this one,
+// restoring the records if fn fails
+func (m *memory) transaction(_ context.Context, fn func(*Store) error) error {
+	m.tx.Lock()
+	defer m.tx.Unlock()
+
+	m.mu.RLock()
+	saved := m.clone()
+	m.mu.RUnlock()
+
+	tx := m.store()
+	tx.transact = func(_ context.Context, fn func(*Store) error) error { return fn(tx) }
+	if err := fn(tx); err != nil {
+		m.mu.Lock()
+		m.substances, m.kinds, m.attributes = saved.substances, saved.kinds, saved.attributes
+		m.modes, m.potentialities, m.actualities, m.causes = saved.modes, saved.potentialities, saved.actualities, saved.causes
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// clone copies the records; the caller holds mu
+func (m *memory) clone() *memory {
+	return &memory{
+		substances:     maps.Clone(m.substances),
+		kinds:          maps.Clone(m.kinds),
+		attributes:     maps.Clone(m.attributes),
+		modes:          maps.Clone(m.modes),
+		potentialities: maps.Clone(m.potentialities),
+		actualities:    maps.Clone(m.actualities),
+		causes:         maps.Clone(m.causes),
+	}
+}
+
+// memory holds every record by ID, without associations, which are filled in
+// on the copies handed out
+type memory struct {
+	tx             sync.Mutex // held by the transaction running
+	mu             sync.RWMutex
+	substances     map[string]entities.Substance
+	kinds          map[string]entities.Kind
+	attributes     map[string]entities.Attribute
+	modes          map[string]entities.Mode
+	potentialities map[string]entities.Potentiality
+	actualities    map[string]entities.Actuality
+	causes         map[string]entities.CausalRelation
+}
+
+// collect returns the records of ctx's workspace that keep, if not nil, allows,
+// ordered by less
+func collect[T any](ctx context.Context, records map[string]T, workspaceOf func(T) string, keep func(T) bool, less func(a, b T) bool) []T {
+	id := workspace.FromContext(ctx)
+	found := []T{}
+	for _, r := range records {
+		if workspaceOf(r) == id && (keep == nil || keep(r)) {
+			found = append(found, r)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return less(found[i], found[j]) })
+	return found
+}
+
+// older orders records by creation time, then ID
+func older(a, b time.Time, aID, bID string) bool {
+	if !a.Equal(b) {
+		return a.Before(b)
+	}
+	return aID < bID
+}
+
+// among returns the set of ids
+func among(ids []string) map[string]bool {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return wanted
+}
+
+// taken returns ErrDuplicate if the ID is in use, in any workspace as IDs are global
+func taken[T any](records map[string]T, id string) error {
+	if _, ok := records[id]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, id)
+	}
+	return nil
+}
+
+type memorySubstances struct{ m *memory }
+
+// bareSubstance strips a substance of its associations
+func bareSubstance(s entities.Substance) entities.Substance {
+	s.Attributes, s.Modes, s.Potentialities, s.Actualities = nil, nil, nil, nil
+	return s
+}
+
+// withAssociations fills in what Get and List return with a substance. Only
+// the merge tool links substances to attributes directly, so the list of
+// attributes is always empty here.
+func (r memorySubstances) withAssociations(s entities.Substance) entities.Substance {
+	s.Attributes = []entities.Attribute{}
+	s.Modes = []entities.Mode{}
+	s.Potentialities = []entities.Potentiality{}
+	s.Actualities = []entities.Actuality{}
+	for _, mode := range r.m.modes {
+		if mode.SubstanceID == s.ID {
+			s.Modes = append(s.Modes, mode)
+		}
+	}
+	for _, potentiality := range r.m.potentialities {
+		if potentiality.SubstanceID == s.ID {
+			s.Potentialities = append(s.Potentialities, potentiality)
+		}
+	}
+	for _, actuality := range r.m.actualities {
+		if actuality.SubstanceID == s.ID {
+			s.Actualities = append(s.Actualities, actuality)
+		}
+	}
+	sort.Slice(s.Modes, func(i, j int) bool {
+		return older(s.Modes[i].CreatedAt, s.Modes[j].CreatedAt, s.Modes[i].ID, s.Modes[j].ID)
+	})
+	sort.Slice(s.Potentialities, func(i, j int) bool {
+		a, b := s.Potentialities[i], s.Potentialities[j]
+		return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	sort.Slice(s.Actualities, func(i, j int) bool {
+		a, b := s.Actualities[i], s.Actualities[j]
+		return older(a.ActualizedAt, b.ActualizedAt, a.ID, b.ID)
+	})
+	return s
+}
+
+func substanceWorkspace(s entities.Substance) string { return s.WorkspaceID }
+func substanceOlder(a, b entities.Substance) bool    { return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID) }
+
+func (r memorySubstances) List(ctx context.Context) ([]entities.Substance, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	substances := collect(ctx, r.m.substances, substanceWorkspace, nil, substanceOlder)
+	for i := range substances {
+		substances[i] = r.withAssociations(substances[i])
+	}
+	return substances, nil
+}
+
+func (r memorySubstances) Get(ctx context.Context, id string) (*entities.Substance, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	substance, ok := r.m.substances[id]
+	if !ok || substance.WorkspaceID != workspace.FromContext(ctx) {
+		return nil, ErrNotFound
+	}
+	substance = r.withAssociations(substance)
+	return &substance, nil
+}
+
+func (r memorySubstances) Find(ctx context.Context, ids []string) ([]entities.Substance, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	return collect(ctx, r.m.substances, substanceWorkspace, func(s entities.Substance) bool { return wanted[s.ID] }, substanceOlder), nil
+}
+
+func (r memorySubstances) Create(ctx context.Context, substance *entities.Substance) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.substances, substance.ID); err != nil {
+		return err
+	}
+	substance.WorkspaceID = workspace.FromContext(ctx)
+	r.m.substances[substance.ID] = bareSubstance(*substance)
+	return nil
+}
+
+func (r memorySubstances) Update(ctx context.Context, substance *entities.Substance) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	stored, ok := r.m.substances[substance.ID]
+	if !ok || stored.WorkspaceID != workspace.FromContext(ctx) {
+		return ErrNotFound
+	}
+	stored.Name, stored.Kind, stored.Essence = substance.Name, substance.Kind, substance.Essence
+	r.m.substances[stored.ID] = stored
+	return nil
+}
+
+// Lock only checks that the substance is stored; the memory store's writes
+// are serialized already
+func (r memorySubstances) Lock(ctx context.Context, id string) error {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	substance, ok := r.m.substances[id]
+	if !ok || substance.WorkspaceID != workspace.FromContext(ctx) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memoryKinds struct{ m *memory }
+
+func kindWorkspace(k entities.Kind) string { return k.WorkspaceID }
+func kindOlder(a, b entities.Kind) bool    { return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID) }
+
+func (r memoryKinds) List(ctx context.Context) ([]entities.Kind, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return collect(ctx, r.m.kinds, kindWorkspace, nil, kindOlder), nil
+}
+
+func (r memoryKinds) Find(ctx context.Context, ids []string) ([]entities.Kind, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	return collect(ctx, r.m.kinds, kindWorkspace, func(k entities.Kind) bool { return wanted[k.ID] }, kindOlder), nil
+}
+
+func (r memoryKinds) Create(ctx context.Context, kind *entities.Kind) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.kinds, kind.ID); err != nil {
+		return err
+	}
+	id := workspace.FromContext(ctx)
+	for _, other := range r.m.kinds {
+		if other.WorkspaceID == id && other.Name == kind.Name {
+			return fmt.Errorf("%w: kind %q", ErrDuplicate, kind.Name)
+		}
+	}
+	kind.WorkspaceID = id
+	stored := *kind
+	stored.Substances = nil
+	r.m.kinds[kind.ID] = stored
+	return nil
+}
+
+type memoryAttributes struct{ m *memory }
+
+func attributeWorkspace(a entities.Attribute) string { return a.WorkspaceID }
+func attributeOlder(a, b entities.Attribute) bool    { return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID) }
+
+func (r memoryAttributes) List(ctx context.Context) ([]entities.Attribute, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return collect(ctx, r.m.attributes, attributeWorkspace, nil, attributeOlder), nil
+}
+
+func (r memoryAttributes) Get(ctx context.Context, id string) (*entities.Attribute, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	attribute, ok := r.m.attributes[id]
+	if !ok || attribute.WorkspaceID != workspace.FromContext(ctx) {
+		return nil, ErrNotFound
+	}
+	return &attribute, nil
+}
+
+func (r memoryAttributes) Find(ctx context.Context, ids []string) ([]entities.Attribute, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	return collect(ctx, r.m.attributes, attributeWorkspace, func(a entities.Attribute) bool { return wanted[a.ID] }, attributeOlder), nil
+}
+
+func (r memoryAttributes) Create(ctx context.Context, attribute *entities.Attribute) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.attributes, attribute.ID); err != nil {
+		return err
+	}
+	id := workspace.FromContext(ctx)
+	for _, other := range r.m.attributes {
+		if other.WorkspaceID == id && other.Name == attribute.Name {
+			return fmt.Errorf("%w: attribute %q", ErrDuplicate, attribute.Name)
+		}
+	}
+	attribute.WorkspaceID = id
+	stored := *attribute
+	stored.Substances, stored.Modes = nil, nil
+	r.m.attributes[attribute.ID] = stored
+	return nil
+}
+
+type memoryModes struct{ m *memory }
+
+func modeWorkspace(m entities.Mode) string { return m.WorkspaceID }
+func modeOlder(a, b entities.Mode) bool    { return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID) }
+
+// withAttribute fills in a mode's attribute
+func (r memoryModes) withAttribute(mode entities.Mode) entities.Mode {
+	if attribute, ok := r.m.attributes[mode.AttributeID]; ok {
+		mode.Attribute = &attribute
+	}
+	return mode
+}
+
+func (r memoryModes) List(ctx context.Context) ([]entities.Mode, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	modes := collect(ctx, r.m.modes, modeWorkspace, nil, modeOlder)
+	for i := range modes {
+		modes[i] = r.withAttribute(modes[i])
+		if substance, ok := r.m.substances[modes[i].SubstanceID]; ok {
+			modes[i].Substance = &substance
+		}
+	}
+	return modes, nil
+}
+
+func (r memoryModes) ListBySubstance(ctx context.Context, substanceID string) ([]entities.Mode, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	modes := collect(ctx, r.m.modes, modeWorkspace, func(m entities.Mode) bool { return m.SubstanceID == substanceID }, modeOlder)
+	for i := range modes {
+		modes[i] = r.withAttribute(modes[i])
+	}
+	return modes, nil
+}
+
+func (r memoryModes) Find(ctx context.Context, ids []string) ([]entities.Mode, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	modes := collect(ctx, r.m.modes, modeWorkspace, func(m entities.Mode) bool { return wanted[m.ID] }, modeOlder)
+	for i := range modes {
+		modes[i] = r.withAttribute(modes[i])
+	}
+	return modes, nil
+}
+
+func (r memoryModes) Create(ctx context.Context, mode *entities.Mode) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.modes, mode.ID); err != nil {
+		return err
+	}
+	mode.WorkspaceID = workspace.FromContext(ctx)
+	stored := *mode
+	stored.Substance, stored.Attribute, stored.Bearers = nil, nil, nil
+	r.m.modes[mode.ID] = stored
+	return nil
+}
+
+type memoryPotentialities struct{ m *memory }
+
+func potentialityWorkspace(p entities.Potentiality) string { return p.WorkspaceID }
+func potentialityOlder(a, b entities.Potentiality) bool {
+	return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+}
+
+// withSubstance fills in a potentiality's substance
+func (r memoryPotentialities) withSubstance(potentiality entities.Potentiality) entities.Potentiality {
+	if substance, ok := r.m.substances[potentiality.SubstanceID]; ok {
+		potentiality.Substance = &substance
+	}
+	return potentiality
+}
+
+func (r memoryPotentialities) List(ctx context.Context) ([]entities.Potentiality, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	potentialities := collect(ctx, r.m.potentialities, potentialityWorkspace, nil, potentialityOlder)
+	for i := range potentialities {
+		potentialities[i] = r.withSubstance(potentialities[i])
+	}
+	return potentialities, nil
+}
+
+func (r memoryPotentialities) Get(ctx context.Context, id string) (*entities.Potentiality, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	potentiality, ok := r.m.potentialities[id]
+	if !ok || potentiality.WorkspaceID != workspace.FromContext(ctx) {
+		return nil, ErrNotFound
+	}
+	potentiality = r.withSubstance(potentiality)
+	return &potentiality, nil
+}
+
+func (r memoryPotentialities) Find(ctx context.Context, ids []string) ([]entities.Potentiality, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	return collect(ctx, r.m.potentialities, potentialityWorkspace, func(p entities.Potentiality) bool { return wanted[p.ID] }, potentialityOlder), nil
+}
+
+func (r memoryPotentialities) ListBySubstance(ctx context.Context, substanceID string) ([]entities.Potentiality, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return collect(ctx, r.m.potentialities, potentialityWorkspace, func(p entities.Potentiality) bool { return p.SubstanceID == substanceID }, potentialityOlder), nil
+}
+
+func (r memoryPotentialities) Create(ctx context.Context, potentiality *entities.Potentiality) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.potentialities, potentiality.ID); err != nil {
+		return err
+	}
+	potentiality.WorkspaceID = workspace.FromContext(ctx)
+	stored := *potentiality
+	stored.Substance = nil
+	r.m.potentialities[potentiality.ID] = stored
+	return nil
+}
+
+type memoryActualities struct{ m *memory }
+
+func actualityWorkspace(a entities.Actuality) string { return a.WorkspaceID }
+func actualityOlder(a, b entities.Actuality) bool {
+	return older(a.ActualizedAt, b.ActualizedAt, a.ID, b.ID)
+}
+
+func (r memoryActualities) Find(ctx context.Context, ids []string) ([]entities.Actuality, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	return collect(ctx, r.m.actualities, actualityWorkspace, func(a entities.Actuality) bool { return wanted[a.ID] }, actualityOlder), nil
+}
+
+func (r memoryActualities) ListBySubstance(ctx context.Context, substanceID string) ([]entities.Actuality, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	actualities := collect(ctx, r.m.actualities, actualityWorkspace, func(a entities.Actuality) bool { return a.SubstanceID == substanceID }, actualityOlder)
+	for i := range actualities {
+		if potentiality, ok := r.m.potentialities[actualities[i].PotentialityID]; ok {
+			actualities[i].Potentiality = &potentiality
+		}
+	}
+	return actualities, nil
+}
+
+func (r memoryActualities) Create(ctx context.Context, actuality *entities.Actuality) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.actualities, actuality.ID); err != nil {
+		return err
+	}
+	actuality.WorkspaceID = workspace.FromContext(ctx)
+	stored := *actuality
+	stored.Substance, stored.Potentiality = nil, nil
+	r.m.actualities[actuality.ID] = stored
+	return nil
+}
+
+type memoryCauses struct{ m *memory }
+
+func (r memoryCauses) List(ctx context.Context, filter CauseFilter) ([]entities.CausalRelation, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return collect(ctx, r.m.causes,
+		func(c entities.CausalRelation) string { return c.WorkspaceID },
+		func(c entities.CausalRelation) bool { return filter.Allows(&c) },
+		func(a, b entities.CausalRelation) bool { return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID) }), nil
+}
+
+func (r memoryCauses) Find(ctx context.Context, ids []string) ([]entities.CausalRelation, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	wanted := among(ids)
+	return collect(ctx, r.m.causes,
+		func(c entities.CausalRelation) string { return c.WorkspaceID },
+		func(c entities.CausalRelation) bool { return wanted[c.ID] },
+		func(a, b entities.CausalRelation) bool { return older(a.CreatedAt, b.CreatedAt, a.ID, b.ID) }), nil
+}
+
+func (r memoryCauses) Create(ctx context.Context, relation *entities.CausalRelation) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := taken(r.m.causes, relation.ID); err != nil {
+		return err
+	}
+	relation.WorkspaceID = workspace.FromContext(ctx)
+	r.m.causes[relation.ID] = *relation
+	return nil
+}
+
+func (r memoryCauses) Detach(ctx context.Context, ids []string) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	detached := among(ids)
+	var count int64
+	for id, c := range r.m.causes {
+		if c.WorkspaceID == workspace.FromContext(ctx) && (detached[c.FromEntity] || detached[c.ToEntity]) {
+			delete(r.m.causes, id)
+			count++
+		}
+	}
+	return count, nil
+}
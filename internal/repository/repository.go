@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when no record in the workspace has the ID asked for
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a record's ID, or a name that must be unique in its workspace, is taken
+	ErrDuplicate = errors.New("record already exists")
+	// ErrNoDatabase is returned for what lies beyond the repositories of a store that has no database
+	ErrNoDatabase = errors.New("store has no database")
+)
+
+// Every method confines itself to the workspace its context selects, as the
+// workspace plugin confines GORM statements: lists and lookups see only that
+// workspace's records and created records are put in it.
+
+// Substances stores substances
+type Substances interface {
+	// List returns every substance, oldest first, with its attributes, modes, potentialities and actualities
+	List(ctx context.Context) ([]entities.Substance, error)
+	// Get returns a substance with its attributes, modes, potentialities and actualities
+	Get(ctx context.Context, id string) (*entities.Substance, error)
+	// Find returns the substances among ids, without associations; IDs of no substance are skipped
+	Find(ctx context.Context, ids []string) ([]entities.Substance, error)
+	Create(ctx context.Context, substance *entities.Substance) error
+	// Update saves the substance's name, kind and essence, returning ErrNotFound if it is not stored
+	Update(ctx context.Context, substance *entities.Substance) error
+	// Lock holds the substance's row until the transaction the store takes part
+	// in ends, so that checks made on its modes still hold when writing; it
+	// returns ErrNotFound if the substance is not stored
+	Lock(ctx context.Context, id string) error
+}
+
+// Kinds stores kinds
+type Kinds interface {
+	// List returns every kind, oldest first
+	List(ctx context.Context) ([]entities.Kind, error)
+	Find(ctx context.Context, ids []string) ([]entities.Kind, error)
+	// Create returns ErrDuplicate if the name is taken
+	Create(ctx context.Context, kind *entities.Kind) error
+}
+
+// Attributes stores attributes
+type Attributes interface {
+	// List returns every attribute, oldest first
+	List(ctx context.Context) ([]entities.Attribute, error)
+	Get(ctx context.Context, id string) (*entities.Attribute, error)
+	Find(ctx context.Context, ids []string) ([]entities.Attribute, error)
+	// Create returns ErrDuplicate if the name is taken
+	Create(ctx context.Context, attribute *entities.Attribute) error
+}
+
+// Modes stores modes, asserted and inferred
+type Modes interface {
+	// List returns every mode, oldest first, with its substance and attribute
+	List(ctx context.Context) ([]entities.Mode, error)
+	// ListBySubstance returns the modes of a substance, oldest first, with their attributes
+	ListBySubstance(ctx context.Context, substanceID string) ([]entities.Mode, error)
+	// Find returns the modes among ids with their attributes
+	Find(ctx context.Context, ids []string) ([]entities.Mode, error)
+	Create(ctx context.Context, mode *entities.Mode) error
+}
+
+// Potentialities stores potentialities
+type Potentialities interface {
+	// List returns every potentiality, oldest first, with its substance
+	List(ctx context.Context) ([]entities.Potentiality, error)
+	// Get returns a potentiality with its substance
+	Get(ctx context.Context, id string) (*entities.Potentiality, error)
+	Find(ctx context.Context, ids []string) ([]entities.Potentiality, error)
+	// ListBySubstance returns the potentialities of a substance, oldest first
+	ListBySubstance(ctx context.Context, substanceID string) ([]entities.Potentiality, error)
+	Create(ctx context.Context, potentiality *entities.Potentiality) error
+}
+
+// Actualities stores actualities
+type Actualities interface {
+	Find(ctx context.Context, ids []string) ([]entities.Actuality, error)
+	// ListBySubstance returns the actualities of a substance, oldest first, with their potentialities
+	ListBySubstance(ctx context.Context, substanceID string) ([]entities.Actuality, error)
+	Create(ctx context.Context, actuality *entities.Actuality) error
+}
+
+// Causes stores causal relations
+type Causes interface {
+	// List returns the causal relations the filter allows, oldest first
+	List(ctx context.Context, filter CauseFilter) ([]entities.CausalRelation, error)
+	Find(ctx context.Context, ids []string) ([]entities.CausalRelation, error)
+	Create(ctx context.Context, relation *entities.CausalRelation) error
+	// Detach deletes the relations with any of ids as cause or effect, returning how many there were
+	Detach(ctx context.Context, ids []string) (int64, error)
+}
+
+// Store holds a repository for each aggregate
+type Store struct {
+	Substances     Substances
+	Kinds          Kinds
+	Attributes     Attributes
+	Modes          Modes
+	Potentialities Potentialities
+	Actualities    Actualities
+	Causes         Causes
+
+	db       *gorm.DB
+	transact func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+// DB returns the database the repositories are over, which serves what lies
+// beyond them: relations, parts, inference, merges and the like. A store in
+// memory has none.
+func (s *Store) DB() *gorm.DB {
+	return s.db
+}
+
+// Transaction runs fn with a store whose reads and writes, and those made
+// through its DB, form one unit of work: committed if fn returns nil and
+// undone otherwise.
+func (s *Store) Transaction(ctx context.Context, fn func(tx *Store) error) error {
+	return s.transact(ctx, fn)
+}
+
+// CauseFilter restricts which causal relations a query considers
+type CauseFilter struct {
+	EntityID    string     `json:"entity_id,omitempty"`   // relations touching this entity on either side
+	CauseTypes  []string   `json:"cause_types,omitempty"` // empty means all cause types
+	MinStrength float64    `json:"min_strength,omitempty"`
+	ValidAt     *time.Time `json:"valid_at,omitempty"` // only relations whose validity period includes this time
+
+	// FromEntities and ToEntities, when not empty, keep only relations whose
+	// effect, or cause, is one of them; traversals expand a level at a time with them
+	FromEntities []string `json:"-"`
+	ToEntities   []string `json:"-"`
+}
+
+// Predicate returns an SQL predicate selecting the relations the filter allows,
+// EntityID aside. alias prefixes column names, e.g. "cr." inside joins.
+func (f CauseFilter) Predicate(alias string) (string, []interface{}) {
+	clauses := []string{"1 = 1"}
+	var args []interface{}
+
+	if len(f.CauseTypes) > 0 {
+		clauses = append(clauses, alias+"cause_type IN ?")
+		args = append(args, f.CauseTypes)
+	}
+	if f.MinStrength > 0 {
+		clauses = append(clauses, alias+"strength >= ?")
+		args = append(args, f.MinStrength)
+	}
+	if f.ValidAt != nil {
+		clauses = append(clauses,
+			"("+alias+"valid_from IS NULL OR "+alias+"valid_from <= ?)",
+			"("+alias+"valid_until IS NULL OR "+alias+"valid_until >= ?)")
+		args = append(args, *f.ValidAt, *f.ValidAt)
+	}
+	if len(f.FromEntities) > 0 {
+		clauses = append(clauses, alias+"from_entity IN ?")
+		args = append(args, f.FromEntities)
+	}
+	if len(f.ToEntities) > 0 {
+		clauses = append(clauses, alias+"to_entity IN ?")
+		args = append(args, f.ToEntities)
+	}
+
+	return "(" + strings.Join(clauses, " AND ") + ")", args
+}
+
+// Allows reports whether the filter lets the relation through, as Predicate
+// and EntityID together would
+func (f CauseFilter) Allows(relation *entities.CausalRelation) bool {
+	if f.EntityID != "" && relation.FromEntity != f.EntityID && relation.ToEntity != f.EntityID {
+		return false
+	}
+	if len(f.CauseTypes) > 0 {
+		found := false
+		for _, causeType := range f.CauseTypes {
+			found = found || causeType == relation.CauseType
+		}
+		if !found {
+			return false
+		}
+	}
+	if relation.Strength < f.MinStrength {
+		return false
+	}
+	if len(f.FromEntities) > 0 && !slices.Contains(f.FromEntities, relation.FromEntity) {
+		return false
+	}
+	if len(f.ToEntities) > 0 && !slices.Contains(f.ToEntities, relation.ToEntity) {
+		return false
+	}
+	return f.ValidAt == nil || relation.HoldsAt(*f.ValidAt)
+}
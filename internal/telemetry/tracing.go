@@ -0,0 +1,101 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span exporters SetupTracing knows
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// TracingConfig says where spans go
+type TracingConfig struct {
+	Exporter    string    // ExporterNone (or empty), ExporterStdout or ExporterOTLP
+	Endpoint    string    // OTLP/HTTP collector URL; OTEL_EXPORTER_OTLP_ENDPOINT and friends if empty
+	Output      io.Writer // where the stdout exporter writes; os.Stdout if nil
+	ServiceName string    // service.name of the spans, unless OTEL_SERVICE_NAME is set
+}
+
+// SetupTracing installs a global tracer provider exporting to the configured
+// exporter and W3C trace context propagation. The returned function flushes
+// the spans not yet exported and stops the provider; it is a no-op when
+// spans go nowhere.
+func SetupTracing(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		output := config.Output
+		if output == nil {
+			output = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(output))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			endpoint, parseErr := url.Parse(config.Endpoint)
+			if parseErr != nil || endpoint.Host == "" {
+				return nil, fmt.Errorf("invalid OTLP endpoint %q", config.Endpoint)
+			}
+			// a bare collector address gets the traces path, as OTEL_EXPORTER_OTLP_ENDPOINT does
+			if endpoint.Path == "" || endpoint.Path == "/" {
+				endpoint.Path = "/v1/traces"
+			}
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint.String()))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q: must be %s, %s or %s", config.Exporter, ExporterNone, ExporterStdout, ExporterOTLP)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s span exporter: %w", config.Exporter, err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "oaas"
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// EndSpan ends a span, marking it failed when *err holds an error. Deferred
+// with a named error result, it records how the function returned.
+func EndSpan(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,110 @@
+package workspace
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type contextKey struct{}
+
+// WithID returns a context selecting the workspace
+func WithID(ctx context.Context, workspaceID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, workspaceID)
+}
+
+// FromContext returns the workspace the context selects, the default workspace if none
+func FromContext(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+			return id
+		}
+	}
+	return entities.DefaultWorkspace
+}
+
+// Scope returns a session of db confined to the workspace
+func Scope(db *gorm.DB, workspaceID string) *gorm.DB {
+	return db.WithContext(WithID(db.Statement.Context, workspaceID))
+}
+
+// ID returns the workspace a session of db is confined to
+func ID(db *gorm.DB) string {
+	return FromContext(db.Statement.Context)
+}
+
+// Plugin confines every statement on a workspace-scoped model to the workspace
+// selected by the statement's context: queries, updates and deletes only see
+// its rows, and created rows are put in it whatever their WorkspaceID says.
+// Raw SQL is not rewritten; it must filter on workspace_id itself.
+type Plugin struct{}
+
+// Name implements gorm.Plugin
+func (Plugin) Name() string {
+	return "workspace"
+}
+
+// Initialize implements gorm.Plugin
+func (Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("workspace:assign", assign); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("workspace:filter", filter); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("workspace:filter", filter); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("workspace:assign", assign); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("workspace:filter", filter); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("workspace:filter", filter)
+}
+
+// assign puts the statement's rows in its workspace
+func assign(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	field := stmt.Schema.LookUpField("WorkspaceID")
+	if field == nil {
+		return
+	}
+	id := FromContext(stmt.Context)
+	set := func(value reflect.Value) {
+		if err := field.Set(stmt.Context, value, id); err != nil {
+			db.AddError(err)
+		}
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			set(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		set(stmt.ReflectValue)
+	}
+}
+
+// filter restricts the statement to rows of its workspace
+func filter(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SQL.Len() > 0 {
+		return
+	}
+	field := stmt.Schema.LookUpField("WorkspaceID")
+	if field == nil {
+		return
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: stmt.Table, Name: field.DBName}, Value: FromContext(stmt.Context)},
+	}})
+}
@@ -0,0 +1,222 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWorkspaceNotFound is returned when no workspace has the given ID or name
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrInvalidWorkspace is returned for a malformed workspace name or a copy within one workspace
+	ErrInvalidWorkspace = errors.New("invalid workspace")
+	// ErrDuplicateWorkspace is returned when another workspace has the name
+	ErrDuplicateWorkspace = errors.New("workspace name already taken")
+	// ErrWorkspaceNotEmpty is returned when deleting a workspace that still holds entities,
+	// or the default workspace
+	ErrWorkspaceNotEmpty = errors.New("workspace not empty")
+	// ErrNameConflict is returned when a copied kind or attribute's name is taken in the target
+	ErrNameConflict = errors.New("name already taken in target workspace")
+)
+
+// validName matches workspace names, which appear in URLs
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Manager creates, finds and deletes workspaces and copies definitions between them
+type Manager struct {
+	db *gorm.DB
+}
+
+// NewManager creates a new workspace manager
+func NewManager(db *gorm.DB) *Manager {
+	return &Manager{db: db}
+}
+
+// CopyResult lists the copies made in the target workspace
+type CopyResult struct {
+	Kinds      []entities.Kind      `json:"kinds"`
+	Attributes []entities.Attribute `json:"attributes"`
+}
+
+// Create creates a workspace
+func (m *Manager) Create(name, description string) (*entities.Workspace, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be lowercase letters, digits, '-' or '_'", ErrInvalidWorkspace)
+	}
+	var taken int64
+	if err := m.db.Model(&entities.Workspace{}).Where("name = ? OR id = ?", name, name).Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+	if taken > 0 || name == entities.DefaultWorkspace {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateWorkspace, name)
+	}
+	workspace := entities.NewWorkspace(name, description)
+	if err := m.db.Create(workspace).Error; err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return workspace, nil
+}
+
+// List returns every workspace, by name
+func (m *Manager) List() ([]entities.Workspace, error) {
+	var workspaces []entities.Workspace
+	if err := m.db.Order("name").Find(&workspaces).Error; err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+	for _, workspace := range workspaces {
+		if workspace.ID == entities.DefaultWorkspace {
+			return workspaces, nil
+		}
+	}
+	workspaces = append(workspaces, defaultWorkspace())
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Name < workspaces[j].Name })
+	return workspaces, nil
+}
+
+// Get returns the workspace with the given ID or name. The default workspace
+// always exists, even before its row is created.
+func (m *Manager) Get(ref string) (*entities.Workspace, error) {
+	var workspace entities.Workspace
+	if err := m.db.Where("id = ? OR name = ?", ref, ref).Order("created_at").Limit(1).Find(&workspace).Error; err != nil {
+		return nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+	if workspace.ID != "" {
+		return &workspace, nil
+	}
+	if ref == entities.DefaultWorkspace {
+		workspace = defaultWorkspace()
+		return &workspace, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrWorkspaceNotFound, ref)
+}
+
+func defaultWorkspace() entities.Workspace {
+	return entities.Workspace{ID: entities.DefaultWorkspace, Name: entities.DefaultWorkspace}
+}
+
+// Delete deletes an empty workspace other than the default one
+func (m *Manager) Delete(ref string) error {
+	workspace, err := m.Get(ref)
+	if err != nil {
+		return err
+	}
+	if workspace.ID == entities.DefaultWorkspace {
+		return fmt.Errorf("%w: the default workspace cannot be deleted", ErrWorkspaceNotEmpty)
+	}
+	scoped := Scope(m.db, workspace.ID)
+	for _, model := range []interface{}{&entities.Substance{}, &entities.Kind{}, &entities.Attribute{}, &entities.Rule{}, &entities.SubstanceTemplate{}} {
+		var count int64
+		if err := scoped.Model(model).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count entities: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", ErrWorkspaceNotEmpty, workspace.Name)
+		}
+	}
+	if err := m.db.Delete(&entities.Workspace{}, "id = ?", workspace.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	return nil
+}
+
+// Copy copies kinds and attributes from one workspace into another under new
+// IDs. Names must be free in the target; nothing is copied if one is taken.
+// Callers should run it in a transaction.
+func (m *Manager) Copy(fromRef, toRef string, kindIDs, attributeIDs []string) (*CopyResult, error) {
+	from, err := m.Get(fromRef)
+	if err != nil {
+		return nil, err
+	}
+	to, err := m.Get(toRef)
+	if err != nil {
+		return nil, err
+	}
+	if from.ID == to.ID {
+		return nil, fmt.Errorf("%w: source and target are the same workspace", ErrInvalidWorkspace)
+	}
+	source, target := Scope(m.db, from.ID), Scope(m.db, to.ID)
+
+	var kinds []entities.Kind
+	var attributes []entities.Attribute
+	if err := load(source, kindIDs, &kinds); err != nil {
+		return nil, err
+	}
+	if err := load(source, attributeIDs, &attributes); err != nil {
+		return nil, err
+	}
+	if len(kinds) != len(unique(kindIDs)) || len(attributes) != len(unique(attributeIDs)) {
+		return nil, fmt.Errorf("%w: not every kind and attribute is in workspace %s", ErrWorkspaceNotFound, from.Name)
+	}
+
+	var conflicts []string
+	for _, kind := range kinds {
+		if taken, err := exists(target, &entities.Kind{}, kind.Name); err != nil {
+			return nil, err
+		} else if taken {
+			conflicts = append(conflicts, "kind '"+kind.Name+"'")
+		}
+	}
+	for _, attribute := range attributes {
+		if taken, err := exists(target, &entities.Attribute{}, attribute.Name); err != nil {
+			return nil, err
+		} else if taken {
+			conflicts = append(conflicts, "attribute '"+attribute.Name+"'")
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrNameConflict, conflicts)
+	}
+
+	result := &CopyResult{Kinds: []entities.Kind{}, Attributes: []entities.Attribute{}}
+	for _, kind := range kinds {
+		copied := kind
+		copied.ID, copied.WorkspaceID, copied.CreatedAt, copied.Substances = uuid.New().String(), to.ID, time.Now(), nil
+		if err := target.Create(&copied).Error; err != nil {
+			return nil, fmt.Errorf("failed to copy kind: %w", err)
+		}
+		result.Kinds = append(result.Kinds, copied)
+	}
+	for _, attribute := range attributes {
+		copied := attribute
+		copied.ID, copied.WorkspaceID, copied.CreatedAt, copied.Substances, copied.Modes = uuid.New().String(), to.ID, time.Now(), nil, nil
+		if err := target.Create(&copied).Error; err != nil {
+			return nil, fmt.Errorf("failed to copy attribute: %w", err)
+		}
+		result.Attributes = append(result.Attributes, copied)
+	}
+	return result, nil
+}
+
+// load finds the entities with the given IDs, by name
+func load(db *gorm.DB, ids []string, dest interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := db.Where("id IN ?", ids).Order("name").Find(dest).Error; err != nil {
+		return fmt.Errorf("failed to get entities: %w", err)
+	}
+	return nil
+}
+
+func exists(db *gorm.DB, model interface{}, name string) (bool, error) {
+	var count int64
+	if err := db.Model(model).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to get entities: %w", err)
+	}
+	return count > 0, nil
+}
+
+func unique(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
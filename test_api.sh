@@ -6,6 +6,9 @@ echo "🧪 Testing OaaS API..."
 # Base URL
 BASE_URL="http://localhost:8080"
 
+# API key sent with every request; issue one with "oaas keys create -name test"
+API_KEY="${OAAS_API_KEY:-}"
+
 # Colors for output
 RED='\033[0;31m'
 GREEN='\033[0;32m'
@@ -24,9 +27,10 @@ test_endpoint() {
     if [ -n "$data" ]; then
         response=$(curl -s -X $method "$BASE_URL$endpoint" \
             -H "Content-Type: application/json" \
+            -H "X-API-Key: $API_KEY" \
             -d "$data")
     else
-        response=$(curl -s -X $method "$BASE_URL$endpoint")
+        response=$(curl -s -X $method "$BASE_URL$endpoint" -H "X-API-Key: $API_KEY")
     fi
     
     if [ $? -eq 0 ]; then
@@ -65,7 +69,9 @@ echo ""
 # Test 7: Add a causal relation
 test_endpoint "POST" "/api/v1/causes" '{
     "from_entity": "test-substance",
+    "from_type": "external",
     "to_entity": "wood_water_carbon",
+    "to_type": "external",
     "cause_type": "material"
 }' "Add Material Cause"
 
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func centralityOf(t *testing.T, scores []causality.Centrality, id string) causality.Centrality {
+	for _, score := range scores {
+		if score.EntityID == id {
+			return score
+		}
+	}
+	t.Fatalf("no centrality for %s", id)
+	return causality.Centrality{}
+}
+
+func TestCausalAnalytics_Centrality(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	scores, err := engine.Centrality(causality.CausalFilter{}, causality.SortByBetweenness)
+	require.NoError(t, err)
+	require.Len(t, scores, 6)
+
+	// Every cause of the oak passes through the sapling
+	assert.Equal(t, "sapling", scores[0].EntityID)
+	sapling := scores[0]
+	assert.Equal(t, 2, sapling.InDegree)
+	assert.Equal(t, 1, sapling.OutDegree)
+	assert.Equal(t, 3, sapling.Degree)
+	// acorn, soil and water each reach oak and shade through it: 6 of 5*4 ordered pairs
+	assert.InDelta(t, 6.0/20.0, sapling.Betweenness, 1e-9)
+	assert.Zero(t, centralityOf(t, scores, "water").Betweenness)
+
+	byRank, err := engine.Centrality(causality.CausalFilter{}, "")
+	require.NoError(t, err)
+	total := 0.0
+	for _, score := range byRank {
+		total += score.PageRank
+	}
+	assert.InDelta(t, 1.0, total, 1e-6)
+	// Rank accumulates downstream, at the end of the chain
+	assert.Equal(t, "shade", byRank[0].EntityID)
+	assert.Greater(t, centralityOf(t, byRank, "oak").PageRank, centralityOf(t, byRank, "acorn").PageRank)
+
+	efficient, err := engine.Centrality(causality.CausalFilter{CauseTypes: []string{"efficient"}}, causality.SortByDegree)
+	require.NoError(t, err)
+	assert.Len(t, efficient, 4)
+
+	_, err = engine.Centrality(causality.CausalFilter{}, "popularity")
+	assert.Error(t, err)
+}
+
+func TestCausalAnalytics_Components(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	// A separate island, and a loop closing acorn -> oak -> sapling -> acorn
+	_, err := relateConcepts(engine, "tide", "moon", "efficient")
+	require.NoError(t, err)
+	_, err = relateConcepts(engine, "acorn", "oak", "efficient")
+	require.NoError(t, err)
+
+	weak, err := engine.WeakComponents(causality.CausalFilter{})
+	require.NoError(t, err)
+	require.Len(t, weak, 2)
+	assert.Equal(t, []string{"acorn", "oak", "sapling", "shade", "soil", "water"}, weak[0].Entities)
+	assert.Equal(t, 6, weak[0].Relations)
+	assert.Equal(t, []string{"moon", "tide"}, weak[1].Entities)
+
+	strong, err := engine.StrongComponents(causality.CausalFilter{})
+	require.NoError(t, err)
+	require.Len(t, strong, 6)
+	assert.Equal(t, []string{"acorn", "oak", "sapling"}, strong[0].Entities)
+	assert.Equal(t, 3, strong[0].Relations)
+	for _, component := range strong[1:] {
+		assert.Len(t, component.Entities, 1)
+	}
+
+	material, err := engine.WeakComponents(causality.CausalFilter{CauseTypes: []string{"material"}})
+	require.NoError(t, err)
+	require.Len(t, material, 1)
+	assert.Equal(t, []string{"sapling", "soil", "water"}, material[0].Entities)
+}
+
+func TestCausalAnalytics_RootCausesAndTerminalEnds(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	// The oak grows for the sake of reproduction, which is for the sake of the species' persistence
+	_, err := relateConcepts(engine, "oak", "reproduction", "final")
+	require.NoError(t, err)
+	_, err = relateConcepts(engine, "reproduction", "persistence", "final")
+	require.NoError(t, err)
+
+	roots, err := engine.RootCauses(causality.CausalFilter{})
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	assert.Equal(t, "acorn", roots[0].ID)
+	assert.Equal(t, "external", roots[0].Type)
+
+	material, err := engine.RootCauses(causality.CausalFilter{CauseTypes: []string{"material"}})
+	require.NoError(t, err)
+	require.Len(t, material, 1)
+	assert.Equal(t, "water", material[0].ID)
+
+	ends, err := engine.TerminalEnds(causality.CausalFilter{})
+	require.NoError(t, err)
+	require.Len(t, ends, 1)
+	assert.Equal(t, "persistence", ends[0].ID)
+}
+
+func TestCausalAnalyticsAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	buildCausalChain(t, causality.NewEngine(db))
+
+	req, _ := http.NewRequest("GET", "/api/v1/causes/centrality?sort=degree&limit=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var centrality struct {
+		Centrality []causality.Centrality `json:"centrality"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &centrality))
+	require.Len(t, centrality.Centrality, 2)
+	assert.Equal(t, "sapling", centrality.Centrality[0].EntityID)
+
+	req, _ = http.NewRequest("GET", "/api/v1/causes/components?connectivity=strong", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var components struct {
+		Components []causality.CausalComponent `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &components))
+	assert.Len(t, components.Components, 6)
+
+	req, _ = http.NewRequest("GET", "/api/v1/causes/roots?cause_type=material", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var roots struct {
+		RootCauses []causality.EntityRef `json:"root_causes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &roots))
+	require.Len(t, roots.RootCauses, 1)
+	assert.Equal(t, "water", roots.RootCauses[0].ID)
+
+	for _, url := range []string{
+		"/api/v1/causes/centrality?sort=popularity",
+		"/api/v1/causes/components?connectivity=partial",
+		"/api/v1/causes/terminals?cause_type=invalid",
+	} {
+		req, _ = http.NewRequest("GET", url, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
@@ -8,7 +8,11 @@ import (
 	"testing"
 
 	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/auth"
 	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/metrics"
+	"github.com/apodicticscott/oaas/internal/telemetry"
+	"github.com/apodicticscott/oaas/internal/workspace"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,9 +21,19 @@ import (
 )
 
 func setupTestAPI(t *testing.T) (*gin.Engine, *gorm.DB) {
+	return setupTestAPIWith(t, nil)
+}
+
+// setupTestAPIWith sets up the test API, letting configure change the handler
+// once it has a database
+func setupTestAPIWith(t *testing.T, configure func(*api.Handler)) (*gin.Engine, *gorm.DB) {
 	// Setup in-memory database
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
+	require.NoError(t, db.Use(workspace.Plugin{}))
+	require.NoError(t, db.Use(auth.AuditPlugin{}))
+	require.NoError(t, db.Use(metrics.GormPlugin{}))
+	require.NoError(t, db.Use(telemetry.GormPlugin{}))
 
 	// Auto-migrate all entities
 	err = db.AutoMigrate(
@@ -30,47 +44,146 @@ func setupTestAPI(t *testing.T) (*gin.Engine, *gorm.DB) {
 		&entities.CausalRelation{},
 		&entities.Potentiality{},
 		&entities.Actuality{},
+		&entities.Rule{},
+		&entities.Parthood{},
+		&entities.Dependence{},
+		&entities.ModeBearer{},
+		&entities.SubstanceRedirect{},
+		&entities.SubstanceMerge{},
+		&entities.SubstanceTemplate{},
+		&entities.TemplateMode{},
+		&entities.TemplatePotentiality{},
+		&entities.Workspace{},
+		&entities.APIKey{},
+		&entities.Role{},
+		&entities.AuditRecord{},
+		&entities.QuotaUsage{},
 	)
 	require.NoError(t, err)
 
 	// Setup API handler
 	handler := api.NewHandler(db)
+	if configure != nil {
+		configure(handler)
+	}
 
 	// Setup Gin router
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(handler.Trace, handler.Instrument)
 
-	// Health check
+	// Health check and metrics
 	router.GET("/health", handler.HealthCheck)
+	router.GET("/livez", handler.Livez)
+	router.GET("/readyz", handler.Readyz)
+	router.GET("/version", handler.GetVersion)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Authentication and API keys
+	router.GET("/api/v1/me", handler.ThrottleAddress, handler.Authenticate, handler.Throttle, handler.GetPrincipal)
+	admin := router.Group("/api/v1/admin", handler.ThrottleAddress, handler.Authenticate, handler.Throttle, handler.RequireAdmin)
+	{
+		admin.GET("/keys", handler.GetAPIKeys)
+		admin.POST("/keys", handler.CreateAPIKey)
+		admin.DELETE("/keys/:id", handler.RevokeAPIKey)
+		admin.GET("/roles", handler.GetRoles)
+		admin.POST("/roles", handler.CreateRole)
+		admin.DELETE("/roles/:name", handler.DeleteRole)
+	}
 
-	// API routes
-	api := router.Group("/api/v1")
+	// Workspaces
+	workspaces := router.Group("/api/v1/workspaces", handler.ThrottleAddress, handler.Authenticate, handler.Throttle)
 	{
+		workspaces.GET("", handler.GetWorkspaces)
+		workspaces.POST("", handler.CreateWorkspace)
+		workspaces.GET("/:workspace", handler.GetWorkspace)
+		workspaces.DELETE("/:workspace", handler.DeleteWorkspace)
+	}
+
+	// API routes, under /api/v1 and /api/v1/workspaces/:workspace
+	for _, api := range []*gin.RouterGroup{router.Group("/api/v1"), router.Group("/api/v1/workspaces/:workspace")} {
+		api.Use(handler.ThrottleAddress, handler.Authenticate, handler.Throttle, handler.SelectWorkspace)
+		api.POST("/copy", handler.CopyToWorkspace)
+		api.GET("/audit", handler.RequireAdmin, handler.GetAuditRecords)
+
 		// Substances
-		api.GET("/substances", handler.GetSubstances)
+		api.GET("/substances", handler.ThrottleExpensive, handler.GetSubstances)
 		api.GET("/substances/:id", handler.GetSubstance)
 		api.POST("/substances", handler.CreateSubstance)
 		api.PUT("/substances/:id", handler.UpdateSubstance)
 		api.DELETE("/substances/:id", handler.DeleteSubstance)
+		api.GET("/substances/:id/classification", handler.GetSubstanceClassification)
+		api.GET("/substances/:id/parts", handler.GetParts)
+		api.POST("/substances/:id/parts", handler.AddPart)
+		api.GET("/substances/:id/parts/aggregate", handler.AggregateParts)
+		api.DELETE("/substances/:id/parts/:part_id", handler.RemovePart)
+		api.GET("/substances/:id/wholes", handler.GetWholes)
+		api.POST("/substances/:id/merge", handler.MergeSubstance)
+		api.GET("/substances/:id/merges", handler.GetSubstanceMerges)
+		api.POST("/substances/:id/clone", handler.CloneSubstance)
+
+		// Substance templates
+		api.GET("/templates", handler.GetTemplates)
+		api.POST("/templates", handler.CreateTemplate)
+		api.GET("/templates/:id", handler.GetTemplate)
+		api.DELETE("/templates/:id", handler.DeleteTemplate)
 
 		// Kinds
 		api.GET("/kinds", handler.GetKinds)
 		api.POST("/kinds", handler.CreateKind)
+		api.DELETE("/kinds/:id", handler.DeleteKind)
+		api.GET("/kinds/misclassified", handler.ThrottleExpensive, handler.GetMisclassifiedSubstances)
+		api.PUT("/kinds/:id/definition", handler.UpdateKindDefinition)
 
 		// Attributes
 		api.GET("/attributes", handler.GetAttributes)
 		api.POST("/attributes", handler.CreateAttribute)
+		api.PUT("/attributes/:id/cardinality", handler.UpdateAttributeCardinality)
 
 		// Modes
-		api.GET("/modes", handler.GetModes)
+		api.GET("/modes", handler.ThrottleExpensive, handler.GetModes)
 		api.POST("/modes", handler.CreateMode)
+		api.DELETE("/modes/:id", handler.DeleteMode)
+		api.GET("/modes/conflicts", handler.GetModeConflicts)
+		api.GET("/relations", handler.GetRelations)
+		api.POST("/relations", handler.CreateRelation)
 
 		// Causality
 		api.GET("/substances/:id/causes", handler.GetCauses)
+		api.GET("/causes", handler.GetCausalRelations)
 		api.POST("/causes", handler.AddCause)
+		api.GET("/causes/path", handler.GetCausalPath)
+		api.GET("/causes/cycles", handler.ThrottleExpensive, handler.GetCausalCycles)
+		api.GET("/causes/centrality", handler.ThrottleExpensive, handler.GetCausalCentrality)
+		api.GET("/causes/components", handler.ThrottleExpensive, handler.GetCausalComponents)
+		api.GET("/causes/roots", handler.GetRootCauses)
+		api.GET("/causes/terminals", handler.GetTerminalEnds)
+		api.GET("/entities/:id/ancestors", handler.GetAncestors)
+		api.GET("/entities/:id/descendants", handler.GetDescendants)
+
+		// Export
+		api.GET("/export/causal-graph", handler.ThrottleExpensive, handler.ExportCausalGraph)
+
+		// Inference Rules
+		api.GET("/rules", handler.GetRules)
+		api.POST("/rules", handler.CreateRule)
+		api.POST("/rules/run", handler.ThrottleExpensive, handler.RunRules)
+		api.GET("/rules/:id", handler.GetRule)
+		api.DELETE("/rules/:id", handler.DeleteRule)
+
+		// Consistency
+		api.GET("/lint", handler.ThrottleExpensive, handler.GetLintReport)
+		api.POST("/lint/repair", handler.ThrottleExpensive, handler.RepairLintIssues)
+
+		// Dependence
+		api.GET("/dependences", handler.GetDependences)
+		api.POST("/dependences", handler.CreateDependence)
+		api.DELETE("/dependences/:id", handler.DeleteDependence)
+		api.GET("/entities/:id/dependents", handler.GetDependents)
+		api.GET("/entities/:id/dependencies", handler.GetDependencies)
 
 		// Potentialities
-		api.GET("/potentialities", handler.GetPotentialities)
+		api.GET("/potentialities", handler.ThrottleExpensive, handler.GetPotentialities)
 		api.POST("/potentialities", handler.CreatePotentiality)
 		api.POST("/potentialities/:id/actualize", handler.ActualizePotentiality)
 		api.GET("/potentialities/:id/conditions", handler.CheckConditions)
@@ -256,7 +369,9 @@ func TestAddCause(t *testing.T) {
 
 	causeData := map[string]string{
 		"from_entity": "substance-1",
+		"from_type":   "external",
 		"to_entity":   "wood_water",
+		"to_type":     "external",
 		"cause_type":  "material",
 	}
 
@@ -283,7 +398,9 @@ func TestAddCause_InvalidType(t *testing.T) {
 
 	causeData := map[string]string{
 		"from_entity": "substance-1",
+		"from_type":   "external",
 		"to_entity":   "wood_water",
+		"to_type":     "external",
 		"cause_type":  "invalid",
 	}
 
@@ -297,6 +414,96 @@ func TestAddCause_InvalidType(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
+func TestAddCause_WithDetails(t *testing.T) {
+	router, _ := setupTestAPI(t)
+
+	causeData := map[string]interface{}{
+		"from_entity": "drought",
+		"from_type":   "external",
+		"to_entity":   "el_nino",
+		"to_type":     "external",
+		"cause_type":  "efficient",
+		"strength":    0.7,
+		"evidence":    "Smith et al. 2001",
+		"notes":       "Correlated rainfall deficits",
+		"valid_from":  "1990-01-01T00:00:00Z",
+		"valid_until": "2000-01-01T00:00:00Z",
+	}
+
+	jsonData, _ := json.Marshal(causeData)
+	req, _ := http.NewRequest("POST", "/api/v1/causes", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response entities.CausalRelation
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, response.Strength)
+	assert.Equal(t, "Smith et al. 2001", response.Evidence)
+	require.NotNil(t, response.ValidUntil)
+
+	req, _ = http.NewRequest("GET", "/api/v1/causes?entity_id=drought&min_strength=0.5&valid_at=1995-06-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list struct {
+		CausalRelations []entities.CausalRelation `json:"causal_relations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.CausalRelations, 1)
+
+	req, _ = http.NewRequest("GET", "/api/v1/causes?valid_at=2010-01-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.CausalRelations)
+}
+
+func TestAddCause_InvalidStrength(t *testing.T) {
+	router, _ := setupTestAPI(t)
+
+	causeData := map[string]interface{}{
+		"from_entity": "drought",
+		"to_entity":   "el_nino",
+		"cause_type":  "efficient",
+		"strength":    2,
+	}
+
+	jsonData, _ := json.Marshal(causeData)
+	req, _ := http.NewRequest("POST", "/api/v1/causes", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAddCause_MissingTypedReference(t *testing.T) {
+	router, _ := setupTestAPI(t)
+
+	causeData := map[string]string{
+		"from_entity": "substance-1",
+		"to_entity":   "kind-1",
+		"to_type":     "kind",
+		"cause_type":  "formal",
+	}
+
+	jsonData, _ := json.Marshal(causeData)
+	req, _ := http.NewRequest("POST", "/api/v1/causes", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
 func TestCreatePotentiality(t *testing.T) {
 	router, db := setupTestAPI(t)
 
@@ -327,6 +534,16 @@ func TestCreatePotentiality(t *testing.T) {
 	assert.Equal(t, "Tree can grow leaves in spring", response.Description)
 	assert.Equal(t, substance.ID, response.SubstanceID)
 	assert.NotEmpty(t, response.ID)
+
+	// Conditions the engine could not evaluate are refused
+	potentialityData["conditions"] = `[{"type":"attribute","name":"season","operator":"like","value":"spring"}]`
+	jsonData, _ = json.Marshal(potentialityData)
+	req, _ = http.NewRequest("POST", "/api/v1/potentialities", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid conditions")
 }
 
 func TestGetSubstanceNotFound(t *testing.T) {
@@ -386,3 +603,23 @@ func TestDeleteSubstance(t *testing.T) {
 	db.Model(&entities.Substance{}).Where("id = ?", substance.ID).Count(&count)
 	assert.Equal(t, int64(0), count)
 }
+
+func TestDeleteSubstance_DetachesCausalRelations(t *testing.T) {
+	router, db := setupTestAPI(t)
+
+	substance := entities.NewSubstance("Tree-001", "Oak", "Essence")
+	require.NoError(t, db.Create(substance).Error)
+	db.Create(entities.NewCausalRelation("efficient", "acorn", substance.ID))
+	db.Create(entities.NewCausalRelation("material", substance.ID, "wood"))
+	db.Create(entities.NewCausalRelation("material", "chair", "wood"))
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/substances/"+substance.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var count int64
+	db.Model(&entities.CausalRelation{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
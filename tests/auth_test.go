@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signJWT signs claims with an ECDSA P-256, RSA or HMAC key
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writeKeyFiles writes a JWK set with an EC and a symmetric key, and a PEM RSA public key
+func writeKeyFiles(t *testing.T, ec *ecdsa.PrivateKey, secret []byte, rsaKey *rsa.PrivateKey) []string {
+	dir := t.TempDir()
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ec.X.FillBytes(make([]byte, 32))), "y": b64(ec.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "oct", "kid": "hs-1", "alg": "HS256", "k": b64(secret)},
+	}})
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	paths := []string{filepath.Join(dir, "jwks.json"), filepath.Join(dir, "rsa.pem")}
+	require.NoError(t, os.WriteFile(paths[0], jwks, 0o600))
+	require.NoError(t, os.WriteFile(paths[1], pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return paths
+}
+
+func TestAuth_Keys(t *testing.T) {
+	db := setupTestDB(t)
+	keys := auth.NewKeys(db)
+
+	key, secret, err := keys.Issue("ci", false, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, auth.IsAPIKey(secret))
+	assert.NotContains(t, key.Hash, secret)
+	verified, err := keys.Verify(secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, verified.ID)
+	assert.NotNil(t, verified.LastUsedAt)
+
+	for _, credential := range []string{"oaas_", "oaas_" + key.Prefix + "_wrong", secret[:len(secret)-1], "not-a-key"} {
+		_, err := keys.Verify(credential)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, credential)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	_, _, err = keys.Issue("old", false, nil, &past)
+	assert.ErrorIs(t, err, auth.ErrInvalidKey)
+	_, _, err = keys.Issue(" ", false, nil, nil)
+	assert.ErrorIs(t, err, auth.ErrInvalidKey)
+	soon := time.Now().Add(time.Hour)
+	expiring, expiringSecret, err := keys.Issue("temporary", false, nil, &soon)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(expiring).Update("expires_at", past).Error)
+	_, err = keys.Verify(expiringSecret)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	revoked, err := keys.Revoke(key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+	_, err = keys.Verify(secret)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = keys.Revoke("missing")
+	assert.ErrorIs(t, err, auth.ErrKeyNotFound)
+}
+
+func TestAuth_JWT(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	secret := bytes.Repeat([]byte("s"), 32)
+	verifier, err := auth.NewVerifier(writeKeyFiles(t, ecKey, secret, rsaKey), "https://issuer.example", "oaas")
+	require.NoError(t, err)
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"sub": "alice", "iss": "https://issuer.example", "aud": []string{"oaas", "other"}, "exp": time.Now().Add(time.Hour).Unix(), "admin": true}
+	}
+	claims, err := verifier.Verify(signJWT(t, "ES256", "ec-1", ecKey, valid()))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.True(t, claims.Admin)
+	assert.Equal(t, []string{"oaas", "other"}, claims.Audience)
+	scoped := valid()
+	scoped["roles"] = "reader editor"
+	claims, err = verifier.Verify(signJWT(t, "ES256", "ec-1", ecKey, scoped))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor", "reader"}, claims.Roles)
+	_, err = verifier.Verify(signJWT(t, "RS256", "", rsaKey, valid()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(signJWT(t, "HS256", "hs-1", secret, valid()))
+	assert.NoError(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	expired, noExpiry, wrongIssuer, wrongAudience, noSubject := valid(), valid(), valid(), valid(), valid()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	delete(noExpiry, "exp")
+	wrongIssuer["iss"] = "https://elsewhere.example"
+	wrongAudience["aud"] = "other"
+	delete(noSubject, "sub")
+	for name, token := range map[string]string{
+		"expired":        signJWT(t, "ES256", "ec-1", ecKey, expired),
+		"no expiry":      signJWT(t, "ES256", "ec-1", ecKey, noExpiry),
+		"wrong issuer":   signJWT(t, "ES256", "ec-1", ecKey, wrongIssuer),
+		"wrong audience": signJWT(t, "ES256", "ec-1", ecKey, wrongAudience),
+		"no subject":     signJWT(t, "ES256", "ec-1", ecKey, noSubject),
+		"unknown key":    signJWT(t, "ES256", "ec-1", otherKey, valid()),
+		"wrong kid":      signJWT(t, "HS256", "ec-1", secret, valid()),
+		"none":           signJWT(t, "none", "", nil, valid()),
+		"malformed":      "a.b",
+	} {
+		_, err := verifier.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, name)
+	}
+
+	_, err = auth.NewVerifier([]string{filepath.Join(t.TempDir(), "missing.pem")}, "", "")
+	assert.Error(t, err)
+}
+
+func TestAuthAPI(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	verifier, err := auth.NewVerifier(writeKeyFiles(t, ecKey, bytes.Repeat([]byte("s"), 32), rsaKey), "", "")
+	require.NoError(t, err)
+
+	var handler *api.Handler
+	router, db := setupTestAPIWith(t, func(h *api.Handler) {
+		h.Auth = auth.NewAuthenticator(h.Store.DB(), verifier)
+		handler = h
+	})
+	send := func(method, url, credential string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	_, admin, err := auth.NewKeys(db).Issue("root", true, nil, nil)
+	require.NoError(t, err)
+
+	w := send("GET", "/api/v1/substances", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	w = send("GET", "/api/v1/substances", "oaas_bogus_key", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = send("GET", "/health", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Anonymous requests may read, but not write, when allowed
+	handler.Auth.AnonymousReads = true
+	w = send("GET", "/api/v1/substances", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("POST", "/api/v1/kinds", "", map[string]string{"name": "Oak"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	handler.Auth.AnonymousReads = false
+
+	w = send("POST", "/api/v1/admin/keys", admin, map[string]string{"name": "writer"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var issued struct {
+		Key    entities.APIKey `json:"key"`
+		APIKey string          `json:"api_key"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.NotContains(t, w.Body.String(), `"hash"`)
+	writer := issued.APIKey
+
+	w = send("GET", "/api/v1/me", writer, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var me struct {
+		Principal auth.Principal `json:"principal"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &me))
+	assert.Equal(t, "key:"+issued.Key.ID, me.Principal.ID)
+	assert.Equal(t, auth.MethodAPIKey, me.Principal.Method)
+
+	w = send("POST", "/api/v1/kinds", writer, map[string]string{"name": "Oak"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var oak entities.Kind
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &oak))
+	w = send("GET", "/api/v1/admin/keys", writer, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = send("GET", "/api/v1/audit", writer, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The JWT's subject is the principal; its admin claim makes it an administrator
+	token := signJWT(t, "ES256", "ec-1", ecKey, map[string]interface{}{"sub": "alice", "name": "Alice", "exp": time.Now().Add(time.Hour).Unix(), "admin": true})
+	w = send("GET", "/api/v1/audit?entity_id="+oak.ID, token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var audit struct {
+		Records []entities.AuditRecord `json:"records"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &audit))
+	require.Len(t, audit.Records, 1)
+	assert.Equal(t, "key:"+issued.Key.ID, audit.Records[0].PrincipalID)
+	assert.Equal(t, "writer", audit.Records[0].PrincipalName)
+	assert.Equal(t, auth.ActionCreate, audit.Records[0].Action)
+	assert.Equal(t, "kinds", audit.Records[0].EntityType)
+
+	w = send("DELETE", "/api/v1/admin/keys/"+issued.Key.ID, token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/api/v1/substances", writer, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = send("DELETE", "/api/v1/admin/keys/missing", token, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send("GET", "/api/v1/audit?entity_type=api_keys", admin, nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &audit))
+	require.Len(t, audit.Records, 2) // issued and revoked
+	assert.Equal(t, "jwt:alice", audit.Records[0].PrincipalID)
+}
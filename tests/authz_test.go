@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// asPrincipal returns db acting for a principal with the given roles
+func asPrincipal(db *gorm.DB, roles ...string) *gorm.DB {
+	return db.WithContext(auth.WithPrincipal(context.Background(), &auth.Principal{ID: "test", Name: "test", Roles: roles}))
+}
+
+func TestAuthorizer_BuiltinRoles(t *testing.T) {
+	db := setupTestDB(t)
+	oak := entities.NewSubstance("Old Oak", "Oak", "tree")
+	require.NoError(t, db.Create(oak).Error)
+	potentiality := entities.NewPotentiality("grow", "", "", oak.ID)
+	require.NoError(t, db.Create(potentiality).Error)
+
+	taxonomist := auth.NewAuthorizer(asPrincipal(db, "taxonomist"))
+	assert.NoError(t, taxonomist.AuthorizeKinds(auth.ActionCreate, auth.ResourceKind, "Oak"))
+	assert.NoError(t, taxonomist.Authorize(auth.ActionUpdate, auth.ResourceAttribute))
+	assert.NoError(t, taxonomist.Authorize(auth.ActionRead, auth.ResourceSubstance, oak.ID))
+	assert.ErrorIs(t, taxonomist.Authorize(auth.ActionCreate, auth.ResourceMode, oak.ID), auth.ErrForbidden)
+
+	fieldStaff := auth.NewAuthorizer(asPrincipal(db, "field_staff"))
+	assert.NoError(t, fieldStaff.Authorize(auth.ActionCreate, auth.ResourceMode, oak.ID))
+	assert.NoError(t, fieldStaff.Authorize(auth.ActionActualize, auth.ResourcePotentiality, potentiality.ID))
+	assert.ErrorIs(t, fieldStaff.AuthorizeKinds(auth.ActionCreate, auth.ResourceKind, "Birch"), auth.ErrForbidden)
+	assert.ErrorIs(t, fieldStaff.Authorize(auth.ActionDelete, auth.ResourceSubstance, oak.ID), auth.ErrForbidden)
+
+	// No roles grant nothing, while administrators and unauthenticated setups are unrestricted
+	assert.ErrorIs(t, auth.NewAuthorizer(asPrincipal(db)).Authorize(auth.ActionRead, auth.ResourceSubstance), auth.ErrForbidden)
+	admin := db.WithContext(auth.WithPrincipal(context.Background(), &auth.Principal{ID: "root", Admin: true}))
+	assert.NoError(t, auth.NewAuthorizer(admin).Authorize(auth.ActionDelete, auth.ResourceSubstance, oak.ID))
+	assert.NoError(t, auth.NewAuthorizer(db).Authorize(auth.ActionDelete, auth.ResourceSubstance, oak.ID))
+}
+
+func TestAuthorizer_KindScopedRoles(t *testing.T) {
+	db := setupTestDB(t)
+	roles := auth.NewRoles(db)
+	_, err := roles.Create("oak_keeper", "Looks after oaks", []auth.Permission{
+		{Action: auth.ActionRead, Resource: auth.Any},
+		{Action: auth.Any, Resource: auth.ResourceSubstance, Kinds: []string{"Oak"}},
+		{Action: auth.ActionCreate, Resource: auth.ResourceMode, Kinds: []string{"Oak"}},
+	})
+	require.NoError(t, err)
+
+	oak := entities.NewSubstance("Old Oak", "Oak", "tree")
+	birch := entities.NewSubstance("Silver Birch", "Birch", "tree")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(birch).Error)
+	height := entities.NewAttribute("height", "", "number")
+	require.NoError(t, db.Create(height).Error)
+	mode := entities.NewMode("20", oak.ID, height.ID)
+	require.NoError(t, db.Create(mode).Error)
+
+	keeper := auth.NewAuthorizer(asPrincipal(db, "oak_keeper"))
+	assert.NoError(t, keeper.Authorize(auth.ActionUpdate, auth.ResourceSubstance, oak.ID))
+	assert.NoError(t, keeper.Authorize(auth.ActionCreate, auth.ResourceMode, mode.ID))
+	assert.NoError(t, keeper.AuthorizeKinds(auth.ActionCreate, auth.ResourceSubstance, "Oak"))
+
+	// Every kind the operation touches must be covered
+	err = keeper.Authorize(auth.ActionUpdate, auth.ResourceSubstance, oak.ID, birch.ID)
+	var denied *auth.PermissionError
+	require.True(t, errors.As(err, &denied))
+	assert.Equal(t, auth.Permission{Action: auth.ActionUpdate, Resource: auth.ResourceSubstance, Kinds: []string{"Birch"}}, denied.Missing)
+	assert.Equal(t, []string{"oak_keeper"}, denied.Roles)
+	assert.Contains(t, err.Error(), "update substance of kind Birch")
+
+	// A kind-scoped permission does not cover operations concerning no kind
+	assert.ErrorIs(t, keeper.Authorize(auth.ActionUpdate, auth.ResourceSubstance), auth.ErrForbidden)
+
+	_, err = roles.Create("viewer", "", []auth.Permission{{Action: auth.ActionRead, Resource: auth.Any}})
+	assert.ErrorIs(t, err, auth.ErrDuplicateRole)
+	_, err = roles.Create("pruner", "", []auth.Permission{{Action: "prune", Resource: auth.ResourceSubstance}})
+	assert.ErrorIs(t, err, auth.ErrInvalidRole)
+	assert.ErrorIs(t, roles.Delete("editor"), auth.ErrInvalidRole)
+
+	// Deleting a role takes its permissions from whoever has it
+	require.NoError(t, roles.Delete("oak_keeper"))
+	assert.ErrorIs(t, keeper.Authorize(auth.ActionUpdate, auth.ResourceSubstance, oak.ID), auth.ErrForbidden)
+}
+
+func TestAuthorizationAPI(t *testing.T) {
+	router, db := setupTestAPIWith(t, func(h *api.Handler) {
+		h.Auth = auth.NewAuthenticator(h.Store.DB(), nil)
+	})
+	send := func(method, url, credential string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.APIKeyHeader, credential)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	issue := func(name string, roles ...string) string {
+		_, secret, err := auth.NewKeys(db).Issue(name, false, roles, nil)
+		require.NoError(t, err)
+		return secret
+	}
+	_, admin, err := auth.NewKeys(db).Issue("root", true, nil, nil)
+	require.NoError(t, err)
+
+	w := send("POST", "/api/v1/admin/roles", admin, map[string]interface{}{
+		"name": "oak_warden",
+		"permissions": []auth.Permission{
+			{Action: auth.ActionRead, Resource: auth.Any},
+			{Action: auth.ActionUpdate, Resource: auth.ResourceSubstance, Kinds: []string{"Oak"}},
+		},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/admin/roles", admin, map[string]interface{}{"name": "oak_warden", "permissions": []auth.Permission{{Action: auth.ActionRead, Resource: auth.Any}}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("GET", "/api/v1/admin/roles", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Roles []auth.Role `json:"roles"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed.Roles, len(auth.BuiltinRoles)+1)
+
+	w = send("POST", "/api/v1/admin/keys", admin, map[string]interface{}{"name": "typo", "roles": []string{"taxonomer"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	taxonomist := issue("linnaeus", "taxonomist")
+	fieldStaff := issue("ranger", "field_staff")
+	warden := issue("warden", "oak_warden")
+
+	w = send("POST", "/api/v1/kinds", taxonomist, map[string]string{"name": "Oak"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/attributes", taxonomist, map[string]string{"name": "height", "data_type": "number"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var height entities.Attribute
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &height))
+
+	// The 403 names the missing permission and the roles the principal has
+	w = send("POST", "/api/v1/substances", taxonomist, map[string]string{"name": "Old Oak", "kind": "Oak", "essence": "tree"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	var forbidden struct {
+		Error   string          `json:"error"`
+		Missing auth.Permission `json:"missing_permission"`
+		Roles   []string        `json:"roles"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &forbidden))
+	assert.Equal(t, auth.Permission{Action: auth.ActionCreate, Resource: auth.ResourceSubstance, Kinds: []string{"Oak"}}, forbidden.Missing)
+	assert.Equal(t, []string{"taxonomist"}, forbidden.Roles)
+	assert.Contains(t, forbidden.Error, "create substance of kind Oak")
+	var count int64
+	db.Model(&entities.Substance{}).Count(&count)
+	assert.Zero(t, count, "a refused creation leaves nothing behind")
+
+	w = send("POST", "/api/v1/substances", admin, map[string]string{"name": "Old Oak", "kind": "Oak", "essence": "tree"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var oak entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &oak))
+	w = send("POST", "/api/v1/substances", admin, map[string]string{"name": "Silver Birch", "kind": "Birch", "essence": "tree"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var birch entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &birch))
+
+	// Field staff add modes and actualize potentialities, and nothing else
+	w = send("POST", "/api/v1/modes", fieldStaff, map[string]string{"value": "20", "substance_id": oak.ID, "attribute_id": height.ID})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/potentialities", admin, map[string]string{"name": "grow", "substance_id": oak.ID, "conditions": `[{"type":"mode","name":"height","value":"20"}]`})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var potentiality entities.Potentiality
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &potentiality))
+	w = send("POST", "/api/v1/potentialities/"+potentiality.ID+"/actualize", fieldStaff, map[string]string{"description": "grew"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/kinds", fieldStaff, map[string]string{"name": "Elm"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = send("GET", "/api/v1/substances/"+oak.ID, fieldStaff, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The warden may edit oaks only
+	w = send("PUT", "/api/v1/substances/"+oak.ID, warden, map[string]string{"essence": "quercus"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("PUT", "/api/v1/substances/"+birch.ID, warden, map[string]string{"essence": "betula"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = send("PUT", "/api/v1/substances/"+oak.ID, warden, map[string]string{"kind": "Birch"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = send("DELETE", "/api/v1/admin/roles/oak_warden", admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("PUT", "/api/v1/substances/"+oak.ID, warden, map[string]string{"essence": "oak"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = send("DELETE", "/api/v1/admin/roles/viewer", admin, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestAuthorizationAPI_CascadeDelete(t *testing.T) {
+	router, db := setupTestAPIWith(t, func(h *api.Handler) {
+		h.Auth = auth.NewAuthenticator(h.Store.DB(), nil)
+	})
+	_, err := auth.NewRoles(db).Create("oak_feller", "", []auth.Permission{
+		{Action: auth.ActionRead, Resource: auth.Any},
+		{Action: auth.ActionDelete, Resource: auth.ResourceSubstance, Kinds: []string{"Oak"}},
+	})
+	require.NoError(t, err)
+	_, feller, err := auth.NewKeys(db).Issue("feller", false, []string{"oak_feller"}, nil)
+	require.NoError(t, err)
+
+	// A pine that cannot exist without the oak it grows against
+	oak := entities.NewSubstance("Old Oak", "Oak", "tree")
+	pine := entities.NewSubstance("Young Pine", "Pine", "tree")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(pine).Error)
+	_, err = dependence.NewTracker(db).Record(dependence.Input{
+		DependentID: pine.ID, DependsOnID: oak.ID, Type: entities.DependenceExistential,
+	})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/substances/"+oak.ID+"?dependents=cascade", nil)
+	req.Header.Set(auth.APIKeyHeader, feller)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	var forbidden struct {
+		Missing auth.Permission `json:"missing_permission"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &forbidden))
+	assert.Equal(t, auth.Permission{Action: auth.ActionDelete, Resource: auth.ResourceSubstance, Kinds: []string{"Pine"}}, forbidden.Missing)
+
+	var count int64
+	db.Model(&entities.Substance{}).Count(&count)
+	assert.Equal(t, int64(2), count, "a refused cascade deletes nothing")
+}
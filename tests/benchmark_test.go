@@ -65,7 +65,7 @@ func BenchmarkCheckConditions(b *testing.B) {
 		b.Fatalf("Failed to connect to database: %v", err)
 	}
 	
-	err = db.AutoMigrate(&entities.Substance{}, &entities.Attribute{}, &entities.Mode{}, &entities.Potentiality{})
+	err = db.AutoMigrate(&entities.Substance{}, &entities.Attribute{}, &entities.Mode{}, &entities.Potentiality{}, &entities.Parthood{}, &entities.ModeBearer{})
 	if err != nil {
 		b.Fatalf("Failed to migrate: %v", err)
 	}
@@ -110,7 +110,7 @@ func BenchmarkActualizePotentiality(b *testing.B) {
 		b.Fatalf("Failed to connect to database: %v", err)
 	}
 	
-	err = db.AutoMigrate(&entities.Substance{}, &entities.Attribute{}, &entities.Mode{}, &entities.Potentiality{}, &entities.Actuality{})
+	err = db.AutoMigrate(&entities.Substance{}, &entities.Attribute{}, &entities.Mode{}, &entities.Potentiality{}, &entities.Actuality{}, &entities.Parthood{}, &entities.ModeBearer{})
 	if err != nil {
 		b.Fatalf("Failed to migrate: %v", err)
 	}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEngine_AssertMode(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	color := entities.NewAttribute("color", "", "string")
+	color.Cardinality = entities.CardinalitySingle
+	tags := entities.NewAttribute("tags", "", "string")
+	require.NoError(t, db.Create(color).Error)
+	require.NoError(t, db.Create(tags).Error)
+	leaf := entities.NewSubstance("Leaf", "Leaf", "Organ")
+	require.NoError(t, db.Create(leaf).Error)
+
+	require.NoError(t, engine.AssertMode(entities.NewMode("green", leaf.ID, color.ID)))
+	// Restating the same value is not a contradiction
+	require.NoError(t, engine.AssertMode(entities.NewMode("green", leaf.ID, color.ID)))
+	err := engine.AssertMode(entities.NewMode("red", leaf.ID, color.ID))
+	assert.ErrorIs(t, err, causality.ErrConflictingMode)
+
+	require.NoError(t, engine.AssertMode(entities.NewMode("deciduous", leaf.ID, tags.ID)))
+	require.NoError(t, engine.AssertMode(entities.NewMode("lobed", leaf.ID, tags.ID)))
+
+	err = engine.AssertMode(entities.NewMode("x", leaf.ID, "missing"))
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	conflicts, err := engine.ModeConflicts(leaf.ID)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
+
+func TestEngine_AssertMode_Concurrent(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "ontology.db"))
+	color := entities.NewAttribute("color", "", "string")
+	color.Cardinality = entities.CardinalitySingle
+	require.NoError(t, db.Create(color).Error)
+	leaf := entities.NewSubstance("Leaf", "Leaf", "Organ")
+	require.NoError(t, db.Create(leaf).Error)
+
+	// Of two contradicting assertions racing each other, one must see the other
+	values := []string{"green", "red", "yellow", "brown"}
+	errs := make(chan error, len(values))
+	var wg sync.WaitGroup
+	for _, value := range values {
+		wg.Add(1)
+		go func(value string) {
+			defer wg.Done()
+			errs <- db.Transaction(func(tx *gorm.DB) error {
+				return causality.NewEngine(tx).AssertMode(entities.NewMode(value, leaf.ID, color.ID))
+			})
+		}(value)
+	}
+	wg.Wait()
+	close(errs)
+	asserted := 0
+	for err := range errs {
+		if err == nil {
+			asserted++
+			continue
+		}
+		assert.ErrorIs(t, err, causality.ErrConflictingMode)
+	}
+	assert.Equal(t, 1, asserted)
+
+	err := causality.NewEngine(db).AssertMode(entities.NewMode("green", "missing", color.ID))
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	// A failing database is not a missing attribute
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	err = causality.NewEngine(db).AssertMode(entities.NewMode("green", leaf.ID, color.ID))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, causality.ErrEntityNotFound)
+}
+
+func TestEngine_ConflictingModes(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	color := entities.NewAttribute("color", "", "string")
+	require.NoError(t, db.Create(color).Error)
+	leaf := entities.NewSubstance("Leaf", "Leaf", "Organ")
+	require.NoError(t, db.Create(leaf).Error)
+
+	// Contradictions stored while the attribute was multi-valued
+	green := entities.NewMode("green", leaf.ID, color.ID)
+	red := entities.NewMode("red", leaf.ID, color.ID)
+	red.CreatedAt = green.CreatedAt.Add(time.Second)
+	require.NoError(t, db.Create(green).Error)
+	require.NoError(t, db.Create(red).Error)
+	require.NoError(t, db.Model(color).Update("cardinality", entities.CardinalitySingle).Error)
+
+	conflicts, err := engine.ModeConflicts("")
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, []string{"green", "red"}, conflicts[0].Values)
+	assert.Equal(t, red.ID, conflicts[0].CurrentModeID)
+
+	// Conditions see only the newest value
+	facts, err := engine.LoadFacts(leaf.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"red"}, facts.Values["color"])
+	met, _ := facts.Evaluate(causality.Condition{Type: causality.ConditionAttribute, Name: "color", Value: "green"})
+	assert.False(t, met)
+}
+
+func TestCurrentMode_PrefersAsserted(t *testing.T) {
+	ruleID := "rule"
+	asserted := entities.Mode{ID: "a", Value: "green"}
+	inferred := entities.Mode{ID: "b", Value: "red", Inferred: true, RuleID: &ruleID}
+	assert.Equal(t, "a", causality.CurrentMode([]entities.Mode{asserted, inferred}).ID)
+	assert.Equal(t, "b", causality.CurrentMode([]entities.Mode{inferred}).ID)
+}
+
+func TestReasoner_SingleValuedConclusion(t *testing.T) {
+	db := setupTestDB(t)
+	attributes := createAttributes(t, db, "health", "color")
+	require.NoError(t, db.Model(attributes["color"]).Update("cardinality", entities.CardinalitySingle).Error)
+	reasoner := inference.NewReasoner(db)
+
+	_, _, err := reasoner.CreateRule(inference.RuleInput{
+		Name: "sick trees yellow", Conditions: `[{"type":"mode","name":"health","value":"poor"}]`,
+		AttributeID: attributes["color"].ID, Value: "yellow",
+	})
+	require.NoError(t, err)
+	_, _, err = reasoner.CreateRule(inference.RuleInput{
+		Name: "sick trees brown", Conditions: `[{"type":"mode","name":"health","value":"poor"}]`,
+		AttributeID: attributes["color"].ID, Value: "brown",
+	})
+	require.NoError(t, err)
+
+	sick := createTree(t, db, "Sick", "Oak", attributes, map[string]string{"health": "poor"})
+	_, err = reasoner.RefreshSubstance(sick.ID)
+	require.NoError(t, err)
+	// The first rule wins; the second would contradict it
+	assert.Equal(t, map[string]string{"color": "yellow"}, inferredValues(t, db, sick.ID))
+
+	// An asserted value blocks derivation altogether
+	painted := createTree(t, db, "Painted", "Oak", attributes, map[string]string{"health": "poor", "color": "white"})
+	_, err = reasoner.RefreshSubstance(painted.ID)
+	require.NoError(t, err)
+	assert.Empty(t, inferredValues(t, db, painted.ID))
+}
+
+func TestCardinalityAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/v1/attributes", map[string]string{"name": "color", "data_type": "string", "cardinality": "single"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var color entities.Attribute
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &color))
+	assert.Equal(t, entities.CardinalitySingle, color.Cardinality)
+
+	w = send("POST", "/api/v1/attributes", map[string]string{"name": "size", "data_type": "string", "cardinality": "several"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("POST", "/api/v1/attributes", map[string]string{"name": "size", "data_type": "string"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var size entities.Attribute
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &size))
+	assert.Equal(t, entities.CardinalityMultiple, size.Cardinality)
+
+	leaf := entities.NewSubstance("Leaf", "Leaf", "Organ")
+	require.NoError(t, db.Create(leaf).Error)
+
+	w = send("POST", "/api/v1/modes", map[string]string{"value": "green", "substance_id": leaf.ID, "attribute_id": color.ID})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/modes", map[string]string{"value": "red", "substance_id": leaf.ID, "attribute_id": color.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("POST", "/api/v1/modes", map[string]string{"value": "red", "substance_id": leaf.ID, "attribute_id": "missing"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = send("POST", "/api/v1/substances", map[string]interface{}{
+		"name": "Maple", "kind": "Maple", "essence": "Tree",
+		"modes": []map[string]string{
+			{"attribute_id": color.ID, "value": "green"},
+			{"attribute_id": color.ID, "value": "red"},
+		},
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Making size single-valued flags the contradiction already stored
+	require.NoError(t, db.Create(entities.NewMode("small", leaf.ID, size.ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("large", leaf.ID, size.ID)).Error)
+	w = send("PUT", "/api/v1/attributes/"+size.ID+"/cardinality", map[string]string{"cardinality": "single"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated struct {
+		Conflicts []causality.ModeConflict `json:"conflicts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	require.Len(t, updated.Conflicts, 1)
+	assert.Equal(t, size.ID, updated.Conflicts[0].AttributeID)
+
+	w = send("GET", "/api/v1/modes/conflicts?substance_id="+leaf.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Conflicts []causality.ModeConflict `json:"conflicts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed.Conflicts, 1)
+
+	w = send("PUT", "/api/v1/attributes/missing/cardinality", map[string]string{"cardinality": "single"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/apodicticscott/oaas/internal/causality"
 	"github.com/apodicticscott/oaas/internal/entities"
@@ -25,12 +26,44 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&entities.CausalRelation{},
 		&entities.Potentiality{},
 		&entities.Actuality{},
+		&entities.Rule{},
+		&entities.Parthood{},
+		&entities.Dependence{},
+		&entities.ModeBearer{},
+		&entities.SubstanceRedirect{},
+		&entities.SubstanceMerge{},
+		&entities.SubstanceTemplate{},
+		&entities.TemplateMode{},
+		&entities.TemplatePotentiality{},
+		&entities.Workspace{},
+		&entities.APIKey{},
+		&entities.Role{},
+		&entities.AuditRecord{},
+		&entities.QuotaUsage{},
 	)
 	require.NoError(t, err)
 	
 	return db
 }
 
+// relateConcepts records a causal relation between two concepts outside the store
+func relateConcepts(engine *causality.Engine, from, to, causeType string) (*entities.CausalRelation, error) {
+	return engine.RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: from, FromType: entities.EntityTypeExternal,
+		ToEntity: to, ToType: entities.EntityTypeExternal,
+		CauseType: causeType,
+	})
+}
+
+// causeOutside records a causal relation from a stored entity to a concept outside the store
+func causeOutside(engine *causality.Engine, from, to, causeType string) (*entities.CausalRelation, error) {
+	return engine.RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: from,
+		ToEntity:   to, ToType: entities.EntityTypeExternal,
+		CauseType: causeType,
+	})
+}
+
 func TestCausalityEngine_AddCausalRelation(t *testing.T) {
 	db := setupTestDB(t)
 	engine := causality.NewEngine(db)
@@ -39,7 +72,7 @@ func TestCausalityEngine_AddCausalRelation(t *testing.T) {
 	validTypes := []string{"material", "formal", "efficient", "final"}
 	
 	for _, causeType := range validTypes {
-		relation, err := engine.AddCausalRelation("substance-1", "cause-entity", causeType)
+		relation, err := relateConcepts(engine, "substance-1", "cause-entity", causeType)
 		assert.NoError(t, err)
 		assert.Equal(t, causeType, relation.CauseType)
 		assert.Equal(t, "substance-1", relation.FromEntity)
@@ -47,7 +80,7 @@ func TestCausalityEngine_AddCausalRelation(t *testing.T) {
 	}
 	
 	// Test invalid cause type
-	_, err := engine.AddCausalRelation("substance-1", "cause-entity", "invalid")
+	_, err := relateConcepts(engine, "substance-1", "cause-entity", "invalid")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid cause type")
 }
@@ -72,6 +105,26 @@ func TestCausalityEngine_CreatePotentiality(t *testing.T) {
 	assert.Equal(t, substance.ID, potentiality.SubstanceID)
 }
 
+func TestCausalityEngine_CreatePotentiality_InvalidConditions(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	substance := entities.NewSubstance("Tree-001", "Oak", "Living organism")
+	require.NoError(t, db.Create(substance).Error)
+
+	for _, conditions := range []string{
+		`not json`,
+		`[{"type":"weather","name":"season","value":"spring"}]`,
+		`[{"type":"attribute","name":"height","operator":"~","value":"10"}]`,
+	} {
+		_, err := engine.CreatePotentiality("Grow Leaves", "", conditions, substance.ID)
+		assert.ErrorIs(t, err, causality.ErrInvalidConditions, conditions)
+	}
+	var count int64
+	require.NoError(t, db.Model(&entities.Potentiality{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
 func TestCausalityEngine_CreatePotentiality_InvalidSubstance(t *testing.T) {
 	db := setupTestDB(t)
 	engine := causality.NewEngine(db)
@@ -209,26 +262,70 @@ func TestCausalityEngine_GetFourCauses(t *testing.T) {
 	require.NoError(t, err)
 	
 	// Add causal relations
-	_, err = engine.AddCausalRelation(substance.ID, "wood_water", "material")
+	_, err = causeOutside(engine, substance.ID, "wood_water", "material")
 	require.NoError(t, err)
 	
-	_, err = engine.AddCausalRelation(substance.ID, "oak_essence", "formal")
+	_, err = causeOutside(engine, substance.ID, "oak_essence", "formal")
 	require.NoError(t, err)
 	
-	_, err = engine.AddCausalRelation(substance.ID, "sunlight_soil", "efficient")
+	_, err = causeOutside(engine, substance.ID, "sunlight_soil", "efficient")
 	require.NoError(t, err)
 	
-	_, err = engine.AddCausalRelation(substance.ID, "provide_shade", "final")
+	_, err = causeOutside(engine, substance.ID, "provide_shade", "final")
 	require.NoError(t, err)
 	
 	// Test getting four causes
 	causes, err := engine.GetFourCauses(substance.ID)
 	
 	assert.NoError(t, err)
-	assert.Equal(t, "wood_water", causes["material"])
-	assert.Equal(t, "oak_essence", causes["formal"])
-	assert.Equal(t, "sunlight_soil", causes["efficient"])
-	assert.Equal(t, "provide_shade", causes["final"])
+	assert.Equal(t, "wood_water", causes.Cause("material"))
+	assert.Equal(t, "oak_essence", causes.Cause("formal"))
+	assert.Equal(t, "sunlight_soil", causes.Cause("efficient"))
+	assert.Equal(t, "provide_shade", causes.Cause("final"))
+}
+
+func TestCausalityEngine_GetFourCauses_AllRelationsWithDirection(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	oak := entities.NewSubstance("Oak-001", "Oak", "Living organism")
+	require.NoError(t, db.Create(oak).Error)
+	acorn := entities.NewSubstance("Acorn-001", "Acorn", "Seed")
+	require.NoError(t, db.Create(acorn).Error)
+	kind := entities.NewKind("Oak", "Deciduous tree")
+	require.NoError(t, db.Create(kind).Error)
+
+	// Two material causes must both be reported
+	_, err := causeOutside(engine, oak.ID, "wood", "material")
+	require.NoError(t, err)
+	_, err = causeOutside(engine, oak.ID, "water", "material")
+	require.NoError(t, err)
+	_, err = engine.AddCausalRelation(oak.ID, kind.ID, "formal")
+	require.NoError(t, err)
+
+	// The oak is the efficient cause of the acorn, not of itself
+	_, err = engine.AddCausalRelation(acorn.ID, oak.ID, "efficient")
+	require.NoError(t, err)
+
+	causes, err := engine.GetFourCauses(oak.ID)
+	require.NoError(t, err)
+
+	require.Len(t, causes.Causes["material"], 2)
+	assert.Equal(t, "wood", causes.Causes["material"][0].Entity.ID)
+	assert.Equal(t, "water", causes.Causes["material"][1].Entity.ID)
+	assert.Equal(t, entities.EntityTypeExternal, causes.Causes["material"][0].Entity.Type)
+
+	require.Len(t, causes.Causes["formal"], 1)
+	assert.Equal(t, entities.EntityTypeKind, causes.Causes["formal"][0].Entity.Type)
+	assert.Equal(t, "Oak", causes.Causes["formal"][0].Entity.Name)
+
+	assert.Empty(t, causes.Causes["efficient"])
+	require.Len(t, causes.Effects["efficient"], 1)
+	assert.Equal(t, acorn.ID, causes.Effects["efficient"][0].Entity.ID)
+	assert.Equal(t, entities.EntityTypeSubstance, causes.Effects["efficient"][0].Entity.Type)
+	assert.Equal(t, "Acorn-001", causes.Effects["efficient"][0].Entity.Name)
+
+	assert.Equal(t, []string{"efficient", "final"}, causes.Missing)
 }
 
 func TestCausalityEngine_GetSubstanceEvolution(t *testing.T) {
@@ -285,3 +382,153 @@ func TestConditionParsing_InvalidJSON(t *testing.T) {
 	err := json.Unmarshal([]byte(invalidConditions), &conditions)
 	assert.Error(t, err)
 }
+
+func TestCausalityEngine_RecordCausalRelation_Details(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	strength := 0.6
+	from := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	relation, err := engine.RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: "drought",
+		FromType:   entities.EntityTypeExternal,
+		ToEntity:   "el_nino",
+		ToType:     entities.EntityTypeExternal,
+		CauseType:  "efficient",
+		Strength:   &strength,
+		Evidence:   "Smith et al. 2001",
+		Notes:      "Correlated rainfall deficits",
+		ValidFrom:  &from,
+		ValidUntil: &until,
+	})
+	require.NoError(t, err)
+
+	var stored entities.CausalRelation
+	require.NoError(t, db.First(&stored, "id = ?", relation.ID).Error)
+	assert.Equal(t, 0.6, stored.Strength)
+	assert.Equal(t, "Smith et al. 2001", stored.Evidence)
+	assert.Equal(t, "Correlated rainfall deficits", stored.Notes)
+	require.NotNil(t, stored.ValidFrom)
+	assert.True(t, stored.ValidFrom.Equal(from))
+
+	// Defaults to full strength
+	plain, err := relateConcepts(engine, "drought", "heat", "efficient")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, plain.Strength)
+
+	invalid := 1.5
+	_, err = engine.RecordCausalRelation(causality.CausalRelationInput{FromEntity: "a", ToEntity: "b", CauseType: "material", Strength: &invalid})
+	assert.ErrorContains(t, err, "invalid strength")
+
+	_, err = engine.RecordCausalRelation(causality.CausalRelationInput{FromEntity: "a", ToEntity: "b", CauseType: "material", ValidFrom: &until, ValidUntil: &from})
+	assert.ErrorContains(t, err, "invalid validity period")
+}
+
+func TestCausalityEngine_ListCausalRelations_Filters(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	weak := 0.2
+	from := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	external := entities.EntityTypeExternal
+	_, err := engine.RecordCausalRelation(causality.CausalRelationInput{FromEntity: "drought", FromType: external, ToEntity: "el_nino", ToType: external, CauseType: "efficient", ValidFrom: &from, ValidUntil: &until})
+	require.NoError(t, err)
+	_, err = engine.RecordCausalRelation(causality.CausalRelationInput{FromEntity: "drought", FromType: external, ToEntity: "sunspots", ToType: external, CauseType: "efficient", Strength: &weak})
+	require.NoError(t, err)
+	_, err = relateConcepts(engine, "famine", "drought", "material")
+	require.NoError(t, err)
+
+	all, err := engine.ListCausalRelations(causality.CausalFilter{EntityID: "drought"})
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	strong, err := engine.ListCausalRelations(causality.CausalFilter{CauseTypes: []string{"efficient"}, MinStrength: 0.5})
+	require.NoError(t, err)
+	require.Len(t, strong, 1)
+	assert.Equal(t, "el_nino", strong[0].ToEntity)
+
+	later := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	current, err := engine.ListCausalRelations(causality.CausalFilter{ValidAt: &later})
+	require.NoError(t, err)
+	assert.Len(t, current, 2)
+	for _, relation := range current {
+		assert.NotEqual(t, "el_nino", relation.ToEntity)
+	}
+}
+
+func TestCausalityEngine_RecordCausalRelation_TypedEndpoints(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	oak := entities.NewSubstance("Oak-001", "Oak", "Living organism")
+	require.NoError(t, db.Create(oak).Error)
+	kind := entities.NewKind("Oak", "Deciduous tree")
+	require.NoError(t, db.Create(kind).Error)
+
+	// Undeclared types are inferred from the store
+	relation, err := engine.AddCausalRelation(oak.ID, kind.ID, "formal")
+	require.NoError(t, err)
+	assert.Equal(t, entities.EntityTypeSubstance, relation.FromType)
+	assert.Equal(t, entities.EntityTypeKind, relation.ToType)
+
+	// Only declared references may name something outside the store
+	relation, err = causeOutside(engine, oak.ID, "wood_water", "material")
+	require.NoError(t, err)
+	assert.Equal(t, entities.EntityTypeExternal, relation.ToType)
+	_, err = engine.AddCausalRelation(oak.ID, "wood_water", "material")
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	// A misspelled kind ID is not taken for an external formal cause
+	_, err = engine.AddCausalRelation(oak.ID, kind.ID+"x", "formal")
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	// Declared types must name an existing entity
+	_, err = engine.RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: oak.ID, FromType: entities.EntityTypeSubstance,
+		ToEntity: "missing-kind", ToType: entities.EntityTypeKind,
+		CauseType: "formal",
+	})
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	_, err = engine.RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: oak.ID, FromType: entities.EntityTypeKind,
+		ToEntity: kind.ID, CauseType: "formal",
+	})
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	// A formal cause must be a kind, not a substance
+	acorn := entities.NewSubstance("Acorn-001", "Acorn", "Seed")
+	require.NoError(t, db.Create(acorn).Error)
+	_, err = engine.AddCausalRelation(oak.ID, acorn.ID, "formal")
+	assert.ErrorIs(t, err, causality.ErrCauseTypeRule)
+}
+
+func TestCausalityEngine_DetachSubstance(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	oak := entities.NewSubstance("Oak-001", "Oak", "Living organism")
+	require.NoError(t, db.Create(oak).Error)
+	acorn := entities.NewSubstance("Acorn-001", "Acorn", "Seed")
+	require.NoError(t, db.Create(acorn).Error)
+	potentiality := entities.NewPotentiality("Sprout", "Acorn can sprout", "", acorn.ID)
+	require.NoError(t, db.Create(potentiality).Error)
+
+	_, err := engine.AddCausalRelation(acorn.ID, oak.ID, "efficient")
+	require.NoError(t, err)
+	_, err = engine.AddCausalRelation(oak.ID, potentiality.ID, "final")
+	require.NoError(t, err)
+	_, err = causeOutside(engine, oak.ID, "sunlight", "efficient")
+	require.NoError(t, err)
+
+	removed, err := engine.DetachSubstance(acorn.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	remaining, err := engine.ListCausalRelations(causality.CausalFilter{})
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "sunlight", remaining[0].ToEntity)
+}
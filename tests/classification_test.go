@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/classification"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// defineTreeKinds creates Oak and Birch, defined by bark and leaf shape, and an undefined Pine
+func defineTreeKinds(t *testing.T, db *gorm.DB) map[string]*entities.Attribute {
+	attributes := createAttributes(t, db, "bark", "leaf_shape")
+
+	oak := entities.NewKind("Oak", "Quercus")
+	oak.Definition = `[{"type":"attribute","name":"bark","value":"furrowed"},{"type":"attribute","name":"leaf_shape","value":"lobed"}]`
+	birch := entities.NewKind("Birch", "Betula")
+	birch.Definition = `[{"type":"attribute","name":"bark","value":"papery"}]`
+	pine := entities.NewKind("Pine", "Pinus")
+	for _, kind := range []*entities.Kind{oak, birch, pine} {
+		require.NoError(t, db.Create(kind).Error)
+	}
+	return attributes
+}
+
+func createTree(t *testing.T, db *gorm.DB, name, kind string, attributes map[string]*entities.Attribute, modes map[string]string) *entities.Substance {
+	substance := entities.NewSubstance(name, kind, "Tree")
+	require.NoError(t, db.Create(substance).Error)
+	for attribute, value := range modes {
+		require.NoError(t, db.Create(entities.NewMode(value, substance.ID, attributes[attribute].ID)).Error)
+	}
+	return substance
+}
+
+func TestClassifier_Classify(t *testing.T) {
+	db := setupTestDB(t)
+	attributes := defineTreeKinds(t, db)
+	classifier := classification.NewClassifier(db)
+
+	oak := createTree(t, db, "Old Oak", "", attributes, map[string]string{"bark": "furrowed", "leaf_shape": "lobed"})
+	kinds, err := classifier.Classify(oak.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Oak"}, kinds)
+
+	// Necessary: furrowed bark alone is not enough for an oak
+	stump := createTree(t, db, "Stump", "", attributes, map[string]string{"bark": "furrowed"})
+	kinds, err = classifier.Classify(stump.ID)
+	require.NoError(t, err)
+	assert.Empty(t, kinds)
+
+	kind, err := classifier.AssignKind(oak.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Oak", kind)
+	var stored entities.Substance
+	require.NoError(t, db.First(&stored, "id = ?", oak.ID).Error)
+	assert.Equal(t, "Oak", stored.Kind)
+
+	_, err = classifier.AssignKind(stump.ID)
+	assert.ErrorIs(t, err, classification.ErrNoMatchingKind)
+
+	// Overlapping definitions make assignment ambiguous
+	hybrid := createTree(t, db, "Hybrid", "", attributes, map[string]string{"bark": "papery", "leaf_shape": "lobed"})
+	require.NoError(t, db.Create(entities.NewMode("furrowed", hybrid.ID, attributes["bark"].ID)).Error)
+	_, err = classifier.AssignKind(hybrid.ID)
+	assert.ErrorIs(t, err, classification.ErrAmbiguousKind)
+}
+
+func TestClassifier_Misclassified(t *testing.T) {
+	db := setupTestDB(t)
+	attributes := defineTreeKinds(t, db)
+
+	createTree(t, db, "True Oak", "Oak", attributes, map[string]string{"bark": "furrowed", "leaf_shape": "lobed"})
+	wrong := createTree(t, db, "Mislabelled Birch", "Oak", attributes, map[string]string{"bark": "papery"})
+	// Undefined kinds are never judged
+	createTree(t, db, "Scots Pine", "Pine", attributes, map[string]string{"bark": "papery"})
+
+	misclassified, err := classification.NewClassifier(db).Misclassified()
+	require.NoError(t, err)
+	require.Len(t, misclassified, 1)
+	assert.Equal(t, wrong.ID, misclassified[0].SubstanceID)
+	assert.Equal(t, "Oak", misclassified[0].AssignedKind)
+	assert.Len(t, misclassified[0].Unmet, 2)
+	assert.Equal(t, []string{"Birch"}, misclassified[0].MatchingKinds)
+}
+
+func TestClassifier_ParseDefinition(t *testing.T) {
+	_, err := classification.ParseDefinition(`[{"type":"mode","name":"bark","value":"papery"}]`)
+	assert.NoError(t, err)
+
+	for _, definition := range []string{
+		`[]`,
+		`not json`,
+		`[{"type":"kind","value":"Oak"}]`,
+		`[{"type":"external","name":"planted"}]`,
+	} {
+		_, err := classification.ParseDefinition(definition)
+		assert.ErrorIs(t, err, classification.ErrInvalidDefinition, definition)
+	}
+}
+
+func TestClassificationAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	attributes := defineTreeKinds(t, db)
+
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Auto-assign from the modes given at creation
+	w := send("POST", "/api/v1/substances", map[string]interface{}{
+		"name":          "Silver Birch",
+		"essence":       "Tree",
+		"auto_classify": true,
+		"modes":         []map[string]string{{"attribute_id": attributes["bark"].ID, "value": "papery"}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var birch entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &birch))
+	assert.Equal(t, "Birch", birch.Kind)
+
+	// The given kind is the fallback when no definition matches
+	w = send("POST", "/api/v1/substances", map[string]interface{}{
+		"name": "Scots Pine", "kind": "Pine", "essence": "Tree", "auto_classify": true,
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var pine entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pine))
+	assert.Equal(t, "Pine", pine.Kind)
+
+	w = send("POST", "/api/v1/substances", map[string]interface{}{
+		"name": "Mystery", "essence": "Tree", "auto_classify": true,
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Without auto_classify a kind is still required
+	w = send("POST", "/api/v1/substances", map[string]interface{}{"name": "Mystery", "essence": "Tree"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("GET", "/api/v1/substances/"+birch.ID+"/classification", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var report struct {
+		AssignedKind  string   `json:"assigned_kind"`
+		MatchingKinds []string `json:"matching_kinds"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "Birch", report.AssignedKind)
+	assert.Equal(t, []string{"Birch"}, report.MatchingKinds)
+
+	// Defining Pine by needles leaves the pine misclassified
+	var pineKind entities.Kind
+	require.NoError(t, db.First(&pineKind, "name = ?", "Pine").Error)
+	w = send("PUT", "/api/v1/kinds/"+pineKind.ID+"/definition", map[string]string{
+		"definition": `[{"type":"mode","name":"leaf_shape","value":"needle"}]`,
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = send("GET", "/api/v1/kinds/misclassified", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var misclassified struct {
+		Misclassified []classification.Misclassification `json:"misclassified"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &misclassified))
+	require.Len(t, misclassified.Misclassified, 1)
+	assert.Equal(t, pine.ID, misclassified.Misclassified[0].SubstanceID)
+
+	w = send("PUT", "/api/v1/kinds/"+pineKind.ID+"/definition", map[string]string{"definition": `[{"type":"kind","value":"Pine"}]`})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", "/api/v1/kinds", map[string]string{"name": "Ash", "definition": `[]`})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependence_Record(t *testing.T) {
+	db := setupTestDB(t)
+	socrates := entities.NewSubstance("Socrates", "Human", "Rational animal")
+	sophroniscus := entities.NewSubstance("Sophroniscus", "Human", "Rational animal")
+	require.NoError(t, db.Create(socrates).Error)
+	require.NoError(t, db.Create(sophroniscus).Error)
+	human := entities.NewKind("Human", "")
+	require.NoError(t, db.Create(human).Error)
+	attributes := createAttributes(t, db, "wisdom")
+	wisdom := entities.NewMode("great", socrates.ID, attributes["wisdom"].ID)
+	require.NoError(t, db.Create(wisdom).Error)
+	tracker := dependence.NewTracker(db)
+
+	// Endpoint types are inferred when omitted
+	recorded, err := tracker.Record(dependence.Input{DependentID: wisdom.ID, DependsOnID: socrates.ID, Type: entities.DependenceIdentity})
+	require.NoError(t, err)
+	assert.Equal(t, entities.EntityTypeMode, recorded.DependentType)
+	assert.Equal(t, entities.EntityTypeSubstance, recorded.DependsOnType)
+	assert.Equal(t, entities.ModalityRigid, recorded.Modality)
+
+	_, err = tracker.Record(dependence.Input{DependentID: wisdom.ID, DependsOnID: socrates.ID, Type: entities.DependenceIdentity})
+	assert.ErrorIs(t, err, dependence.ErrDuplicateDependence)
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: wisdom.ID, Type: entities.DependenceIdentity})
+	assert.ErrorIs(t, err, dependence.ErrDependenceCycle)
+	// Mutual existential dependence is allowed
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: wisdom.ID, Type: entities.DependenceExistential})
+	assert.NoError(t, err)
+
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: sophroniscus.ID, Type: entities.DependenceExistential, Modality: entities.ModalityGeneric})
+	assert.ErrorIs(t, err, dependence.ErrInvalidDependence)
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: human.ID, Type: entities.DependenceIdentity, Modality: entities.ModalityGeneric})
+	assert.ErrorIs(t, err, dependence.ErrInvalidDependence)
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: human.ID, Type: entities.DependenceExistential, Modality: entities.ModalityGeneric})
+	assert.NoError(t, err)
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: attributes["wisdom"].ID, Type: entities.DependenceExistential})
+	assert.ErrorIs(t, err, dependence.ErrInvalidDependence)
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: "missing", Type: entities.DependenceExistential})
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+	_, err = tracker.Record(dependence.Input{DependentID: socrates.ID, DependsOnID: socrates.ID, Type: entities.DependenceExistential})
+	assert.ErrorIs(t, err, dependence.ErrInvalidDependence)
+}
+
+func TestDependence_Dependents(t *testing.T) {
+	db := setupTestDB(t)
+	a := entities.NewSubstance("A", "Thing", "")
+	b := entities.NewSubstance("B", "Thing", "")
+	c := entities.NewSubstance("C", "Thing", "")
+	for _, substance := range []*entities.Substance{a, b, c} {
+		require.NoError(t, db.Create(substance).Error)
+	}
+	tracker := dependence.NewTracker(db)
+	for _, pair := range [][2]string{{b.ID, a.ID}, {c.ID, b.ID}} {
+		_, err := tracker.Record(dependence.Input{DependentID: pair[0], DependsOnID: pair[1], Type: entities.DependenceExistential})
+		require.NoError(t, err)
+	}
+
+	direct, err := tracker.Dependents(a.ID, false)
+	require.NoError(t, err)
+	require.Len(t, direct, 1)
+	assert.Equal(t, b.ID, direct[0].DependentID)
+
+	all, err := tracker.Dependents(a.ID, true)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, c.ID, all[1].DependentID)
+	assert.Equal(t, 2, all[1].Depth)
+
+	upstream, err := tracker.Dependencies(c.ID, true)
+	require.NoError(t, err)
+	require.Len(t, upstream, 2)
+	assert.Equal(t, a.ID, upstream[1].DependsOnID)
+}
+
+func TestDependence_DeletionPolicy(t *testing.T) {
+	db := setupTestDB(t)
+	a := entities.NewSubstance("A", "Thing", "")
+	b := entities.NewSubstance("B", "Thing", "")
+	c := entities.NewSubstance("C", "Thing", "")
+	for _, substance := range []*entities.Substance{a, b, c} {
+		require.NoError(t, db.Create(substance).Error)
+	}
+	tracker := dependence.NewTracker(db)
+	for _, pair := range [][2]string{{b.ID, a.ID}, {c.ID, b.ID}} {
+		_, err := tracker.Record(dependence.Input{DependentID: pair[0], DependsOnID: pair[1], Type: entities.DependenceExistential})
+		require.NoError(t, err)
+	}
+
+	plan, err := tracker.PlanDeletion(entities.EntityTypeSubstance, a.ID, dependence.PolicyBlock)
+	assert.ErrorIs(t, err, dependence.ErrHasDependents)
+	require.Len(t, plan.Dependents, 1)
+	assert.Equal(t, b.ID, plan.Dependents[0].DependentID)
+
+	// Deleting the last dependent in the chain is not blocked
+	plan, err = tracker.PlanDeletion(entities.EntityTypeSubstance, c.ID, dependence.PolicyBlock)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Dependents)
+
+	plan, err = tracker.PlanDeletion(entities.EntityTypeSubstance, a.ID, dependence.PolicyCascade)
+	require.NoError(t, err)
+	require.Len(t, plan.Entities, 3)
+	_, err = tracker.Execute(plan)
+	require.NoError(t, err)
+
+	var substances, dependences int64
+	db.Model(&entities.Substance{}).Count(&substances)
+	db.Model(&entities.Dependence{}).Count(&dependences)
+	assert.Zero(t, substances)
+	assert.Zero(t, dependences)
+
+	_, err = tracker.PlanDeletion(entities.EntityTypeSubstance, a.ID, "orphan")
+	assert.Error(t, err)
+}
+
+func TestDependence_GenericOnLastInstance(t *testing.T) {
+	db := setupTestDB(t)
+	oxygen := entities.NewKind("Oxygen", "")
+	require.NoError(t, db.Create(oxygen).Error)
+	o1 := entities.NewSubstance("O1", "Oxygen", "")
+	o2 := entities.NewSubstance("O2", "Oxygen", "")
+	fire := entities.NewSubstance("Fire", "Process", "")
+	for _, substance := range []*entities.Substance{o1, o2, fire} {
+		require.NoError(t, db.Create(substance).Error)
+	}
+	tracker := dependence.NewTracker(db)
+	_, err := tracker.Record(dependence.Input{DependentID: fire.ID, DependsOnID: oxygen.ID, Type: entities.DependenceExistential, Modality: entities.ModalityGeneric})
+	require.NoError(t, err)
+
+	// Any instance of the kind will do while another remains
+	plan, err := tracker.PlanDeletion(entities.EntityTypeSubstance, o1.ID, dependence.PolicyBlock)
+	require.NoError(t, err)
+	_, err = tracker.Execute(plan)
+	require.NoError(t, err)
+
+	plan, err = tracker.PlanDeletion(entities.EntityTypeSubstance, o2.ID, dependence.PolicyBlock)
+	assert.ErrorIs(t, err, dependence.ErrHasDependents)
+	require.Len(t, plan.Dependents, 1)
+	assert.Equal(t, fire.ID, plan.Dependents[0].DependentID)
+}
+
+func TestDependenceAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	marriage := entities.NewSubstance("Marriage", "Relation", "")
+	spouse := entities.NewSubstance("Spouse", "Human", "")
+	require.NoError(t, db.Create(marriage).Error)
+	require.NoError(t, db.Create(spouse).Error)
+
+	body := map[string]string{"dependent_id": marriage.ID, "depends_on_id": spouse.ID, "type": "existential"}
+	w := send("POST", "/api/v1/dependences", body)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var recorded entities.Dependence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &recorded))
+
+	w = send("POST", "/api/v1/dependences", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("POST", "/api/v1/dependences", map[string]string{"dependent_id": marriage.ID, "depends_on_id": "missing", "type": "existential"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", "/api/v1/dependences", map[string]string{"dependent_id": marriage.ID, "depends_on_id": spouse.ID, "type": "causal"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("GET", "/api/v1/entities/"+spouse.ID+"/dependents", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Dependents []dependence.DependenceNode `json:"dependents"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Dependents, 1)
+	assert.Equal(t, marriage.ID, listed.Dependents[0].DependentID)
+
+	w = send("DELETE", "/api/v1/substances/"+spouse.ID, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), marriage.ID)
+	w = send("DELETE", "/api/v1/substances/"+spouse.ID+"?dependents=ignore", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("DELETE", "/api/v1/substances/"+spouse.ID+"?dependents=cascade", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var count int64
+	db.Model(&entities.Substance{}).Count(&count)
+	assert.Zero(t, count)
+
+	w = send("DELETE", "/api/v1/dependences/"+recorded.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDependenceAPI_ModesAndKinds(t *testing.T) {
+	router, db := setupTestAPI(t)
+	remove := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("DELETE", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	tracker := dependence.NewTracker(db)
+
+	// A bruise exists only while the apple is soft
+	apple := entities.NewSubstance("Apple", "Fruit", "")
+	bruise := entities.NewSubstance("Bruise", "Blemish", "")
+	require.NoError(t, db.Create(apple).Error)
+	require.NoError(t, db.Create(bruise).Error)
+	firmness := entities.NewAttribute("firmness", "", "string")
+	require.NoError(t, db.Create(firmness).Error)
+	soft := entities.NewMode("soft", apple.ID, firmness.ID)
+	require.NoError(t, db.Create(soft).Error)
+	_, err := tracker.Record(dependence.Input{DependentID: bruise.ID, DependsOnID: soft.ID, Type: entities.DependenceExistential})
+	require.NoError(t, err)
+
+	w := remove("/api/v1/modes/" + soft.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), bruise.ID)
+	w = remove("/api/v1/modes/" + soft.ID + "?dependents=cascade")
+	require.Equal(t, http.StatusOK, w.Code)
+	var count int64
+	db.Model(&entities.Mode{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entities.Substance{}).Where("id = ?", bruise.ID).Count(&count)
+	assert.Zero(t, count)
+
+	// An orchard needs some fruit tree or other to exist
+	tree := entities.NewKind("Fruit Tree", "")
+	require.NoError(t, db.Create(tree).Error)
+	orchard := entities.NewSubstance("Orchard", "Place", "")
+	require.NoError(t, db.Create(orchard).Error)
+	_, err = tracker.Record(dependence.Input{
+		DependentID: orchard.ID, DependsOnID: tree.ID, Type: entities.DependenceExistential, Modality: entities.ModalityGeneric,
+	})
+	require.NoError(t, err)
+
+	w = remove("/api/v1/kinds/" + tree.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = remove("/api/v1/kinds/" + tree.ID + "?dependents=cascade")
+	require.Equal(t, http.StatusOK, w.Code)
+	db.Model(&entities.Kind{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entities.Substance{}).Where("id = ?", orchard.ID).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entities.Dependence{}).Count(&count)
+	assert.Zero(t, count)
+}
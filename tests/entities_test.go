@@ -105,3 +105,19 @@ func TestCausalRelationTypes(t *testing.T) {
 		assert.Equal(t, causeType, relation.CauseType)
 	}
 }
+
+func TestCausalRelationHoldsAt(t *testing.T) {
+	relation := entities.NewCausalRelation("efficient", "drought", "el_nino")
+	assert.Equal(t, 1.0, relation.Strength)
+	assert.True(t, relation.HoldsAt(time.Now()))
+
+	from := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	relation.ValidFrom = &from
+	relation.ValidUntil = &until
+
+	assert.False(t, relation.HoldsAt(from.Add(-time.Hour)))
+	assert.True(t, relation.HoldsAt(from))
+	assert.True(t, relation.HoldsAt(until))
+	assert.False(t, relation.HoldsAt(until.Add(time.Hour)))
+}
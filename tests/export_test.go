@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedExportGraph records an oak grown from an acorn, made of wood, and an unrelated pine
+func seedExportGraph(t *testing.T, db *gorm.DB) (oak, pine *entities.Substance) {
+	engine := causality.NewEngine(db)
+
+	kind := entities.NewKind("Oak", "Deciduous tree")
+	require.NoError(t, db.Create(kind).Error)
+	oak = entities.NewSubstance("Old Oak", "Oak", "Living organism")
+	require.NoError(t, db.Create(oak).Error)
+	pine = entities.NewSubstance("Pine", "Pine", "Living organism")
+	require.NoError(t, db.Create(pine).Error)
+
+	_, err := causeOutside(engine, oak.ID, "acorn", "efficient")
+	require.NoError(t, err)
+	_, err = causeOutside(engine, oak.ID, "wood", "material")
+	require.NoError(t, err)
+	_, err = engine.AddCausalRelation(oak.ID, kind.ID, "formal")
+	require.NoError(t, err)
+	_, err = causeOutside(engine, pine.ID, "resin", "material")
+	require.NoError(t, err)
+	return oak, pine
+}
+
+func TestExport_BuildScopes(t *testing.T) {
+	db := setupTestDB(t)
+	oak, _ := seedExportGraph(t, db)
+
+	whole, err := export.Build(db, export.Scope{})
+	require.NoError(t, err)
+	assert.Len(t, whole.Edges, 4)
+
+	around, err := export.Build(db, export.Scope{SubstanceID: oak.ID})
+	require.NoError(t, err)
+	assert.Len(t, around.Edges, 3)
+	assert.Len(t, around.Nodes, 4)
+	for _, edge := range around.Edges {
+		assert.Equal(t, oak.ID, edge.Target, "edges point from cause to effect")
+	}
+
+	byKind, err := export.Build(db, export.Scope{Kind: "Oak", CauseTypes: []string{"material"}})
+	require.NoError(t, err)
+	require.Len(t, byKind.Edges, 1)
+	assert.Equal(t, "wood", byKind.Edges[0].Source)
+
+	_, err = export.Build(db, export.Scope{Kind: "Birch"})
+	assert.ErrorIs(t, err, export.ErrKindNotFound)
+}
+
+func TestExport_Formats(t *testing.T) {
+	db := setupTestDB(t)
+	oak, _ := seedExportGraph(t, db)
+
+	graph, err := export.Build(db, export.Scope{SubstanceID: oak.ID})
+	require.NoError(t, err)
+
+	var dot bytes.Buffer
+	require.NoError(t, export.Render(&dot, graph, export.FormatDOT))
+	assert.True(t, strings.HasPrefix(dot.String(), "digraph causes {"))
+	assert.Contains(t, dot.String(), `"acorn" -> "`+oak.ID+`" [label="efficient", color="#b22222"`)
+	assert.Contains(t, dot.String(), `label="Old Oak"`)
+
+	var graphml bytes.Buffer
+	require.NoError(t, export.Render(&graphml, graph, export.FormatGraphML))
+	var parsed struct {
+		Graph struct {
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	require.NoError(t, xml.Unmarshal(graphml.Bytes(), &parsed))
+	assert.Len(t, parsed.Graph.Nodes, 4)
+	assert.Len(t, parsed.Graph.Edges, 3)
+
+	var mermaid bytes.Buffer
+	require.NoError(t, export.Render(&mermaid, graph, export.FormatMermaid))
+	assert.True(t, strings.HasPrefix(mermaid.String(), "flowchart LR\n"))
+	assert.Contains(t, mermaid.String(), `["Old Oak"]`)
+	assert.Contains(t, mermaid.String(), "-->|material|")
+	assert.Contains(t, mermaid.String(), "linkStyle 0 stroke:")
+
+	var cytoscape bytes.Buffer
+	require.NoError(t, export.Render(&cytoscape, graph, export.FormatCytoscape))
+	var elements struct {
+		Elements struct {
+			Nodes []map[string]map[string]interface{} `json:"nodes"`
+			Edges []map[string]map[string]interface{} `json:"edges"`
+		} `json:"elements"`
+	}
+	require.NoError(t, json.Unmarshal(cytoscape.Bytes(), &elements))
+	assert.Len(t, elements.Elements.Nodes, 4)
+	require.Len(t, elements.Elements.Edges, 3)
+	assert.NotEmpty(t, elements.Elements.Edges[0]["data"]["color"])
+
+	assert.Error(t, export.Render(&bytes.Buffer{}, graph, "png"))
+}
+
+func TestExport_EscapesLabels(t *testing.T) {
+	graph := &export.Graph{Nodes: []export.Node{{ID: "q", Label: `The "Royal" Oak`, Type: "substance"}}}
+
+	var dot bytes.Buffer
+	require.NoError(t, export.Render(&dot, graph, export.FormatDOT))
+	assert.Contains(t, dot.String(), `label="The \"Royal\" Oak"`)
+
+	var mermaid bytes.Buffer
+	require.NoError(t, export.Render(&mermaid, graph, export.FormatMermaid))
+	assert.Contains(t, mermaid.String(), `n0["The #quot;Royal#quot; Oak"]`)
+}
+
+func TestExportAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	seedExportGraph(t, db)
+
+	req, _ := http.NewRequest("GET", "/api/v1/export/causal-graph?format=mermaid&kind=Oak", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, w.Body.String(), "flowchart LR")
+	assert.Contains(t, w.Body.String(), `["Oak"]`, "kind nodes are labelled by name")
+
+	req, _ = http.NewRequest("GET", "/api/v1/export/causal-graph?format=png", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/version"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probe sends a GET and decodes the JSON answer
+func probe(t *testing.T, router *gin.Engine, url string) (int, map[string]interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return w.Code, body
+}
+
+func TestReadyz(t *testing.T) {
+	var handler *api.Handler
+	router, db := setupTestAPIWith(t, func(h *api.Handler) { handler = h })
+
+	code, body := probe(t, router, "/readyz")
+	require.Equal(t, http.StatusOK, code, body)
+	assert.Equal(t, "ready", body["status"])
+	checks := body["checks"].(map[string]interface{})
+	assert.Equal(t, "ok", checks["database"].(map[string]interface{})["status"])
+	assert.Equal(t, "ok", checks["schema"].(map[string]interface{})["status"])
+
+	// A migration left half applied makes the server unready
+	require.NoError(t, db.Exec("CREATE TABLE schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)").Error)
+	require.NoError(t, db.Exec("INSERT INTO schema_migrations (version, dirty) VALUES (16, true)").Error)
+	code, body = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", body["status"])
+	schema := body["checks"].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.Equal(t, "failed", schema["status"])
+	assert.Contains(t, schema["error"], "migration 16 is dirty")
+
+	require.NoError(t, db.Exec("UPDATE schema_migrations SET dirty = false").Error)
+	code, _ = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// So does a missing table
+	require.NoError(t, db.Migrator().DropTable(&entities.TemplateMode{}))
+	code, body = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	schema = body["checks"].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.Contains(t, schema["error"], "missing tables template_modes")
+
+	// Draining fails readiness whatever the database says, but not liveness
+	handler.Drain()
+	code, body = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "shutting down", body["status"])
+	code, body = probe(t, router, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "alive", body["status"])
+}
+
+func TestHealth_DatabaseDown(t *testing.T) {
+	router, db := setupTestAPIWith(t, nil)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	code, body := probe(t, router, "/health")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unhealthy", body["status"])
+	database := body["checks"].(map[string]interface{})["database"].(map[string]interface{})
+	assert.Equal(t, "failed", database["status"])
+	assert.NotContains(t, body["checks"], "schema", "the schema is not checked without a database")
+
+	code, _ = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = probe(t, router, "/livez")
+	assert.Equal(t, http.StatusOK, code, "restarting the server would not bring the database back")
+}
+
+func TestVersion(t *testing.T) {
+	router, _ := setupTestAPIWith(t, nil)
+
+	code, body := probe(t, router, "/version")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, version.Version, body["version"])
+	assert.Equal(t, runtime.Version(), body["go_version"])
+	if commit := version.Get().Commit; commit != "" {
+		assert.Equal(t, commit, body["commit"])
+	} else {
+		assert.NotContains(t, body, "commit", "an unknown commit is left out")
+	}
+}
@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// inferredValues returns the inferred mode values of a substance by attribute name
+func inferredValues(t *testing.T, db *gorm.DB, substanceID string) map[string]string {
+	var modes []entities.Mode
+	require.NoError(t, db.Preload("Attribute").Where("substance_id = ? AND inferred = ?", substanceID, true).Find(&modes).Error)
+	values := make(map[string]string, len(modes))
+	for _, mode := range modes {
+		require.NotNil(t, mode.RuleID)
+		values[mode.Attribute.Name] = mode.Value
+	}
+	return values
+}
+
+func createAttributes(t *testing.T, db *gorm.DB, names ...string) map[string]*entities.Attribute {
+	attributes := make(map[string]*entities.Attribute, len(names))
+	for _, name := range names {
+		attribute := entities.NewAttribute(name, "", "string")
+		require.NoError(t, db.Create(attribute).Error)
+		attributes[name] = attribute
+	}
+	return attributes
+}
+
+func TestConditionEvaluation(t *testing.T) {
+	facts := causality.NewSubstanceFacts("tree", "Oak")
+	facts.Add("age", "120")
+	facts.Add("health", "poor")
+
+	cases := []struct {
+		condition causality.Condition
+		met       bool
+	}{
+		{causality.Condition{Type: "kind", Value: "Oak"}, true},
+		{causality.Condition{Type: "kind", Operator: "!=", Value: "Oak"}, false},
+		{causality.Condition{Type: "attribute", Name: "age", Operator: ">", Value: 100}, true},
+		{causality.Condition{Type: "attribute", Name: "age", Operator: "<=", Value: "100"}, false},
+		{causality.Condition{Type: "attribute", Name: "age", Value: 120.0}, true},
+		{causality.Condition{Type: "mode", Name: "health", Value: "poor"}, true},
+		{causality.Condition{Type: "mode", Name: "health", Operator: ">", Value: "fair"}, false},
+		{causality.Condition{Type: "attribute", Name: "height", Operator: "!=", Value: "tall"}, false},
+		{causality.Condition{Type: "mode", Name: "health", Operator: "~", Value: "poor"}, false},
+	}
+	for _, tc := range cases {
+		met, reason := facts.Evaluate(tc.condition)
+		assert.Equal(t, tc.met, met, "%+v", tc.condition)
+		if !met {
+			assert.NotEmpty(t, reason)
+		}
+	}
+
+	_, err := causality.ParseConditions(`[{"type":"kind","value":"Oak"},{"type":"attribute","name":"age","operator":">","value":100}]`)
+	assert.NoError(t, err)
+	_, err = causality.ParseConditions(`[{"type":"colour","value":"green"}]`)
+	assert.Error(t, err)
+	_, err = causality.ParseConditions(`[{"type":"mode","name":"age","operator":"about","value":100}]`)
+	assert.Error(t, err)
+}
+
+func TestReasoner_KindRule(t *testing.T) {
+	db := setupTestDB(t)
+	reasoner := inference.NewReasoner(db)
+	attributes := createAttributes(t, db, "leaf_type")
+
+	oak := entities.NewSubstance("Old Oak", "Oak", "Tree")
+	birch := entities.NewSubstance("Silver Birch", "Birch", "Tree")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(birch).Error)
+
+	rule, result, err := reasoner.CreateRule(inference.RuleInput{
+		Name:        "oaks are broadleaved",
+		Conditions:  `[{"type":"kind","value":"Oak"}]`,
+		AttributeID: attributes["leaf_type"].ID,
+		Value:       "broad",
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Derived, 1)
+	assert.Equal(t, oak.ID, result.Derived[0].SubstanceID)
+	assert.Equal(t, rule.ID, *result.Derived[0].RuleID)
+	assert.True(t, result.Derived[0].Inferred)
+
+	assert.Equal(t, map[string]string{"leaf_type": "broad"}, inferredValues(t, db, oak.ID))
+	assert.Empty(t, inferredValues(t, db, birch.ID))
+
+	// Re-running changes nothing
+	result, err = reasoner.RefreshAll()
+	require.NoError(t, err)
+	assert.Empty(t, result.Derived)
+	assert.Empty(t, result.Retracted)
+
+	// Reclassifying the birch as an oak derives the mode incrementally
+	require.NoError(t, db.Model(birch).Update("kind", "Oak").Error)
+	result, err = reasoner.RefreshSubstance(birch.ID)
+	require.NoError(t, err)
+	assert.Len(t, result.Derived, 1)
+
+	derived, err := reasoner.DerivedModes(rule.ID)
+	require.NoError(t, err)
+	assert.Len(t, derived, 2)
+
+	// An asserted value already satisfies the rule, so nothing is derived
+	sapling := entities.NewSubstance("Sapling", "Oak", "Tree")
+	require.NoError(t, db.Create(sapling).Error)
+	require.NoError(t, db.Create(entities.NewMode("broad", sapling.ID, attributes["leaf_type"].ID)).Error)
+	result, err = reasoner.RefreshSubstance(sapling.ID)
+	require.NoError(t, err)
+	assert.Empty(t, result.Derived)
+}
+
+func TestReasoner_ChainingAndRetraction(t *testing.T) {
+	db := setupTestDB(t)
+	reasoner := inference.NewReasoner(db)
+	attributes := createAttributes(t, db, "health", "age", "risk", "action")
+
+	tree := entities.NewSubstance("Veteran Oak", "Oak", "Tree")
+	require.NoError(t, db.Create(tree).Error)
+	health := entities.NewMode("poor", tree.ID, attributes["health"].ID)
+	require.NoError(t, db.Create(health).Error)
+	require.NoError(t, db.Create(entities.NewMode("150", tree.ID, attributes["age"].ID)).Error)
+
+	// The second rule depends on the first; creating it first exercises chaining
+	_, _, err := reasoner.CreateRule(inference.RuleInput{
+		Name:        "inspect risky trees",
+		Conditions:  `[{"type":"mode","name":"risk","value":"high"}]`,
+		AttributeID: attributes["action"].ID,
+		Value:       "inspect",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, inferredValues(t, db, tree.ID))
+
+	_, result, err := reasoner.CreateRule(inference.RuleInput{
+		Name:        "old and sick trees are at risk",
+		Conditions:  `[{"type":"mode","name":"health","value":"poor"},{"type":"attribute","name":"age","operator":">","value":100}]`,
+		AttributeID: attributes["risk"].ID,
+		Value:       "high",
+	})
+	require.NoError(t, err)
+	assert.Len(t, result.Derived, 2)
+	assert.Equal(t, map[string]string{"risk": "high", "action": "inspect"}, inferredValues(t, db, tree.ID))
+
+	// Inferred modes count as facts for potentialities too
+	potentiality, err := causality.NewEngine(db).CreatePotentiality("Be felled", "", `[{"type":"mode","name":"action","value":"inspect"}]`, tree.ID)
+	require.NoError(t, err)
+	canActualize, _, err := causality.NewEngine(db).CheckConditions(potentiality.ID)
+	require.NoError(t, err)
+	assert.True(t, canActualize)
+
+	// Once the tree recovers, the whole chain is retracted
+	require.NoError(t, db.Model(health).Update("value", "good").Error)
+	result, err = reasoner.RefreshSubstance(tree.ID)
+	require.NoError(t, err)
+	assert.Len(t, result.Retracted, 2)
+	assert.Empty(t, inferredValues(t, db, tree.ID))
+}
+
+func TestReasoner_DeleteRuleRetracts(t *testing.T) {
+	db := setupTestDB(t)
+	reasoner := inference.NewReasoner(db)
+	attributes := createAttributes(t, db, "leaf_type")
+
+	oak := entities.NewSubstance("Old Oak", "Oak", "Tree")
+	require.NoError(t, db.Create(oak).Error)
+
+	rule, _, err := reasoner.CreateRule(inference.RuleInput{
+		Name:        "oaks are broadleaved",
+		Conditions:  `[{"type":"kind","value":"Oak"}]`,
+		AttributeID: attributes["leaf_type"].ID,
+		Value:       "broad",
+	})
+	require.NoError(t, err)
+
+	// A causal relation citing the derived mode, and a dependence on it, go with it
+	derived, err := reasoner.DerivedModes(rule.ID)
+	require.NoError(t, err)
+	require.Len(t, derived, 1)
+	_, err = causality.NewEngine(db).RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: "shade", FromType: entities.EntityTypeExternal, ToEntity: derived[0].ID, CauseType: "efficient",
+	})
+	require.NoError(t, err)
+	canopy := entities.NewSubstance("Canopy", "Foliage", "")
+	require.NoError(t, db.Create(canopy).Error)
+	_, err = dependence.NewTracker(db).Record(dependence.Input{
+		DependentID: canopy.ID, DependsOnID: derived[0].ID, Type: entities.DependenceExistential,
+	})
+	require.NoError(t, err)
+
+	result, err := reasoner.DeleteRule(rule.ID)
+	require.NoError(t, err)
+	assert.Len(t, result.Retracted, 1)
+	assert.Empty(t, inferredValues(t, db, oak.ID))
+
+	var relations, dependences int64
+	require.NoError(t, db.Model(&entities.CausalRelation{}).Count(&relations).Error)
+	assert.Zero(t, relations)
+	require.NoError(t, db.Model(&entities.Dependence{}).Count(&dependences).Error)
+	assert.Zero(t, dependences)
+
+	_, err = reasoner.DeleteRule(rule.ID)
+	assert.ErrorIs(t, err, inference.ErrRuleNotFound)
+
+	_, _, err = reasoner.CreateRule(inference.RuleInput{Name: "empty", Conditions: `[]`, AttributeID: attributes["leaf_type"].ID, Value: "broad"})
+	assert.ErrorIs(t, err, inference.ErrInvalidRule)
+	_, _, err = reasoner.CreateRule(inference.RuleInput{Name: "dangling", Conditions: `[{"type":"kind","value":"Oak"}]`, AttributeID: "missing", Value: "broad"})
+	assert.ErrorIs(t, err, inference.ErrInvalidRule)
+}
+
+func TestRulesAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	attributes := createAttributes(t, db, "leaf_type", "season")
+
+	post := func(url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/rules", map[string]string{
+		"name":         "oaks shed leaves in autumn",
+		"conditions":   `[{"type":"kind","value":"Oak"},{"type":"mode","name":"season","value":"autumn"}]`,
+		"attribute_id": attributes["leaf_type"].ID,
+		"value":        "falling",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Rule entities.Rule `json:"rule"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// Creating a substance and asserting a mode triggers inference
+	w = post("/api/v1/substances", map[string]string{"name": "Old Oak", "kind": "Oak", "essence": "Tree"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var oak entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &oak))
+
+	w = post("/api/v1/modes", map[string]string{"value": "autumn", "substance_id": oak.ID, "attribute_id": attributes["season"].ID})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, map[string]string{"leaf_type": "falling"}, inferredValues(t, db, oak.ID))
+
+	req, _ := http.NewRequest("GET", "/api/v1/rules/"+created.Rule.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail struct {
+		DerivedModes []entities.Mode `json:"derived_modes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	require.Len(t, detail.DerivedModes, 1)
+	assert.Equal(t, oak.ID, detail.DerivedModes[0].SubstanceID)
+
+	w = post("/api/v1/rules", map[string]string{
+		"name":         "broken",
+		"conditions":   `[{"type":"weather","value":"rain"}]`,
+		"attribute_id": attributes["leaf_type"].ID,
+		"value":        "wet",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = post("/api/v1/rules/run", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/api/v1/rules/"+created.Rule.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, inferredValues(t, db, oak.ID))
+
+	req, _ = http.NewRequest("GET", "/api/v1/rules/"+created.Rule.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
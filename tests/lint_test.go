@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/lint"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedInconsistencies stores one of each common inconsistency beside a consistent substance
+func seedInconsistencies(t *testing.T, db *gorm.DB) map[string]string {
+	require.NoError(t, db.Create(entities.NewKind("Tree", "")).Error)
+	attributes := createAttributes(t, db, "height")
+	oak := entities.NewSubstance("Oak", "Tree", "Tree")
+	stray := entities.NewSubstance("Stray", "Shrub", "Plant")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(stray).Error)
+	require.NoError(t, db.Create(entities.NewMode("tall", oak.ID, attributes["height"].ID)).Error)
+
+	orphanMode := entities.NewMode("blue", oak.ID, uuid.New().String())
+	require.NoError(t, db.Create(orphanMode).Error)
+
+	malformed := entities.NewPotentiality("Grow", "", `{"type":`, oak.ID)
+	empty := entities.NewPotentiality("Wither", "", "", oak.ID)
+	require.NoError(t, db.Create(malformed).Error)
+	require.NoError(t, db.Create(empty).Error)
+
+	dangling := entities.NewCausalRelation("efficient", oak.ID, uuid.New().String())
+	dangling.FromType = entities.EntityTypeSubstance
+	dangling.ToType = entities.EntityTypeSubstance
+	require.NoError(t, db.Create(dangling).Error)
+
+	return map[string]string{
+		"oak":       oak.ID,
+		"stray":     stray.ID,
+		"mode":      orphanMode.ID,
+		"malformed": malformed.ID,
+		"empty":     empty.ID,
+		"relation":  dangling.ID,
+	}
+}
+
+func issuesByCode(report *lint.Report) map[string]lint.Issue {
+	byCode := map[string]lint.Issue{}
+	for _, issue := range report.Issues {
+		byCode[issue.Code] = issue
+	}
+	return byCode
+}
+
+func TestLint_Run(t *testing.T) {
+	db := setupTestDB(t)
+	ids := seedInconsistencies(t, db)
+
+	report, err := lint.Run(db, lint.Options{})
+	require.NoError(t, err)
+	byCode := issuesByCode(report)
+
+	require.Contains(t, byCode, "mode.missing_attribute")
+	assert.Equal(t, lint.SeverityError, byCode["mode.missing_attribute"].Severity)
+	assert.Equal(t, ids["mode"], byCode["mode.missing_attribute"].Entities[0].ID)
+	require.Contains(t, byCode, "potentiality.malformed_conditions")
+	assert.Equal(t, ids["malformed"], byCode["potentiality.malformed_conditions"].Entities[0].ID)
+	assert.Empty(t, byCode["potentiality.malformed_conditions"].Repair, "malformed conditions need a human")
+	require.Contains(t, byCode, "potentiality.empty_conditions")
+	require.Contains(t, byCode, "causal_relation.missing_entity")
+	assert.Equal(t, ids["relation"], byCode["causal_relation.missing_entity"].Entities[0].ID)
+	require.Contains(t, byCode, "substance.unknown_kind")
+	assert.Equal(t, ids["stray"], byCode["substance.unknown_kind"].Entities[0].ID)
+	assert.Len(t, report.Issues, 5)
+	assert.Equal(t, 3, report.Counts[lint.SeverityError])
+	assert.Equal(t, 0, report.Repaired)
+
+	// Errors sort first
+	assert.Equal(t, lint.SeverityError, report.Issues[0].Severity)
+
+	report, err = lint.Run(db, lint.Options{MinSeverity: lint.SeverityError})
+	require.NoError(t, err)
+	assert.Len(t, report.Issues, 3)
+}
+
+func TestLint_Repair(t *testing.T) {
+	db := setupTestDB(t)
+	ids := seedInconsistencies(t, db)
+
+	report, err := lint.Run(db, lint.Options{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Repaired)
+	assert.False(t, issuesByCode(report)["potentiality.malformed_conditions"].Repaired)
+
+	var count int64
+	db.Model(&entities.Mode{}).Where("id = ?", ids["mode"]).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entities.CausalRelation{}).Where("id = ?", ids["relation"]).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entities.Kind{}).Where("name = ?", "Shrub").Count(&count)
+	assert.Equal(t, int64(1), count)
+	var empty entities.Potentiality
+	require.NoError(t, db.First(&empty, "id = ?", ids["empty"]).Error)
+	assert.Equal(t, "[]", empty.Conditions)
+
+	// Only the issue without a safe repair remains
+	report, err = lint.Run(db, lint.Options{})
+	require.NoError(t, err)
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, "potentiality.malformed_conditions", report.Issues[0].Code)
+}
+
+func TestLintAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	seedInconsistencies(t, db)
+
+	send := func(method, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("GET", "/api/v1/lint?severity=warning")
+	require.Equal(t, http.StatusOK, w.Code)
+	var report lint.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Len(t, report.Issues, 5)
+	assert.Equal(t, 3, report.Counts[lint.SeverityError])
+
+	w = send("GET", "/api/v1/lint?severity=fatal")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("POST", "/api/v1/lint/repair")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 4, report.Repaired)
+
+	w = send("GET", "/api/v1/lint")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Len(t, report.Issues, 1)
+}
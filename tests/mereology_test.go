@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/apodicticscott/oaas/internal/mereology"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// buildTree creates a tree with a branch bearing two leaves, plus a root
+func buildTree(t *testing.T, db *gorm.DB) map[string]*entities.Substance {
+	tree := map[string]*entities.Substance{
+		"tree":   entities.NewSubstance("Oak", "Tree", "Tree"),
+		"branch": entities.NewSubstance("Branch", "Branch", "Organ"),
+		"root":   entities.NewSubstance("Root", "Root", "Organ"),
+		"leaf1":  entities.NewSubstance("Leaf 1", "Leaf", "Organ"),
+		"leaf2":  entities.NewSubstance("Leaf 2", "Leaf", "Organ"),
+	}
+	for _, name := range []string{"tree", "branch", "root", "leaf1", "leaf2"} {
+		require.NoError(t, db.Create(tree[name]).Error)
+	}
+
+	parts := mereology.NewMereology(db)
+	for _, pair := range [][2]string{{"branch", "tree"}, {"root", "tree"}, {"leaf1", "branch"}, {"leaf2", "branch"}} {
+		_, err := parts.AddPart(tree[pair[0]].ID, tree[pair[1]].ID)
+		require.NoError(t, err)
+	}
+	return tree
+}
+
+func partIDs(nodes []mereology.PartNode) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.Substance.ID
+	}
+	return ids
+}
+
+func TestMereology_PartsAndWholes(t *testing.T) {
+	db := setupTestDB(t)
+	tree := buildTree(t, db)
+	parts := mereology.NewMereology(db)
+
+	direct, err := parts.Parts(tree["tree"].ID, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{tree["branch"].ID, tree["root"].ID}, partIDs(direct))
+
+	all, err := parts.Parts(tree["tree"].ID, true)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, 1, all[0].Depth)
+	assert.Equal(t, 2, all[3].Depth)
+	assert.Equal(t, tree["branch"].ID, all[3].Via)
+
+	wholes, err := parts.Wholes(tree["leaf1"].ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tree["branch"].ID, tree["tree"].ID}, partIDs(wholes))
+
+	_, err = parts.Parts("missing", false)
+	assert.ErrorIs(t, err, mereology.ErrSubstanceNotFound)
+}
+
+func TestMereology_PreventsCycles(t *testing.T) {
+	db := setupTestDB(t)
+	tree := buildTree(t, db)
+	parts := mereology.NewMereology(db)
+
+	_, err := parts.AddPart(tree["tree"].ID, tree["leaf1"].ID)
+	assert.ErrorIs(t, err, mereology.ErrParthoodCycle)
+	_, err = parts.AddPart(tree["leaf1"].ID, tree["leaf1"].ID)
+	assert.ErrorIs(t, err, mereology.ErrParthoodCycle)
+	_, err = parts.AddPart(tree["leaf1"].ID, tree["branch"].ID)
+	assert.ErrorIs(t, err, mereology.ErrDuplicateParthood)
+
+	// Redundant but acyclic parthood is allowed
+	_, err = parts.AddPart(tree["leaf1"].ID, tree["tree"].ID)
+	assert.NoError(t, err)
+
+	require.NoError(t, parts.RemovePart(tree["leaf1"].ID, tree["tree"].ID))
+	assert.ErrorIs(t, parts.RemovePart(tree["leaf1"].ID, tree["tree"].ID), mereology.ErrParthoodNotFound)
+}
+
+func TestConditions_QuantifyOverParts(t *testing.T) {
+	db := setupTestDB(t)
+	tree := buildTree(t, db)
+	attributes := createAttributes(t, db, "color", "height")
+	for name, color := range map[string]string{"leaf1": "green", "leaf2": "green", "branch": "brown"} {
+		require.NoError(t, db.Create(entities.NewMode(color, tree[name].ID, attributes["color"].ID)).Error)
+	}
+	require.NoError(t, db.Create(entities.NewMode("3", tree["leaf1"].ID, attributes["height"].ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("5", tree["leaf2"].ID, attributes["height"].ID)).Error)
+
+	facts, err := causality.NewEngine(db).LoadFacts(tree["tree"].ID)
+	require.NoError(t, err)
+	require.Len(t, facts.Parts, 4)
+
+	allLeavesGreen := causality.Condition{Type: "mode", Name: "color", Value: "green", Parts: "all", PartKind: "Leaf"}
+	met, reason := facts.Evaluate(allLeavesGreen)
+	assert.True(t, met, reason)
+
+	allPartsGreen := causality.Condition{Type: "mode", Name: "color", Value: "green", Parts: "all"}
+	met, reason = facts.Evaluate(allPartsGreen)
+	assert.False(t, met)
+	assert.Contains(t, reason, "do not meet")
+
+	met, _ = facts.Evaluate(causality.Condition{Type: "kind", Value: "Root", Parts: "any"})
+	assert.True(t, met)
+	met, _ = facts.Evaluate(causality.Condition{Type: "mode", Name: "color", Value: "red", Parts: "none"})
+	assert.True(t, met)
+	// "all" needs something to quantify over
+	met, _ = facts.Evaluate(causality.Condition{Type: "mode", Name: "color", Value: "green", Parts: "all", PartKind: "Flower"})
+	assert.False(t, met)
+
+	aggregate := facts.AggregateParts("height", "Leaf")
+	assert.Equal(t, 2, aggregate.Parts)
+	assert.Equal(t, map[string]int{"3": 1, "5": 1}, aggregate.Values)
+	require.NotNil(t, aggregate.Sum)
+	assert.Equal(t, 8.0, *aggregate.Sum)
+	assert.Equal(t, 4.0, *aggregate.Mean)
+	assert.Nil(t, facts.AggregateParts("color", "").Sum)
+
+	for _, raw := range []string{
+		`[{"type":"mode","name":"color","value":"green","parts":"most"}]`,
+		`[{"type":"external","name":"rain","parts":"all"}]`,
+		`[{"type":"mode","name":"color","value":"green","part_kind":"Leaf"}]`,
+	} {
+		_, err := causality.ParseConditions(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestReasoner_RulesOverParts(t *testing.T) {
+	db := setupTestDB(t)
+	tree := buildTree(t, db)
+	attributes := createAttributes(t, db, "color", "foliage")
+	reasoner := inference.NewReasoner(db)
+	_, _, err := reasoner.CreateRule(inference.RuleInput{
+		Name:        "green leaves make lush foliage",
+		Conditions:  `[{"type":"mode","name":"color","value":"green","parts":"all","part_kind":"Leaf"}]`,
+		AttributeID: attributes["foliage"].ID,
+		Value:       "lush",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, inferredValues(t, db, tree["tree"].ID))
+
+	// A change to a part re-evaluates its wholes
+	require.NoError(t, db.Create(entities.NewMode("green", tree["leaf1"].ID, attributes["color"].ID)).Error)
+	_, err = reasoner.RefreshSubstance(tree["leaf1"].ID)
+	require.NoError(t, err)
+	assert.Empty(t, inferredValues(t, db, tree["tree"].ID))
+
+	require.NoError(t, db.Create(entities.NewMode("green", tree["leaf2"].ID, attributes["color"].ID)).Error)
+	_, err = reasoner.RefreshSubstance(tree["leaf2"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foliage": "lush"}, inferredValues(t, db, tree["tree"].ID))
+	assert.Equal(t, map[string]string{"foliage": "lush"}, inferredValues(t, db, tree["branch"].ID))
+}
+
+func TestMereologyAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	cart := entities.NewSubstance("Cart", "Cart", "Vehicle")
+	wheel := entities.NewSubstance("Wheel", "Wheel", "Component")
+	spoke := entities.NewSubstance("Spoke", "Spoke", "Component")
+	for _, substance := range []*entities.Substance{cart, wheel, spoke} {
+		require.NoError(t, db.Create(substance).Error)
+	}
+
+	w := send("POST", "/api/v1/substances/"+cart.ID+"/parts", map[string]string{"part_id": wheel.ID})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/substances/"+wheel.ID+"/parts", map[string]string{"part_id": spoke.ID})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/substances/"+cart.ID+"/parts", map[string]string{"part_id": wheel.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("POST", "/api/v1/substances/"+spoke.ID+"/parts", map[string]string{"part_id": cart.ID})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", "/api/v1/substances/"+cart.ID+"/parts", map[string]string{"part_id": "missing"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var listed struct {
+		Parts  []mereology.PartNode `json:"parts"`
+		Wholes []mereology.PartNode `json:"wholes"`
+	}
+	w = send("GET", "/api/v1/substances/"+cart.ID+"/parts?transitive=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Equal(t, []string{wheel.ID, spoke.ID}, partIDs(listed.Parts))
+
+	w = send("GET", "/api/v1/substances/"+spoke.ID+"/wholes", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Equal(t, []string{wheel.ID}, partIDs(listed.Wholes))
+
+	w = send("GET", "/api/v1/substances/"+cart.ID+"/parts/aggregate?attribute=weight", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var aggregate causality.PartAggregate
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &aggregate))
+	assert.Equal(t, 2, aggregate.Parts)
+	w = send("GET", "/api/v1/substances/"+cart.ID+"/parts/aggregate", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("DELETE", "/api/v1/substances/"+wheel.ID+"/parts/"+spoke.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = send("DELETE", "/api/v1/substances/"+wheel.ID+"/parts/"+spoke.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Deleting a substance removes its parthoods
+	w = send("DELETE", "/api/v1/substances/"+wheel.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var count int64
+	db.Model(&entities.Parthood{}).Count(&count)
+	assert.Zero(t, count)
+}
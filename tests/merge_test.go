@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/merge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge_MovesEverything(t *testing.T) {
+	db := setupTestDB(t)
+	socrates := entities.NewSubstance("Socrates", "Human", "")
+	sokrates := entities.NewSubstance("Sokrates", "", "Rational animal")
+	athens := entities.NewSubstance("Athens", "City", "")
+	hand := entities.NewSubstance("Hand", "Organ", "")
+	for _, substance := range []*entities.Substance{socrates, sokrates, athens, hand} {
+		require.NoError(t, db.Create(substance).Error)
+	}
+	attributes := createAttributes(t, db, "virtue", "trade")
+	require.NoError(t, db.Create(entities.NewMode("courage", socrates.ID, attributes["virtue"].ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("courage", sokrates.ID, attributes["virtue"].ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("stonemason", sokrates.ID, attributes["trade"].ID)).Error)
+	potentiality := entities.NewPotentiality("teach", "", "", sokrates.ID)
+	require.NoError(t, db.Create(potentiality).Error)
+	require.NoError(t, db.Create(entities.NewCausalRelation("material", athens.ID, sokrates.ID)).Error)
+	require.NoError(t, db.Create(entities.NewParthood(hand.ID, sokrates.ID)).Error)
+
+	result, err := merge.NewMerger(db).Merge(socrates.ID, sokrates.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Summary.Modes)
+	assert.Equal(t, 1, result.Summary.DroppedModes)
+	assert.Equal(t, 1, result.Summary.Potentialities)
+	assert.Equal(t, 1, result.Summary.CausalRelations)
+	assert.Equal(t, 1, result.Summary.Parthoods)
+	assert.Equal(t, "Rational animal", result.Survivor.Essence)
+	assert.Equal(t, merge.StrategySurvivor, result.Merge.Strategy)
+
+	var modes, gone int64
+	db.Model(&entities.Mode{}).Where("substance_id = ?", socrates.ID).Count(&modes)
+	db.Model(&entities.Substance{}).Where("id = ?", sokrates.ID).Count(&gone)
+	assert.Equal(t, int64(2), modes)
+	assert.Zero(t, gone)
+	var moved entities.Potentiality
+	require.NoError(t, db.First(&moved, "id = ?", potentiality.ID).Error)
+	assert.Equal(t, socrates.ID, moved.SubstanceID)
+	var relation entities.CausalRelation
+	require.NoError(t, db.First(&relation).Error)
+	assert.Equal(t, socrates.ID, relation.ToEntity)
+	var parthood entities.Parthood
+	require.NoError(t, db.First(&parthood).Error)
+	assert.Equal(t, socrates.ID, parthood.WholeID)
+
+	resolved, err := merge.NewMerger(db).Resolve(sokrates.ID)
+	require.NoError(t, err)
+	assert.Equal(t, socrates.ID, resolved)
+	_, err = merge.NewMerger(db).Resolve("missing")
+	assert.ErrorIs(t, err, merge.ErrSubstanceNotFound)
+}
+
+func TestMerge_Strategies(t *testing.T) {
+	for strategy, kept := range map[string]string{
+		merge.StrategySurvivor:  "white",
+		merge.StrategyDuplicate: "black",
+		merge.StrategyNewest:    "black",
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			db := setupTestDB(t)
+			swan := entities.NewSubstance("Swan", "Bird", "")
+			duplicate := entities.NewSubstance("Swan (2)", "Bird", "")
+			require.NoError(t, db.Create(swan).Error)
+			require.NoError(t, db.Create(duplicate).Error)
+			color := entities.NewAttribute("color", "", "string")
+			color.Cardinality = entities.CardinalitySingle
+			require.NoError(t, db.Create(color).Error)
+			white := entities.NewMode("white", swan.ID, color.ID)
+			black := entities.NewMode("black", duplicate.ID, color.ID)
+			black.CreatedAt = white.CreatedAt.Add(time.Minute)
+			require.NoError(t, db.Create(white).Error)
+			require.NoError(t, db.Create(black).Error)
+
+			result, err := merge.NewMerger(db).Merge(swan.ID, duplicate.ID, strategy)
+			require.NoError(t, err)
+			require.Len(t, result.Summary.Conflicts, 1)
+			assert.Equal(t, "white", result.Summary.Conflicts[0].SurvivorValue)
+
+			var modes []entities.Mode
+			require.NoError(t, db.Where("substance_id = ?", swan.ID).Find(&modes).Error)
+			require.Len(t, modes, 1)
+			assert.Equal(t, kept, modes[0].Value)
+		})
+	}
+}
+
+func TestMerge_Rejected(t *testing.T) {
+	db := setupTestDB(t)
+	swan := entities.NewSubstance("Swan", "Bird", "")
+	duplicate := entities.NewSubstance("Swan (2)", "Bird", "")
+	require.NoError(t, db.Create(swan).Error)
+	require.NoError(t, db.Create(duplicate).Error)
+	color := entities.NewAttribute("color", "", "string")
+	color.Cardinality = entities.CardinalitySingle
+	require.NoError(t, db.Create(color).Error)
+	require.NoError(t, db.Create(entities.NewMode("white", swan.ID, color.ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("black", duplicate.ID, color.ID)).Error)
+	merger := merge.NewMerger(db)
+
+	result, err := merger.Merge(swan.ID, duplicate.ID, merge.StrategyReject)
+	assert.ErrorIs(t, err, merge.ErrMergeConflict)
+	require.Len(t, result.Summary.Conflicts, 1)
+	assert.Equal(t, "black", result.Summary.Conflicts[0].DuplicateValue)
+	assert.Empty(t, result.Summary.Conflicts[0].Kept)
+
+	_, err = merger.Merge(swan.ID, swan.ID, "")
+	assert.ErrorIs(t, err, merge.ErrInvalidMerge)
+	_, err = merger.Merge(swan.ID, duplicate.ID, "coin")
+	assert.ErrorIs(t, err, merge.ErrInvalidMerge)
+	_, err = merger.Merge(swan.ID, "missing", "")
+	assert.ErrorIs(t, err, merge.ErrSubstanceNotFound)
+
+	// A part of one cannot become a whole of the other's survivor
+	hand := entities.NewSubstance("Hand", "Organ", "")
+	require.NoError(t, db.Create(hand).Error)
+	require.NoError(t, db.Create(entities.NewParthood(hand.ID, swan.ID)).Error)
+	require.NoError(t, db.Create(entities.NewParthood(swan.ID, duplicate.ID)).Error)
+	require.NoError(t, db.Create(entities.NewParthood(duplicate.ID, hand.ID)).Error)
+	_, err = merger.Merge(swan.ID, duplicate.ID, merge.StrategySurvivor)
+	assert.ErrorIs(t, err, merge.ErrInvalidMerge)
+}
+
+func TestMerge_RedirectChain(t *testing.T) {
+	db := setupTestDB(t)
+	a := entities.NewSubstance("A", "Thing", "")
+	b := entities.NewSubstance("B", "Thing", "")
+	c := entities.NewSubstance("C", "Thing", "")
+	for _, substance := range []*entities.Substance{a, b, c} {
+		require.NoError(t, db.Create(substance).Error)
+	}
+	merger := merge.NewMerger(db)
+
+	_, err := merger.Merge(b.ID, c.ID, "")
+	require.NoError(t, err)
+	// Merging into an old ID merges into its survivor
+	result, err := merger.Merge(c.ID, a.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, b.ID, result.Survivor.ID)
+	_, err = merger.Merge(b.ID, a.ID, "")
+	assert.ErrorIs(t, err, merge.ErrSubstanceNotFound) // already merged
+
+	d := entities.NewSubstance("D", "Thing", "")
+	require.NoError(t, db.Create(d).Error)
+	result, err = merger.Merge(d.ID, b.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Summary.Redirects)
+	for _, id := range []string{a.ID, b.ID, c.ID} {
+		resolved, err := merger.Resolve(id)
+		require.NoError(t, err)
+		assert.Equal(t, d.ID, resolved)
+	}
+
+	history, err := merger.History(b.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 3)
+}
+
+func TestMergeAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	swan := entities.NewSubstance("Swan", "Bird", "")
+	duplicate := entities.NewSubstance("Swan (2)", "Bird", "")
+	require.NoError(t, db.Create(swan).Error)
+	require.NoError(t, db.Create(duplicate).Error)
+	color := entities.NewAttribute("color", "", "string")
+	color.Cardinality = entities.CardinalitySingle
+	require.NoError(t, db.Create(color).Error)
+	require.NoError(t, db.Create(entities.NewMode("white", swan.ID, color.ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("black", duplicate.ID, color.ID)).Error)
+
+	url := "/api/v1/substances/" + swan.ID + "/merge"
+	w := send("POST", url, map[string]string{"duplicate_id": duplicate.ID, "strategy": "reject"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "black")
+	w = send("POST", url, map[string]string{"duplicate_id": duplicate.ID, "strategy": "coin"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("POST", url, map[string]string{"duplicate_id": swan.ID})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", url, map[string]string{"duplicate_id": "missing"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = send("POST", url, map[string]string{"duplicate_id": duplicate.ID, "strategy": "duplicate"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var merged struct {
+		Merge   entities.SubstanceMerge `json:"merge"`
+		Summary merge.Summary           `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &merged))
+	assert.Equal(t, merge.StrategyDuplicate, merged.Summary.Conflicts[0].Kept)
+
+	// The old ID still resolves, to the survivor
+	w = send("GET", "/api/v1/substances/"+duplicate.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/v1/substances/"+swan.ID, w.Header().Get("Content-Location"))
+	var substance entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &substance))
+	assert.Equal(t, swan.ID, substance.ID)
+	w = send("GET", "/api/v1/substances/missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = send("GET", "/api/v1/substances/"+duplicate.ID+"/merges", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var history struct {
+		Merges []entities.SubstanceMerge `json:"merges"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Merges, 1)
+	assert.Equal(t, merged.Merge.ID, history.Merges[0].ID)
+	assert.Equal(t, "Swan (2)", history.Merges[0].DuplicateName)
+
+	// Deleting the survivor takes the redirect with it
+	w = send("DELETE", "/api/v1/substances/"+swan.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/api/v1/substances/"+duplicate.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
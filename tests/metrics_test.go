@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape returns the value of a series at /metrics, zero if it has not been
+// written yet
+func scrape(t *testing.T, series string) float64 {
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			require.NoError(t, err)
+			return v
+		}
+	}
+	return 0
+}
+
+func TestEngineMetrics(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	substance := entities.NewSubstance("Tree-001", "Oak", "Living organism")
+	require.NoError(t, db.Create(substance).Error)
+	attribute := entities.NewAttribute("color", "Visual property", "string")
+	require.NoError(t, db.Create(attribute).Error)
+	require.NoError(t, db.Create(entities.NewMode("green", substance.ID, attribute.ID)).Error)
+	met := entities.NewPotentiality("Grow Leaves", "", `[{"type":"mode","name":"color","value":"green"}]`, substance.ID)
+	unmet := entities.NewPotentiality("Bloom", "", `[{"type":"mode","name":"color","value":"pink"}]`, substance.ID)
+	require.NoError(t, db.Create(met).Error)
+	require.NoError(t, db.Create(unmet).Error)
+
+	checkedMet := scrape(t, `oaas_condition_checks_total{result="met"}`)
+	checkedUnmet := scrape(t, `oaas_condition_checks_total{result="unmet"}`)
+	succeeded := scrape(t, `oaas_actualizations_total{result="succeeded"}`)
+	refused := scrape(t, `oaas_actualizations_total{result="unmet"}`)
+	efficient := scrape(t, `oaas_causal_relations_created_total{cause_type="efficient"}`)
+
+	_, err := engine.ActualizePotentiality(met.ID, "Leaves grew")
+	require.NoError(t, err)
+	_, err = engine.ActualizePotentiality(unmet.ID, "Bloomed")
+	require.Error(t, err)
+	_, err = relateConcepts(engine, "substance-1", "cause-entity", "efficient")
+	require.NoError(t, err)
+	_, err = relateConcepts(engine, "substance-1", "cause-entity", "invalid")
+	require.Error(t, err)
+
+	assert.Equal(t, checkedMet+1, scrape(t, `oaas_condition_checks_total{result="met"}`))
+	assert.Equal(t, checkedUnmet+1, scrape(t, `oaas_condition_checks_total{result="unmet"}`))
+	assert.Equal(t, succeeded+1, scrape(t, `oaas_actualizations_total{result="succeeded"}`))
+	assert.Equal(t, refused+1, scrape(t, `oaas_actualizations_total{result="unmet"}`))
+	assert.Equal(t, efficient+1, scrape(t, `oaas_causal_relations_created_total{cause_type="efficient"}`))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router, db := setupTestAPI(t)
+	require.NoError(t, db.Create(entities.NewSubstance("Tree-001", "Oak", "")).Error)
+
+	for _, url := range []string{"/api/v1/substances", "/api/v1/substances/missing", "/nowhere"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+
+	body := w.Body.String()
+	assert.Contains(t, body, `oaas_http_request_duration_seconds_count{method="GET",route="/api/v1/substances",status="200"}`)
+	assert.Contains(t, body, `oaas_http_request_duration_seconds_count{method="GET",route="/api/v1/substances/:id",status="404"}`)
+	assert.Contains(t, body, `oaas_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"}`)
+	assert.Contains(t, body, `oaas_db_query_duration_seconds_count{operation="query",table="substances"}`)
+	assert.Contains(t, body, `oaas_db_query_duration_seconds_count{operation="create",table="substances"}`)
+	assert.Contains(t, body, "# TYPE oaas_http_requests_in_flight gauge")
+	assert.Contains(t, body, "# TYPE go_goroutines gauge")
+	assert.Contains(t, body, "# TYPE process_cpu_seconds_total counter")
+}
@@ -26,6 +26,8 @@ func TestCompleteNeoAristotelianFlow(t *testing.T) {
 		&entities.CausalRelation{},
 		&entities.Potentiality{},
 		&entities.Actuality{},
+		&entities.Parthood{},
+		&entities.ModeBearer{},
 	)
 	require.NoError(t, err)
 	
@@ -57,19 +59,19 @@ func TestCompleteNeoAristotelianFlow(t *testing.T) {
 	
 	// Step 5: Add the Four Aristotelian Causes
 	// Material Cause: What Socrates is made of
-	_, err = engine.AddCausalRelation(substance.ID, "flesh_bone_soul", "material")
+	_, err = causeOutside(engine, substance.ID, "flesh_bone_soul", "material")
 	require.NoError(t, err)
 	
 	// Formal Cause: The essence of being human
-	_, err = engine.AddCausalRelation(substance.ID, "human_essence", "formal")
+	_, err = causeOutside(engine, substance.ID, "human_essence", "formal")
 	require.NoError(t, err)
 	
 	// Efficient Cause: What brought Socrates about
-	_, err = engine.AddCausalRelation(substance.ID, "philosophical_education", "efficient")
+	_, err = causeOutside(engine, substance.ID, "philosophical_education", "efficient")
 	require.NoError(t, err)
 	
 	// Final Cause: Socrates' purpose
-	_, err = engine.AddCausalRelation(substance.ID, "pursuit_of_wisdom", "final")
+	_, err = causeOutside(engine, substance.ID, "pursuit_of_wisdom", "final")
 	require.NoError(t, err)
 	
 	// Step 6: Create a Potentiality
@@ -100,10 +102,10 @@ func TestCompleteNeoAristotelianFlow(t *testing.T) {
 	// Step 9: Verify the Four Causes
 	causes, err := engine.GetFourCauses(substance.ID)
 	require.NoError(t, err)
-	assert.Equal(t, "flesh_bone_soul", causes["material"])
-	assert.Equal(t, "human_essence", causes["formal"])
-	assert.Equal(t, "philosophical_education", causes["efficient"])
-	assert.Equal(t, "pursuit_of_wisdom", causes["final"])
+	assert.Equal(t, "flesh_bone_soul", causes.Cause("material"))
+	assert.Equal(t, "human_essence", causes.Cause("formal"))
+	assert.Equal(t, "philosophical_education", causes.Cause("efficient"))
+	assert.Equal(t, "pursuit_of_wisdom", causes.Cause("final"))
 	
 	// Step 10: Get the Complete Evolution
 	evolution, err := engine.GetSubstanceEvolution(substance.ID)
@@ -139,6 +141,8 @@ func TestPhilosophicalCorrectness(t *testing.T) {
 		&entities.CausalRelation{},
 		&entities.Potentiality{},
 		&entities.Actuality{},
+		&entities.Parthood{},
+		&entities.ModeBearer{},
 	)
 	require.NoError(t, err)
 	
@@ -171,7 +175,7 @@ func TestPhilosophicalCorrectness(t *testing.T) {
 	
 	validCauseTypes := []string{"material", "formal", "efficient", "final"}
 	for _, causeType := range validCauseTypes {
-		_, err := engine.AddCausalRelation(substance.ID, "cause-entity", causeType)
+		_, err := causeOutside(engine, substance.ID, "cause-entity", causeType)
 		assert.NoError(t, err, "Should accept valid cause type: %s", causeType)
 	}
 	
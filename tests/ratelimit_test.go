@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/auth"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Rate: 2, Burst: 3})
+	limiter.Now = func() time.Time { return now }
+
+	for i := 2; i >= 0; i-- {
+		decision := limiter.Allow("key:a")
+		require.True(t, decision.Allowed)
+		assert.Equal(t, 3, decision.Limit)
+		assert.Equal(t, i, decision.Remaining)
+	}
+	decision := limiter.Allow("key:a")
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, decision.Reset)
+
+	// Clients have buckets of their own
+	assert.True(t, limiter.Allow("key:b").Allowed)
+
+	// Tokens come back at the rate, up to the burst
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.Allow("key:a").Allowed)
+	assert.False(t, limiter.Allow("key:a").Allowed)
+	now = now.Add(time.Hour)
+	assert.Equal(t, 2, limiter.Allow("key:a").Remaining)
+
+	assert.Nil(t, ratelimit.NewLimiter(ratelimit.Limit{}), "a zero rate limits nothing")
+}
+
+func TestQuotas_Daily(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 1, 1, 18, 0, 0, 0, time.UTC)
+	quotas := ratelimit.NewQuotas(db, 2)
+	quotas.Now = func() time.Time { return now }
+
+	usage, err := quotas.Use("key:a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Remaining())
+	assert.Equal(t, 6*time.Hour, usage.Reset)
+	_, err = quotas.Use("key:a")
+	require.NoError(t, err)
+	usage, err = quotas.Use("key:a")
+	assert.ErrorIs(t, err, ratelimit.ErrQuotaExceeded)
+	assert.Zero(t, usage.Remaining())
+	_, err = quotas.Use("key:b")
+	assert.NoError(t, err)
+
+	// The quota renews at midnight UTC, and each day is kept
+	now = now.Add(7 * time.Hour)
+	_, err = quotas.Use("key:a")
+	assert.NoError(t, err)
+	var days []entities.QuotaUsage
+	require.NoError(t, db.Where("client = ?", "key:a").Order("day").Find(&days).Error)
+	require.Len(t, days, 2)
+	assert.Equal(t, "2026-01-01", days[0].Day)
+	assert.Equal(t, int64(3), days[0].Requests)
+	assert.Equal(t, int64(1), days[1].Requests)
+}
+
+func TestRateLimitAPI(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	router, db := setupTestAPIWith(t, func(h *api.Handler) {
+		h.Auth = auth.NewAuthenticator(h.Store.DB(), nil)
+		h.Auth.AnonymousReads = true
+		h.RateLimits = &ratelimit.Policy{
+			Addresses: ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.1, Burst: 9}),
+			Requests:  ratelimit.NewLimiter(ratelimit.Limit{Rate: 1, Burst: 3}),
+			Expensive: ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.1, Burst: 1}),
+			Quotas:    ratelimit.NewQuotas(h.Store.DB(), 4),
+		}
+		h.RateLimits.Addresses.Now = clock
+		h.RateLimits.Requests.Now = clock
+		h.RateLimits.Expensive.Now = clock
+	})
+	_, key, err := auth.NewKeys(db).Issue("script", false, nil, nil)
+	require.NoError(t, err)
+	send := func(url, credential string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		if credential != "" {
+			req.Header.Set(auth.APIKeyHeader, credential)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/api/v1/kinds", key)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "4", w.Header().Get(api.QuotaLimitHeader))
+	assert.Equal(t, "3", w.Header().Get(api.QuotaRemainingHeader))
+
+	// Expensive routes have a bucket of their own
+	w = send("/api/v1/substances", key)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	w = send("/api/v1/substances", key)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	// A request the bucket refuses does not count against the quota
+	w = send("/api/v1/kinds", key)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "the expensive requests also used the general bucket")
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Empty(t, w.Header().Get(api.QuotaRemainingHeader))
+
+	// Requests without credentials count against their address
+	w = send("/api/v1/kinds", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	now = now.Add(time.Second)
+	w = send("/api/v1/kinds", key)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get(api.QuotaRemainingHeader))
+
+	// Past the daily quota every request is refused, whatever the bucket holds
+	now = now.Add(time.Second)
+	w = send("/api/v1/kinds", key)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(api.QuotaRemainingHeader))
+	assert.Contains(t, w.Body.String(), "daily quota exceeded")
+
+	// Bad keys are limited by address before they are checked
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, send("/api/v1/kinds", "oaas_invalid").Code)
+	}
+	w = send("/api/v1/kinds", "oaas_invalid")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = send("/health", key)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/dependence"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/inference"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createRelationalAttribute creates an attribute whose modes are borne by one substance per role
+func createRelationalAttribute(t *testing.T, db *gorm.DB, name string, roles ...string) *entities.Attribute {
+	attribute := entities.NewAttribute(name, "", "string")
+	encoded, err := json.Marshal(roles)
+	require.NoError(t, err)
+	attribute.Roles = string(encoded)
+	require.NoError(t, db.Create(attribute).Error)
+	return attribute
+}
+
+func TestRelations_Assert(t *testing.T) {
+	db := setupTestDB(t)
+	socrates := entities.NewSubstance("Socrates", "Human", "")
+	plato := entities.NewSubstance("Plato", "Human", "")
+	require.NoError(t, db.Create(socrates).Error)
+	require.NoError(t, db.Create(plato).Error)
+	taller := createRelationalAttribute(t, db, "taller_than", "taller", "shorter")
+	color := createAttributes(t, db, "color")["color"]
+	engine := causality.NewEngine(db)
+
+	// Named roles may come in any order
+	mode, err := engine.AssertRelation(causality.RelationInput{
+		AttributeID: taller.ID,
+		Value:       "10cm",
+		Bearers:     []causality.BearerInput{{SubstanceID: socrates.ID, Role: "shorter"}, {SubstanceID: plato.ID, Role: "taller"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, plato.ID, mode.SubstanceID)
+	require.Len(t, mode.Bearers, 2)
+	assert.Equal(t, "shorter", mode.Bearers[1].Role)
+	assert.Equal(t, socrates.ID, mode.Bearers[1].SubstanceID)
+
+	positional := causality.RelationInput{AttributeID: taller.ID, Value: "10cm", Bearers: []causality.BearerInput{{SubstanceID: plato.ID}, {SubstanceID: socrates.ID}}}
+	_, err = engine.AssertRelation(positional)
+	assert.ErrorIs(t, err, causality.ErrDuplicateRelation)
+
+	for _, input := range []causality.RelationInput{
+		{AttributeID: color.ID, Bearers: []causality.BearerInput{{SubstanceID: plato.ID}, {SubstanceID: socrates.ID}}},
+		{AttributeID: taller.ID, Bearers: []causality.BearerInput{{SubstanceID: plato.ID}}},
+		{AttributeID: taller.ID, Bearers: []causality.BearerInput{{SubstanceID: plato.ID, Role: "taller"}, {SubstanceID: socrates.ID}}},
+		{AttributeID: taller.ID, Bearers: []causality.BearerInput{{SubstanceID: plato.ID, Role: "taller"}, {SubstanceID: socrates.ID, Role: "wiser"}}},
+		{AttributeID: taller.ID, Bearers: []causality.BearerInput{{SubstanceID: plato.ID, Role: "taller"}, {SubstanceID: socrates.ID, Role: "taller"}}},
+	} {
+		_, err := engine.AssertRelation(input)
+		assert.ErrorIs(t, err, causality.ErrInvalidRelation)
+	}
+	_, err = engine.AssertRelation(causality.RelationInput{AttributeID: taller.ID, Bearers: []causality.BearerInput{{SubstanceID: plato.ID}, {SubstanceID: "missing"}}})
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+
+	// A relational attribute has no monadic modes
+	err = engine.AssertMode(entities.NewMode("yes", plato.ID, taller.ID))
+	assert.ErrorIs(t, err, causality.ErrInvalidRelation)
+
+	facts, err := engine.LoadFacts(plato.ID)
+	require.NoError(t, err)
+	assert.Empty(t, facts.Values)
+	require.Len(t, facts.Relations, 1)
+	assert.Equal(t, "taller", facts.Relations[0].Role)
+
+	shorter, err := engine.ListRelations(causality.RelationFilter{SubstanceID: socrates.ID, Role: "shorter"})
+	require.NoError(t, err)
+	require.Len(t, shorter, 1)
+	assert.Equal(t, "Plato", shorter[0].Bearers[0].Substance.Name)
+	none, err := engine.ListRelations(causality.RelationFilter{SubstanceID: socrates.ID, Role: "taller"})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestConditions_Relations(t *testing.T) {
+	facts := causality.NewSubstanceFacts("oak", "Oak")
+	facts.Relations = []causality.RelationFact{{
+		Attribute: "shades",
+		Value:     "3",
+		Role:      "shader",
+		Position:  0,
+		Bearers: []causality.RelatedBearer{
+			{Role: "shader", SubstanceID: "oak", Kind: "Oak"},
+			{Role: "shaded", SubstanceID: "pine", Kind: "Pine"},
+		},
+	}}
+
+	for _, condition := range []causality.Condition{
+		{Type: "relation", Name: "shades"},
+		{Type: "relation", Name: "shades", Role: "shader", Related: "pine"},
+		{Type: "relation", Name: "shades", RelatedKind: "Pine", RelatedRole: "shaded"},
+		{Type: "relation", Name: "shades", Operator: ">", Value: 2},
+	} {
+		met, reason := facts.Evaluate(condition)
+		assert.True(t, met, reason)
+	}
+	for _, condition := range []causality.Condition{
+		{Type: "relation", Name: "shades", Role: "shaded"},
+		{Type: "relation", Name: "shades", Related: "oak"}, // a substance is not its own relatum
+		{Type: "relation", Name: "shades", RelatedKind: "Birch"},
+		{Type: "relation", Name: "shades", Operator: ">", Value: 5},
+		{Type: "relation", Name: "taller_than"},
+	} {
+		met, reason := facts.Evaluate(condition)
+		assert.False(t, met)
+		assert.Contains(t, reason, "relation condition not met")
+	}
+
+	for _, raw := range []string{
+		`[{"type":"relation"}]`,
+		`[{"type":"mode","name":"color","value":"green","related_kind":"Pine"}]`,
+	} {
+		_, err := causality.ParseConditions(raw)
+		assert.Error(t, err, raw)
+	}
+	_, err := causality.ParseConditions(`[{"type":"relation","name":"shades","role":"shaded","related_kind":"Oak","parts":"any"}]`)
+	assert.NoError(t, err)
+}
+
+func TestReasoner_RulesOverRelations(t *testing.T) {
+	db := setupTestDB(t)
+	oak := entities.NewSubstance("Oak", "Oak", "")
+	pine := entities.NewSubstance("Pine", "Pine", "")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(pine).Error)
+	shades := createRelationalAttribute(t, db, "shades", "shader", "shaded")
+	light := createAttributes(t, db, "light")["light"]
+	reasoner := inference.NewReasoner(db)
+
+	_, _, err := reasoner.CreateRule(inference.RuleInput{
+		Name:        "shaded by an oak",
+		Conditions:  `[{"type":"relation","name":"shades","role":"shaded","related_kind":"Oak"}]`,
+		AttributeID: light.ID,
+		Value:       "low",
+	})
+	require.NoError(t, err)
+	_, _, err = reasoner.CreateRule(inference.RuleInput{
+		Name:        "relations are not derived",
+		Conditions:  `[{"type":"kind","value":"Oak"}]`,
+		AttributeID: shades.ID,
+		Value:       "1",
+	})
+	assert.ErrorIs(t, err, inference.ErrInvalidRule)
+
+	engine := causality.NewEngine(db)
+	mode, err := engine.AssertRelation(causality.RelationInput{AttributeID: shades.ID, Bearers: []causality.BearerInput{{SubstanceID: oak.ID}, {SubstanceID: pine.ID}}})
+	require.NoError(t, err)
+	// Refreshing one bearer refreshes the others
+	_, err = reasoner.RefreshSubstance(mode.SubstanceID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"light": "low"}, inferredValues(t, db, pine.ID))
+	assert.Empty(t, inferredValues(t, db, oak.ID))
+
+	// The relation goes with either bearer, and what was derived from it is retracted
+	tracker := dependence.NewTracker(db)
+	plan, err := tracker.PlanDeletion(entities.EntityTypeSubstance, oak.ID, dependence.PolicyBlock)
+	require.NoError(t, err)
+	affected, err := tracker.Execute(plan)
+	require.NoError(t, err)
+	assert.Equal(t, []string{pine.ID}, affected)
+	for _, id := range affected {
+		_, err := reasoner.RefreshSubstance(id)
+		require.NoError(t, err)
+	}
+	assert.Empty(t, inferredValues(t, db, pine.ID))
+	var bearers int64
+	db.Model(&entities.ModeBearer{}).Count(&bearers)
+	assert.Zero(t, bearers)
+}
+
+func TestRelationsAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	socrates := entities.NewSubstance("Socrates", "Human", "")
+	plato := entities.NewSubstance("Plato", "Human", "")
+	require.NoError(t, db.Create(socrates).Error)
+	require.NoError(t, db.Create(plato).Error)
+
+	w := send("POST", "/api/v1/attributes", map[string]interface{}{"name": "taller_than", "data_type": "string", "roles": []string{"taller", "shorter"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var attribute entities.Attribute
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &attribute))
+	assert.True(t, attribute.IsRelational())
+
+	w = send("POST", "/api/v1/attributes", map[string]interface{}{"name": "alone", "data_type": "string", "roles": []string{"only"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("POST", "/api/v1/attributes", map[string]interface{}{"name": "twice", "data_type": "string", "roles": []string{"a", "a"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("PUT", "/api/v1/attributes/"+attribute.ID+"/cardinality", map[string]string{"cardinality": "single"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	relation := map[string]interface{}{
+		"attribute_id": attribute.ID,
+		"bearers":      []map[string]string{{"substance_id": plato.ID}, {"substance_id": socrates.ID}},
+	}
+	w = send("POST", "/api/v1/relations", relation)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/relations", relation)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("POST", "/api/v1/relations", map[string]interface{}{
+		"attribute_id": attribute.ID,
+		"bearers":      []map[string]string{{"substance_id": plato.ID}, {"substance_id": "missing"}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", "/api/v1/modes", map[string]string{"value": "yes", "substance_id": plato.ID, "attribute_id": attribute.ID})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var listed struct {
+		Relations []entities.Mode `json:"relations"`
+	}
+	w = send("GET", "/api/v1/relations?substance_id="+socrates.ID+"&role=shorter", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Relations, 1)
+	require.Len(t, listed.Relations[0].Bearers, 2)
+	assert.Equal(t, plato.ID, listed.Relations[0].Bearers[0].SubstanceID)
+
+	w = send("GET", "/api/v1/relations?role=shorter", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
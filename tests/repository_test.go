@@ -0,0 +1,358 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/repository"
+	"github.com/apodicticscott/oaas/internal/workspace"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stores returns a fresh store of each implementation
+func stores(t *testing.T) map[string]*repository.Store {
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(workspace.Plugin{}))
+	return map[string]*repository.Store{
+		"gorm":   repository.NewGorm(db),
+		"memory": repository.NewMemory(),
+	}
+}
+
+func TestRepository_Substances(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			oak := entities.NewSubstance("Oak", "Tree", "Quercus")
+			acorn := entities.NewSubstance("Acorn", "Seed", "")
+			acorn.CreatedAt = oak.CreatedAt.Add(time.Second)
+			require.NoError(t, store.Substances.Create(ctx, oak))
+			require.NoError(t, store.Substances.Create(ctx, acorn))
+			assert.Equal(t, entities.DefaultWorkspace, oak.WorkspaceID)
+			assert.ErrorIs(t, store.Substances.Create(ctx, oak), repository.ErrDuplicate)
+
+			height := entities.NewAttribute("height", "", "number")
+			require.NoError(t, store.Attributes.Create(ctx, height))
+			require.NoError(t, store.Modes.Create(ctx, entities.NewMode("20", oak.ID, height.ID)))
+
+			substances, err := store.Substances.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, substances, 2)
+			assert.Equal(t, "Oak", substances[0].Name)
+			assert.Equal(t, "Acorn", substances[1].Name)
+
+			got, err := store.Substances.Get(ctx, oak.ID)
+			require.NoError(t, err)
+			require.Len(t, got.Modes, 1)
+			assert.Equal(t, "20", got.Modes[0].Value)
+
+			_, err = store.Substances.Get(ctx, "missing")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+
+			found, err := store.Substances.Find(ctx, []string{acorn.ID, "missing"})
+			require.NoError(t, err)
+			require.Len(t, found, 1)
+			assert.Equal(t, acorn.ID, found[0].ID)
+
+			oak.Name, oak.Essence = "Old Oak", "Quercus robur"
+			require.NoError(t, store.Substances.Update(ctx, oak))
+			got, err = store.Substances.Get(ctx, oak.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "Old Oak", got.Name)
+			assert.Equal(t, "Quercus robur", got.Essence)
+
+			modes, err := store.Modes.ListBySubstance(ctx, oak.ID)
+			require.NoError(t, err)
+			require.Len(t, modes, 1)
+			require.NotNil(t, modes[0].Attribute)
+			assert.Equal(t, "height", modes[0].Attribute.Name)
+		})
+	}
+}
+
+func TestRepository_UniqueNames(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, store.Kinds.Create(ctx, entities.NewKind("Tree", "")))
+			assert.ErrorIs(t, store.Kinds.Create(ctx, entities.NewKind("Tree", "")), repository.ErrDuplicate)
+			require.NoError(t, store.Attributes.Create(ctx, entities.NewAttribute("height", "", "number")))
+			assert.ErrorIs(t, store.Attributes.Create(ctx, entities.NewAttribute("height", "", "number")), repository.ErrDuplicate)
+
+			// Names are unique per workspace
+			lab := workspace.WithID(ctx, "lab")
+			require.NoError(t, store.Kinds.Create(lab, entities.NewKind("Tree", "")))
+			require.NoError(t, store.Attributes.Create(lab, entities.NewAttribute("height", "", "number")))
+		})
+	}
+}
+
+func TestRepository_WorkspaceIsolation(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			lab := workspace.WithID(ctx, "lab")
+			oak := entities.NewSubstance("Oak", "Tree", "")
+			require.NoError(t, store.Substances.Create(lab, oak))
+			assert.Equal(t, "lab", oak.WorkspaceID)
+
+			substances, err := store.Substances.List(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, substances)
+			_, err = store.Substances.Get(ctx, oak.ID)
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+			assert.ErrorIs(t, store.Substances.Update(ctx, oak), repository.ErrNotFound)
+
+			substances, err = store.Substances.List(lab)
+			require.NoError(t, err)
+			assert.Len(t, substances, 1)
+		})
+	}
+}
+
+func TestRepository_CauseFilter(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			then := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			until := then.AddDate(1, 0, 0)
+
+			efficient := entities.NewCausalRelation("efficient", "sun", "oak")
+			material := entities.NewCausalRelation("material", "wood", "oak")
+			material.Strength = 0.4
+			material.ValidUntil = &until
+			other := entities.NewCausalRelation("final", "shade", "garden")
+			for _, relation := range []*entities.CausalRelation{efficient, material, other} {
+				require.NoError(t, store.Causes.Create(ctx, relation))
+			}
+
+			ids := func(filter repository.CauseFilter) []string {
+				relations, err := store.Causes.List(ctx, filter)
+				require.NoError(t, err)
+				found := []string{}
+				for _, relation := range relations {
+					found = append(found, relation.ID)
+				}
+				return found
+			}
+
+			assert.Len(t, ids(repository.CauseFilter{}), 3)
+			found, err := store.Causes.Find(ctx, []string{other.ID, "missing"})
+			require.NoError(t, err)
+			require.Len(t, found, 1)
+			assert.Equal(t, "shade", found[0].FromEntity)
+			assert.ElementsMatch(t, []string{efficient.ID, material.ID}, ids(repository.CauseFilter{EntityID: "oak"}))
+			assert.Equal(t, []string{material.ID}, ids(repository.CauseFilter{CauseTypes: []string{"material"}}))
+			assert.ElementsMatch(t, []string{efficient.ID, other.ID}, ids(repository.CauseFilter{MinStrength: 0.5}))
+
+			later := until.AddDate(1, 0, 0)
+			assert.NotContains(t, ids(repository.CauseFilter{ValidAt: &later}), material.ID)
+			assert.Contains(t, ids(repository.CauseFilter{ValidAt: &then}), material.ID)
+		})
+	}
+}
+
+func TestEngine_MemoryStore(t *testing.T) {
+	store := repository.NewMemory()
+	ctx := context.Background()
+	engine := causality.NewEngineWith(ctx, store)
+
+	oak := entities.NewSubstance("Oak", "Tree", "")
+	season := entities.NewAttribute("season", "", "string")
+	require.NoError(t, store.Substances.Create(ctx, oak))
+	require.NoError(t, store.Attributes.Create(ctx, season))
+
+	conditions := `[{"type": "attribute", "name": "season", "value": "spring"}]`
+	potentiality, err := engine.CreatePotentiality("Grow Leaves", "", conditions, oak.ID)
+	require.NoError(t, err)
+	_, err = engine.CreatePotentiality("Grow Leaves", "", "", "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	met, unmet, err := engine.CheckConditions(potentiality.ID)
+	require.NoError(t, err)
+	assert.False(t, met)
+	assert.Len(t, unmet, 1)
+
+	require.NoError(t, store.Modes.Create(ctx, entities.NewMode("spring", oak.ID, season.ID)))
+	actuality, err := engine.ActualizePotentiality(potentiality.ID, "Leaves grew")
+	require.NoError(t, err)
+	assert.Equal(t, oak.ID, actuality.SubstanceID)
+
+	actualities, err := engine.GetActualitiesForSubstance(oak.ID)
+	require.NoError(t, err)
+	assert.Len(t, actualities, 1)
+
+	_, err = engine.RecordCausalRelation(causality.CausalRelationInput{
+		FromEntity: oak.ID, ToEntity: "sun", CauseType: "efficient", FromType: "substance", ToType: "external",
+	})
+	require.NoError(t, err)
+	causes, err := engine.GetFourCauses(oak.ID)
+	require.NoError(t, err)
+	assert.Len(t, causes.Causes["efficient"], 1)
+	assert.NotContains(t, causes.Missing, "efficient")
+
+	// Traversal runs in memory over the store, as does detaching and finding
+	// conflicts; relations and parts need a database
+	ancestors, err := engine.GetAncestors(oak.ID, causality.TraversalOptions{})
+	require.NoError(t, err)
+	require.Len(t, ancestors, 1)
+	assert.Equal(t, "sun", ancestors[0].EntityID)
+	path, err := engine.FindCausalPath(oak.ID, "sun", causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Len(t, path.Relations, 1)
+	_, err = engine.ListRelations(causality.RelationFilter{})
+	assert.ErrorIs(t, err, causality.ErrNoDatabase)
+	conflicts, err := engine.ModeConflicts("")
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	detached, err := engine.DetachSubstance(oak.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), detached)
+	causes, err = engine.GetFourCauses(oak.ID)
+	require.NoError(t, err)
+	assert.Empty(t, causes.Causes["efficient"])
+}
+
+func TestRepository_Transaction(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			oak := entities.NewSubstance("Oak", "Tree", "")
+			pine := entities.NewSubstance("Pine", "Tree", "")
+
+			failed := errors.New("failed")
+			err := store.Transaction(ctx, func(tx *repository.Store) error {
+				require.NoError(t, tx.Substances.Create(ctx, oak))
+				return tx.Transaction(ctx, func(tx *repository.Store) error {
+					require.NoError(t, tx.Substances.Create(ctx, pine))
+					return failed
+				})
+			})
+			assert.ErrorIs(t, err, failed)
+			substances, err := store.Substances.List(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, substances, "a failed transaction leaves nothing behind")
+
+			require.NoError(t, store.Transaction(ctx, func(tx *repository.Store) error {
+				return tx.Substances.Create(ctx, oak)
+			}))
+			_, err = store.Substances.Get(ctx, oak.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, name == "gorm", store.DB() != nil)
+		})
+	}
+}
+
+func TestHandler_MemoryStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := repository.NewMemory()
+	handler := &api.Handler{Store: store}
+	router := gin.New()
+	router.GET("/kinds", handler.GetKinds)
+	router.POST("/kinds", handler.CreateKind)
+	router.GET("/substances", handler.GetSubstances)
+	router.POST("/potentialities", handler.CreatePotentiality)
+	router.POST("/causes", handler.AddCause)
+	router.GET("/causes", handler.GetCausalRelations)
+	router.GET("/causes/cycles", handler.GetCausalCycles)
+	router.GET("/entities/:id/ancestors", handler.GetAncestors)
+	router.GET("/substances/:id", handler.GetSubstance)
+	router.POST("/substances", handler.CreateSubstance)
+	router.POST("/modes", handler.CreateMode)
+	router.GET("/rules", handler.GetRules)
+
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	oak := entities.NewSubstance("Oak", "Tree", "")
+	require.NoError(t, store.Substances.Create(context.Background(), oak))
+
+	assert.Equal(t, http.StatusCreated, request("POST", "/kinds", map[string]string{"name": "Tree"}).Code)
+	assert.Equal(t, http.StatusConflict, request("POST", "/kinds", map[string]string{"name": "Tree"}).Code)
+	w := request("GET", "/kinds", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Tree"`)
+
+	w = request("GET", "/substances", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), oak.ID)
+
+	w = request("POST", "/potentialities", map[string]string{"name": "Grow Leaves", "substance_id": oak.ID})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = request("POST", "/causes", map[string]string{"from_entity": "sun", "to_entity": oak.ID, "cause_type": "efficient"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "sun is in no store unless declared external")
+	w = request("POST", "/causes", map[string]string{"from_entity": "sun", "from_type": "external", "to_entity": oak.ID, "cause_type": "efficient"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request("GET", "/causes?entity_id="+oak.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		CausalRelations []entities.CausalRelation `json:"causal_relations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.CausalRelations, 1)
+
+	w = request("GET", "/entities/sun/ancestors", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), oak.ID)
+	w = request("GET", "/causes/cycles", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Substances and modes go through the store alone
+	season := entities.NewAttribute("season", "", "string")
+	season.Cardinality = entities.CardinalitySingle
+	require.NoError(t, store.Attributes.Create(context.Background(), season))
+	w = request("GET", "/substances/"+oak.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Location"))
+	assert.Equal(t, http.StatusNotFound, request("GET", "/substances/missing", nil).Code)
+	w = request("POST", "/modes", map[string]string{"value": "spring", "substance_id": oak.ID, "attribute_id": season.ID})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request("POST", "/modes", map[string]string{"value": "autumn", "substance_id": oak.ID, "attribute_id": season.ID})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request("POST", "/modes", map[string]string{"value": "spring", "substance_id": "missing", "attribute_id": season.ID})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = request("POST", "/substances", map[string]interface{}{
+		"name": "Pine", "kind": "Tree", "essence": "Pinus",
+		"modes": []map[string]string{{"attribute_id": season.ID, "value": "winter"}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var pine entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pine))
+	got, err := store.Substances.Get(context.Background(), pine.ID)
+	require.NoError(t, err)
+	assert.Len(t, got.Modes, 1)
+
+	// A failed creation is rolled back with the store's transaction
+	w = request("POST", "/substances", map[string]interface{}{
+		"name": "Birch", "kind": "Tree", "essence": "Betula",
+		"modes": []map[string]string{{"attribute_id": "missing", "value": "winter"}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	substances, err := store.Substances.List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, substances, 2)
+
+	// What needs a database is refused rather than attempted
+	assert.Equal(t, http.StatusNotImplemented, request("GET", "/rules", nil).Code)
+	w = request("POST", "/substances", map[string]interface{}{"name": "Elm", "template": "tree"})
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// openSQLite opens a SQLite database in the file at path, closing it when the test ends
+func openSQLite(t *testing.T, path string) *gorm.DB {
+	db, err := persistence.NewSQLite(path)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestSQLite_Pragmas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ontology.db")
+	db := openSQLite(t, path)
+
+	var journalMode string
+	var foreignKeys, busyTimeout int
+	require.NoError(t, db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	require.NoError(t, db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error)
+	require.NoError(t, db.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error)
+	assert.Equal(t, "wal", journalMode)
+	assert.Equal(t, 1, foreignKeys)
+	assert.Equal(t, 5000, busyTimeout)
+	assert.NoError(t, persistence.CheckSchema(t.Context(), db))
+
+	// Reopening migrates an existing file, and parameters in a URI override the defaults
+	reopened := openSQLite(t, "file:"+path+"?_busy_timeout=100")
+	require.NoError(t, reopened.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error)
+	assert.Equal(t, 100, busyTimeout)
+	assert.NoError(t, persistence.CheckSchema(t.Context(), reopened))
+
+	_, err := persistence.Open("mysql", path)
+	assert.Error(t, err)
+}
+
+func TestSQLite_ForeignKeys(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "ontology.db"))
+
+	height := entities.NewAttribute("height", "", "number")
+	require.NoError(t, db.Create(height).Error)
+	assert.Error(t, db.Create(entities.NewMode("20", "missing", height.ID)).Error)
+
+	// A substance's kind is a name, not a reference to a kind row
+	oak := entities.NewSubstance("Oak", "Tree", "")
+	require.NoError(t, db.Create(oak).Error)
+	potentiality := entities.NewPotentiality("Grow Leaves", "", "", oak.ID)
+	require.NoError(t, db.Create(potentiality).Error)
+	require.NoError(t, db.Create(entities.NewActuality("Leaves grew", oak.ID, potentiality.ID)).Error)
+	require.NoError(t, db.Create(entities.NewMode("20", oak.ID, height.ID)).Error)
+
+	// Deleting a substance deletes what depends on it
+	require.NoError(t, db.Delete(&entities.Substance{}, "id = ?", oak.ID).Error)
+	for _, model := range []interface{}{&entities.Mode{}, &entities.Potentiality{}, &entities.Actuality{}} {
+		var count int64
+		require.NoError(t, db.Model(model).Count(&count).Error)
+		assert.Zero(t, count, "%T", model)
+	}
+}
+
+func TestSQLite_ConcurrentWriters(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "ontology.db"))
+
+	const writers, writes = 16, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*writes)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				// Read, then write, in one transaction: the pattern that deadlocks
+				// when transactions only take the write lock at their first write
+				errs <- db.Transaction(func(tx *gorm.DB) error {
+					var count int64
+					if err := tx.Model(&entities.Kind{}).Count(&count).Error; err != nil {
+						return err
+					}
+					kind := entities.NewKind(fmt.Sprintf("Kind %d-%d", w, i), fmt.Sprintf("kind number %d", count+1))
+					if err := tx.Create(kind).Error; err != nil {
+						return err
+					}
+					return tx.Create(entities.NewSubstance("Specimen", kind.Name, "")).Error
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var kinds, substances int64
+	require.NoError(t, db.Model(&entities.Kind{}).Count(&kinds).Error)
+	require.NoError(t, db.Model(&entities.Substance{}).Count(&substances).Error)
+	assert.Equal(t, int64(writers*writes), kinds)
+	assert.Equal(t, int64(writers*writes), substances)
+}
+
+func TestSQLite_Engine(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "ontology.db"))
+	engine := causality.NewEngine(db)
+
+	acorn := entities.NewSubstance("Acorn", "Seed", "")
+	oak := entities.NewSubstance("Oak", "Tree", "")
+	require.NoError(t, db.Create(acorn).Error)
+	require.NoError(t, db.Create(oak).Error)
+	season := entities.NewAttribute("season", "", "string")
+	require.NoError(t, db.Create(season).Error)
+	require.NoError(t, db.Create(entities.NewMode("spring", oak.ID, season.ID)).Error)
+
+	potentiality, err := engine.CreatePotentiality("Grow Leaves", "",
+		`[{"type": "attribute", "name": "season", "value": "spring"}]`, oak.ID)
+	require.NoError(t, err)
+	_, err = engine.ActualizePotentiality(potentiality.ID, "Leaves grew")
+	require.NoError(t, err)
+
+	// oak <- acorn <- soil, and a cycle between rain and clouds
+	_, err = engine.AddCausalRelation(oak.ID, acorn.ID, "material")
+	require.NoError(t, err)
+	_, err = causeOutside(engine, acorn.ID, "soil", "material")
+	require.NoError(t, err)
+	for _, link := range [][2]string{{"rain", "clouds"}, {"clouds", "rain"}} {
+		_, err := relateConcepts(engine, link[0], link[1], "material")
+		require.NoError(t, err)
+	}
+
+	ancestors, err := engine.GetAncestors(oak.ID, causality.TraversalOptions{})
+	require.NoError(t, err)
+	require.Len(t, ancestors, 2)
+	assert.Equal(t, "soil", ancestors[1].EntityID)
+
+	path, err := engine.FindCausalPath(oak.ID, "soil", causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{oak.ID, acorn.ID, "soil"}, path.Entities)
+
+	cycles, err := engine.DetectCycles(causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Len(t, cycles, 1)
+
+	causes, err := engine.GetFourCauses(oak.ID)
+	require.NoError(t, err)
+	assert.Equal(t, acorn.ID, causes.Cause("material"))
+
+	evolution, err := engine.GetSubstanceEvolution(oak.ID)
+	require.NoError(t, err)
+	assert.Len(t, evolution.Actualities, 1)
+}
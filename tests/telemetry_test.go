@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/api"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	spanRecorder    = tracetest.NewInMemoryExporter()
+	spanProvider    = sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanRecorder))
+	installProvider sync.Once
+)
+
+// recordSpans makes the global tracer provider record spans in memory,
+// forgetting those of earlier tests. The first provider set is the one
+// tracers obtained before it delegate to, so it is set once and kept.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	installProvider.Do(func() { otel.SetTracerProvider(spanProvider) })
+	spanRecorder.Reset()
+	return spanRecorder
+}
+
+// captureLogs sends the default logger's records to a buffer, as JSON, for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, err := telemetry.NewLogger(&buf, "json", "debug")
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords decodes the JSON records with the given message
+func logRecords(t *testing.T, buf *bytes.Buffer, msg string) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := telemetry.NewLogger(&buf, "json", "info")
+	require.NoError(t, err)
+
+	ctx, span := spanProvider.Tracer("test").Start(telemetry.WithRequestID(context.Background(), "req-1"), "test")
+	logger.InfoContext(ctx, "hello", "n", 1)
+	logger.DebugContext(ctx, "below the level")
+	span.End()
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "hello", record["msg"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), record["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), record["span_id"])
+	assert.NotContains(t, buf.String(), "below the level")
+
+	_, err = telemetry.NewLogger(&buf, "xml", "info")
+	assert.Error(t, err)
+	_, err = telemetry.NewLogger(&buf, "text", "loud")
+	assert.Error(t, err)
+}
+
+func TestTracing_Actualization(t *testing.T) {
+	spans := recordSpans(t)
+	logs := captureLogs(t)
+	router, db := setupTestAPI(t)
+
+	substance := entities.NewSubstance("Tree-001", "Oak", "")
+	require.NoError(t, db.Create(substance).Error)
+	potentiality := entities.NewPotentiality("Grow", "", `[]`, substance.ID)
+	require.NoError(t, db.Create(potentiality).Error)
+	spans.Reset()
+
+	req, _ := http.NewRequest("POST", "/api/v1/potentialities/"+potentiality.ID+"/actualize", strings.NewReader(`{"description":"grew"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(api.RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "req-42", w.Header().Get(api.RequestIDHeader))
+
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans.GetSpans() {
+		byName[span.Name] = span
+	}
+	server, ok := byName["POST /api/v1/potentialities/:id/actualize"]
+	require.True(t, ok, "the request has a server span")
+	engine, ok := byName["causality.ActualizePotentiality"]
+	require.True(t, ok, "actualizing has an engine span")
+	check, ok := byName["causality.CheckConditions"]
+	require.True(t, ok)
+	insert, ok := byName["gorm.create actualities"]
+	require.True(t, ok, "statements have spans")
+
+	trace := server.SpanContext.TraceID()
+	for _, span := range []tracetest.SpanStub{engine, check, insert} {
+		assert.Equal(t, trace, span.SpanContext.TraceID(), span.Name)
+	}
+	assert.Equal(t, server.SpanContext.SpanID(), engine.Parent.SpanID())
+	assert.Equal(t, engine.SpanContext.SpanID(), check.Parent.SpanID())
+	assert.Equal(t, engine.SpanContext.SpanID(), insert.Parent.SpanID())
+	assert.Contains(t, server.Attributes, attribute.String("oaas.request_id", "req-42"))
+
+	// The engine's and the request's records carry the request ID
+	actualized := logRecords(t, logs, "potentiality actualized")
+	require.Len(t, actualized, 1)
+	assert.Equal(t, "req-42", actualized[0]["request_id"])
+	assert.Equal(t, trace.String(), actualized[0]["trace_id"])
+	served := logRecords(t, logs, "request served")
+	require.Len(t, served, 1)
+	assert.Equal(t, "req-42", served[0]["request_id"])
+	assert.Equal(t, float64(http.StatusCreated), served[0]["status"])
+
+	// A request without an ID is given one
+	req, _ = http.NewRequest("GET", "/health", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get(api.RequestIDHeader), 36)
+}
+
+func TestTracing_FailedStatement(t *testing.T) {
+	spans := recordSpans(t)
+	logs := captureLogs(t)
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(telemetry.GormPlugin{}))
+	db.Logger = telemetry.NewGormLogger(0)
+
+	ctx := telemetry.WithRequestID(context.Background(), "req-7")
+	assert.Error(t, db.WithContext(ctx).Exec("INSERT INTO nowhere VALUES (1)").Error)
+
+	recorded := spans.GetSpans()
+	require.Len(t, recorded, 1)
+	assert.Equal(t, "gorm.raw", recorded[0].Name)
+	assert.Equal(t, "Error", recorded[0].Status.Code.String())
+	failed := logRecords(t, logs, "statement failed")
+	require.Len(t, failed, 1)
+	assert.Equal(t, "req-7", failed[0]["request_id"])
+	assert.Equal(t, "ERROR", failed[0]["level"])
+}
+
+func TestSetupTracing_OTLP(t *testing.T) {
+	recordSpans(t)
+	t.Cleanup(func() { otel.SetTracerProvider(spanProvider) })
+
+	// A stand-in for a collector, receiving OTLP over HTTP
+	var mu sync.Mutex
+	var received []*coltracepb.ExportTraceServiceRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		export := &coltracepb.ExportTraceServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, export))
+		mu.Lock()
+		received = append(received, export)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	shutdown, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		Exporter: telemetry.ExporterOTLP,
+		Endpoint: collector.URL,
+	})
+	require.NoError(t, err)
+	_, span := otel.GetTracerProvider().Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 1)
+	resourceSpans := received[0].GetResourceSpans()
+	require.Len(t, resourceSpans, 1)
+	var service string
+	for _, attr := range resourceSpans[0].GetResource().GetAttributes() {
+		if attr.GetKey() == "service.name" {
+			service = attr.GetValue().GetStringValue()
+		}
+	}
+	assert.Equal(t, "oaas", service)
+	assert.Equal(t, "exported", resourceSpans[0].GetScopeSpans()[0].GetSpans()[0].GetName())
+}
+
+func TestSetupTracing_Stdout(t *testing.T) {
+	recordSpans(t)
+	t.Cleanup(func() { otel.SetTracerProvider(spanProvider) })
+
+	var out bytes.Buffer
+	shutdown, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		Exporter: telemetry.ExporterStdout,
+		Output:   &out,
+	})
+	require.NoError(t, err)
+	_, span := otel.GetTracerProvider().Tracer("test").Start(context.Background(), "printed")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, out.String(), `"Name":"printed"`)
+
+	_, err = telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/cloning"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloning_Clone(t *testing.T) {
+	db := setupTestDB(t)
+	oak := entities.NewSubstance("Oak", "Oak", "Deciduous tree")
+	acorn := entities.NewSubstance("Acorn", "Seed", "")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(acorn).Error)
+	attributes := createAttributes(t, db, "leaf", "height")
+	require.NoError(t, db.Model(attributes["height"]).Update("cardinality", entities.CardinalitySingle).Error)
+	require.NoError(t, db.Create(entities.NewMode("lobed", oak.ID, attributes["leaf"].ID)).Error)
+	short := entities.NewMode("2m", oak.ID, attributes["height"].ID)
+	tall := entities.NewMode("20m", oak.ID, attributes["height"].ID)
+	tall.CreatedAt = short.CreatedAt.Add(time.Hour)
+	require.NoError(t, db.Create(short).Error)
+	require.NoError(t, db.Create(tall).Error)
+	potentiality := entities.NewPotentiality("grow", "", "", oak.ID)
+	require.NoError(t, db.Create(potentiality).Error)
+	material := entities.NewCausalRelation("material", acorn.ID, oak.ID)
+	final := entities.NewCausalRelation("final", potentiality.ID, oak.ID)
+	require.NoError(t, db.Create(material).Error)
+	require.NoError(t, db.Create(final).Error)
+	cloner := cloning.NewCloner(db)
+
+	// A shallow clone gets the modes, only the current value of a single-valued attribute
+	shallow, err := cloner.Clone(oak.ID, cloning.CloneOptions{Name: "Oak 2"})
+	require.NoError(t, err)
+	assert.Equal(t, "Oak 2", shallow.Name)
+	assert.Equal(t, "Deciduous tree", shallow.Essence)
+	values := map[string]string{}
+	for _, mode := range shallow.Modes {
+		values[mode.AttributeID] = mode.Value
+	}
+	assert.Equal(t, map[string]string{attributes["leaf"].ID: "lobed", attributes["height"].ID: "20m"}, values)
+	assert.Empty(t, shallow.Potentialities)
+
+	_, err = cloner.Clone(oak.ID, cloning.CloneOptions{CauseIDs: []string{material.ID}})
+	assert.ErrorIs(t, err, cloning.ErrInvalidClone)
+	_, err = cloner.Clone("missing", cloning.CloneOptions{})
+	assert.ErrorIs(t, err, cloning.ErrSubstanceNotFound)
+
+	deep, err := cloner.Clone(oak.ID, cloning.CloneOptions{Deep: true, CauseIDs: []string{material.ID, final.ID}})
+	require.NoError(t, err)
+	assert.Equal(t, "Oak", deep.Name)
+	require.Len(t, deep.Potentialities, 1)
+	var causes []entities.CausalRelation
+	require.NoError(t, db.Where("to_entity = ?", deep.ID).Order("cause_type").Find(&causes).Error)
+	require.Len(t, causes, 2)
+	assert.Equal(t, deep.Potentialities[0].ID, causes[0].FromEntity) // final: the clone's own potentiality
+	assert.Equal(t, acorn.ID, causes[1].FromEntity)
+
+	unrelated := entities.NewCausalRelation("efficient", acorn.ID, shallow.ID)
+	require.NoError(t, db.Create(unrelated).Error)
+	_, err = cloner.Clone(oak.ID, cloning.CloneOptions{Deep: true, CauseIDs: []string{unrelated.ID}})
+	assert.ErrorIs(t, err, cloning.ErrInvalidClone)
+}
+
+func TestCloning_Templates(t *testing.T) {
+	db := setupTestDB(t)
+	attributes := createAttributes(t, db, "leaf", "height")
+	require.NoError(t, db.Model(attributes["height"]).Update("cardinality", entities.CardinalitySingle).Error)
+	taller := createRelationalAttribute(t, db, "taller_than", "taller", "shorter")
+	cloner := cloning.NewCloner(db)
+
+	template, err := cloner.CreateTemplate(cloning.TemplateInput{
+		Name:    "oak",
+		Kind:    "Oak",
+		Essence: "Deciduous tree",
+		Modes: []cloning.ModeInput{
+			{AttributeID: attributes["leaf"].ID, Value: "lobed"},
+			{AttributeID: attributes["height"].ID, Value: "1m"},
+		},
+		Potentialities: []cloning.PotentialityInput{{Name: "grow", Conditions: `[{"type":"mode","name":"height","value":"1m"}]`}},
+	})
+	require.NoError(t, err)
+	require.Len(t, template.Modes, 2)
+	assert.Equal(t, "leaf", template.Modes[0].Attribute.Name)
+
+	for _, input := range []cloning.TemplateInput{
+		{Name: "sapling", Kind: "Oak", Modes: []cloning.ModeInput{{AttributeID: taller.ID, Value: "yes"}}},
+		{Name: "sapling", Kind: "Oak", Modes: []cloning.ModeInput{{AttributeID: attributes["height"].ID, Value: "1m"}, {AttributeID: attributes["height"].ID, Value: "2m"}}},
+		{Name: "sapling", Kind: "Oak", Potentialities: []cloning.PotentialityInput{{Name: "grow", Conditions: "tall"}}},
+	} {
+		_, err := cloner.CreateTemplate(input)
+		assert.ErrorIs(t, err, cloning.ErrInvalidTemplate)
+	}
+	_, err = cloner.CreateTemplate(cloning.TemplateInput{Name: "sapling", Kind: "Oak", Modes: []cloning.ModeInput{{AttributeID: "missing"}}})
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+	_, err = cloner.CreateTemplate(cloning.TemplateInput{Name: "oak", Kind: "Oak"})
+	assert.ErrorIs(t, err, cloning.ErrDuplicateTemplate)
+
+	// The instance's height replaces the default; its leaf adds to it
+	substance, err := cloner.Instantiate("oak", cloning.Instance{
+		Name: "Old oak",
+		Modes: []cloning.ModeInput{
+			{AttributeID: attributes["height"].ID, Value: "30m"},
+			{AttributeID: attributes["leaf"].ID, Value: "yellowing"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Oak", substance.Kind)
+	assert.Equal(t, "Deciduous tree", substance.Essence)
+	var modes []entities.Mode
+	require.NoError(t, db.Where("substance_id = ?", substance.ID).Order("value").Find(&modes).Error)
+	require.Len(t, modes, 3)
+	assert.Equal(t, []string{"30m", "lobed", "yellowing"}, []string{modes[0].Value, modes[1].Value, modes[2].Value})
+	var potentialities []entities.Potentiality
+	require.NoError(t, db.Where("substance_id = ?", substance.ID).Find(&potentialities).Error)
+	require.Len(t, potentialities, 1)
+
+	_, err = cloner.Instantiate("birch", cloning.Instance{Name: "Birch"})
+	assert.ErrorIs(t, err, cloning.ErrTemplateNotFound)
+
+	require.NoError(t, cloner.DeleteTemplate(template.ID))
+	var count int64
+	db.Model(&entities.TemplateMode{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entities.Substance{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestTemplatesAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	leaf := createAttributes(t, db, "leaf")["leaf"]
+
+	w := send("POST", "/api/v1/templates", map[string]interface{}{
+		"name":           "oak",
+		"kind":           "Oak",
+		"essence":        "Deciduous tree",
+		"modes":          []map[string]string{{"attribute_id": leaf.ID, "value": "lobed"}},
+		"potentialities": []map[string]string{{"name": "grow"}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/templates", map[string]string{"name": "oak", "kind": "Oak"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("POST", "/api/v1/templates", map[string]string{"name": "birch"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("GET", "/api/v1/templates/oak", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var template entities.SubstanceTemplate
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &template))
+	require.Len(t, template.Potentialities, 1)
+
+	// The template supplies kind and essence
+	w = send("POST", "/api/v1/substances", map[string]string{"name": "Old oak", "template": "oak"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var substance entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &substance))
+	assert.Equal(t, "Oak", substance.Kind)
+	require.Len(t, substance.Modes, 1)
+	require.Len(t, substance.Potentialities, 1)
+	w = send("POST", "/api/v1/substances", map[string]string{"name": "Birch", "template": "birch"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", "/api/v1/substances", map[string]string{"name": "Birch", "kind": "Birch"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("POST", "/api/v1/substances/"+substance.ID+"/clone", map[string]interface{}{"name": "Young oak", "deep": true})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var clone entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &clone))
+	assert.Equal(t, "Young oak", clone.Name)
+	assert.Len(t, clone.Modes, 1)
+	assert.Len(t, clone.Potentialities, 1)
+	w = send("POST", "/api/v1/substances/missing/clone", map[string]interface{}{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send("POST", "/api/v1/substances/"+substance.ID+"/clone", map[string]interface{}{"cause_ids": []string{"x"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = send("DELETE", "/api/v1/templates/"+template.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/api/v1/templates", nil)
+	assert.Equal(t, "[]", w.Body.String())
+	w = send("DELETE", "/api/v1/templates/oak", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildCausalChain records acorn <- sapling <- oak <- shade, with soil and water feeding the sapling
+func buildCausalChain(t *testing.T, engine *causality.Engine) {
+	links := []struct{ from, to, causeType string }{
+		{"oak", "sapling", "efficient"},
+		{"sapling", "acorn", "efficient"},
+		{"sapling", "soil", "material"},
+		{"soil", "water", "material"},
+		{"shade", "oak", "efficient"},
+	}
+	for _, link := range links {
+		_, err := relateConcepts(engine, link.from, link.to, link.causeType)
+		require.NoError(t, err)
+	}
+}
+
+func nodeIDs(nodes []causality.CausalNode) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.EntityID)
+	}
+	return ids
+}
+
+func TestCausalTraversal_Ancestors(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	ancestors, err := engine.GetAncestors("oak", causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sapling", "acorn", "soil", "water"}, nodeIDs(ancestors))
+	assert.Equal(t, 1, ancestors[0].Depth)
+	assert.Equal(t, 3, ancestors[3].Depth)
+	assert.Equal(t, "soil", ancestors[3].Via)
+
+	shallow, err := engine.GetAncestors("oak", causality.TraversalOptions{MaxDepth: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sapling"}, nodeIDs(shallow))
+
+	efficient, err := engine.GetAncestors("oak", causality.TraversalOptions{CauseTypes: []string{"efficient"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sapling", "acorn"}, nodeIDs(efficient))
+
+	_, err = engine.GetAncestors("oak", causality.TraversalOptions{CauseTypes: []string{"invalid"}})
+	assert.Error(t, err)
+}
+
+func TestCausalTraversal_Descendants(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	descendants, err := engine.GetDescendants("acorn", causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sapling", "oak", "shade"}, nodeIDs(descendants))
+	assert.Equal(t, "efficient", descendants[0].CauseType)
+}
+
+func TestCausalTraversal_ShortestPath(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	// A shortcut that the search must prefer over shade -> oak -> sapling -> soil
+	_, err := relateConcepts(engine, "shade", "soil", "material")
+	require.NoError(t, err)
+
+	path, err := engine.FindCausalPath("shade", "water", causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shade", "soil", "water"}, path.Entities)
+	assert.Len(t, path.Relations, 2)
+
+	_, err = engine.FindCausalPath("water", "shade", causality.TraversalOptions{})
+	assert.ErrorIs(t, err, causality.ErrNoCausalPath)
+}
+
+func TestCausalTraversal_DetectCycles(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+	buildCausalChain(t, engine)
+
+	cycles, err := engine.DetectCycles(causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, cycles)
+
+	// Acorns come from oaks, closing acorn -> oak -> sapling -> acorn
+	_, err = relateConcepts(engine, "acorn", "oak", "efficient")
+	require.NoError(t, err)
+
+	cycles, err = engine.DetectCycles(causality.TraversalOptions{})
+	require.NoError(t, err)
+	require.Len(t, cycles, 1)
+	assert.Equal(t, []string{"acorn", "oak", "sapling"}, cycles[0].Entities)
+	assert.Len(t, cycles[0].Relations, 3)
+
+	material, err := engine.DetectCycles(causality.TraversalOptions{CauseTypes: []string{"material"}})
+	require.NoError(t, err)
+	assert.Empty(t, material)
+
+	tooShort, err := engine.DetectCycles(causality.TraversalOptions{MaxDepth: 2})
+	require.NoError(t, err)
+	assert.Empty(t, tooShort)
+}
+
+func TestCausalTraversalAPI(t *testing.T) {
+	router, db := setupTestAPI(t)
+	buildCausalChain(t, causality.NewEngine(db))
+
+	req, _ := http.NewRequest("GET", "/api/v1/entities/oak/ancestors?depth=2&cause_type=efficient", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var ancestors struct {
+		Ancestors []causality.CausalNode `json:"ancestors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ancestors))
+	assert.Equal(t, []string{"sapling", "acorn"}, nodeIDs(ancestors.Ancestors))
+
+	req, _ = http.NewRequest("GET", "/api/v1/causes/path?from=oak&to=water", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var path causality.CausalPath
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &path))
+	assert.Equal(t, []string{"oak", "sapling", "soil", "water"}, path.Entities)
+
+	req, _ = http.NewRequest("GET", "/api/v1/causes/path?from=water&to=oak", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/v1/entities/oak/descendants?cause_type=invalid", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCausalTraversal_StrengthWeights(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	strength := func(v float64) *float64 { return &v }
+	links := []causality.CausalRelationInput{
+		// Direct but doubtful
+		{FromEntity: "harvest", ToEntity: "rain", CauseType: "efficient", Strength: strength(0.3)},
+		// Longer but well established
+		{FromEntity: "harvest", ToEntity: "soil_moisture", CauseType: "efficient", Strength: strength(0.9)},
+		{FromEntity: "soil_moisture", ToEntity: "rain", CauseType: "efficient", Strength: strength(0.8)},
+	}
+	for _, link := range links {
+		link.FromType, link.ToType = entities.EntityTypeExternal, entities.EntityTypeExternal
+		_, err := engine.RecordCausalRelation(link)
+		require.NoError(t, err)
+	}
+
+	shortest, err := engine.FindCausalPath("harvest", "rain", causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"harvest", "rain"}, shortest.Entities)
+	assert.InDelta(t, 0.3, shortest.Strength, 1e-9)
+
+	strongest, err := engine.FindCausalPath("harvest", "rain", causality.TraversalOptions{Weighted: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"harvest", "soil_moisture", "rain"}, strongest.Entities)
+	assert.InDelta(t, 0.72, strongest.Strength, 1e-9)
+
+	ancestors, err := engine.GetAncestors("harvest", causality.TraversalOptions{MinStrength: 0.5})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"soil_moisture", "rain"}, nodeIDs(ancestors))
+	assert.InDelta(t, 0.72, ancestors[1].Strength, 1e-9)
+}
+
+func TestCausalTraversal_WideDAG(t *testing.T) {
+	db := setupTestDB(t)
+	engine := causality.NewEngine(db)
+
+	// Every entity of a layer is caused by every entity of the next one, so
+	// the number of paths grows as width^depth while the entities do not
+	const width, layers = 8, 40
+	entity := func(layer, i int) string { return fmt.Sprintf("layer%02d_%d", layer, i) }
+	var relations []*entities.CausalRelation
+	for layer := 0; layer+1 < layers; layer++ {
+		for i := 0; i < width; i++ {
+			for j := 0; j < width; j++ {
+				relations = append(relations, entities.NewCausalRelation("efficient", entity(layer, i), entity(layer+1, j)))
+			}
+		}
+	}
+	require.NoError(t, db.CreateInBatches(relations, 500).Error)
+
+	ancestors, err := engine.GetAncestors(entity(0, 0), causality.TraversalOptions{MaxDepth: causality.MaxTraversalDepth})
+	require.NoError(t, err)
+	require.Len(t, ancestors, (layers-1)*width)
+	for _, node := range ancestors {
+		assert.Equal(t, node.EntityID[:len("layer00")], fmt.Sprintf("layer%02d", node.Depth))
+	}
+
+	descendants, err := engine.GetDescendants(entity(layers-1, 0), causality.TraversalOptions{MaxDepth: causality.MaxTraversalDepth})
+	require.NoError(t, err)
+	assert.Len(t, descendants, (layers-1)*width)
+
+	// Path searches expand level by level too, and reach across the whole graph
+	path, err := engine.FindCausalPath(entity(0, 0), entity(layers-1, width-1), causality.TraversalOptions{MaxDepth: layers})
+	require.NoError(t, err)
+	assert.Len(t, path.Relations, layers-1)
+	strongest, err := engine.FindCausalPath(entity(0, 0), entity(layers-1, 3), causality.TraversalOptions{MaxDepth: layers, Weighted: true})
+	require.NoError(t, err)
+	assert.Len(t, strongest.Relations, layers-1)
+	_, err = engine.FindCausalPath(entity(0, 0), entity(layers-1, 0), causality.TraversalOptions{})
+	assert.ErrorIs(t, err, causality.ErrNoCausalPath, "beyond the default depth")
+
+	cycles, err := engine.DetectCycles(causality.TraversalOptions{MaxDepth: causality.MaxCycleLength})
+	require.NoError(t, err)
+	assert.Empty(t, cycles)
+}
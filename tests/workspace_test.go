@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apodicticscott/oaas/internal/causality"
+	"github.com/apodicticscott/oaas/internal/entities"
+	"github.com/apodicticscott/oaas/internal/workspace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace_Isolation(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(workspace.Plugin{}))
+	manager := workspace.NewManager(db)
+	lab, err := manager.Create("lab", "Experiments")
+	require.NoError(t, err)
+	scoped := workspace.Scope(db, lab.ID)
+
+	// The same name may be used once per workspace
+	require.NoError(t, db.Create(entities.NewKind("Oak", "")).Error)
+	require.NoError(t, scoped.Create(entities.NewKind("Oak", "")).Error)
+	assert.Error(t, scoped.Create(entities.NewKind("Oak", "")).Error)
+
+	// Rows created in a workspace are only seen from it, whatever WorkspaceID they were given
+	oak := entities.NewSubstance("Oak", "Oak", "Tree")
+	oak.WorkspaceID = entities.DefaultWorkspace
+	acorn := entities.NewSubstance("Acorn", "Seed", "")
+	require.NoError(t, scoped.Create(oak).Error)
+	require.NoError(t, scoped.Create(acorn).Error)
+	assert.Equal(t, lab.ID, oak.WorkspaceID)
+	var count int64
+	db.Model(&entities.Substance{}).Count(&count)
+	assert.Zero(t, count)
+	var found entities.Substance
+	assert.Zero(t, db.Where("id = ?", oak.ID).Find(&found).RowsAffected)
+	assert.Zero(t, db.Model(&entities.Substance{}).Where("id = ?", oak.ID).Update("name", "Stolen").RowsAffected)
+	assert.Zero(t, db.Delete(&entities.Substance{}, "id = ?", oak.ID).RowsAffected)
+
+	// Entities of another workspace are not found
+	relation, err := causality.NewEngine(scoped).AddCausalRelation(oak.ID, acorn.ID, "material")
+	require.NoError(t, err)
+	assert.Equal(t, entities.EntityTypeSubstance, relation.ToType)
+	_, err = causality.NewEngine(db).AddCausalRelation(acorn.ID, oak.ID, "material")
+	assert.ErrorIs(t, err, causality.ErrEntityNotFound)
+	nodes, err := causality.NewEngine(scoped).GetAncestors(oak.ID, causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	nodes, err = causality.NewEngine(db).GetAncestors(oak.ID, causality.TraversalOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	assert.Equal(t, lab.ID, workspace.FromContext(workspace.WithID(context.Background(), lab.ID)))
+	assert.Equal(t, entities.DefaultWorkspace, workspace.FromContext(context.Background()))
+}
+
+func TestWorkspace_Manager(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(workspace.Plugin{}))
+	manager := workspace.NewManager(db)
+
+	lab, err := manager.Create("lab", "")
+	require.NoError(t, err)
+	_, err = manager.Create("lab", "")
+	assert.ErrorIs(t, err, workspace.ErrDuplicateWorkspace)
+	_, err = manager.Create("default", "")
+	assert.ErrorIs(t, err, workspace.ErrDuplicateWorkspace)
+	_, err = manager.Create("Lab 2", "")
+	assert.ErrorIs(t, err, workspace.ErrInvalidWorkspace)
+	_, err = manager.Get("missing")
+	assert.ErrorIs(t, err, workspace.ErrWorkspaceNotFound)
+
+	workspaces, err := manager.List()
+	require.NoError(t, err)
+	require.Len(t, workspaces, 2)
+	assert.Equal(t, []string{"default", "lab"}, []string{workspaces[0].Name, workspaces[1].Name})
+
+	oak := entities.NewKind("Oak", "Deciduous tree")
+	birch := entities.NewKind("Birch", "")
+	height := entities.NewAttribute("height", "", "string")
+	require.NoError(t, db.Create(oak).Error)
+	require.NoError(t, db.Create(birch).Error)
+	require.NoError(t, db.Create(height).Error)
+	require.NoError(t, workspace.Scope(db, lab.ID).Create(entities.NewKind("Birch", "")).Error)
+
+	_, err = manager.Copy("default", "lab", []string{oak.ID, birch.ID}, []string{height.ID})
+	assert.ErrorIs(t, err, workspace.ErrNameConflict)
+	_, err = manager.Copy("default", "lab", []string{"missing"}, nil)
+	assert.ErrorIs(t, err, workspace.ErrWorkspaceNotFound)
+	_, err = manager.Copy("lab", "lab", nil, nil)
+	assert.ErrorIs(t, err, workspace.ErrInvalidWorkspace)
+	result, err := manager.Copy("default", "lab", []string{oak.ID}, []string{height.ID})
+	require.NoError(t, err)
+	require.Len(t, result.Kinds, 1)
+	require.Len(t, result.Attributes, 1)
+	assert.NotEqual(t, oak.ID, result.Kinds[0].ID)
+	assert.Equal(t, "Deciduous tree", result.Kinds[0].Description)
+	assert.Equal(t, lab.ID, result.Attributes[0].WorkspaceID)
+	var count int64
+	workspace.Scope(db, lab.ID).Model(&entities.Kind{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	assert.ErrorIs(t, manager.Delete("default"), workspace.ErrWorkspaceNotEmpty)
+	assert.ErrorIs(t, manager.Delete("lab"), workspace.ErrWorkspaceNotEmpty)
+	require.NoError(t, workspace.Scope(db, lab.ID).Where("1 = 1").Delete(&entities.Kind{}).Error)
+	require.NoError(t, workspace.Scope(db, lab.ID).Where("1 = 1").Delete(&entities.Attribute{}).Error)
+	require.NoError(t, manager.Delete("lab"))
+	_, err = manager.Get("lab")
+	assert.ErrorIs(t, err, workspace.ErrWorkspaceNotFound)
+}
+
+func TestWorkspacesAPI(t *testing.T) {
+	router, _ := setupTestAPI(t)
+	send := func(method, url, selected string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if selected != "" {
+			req.Header.Set("X-Workspace", selected)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/v1/workspaces", "", map[string]string{"name": "lab"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/workspaces", "", map[string]string{"name": "lab"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("POST", "/api/v1/workspaces", "", map[string]string{"name": "Not valid"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("GET", "/api/v1/workspaces/missing/substances", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send("GET", "/api/v1/substances", "missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// The path and the header select the same workspace
+	w = send("POST", "/api/v1/workspaces/lab/kinds", "", map[string]string{"name": "Oak"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var oak entities.Kind
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &oak))
+	w = send("POST", "/api/v1/kinds", "", map[string]string{"name": "Oak"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/kinds", "lab", map[string]string{"name": "Oak"})
+	assert.NotEqual(t, http.StatusCreated, w.Code)
+
+	w = send("POST", "/api/v1/substances", "lab", map[string]string{"name": "Old oak", "kind": "Oak", "essence": "Tree"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "lab", w.Header().Get("X-Workspace"))
+	var substance entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &substance))
+	w = send("GET", "/api/v1/workspaces/lab/substances/"+substance.ID, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/api/v1/substances/"+substance.ID, "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	send("DELETE", "/api/v1/substances/"+substance.ID, "", nil)
+	w = send("GET", "/api/v1/substances/"+substance.ID, "lab", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/api/v1/substances", "", nil)
+	assert.Equal(t, `{"substances":[]}`, w.Body.String())
+
+	// A merged substance redirects to its survivor in the same workspace
+	w = send("POST", "/api/v1/workspaces/lab/substances", "", map[string]string{"name": "Old oak (2)", "kind": "Oak", "essence": "Tree"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var duplicate entities.Substance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &duplicate))
+	w = send("POST", "/api/v1/workspaces/lab/substances/"+substance.ID+"/merge", "", map[string]string{"duplicate_id": duplicate.ID})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = send("GET", "/api/v1/workspaces/lab/substances/"+duplicate.ID, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/v1/workspaces/lab/substances/"+substance.ID, w.Header().Get("Content-Location"))
+	w = send("GET", "/api/v1/substances/"+duplicate.ID, "lab", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/v1/substances/"+substance.ID, w.Header().Get("Content-Location"))
+
+	w = send("POST", "/api/v1/workspaces", "", map[string]string{"name": "field"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/workspaces/lab/copy", "", map[string]interface{}{"to": "field", "kind_ids": []string{oak.ID}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/workspaces/lab/copy", "", map[string]interface{}{"to": "field", "kind_ids": []string{oak.ID}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("GET", "/api/v1/kinds", "field", nil)
+	var kinds struct {
+		Kinds []entities.Kind `json:"kinds"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &kinds))
+	require.Len(t, kinds.Kinds, 1)
+	assert.NotEqual(t, oak.ID, kinds.Kinds[0].ID)
+
+	w = send("DELETE", "/api/v1/workspaces/lab", "", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("GET", "/api/v1/workspaces", "", nil)
+	var workspaces []entities.Workspace
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &workspaces))
+	assert.Len(t, workspaces, 3)
+}